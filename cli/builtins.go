@@ -0,0 +1,336 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/JFryy/qq/internal/css"
+	"github.com/JFryy/qq/internal/flatten"
+	"github.com/JFryy/qq/internal/jsonpath"
+	"github.com/goccy/go-json"
+	"github.com/itchyny/gojq"
+	"github.com/jmespath/go-jmespath"
+	"github.com/spf13/cobra"
+)
+
+// builtins are the functions qq adds to the jq language.
+var builtins = []gojq.CompilerOption{
+	gojq.WithIterFunction("jsonpath", 1, 1, jsonpathBuiltin),
+	gojq.WithFunction("jmespath", 1, 1, jmespathBuiltin),
+	gojq.WithIterFunction("select_css", 1, 1, selectCSSBuiltin),
+	gojq.WithFunction("flatten_keys", 0, 1, flattenKeysBuiltin),
+	gojq.WithFunction("unflatten_keys", 0, 1, unflattenKeysBuiltin),
+}
+
+// queryLanguages are the flags that replace the jq expression. Each is run as
+// a call of the builtin of the same name so that it goes through the normal
+// jq pipeline; compile validates the expression up front.
+var queryLanguages = []struct {
+	flag    string
+	name    string
+	compile func(string) error
+}{
+	{"jsonpath", "JSONPath", func(s string) error { _, err := jsonpath.Compile(s); return err }},
+	{"jmespath", "JMESPath", func(s string) error { _, err := jmespath.Compile(legacyLiterals(s)); return err }},
+}
+
+// alternateQuery returns the jq expression for a query language flag, if one
+// was given.
+func alternateQuery(cmd *cobra.Command) (string, bool, error) {
+	var expression, used string
+	for _, lang := range queryLanguages {
+		if !cmd.Flags().Changed(lang.flag) {
+			continue
+		}
+		if used != "" {
+			return "", false, fmt.Errorf("Error: --%s and --%s flags cannot be used together", used, lang.flag)
+		}
+		used = lang.flag
+		src, _ := cmd.Flags().GetString(lang.flag)
+		if err := lang.compile(src); err != nil {
+			return "", false, fmt.Errorf("Error parsing %s expression: %v", lang.name, err)
+		}
+		expression = builtinCall(lang.flag, src)
+	}
+	return expression, used != "", nil
+}
+
+// compileQuery parses and compiles a jq expression with the qq builtins.
+func compileQuery(expression string) (*gojq.Code, error) {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(query, builtins...)
+}
+
+// builtinCall renders a call of a single-argument builtin as a jq expression.
+func builtinCall(name string, arg string) string {
+	b, _ := json.Marshal(arg)
+	return fmt.Sprintf("%s(%s)", name, b)
+}
+
+var jsonpathCache sync.Map
+
+// jsonpathBuiltin implements `jsonpath($expr)`, emitting each selected node.
+func jsonpathBuiltin(v any, args []any) gojq.Iter {
+	expr, ok := args[0].(string)
+	if !ok {
+		return gojq.NewIter(fmt.Errorf("jsonpath: expression must be a string, got %T", args[0]))
+	}
+	var q *jsonpath.Query
+	if cached, ok := jsonpathCache.Load(expr); ok {
+		q = cached.(*jsonpath.Query)
+	} else {
+		var err error
+		if q, err = jsonpath.Compile(expr); err != nil {
+			return gojq.NewIter(err)
+		}
+		jsonpathCache.Store(expr, q)
+	}
+	return gojq.NewIter(q.Select(v)...)
+}
+
+var jmespathCache sync.Map
+
+// jmespathBuiltin implements `jmespath($expr)`. JMESPath only understands
+// float64 numbers, so integers are converted on the way in and whole numbers
+// converted back on the way out.
+func jmespathBuiltin(v any, args []any) any {
+	expr, ok := args[0].(string)
+	if !ok {
+		return fmt.Errorf("jmespath: expression must be a string, got %T", args[0])
+	}
+	var jp *jmespath.JMESPath
+	if cached, ok := jmespathCache.Load(expr); ok {
+		jp = cached.(*jmespath.JMESPath)
+	} else {
+		var err error
+		if jp, err = jmespath.Compile(legacyLiterals(expr)); err != nil {
+			return fmt.Errorf("jmespath: %v", err)
+		}
+		jmespathCache.Store(expr, jp)
+	}
+	result, err := jp.Search(toFloats(v))
+	if err != nil {
+		return fmt.Errorf("jmespath: %v", err)
+	}
+	return fromFloats(result)
+}
+
+// selectCSSBuiltin implements `select_css($sel)`. The input is an HTML string,
+// or an element object from --css or select_css, whose inner HTML is searched
+// in the context of its tag.
+func selectCSSBuiltin(v any, args []any) gojq.Iter {
+	selector, ok := args[0].(string)
+	if !ok {
+		return gojq.NewIter(fmt.Errorf("select_css: selector must be a string, got %T", args[0]))
+	}
+	var fragment, context string
+	switch v := v.(type) {
+	case string:
+		fragment = v
+	case map[string]any:
+		fragment, _ = v["html"].(string)
+		context, _ = v["tag"].(string)
+	default:
+		return gojq.NewIter(fmt.Errorf("select_css: input must be an HTML string or element, got %T", v))
+	}
+	sel, err := css.Compile(selector)
+	if err != nil {
+		return gojq.NewIter(err)
+	}
+	results, err := css.SelectFragment(fragment, context, sel)
+	if err != nil {
+		return gojq.NewIter(err)
+	}
+	return gojq.NewIter(results...)
+}
+
+// keyOptions reads the optional argument of flatten_keys and unflatten_keys:
+// a separator, or an object with separator and index keys.
+func keyOptions(name string, args []any) (flatten.Options, error) {
+	var o flatten.Options
+	if len(args) == 0 {
+		return o, nil
+	}
+	switch arg := args[0].(type) {
+	case string:
+		if err := o.SetOption("separator", arg); err != nil {
+			return o, fmt.Errorf("%s: %v", name, err)
+		}
+	case map[string]any:
+		for k, v := range arg {
+			s, ok := v.(string)
+			if !ok || (k != "separator" && k != "index") {
+				return o, fmt.Errorf("%s: options are {separator: string, index: \"bracket\" or \"separator\"}", name)
+			}
+			if err := o.SetOption(k, s); err != nil {
+				return o, fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	default:
+		return o, fmt.Errorf("%s: argument must be a separator or an options object, got %T", name, arg)
+	}
+	return o, nil
+}
+
+// flattenKeysBuiltin implements `flatten_keys` and `flatten_keys($opts)`,
+// turning {"a":{"b":[1]}} into {"a.b[0]":1}.
+func flattenKeysBuiltin(v any, args []any) any {
+	o, err := keyOptions("flatten_keys", args)
+	if err != nil {
+		return err
+	}
+	switch v.(type) {
+	case map[string]any, []any:
+	default:
+		return fmt.Errorf("flatten_keys: input must be an object or array, got %T", v)
+	}
+	m, err := o.Map(v)
+	if err != nil {
+		return fmt.Errorf("flatten_keys: %v", err)
+	}
+	return m
+}
+
+// unflattenKeysBuiltin implements `unflatten_keys` and
+// `unflatten_keys($opts)`, the reverse of flatten_keys.
+func unflattenKeysBuiltin(v any, args []any) any {
+	o, err := keyOptions("unflatten_keys", args)
+	if err != nil {
+		return err
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("unflatten_keys: input must be an object, got %T", v)
+	}
+	result, err := o.Unflatten(m)
+	if err != nil {
+		return fmt.Errorf("unflatten_keys: %v", err)
+	}
+	return result
+}
+
+// legacyLiterals rewrites backtick literals that are not valid JSON, such as
+// `running`, into JSON strings. The AWS CLI accepts these and many existing
+// --query expressions rely on it.
+func legacyLiterals(expr string) string {
+	var b strings.Builder
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch c {
+		case '\'', '"':
+			// copy raw strings and quoted identifiers verbatim
+			j := i + 1
+			for j < len(expr) && expr[j] != c {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			end := min(j+1, len(expr))
+			b.WriteString(expr[i:end])
+			i = end - 1
+		case '`':
+			var lit strings.Builder
+			j := i + 1
+			for ; j < len(expr) && expr[j] != '`'; j++ {
+				if expr[j] == '\\' && j+1 < len(expr) && expr[j+1] == '`' {
+					j++
+				}
+				lit.WriteByte(expr[j])
+			}
+			if j >= len(expr) {
+				// unterminated; leave it for the parser to report
+				b.WriteString(expr[i:])
+				return b.String()
+			}
+			content := lit.String()
+			if !json.Valid([]byte(strings.TrimSpace(content))) {
+				quoted, _ := json.Marshal(content)
+				content = string(quoted)
+			}
+			b.WriteByte('`')
+			b.WriteString(strings.ReplaceAll(content, "`", "\\`"))
+			b.WriteByte('`')
+			i = j
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func toFloats(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, x := range v {
+			m[k] = toFloats(x)
+		}
+		return m
+	case []any:
+		a := make([]any, len(v))
+		for i, x := range v {
+			a[i] = toFloats(x)
+		}
+		return a
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		// widen by the shortest decimal form, so 87.2 stays 87.2
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+		return f
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+func fromFloats(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			v[k] = fromFloats(x)
+		}
+		return v
+	case []any:
+		for i, x := range v {
+			v[i] = fromFloats(x)
+		}
+		return v
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int(v)
+		}
+	}
+	return v
+}
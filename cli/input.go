@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/JFryy/qq/codec"
+	"github.com/JFryy/qq/internal/css"
+	"github.com/JFryy/qq/internal/xpath"
+)
+
+// loadInput reads and decodes a single document for the subcommands. An empty
+// path or "-" reads stdin. The codec comes from -i when it was set explicitly,
+// otherwise it is inferred from the file extension.
+func loadInput(path string, inputType string, inputSet bool) (any, codec.EncodingType, error) {
+	var input []byte
+	var err error
+	if path == "" || path == "-" {
+		input, err = io.ReadAll(os.Stdin)
+	} else {
+		input, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, codec.JSON, err
+	}
+
+	inputCodec, err := resolveInputCodec(path, inputType, inputSet)
+	if err != nil {
+		return nil, codec.JSON, err
+	}
+
+	var data any
+	if err := codec.Unmarshal(input, inputCodec, &data); err != nil {
+		return nil, inputCodec, err
+	}
+	return data, inputCodec, nil
+}
+
+// resolveInputCodec applies the same precedence as the root command: -i wins,
+// then the file extension, then the -i default.
+func resolveInputCodec(path string, inputType string, inputSet bool) (codec.EncodingType, error) {
+	if !inputSet && path != "" && path != "-" {
+		return inferFileType(path), nil
+	}
+	return codec.GetEncodingType(inputType)
+}
+
+// nodeSelector selects nodes from a raw xml or html document for --xpath and
+// --css, returning them as an array.
+type nodeSelector func(input []byte, inputCodec codec.EncodingType) ([]any, error)
+
+func xpathSelector(expr string, bindings []string) nodeSelector {
+	return func(input []byte, inputCodec codec.EncodingType) ([]any, error) {
+		if inputCodec != codec.XML && inputCodec != codec.HTML {
+			return nil, fmt.Errorf("Error: --xpath requires xml or html input, got %s", inputCodec)
+		}
+		namespaces, err := xpath.ParseNamespaces(bindings)
+		if err != nil {
+			return nil, err
+		}
+		results, err := xpath.Query(input, inputCodec == codec.HTML, expr, namespaces)
+		if err != nil {
+			return nil, err
+		}
+		if results == nil {
+			results = []any{}
+		}
+		return results, nil
+	}
+}
+
+func cssNodeSelector(selector string) nodeSelector {
+	return func(input []byte, inputCodec codec.EncodingType) ([]any, error) {
+		if inputCodec != codec.HTML {
+			return nil, fmt.Errorf("Error: --css requires html input, got %s", inputCodec)
+		}
+		return css.Select(input, selector)
+	}
+}
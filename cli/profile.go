@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/JFryy/qq/codec"
+	"github.com/JFryy/qq/internal/profile"
+	"github.com/spf13/cobra"
+)
+
+func newProfileCmd() *cobra.Command {
+	var inputType, outputType string
+	var top int
+	var stream, monochrome bool
+	cmd := &cobra.Command{
+		Use:   "profile [file]",
+		Short: "Summarise the fields of a dataset",
+		Long: "Report every field path with its observed types, null and missing counts, a distinct-count estimate, " +
+			"min/max/mean for numbers and length statistics and the most frequent values for strings. " +
+			"A top-level array is profiled as a list of records. With --stream, records are read one at a time in bounded memory.",
+		Args:          cobra.MaximumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var path string
+			if len(args) == 1 {
+				path = args[0]
+			}
+
+			p := profile.New(top)
+			if stream {
+				inputCodec, err := resolveInputCodec(path, inputType, cmd.Flags().Changed("input"))
+				if err != nil {
+					return err
+				}
+				if err := profileStream(p, path, inputCodec); err != nil {
+					return err
+				}
+			} else {
+				data, _, err := loadInput(path, inputType, cmd.Flags().Changed("input"))
+				if err != nil {
+					return err
+				}
+				p.AddAll(data)
+			}
+
+			report := p.Report()
+			if outputType == "table" {
+				return profile.WriteTable(os.Stdout, report)
+			}
+			outputCodec, err := codec.GetEncodingType(outputType)
+			if err != nil {
+				return err
+			}
+			b, err := codec.Marshal(profile.ToValue(report), outputCodec)
+			if err != nil {
+				return err
+			}
+			return writeOutput(os.Stdout, b, outputCodec, false, monochrome)
+		},
+	}
+	cmd.Flags().StringVarP(&inputType, "input", "i", "json", "specify input file type, only required on parsing stdin.")
+	cmd.Flags().StringVarP(&outputType, "output", "o", "table", "output format: table, or any output file type such as json or yaml")
+	cmd.Flags().IntVar(&top, "top", 5, "number of most frequent string values to report per field")
+	cmd.Flags().BoolVar(&stream, "stream", false, "read records one at a time in bounded memory")
+	cmd.Flags().BoolVarP(&monochrome, "monochrome-output", "M", false, "disable colored output")
+	return cmd
+}
+
+// profileStream feeds records from codec.StreamParser into the profiler.
+func profileStream(p *profile.Profiler, path string, inputCodec codec.EncodingType) error {
+	var reader io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	dataChan, errChan := codec.StreamParser(reader, inputCodec)
+	p.AddStream(dataChan)
+	if err := <-errChan; err != nil {
+		return fmt.Errorf("error parsing stream: %v", err)
+	}
+	return nil
+}
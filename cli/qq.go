@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/JFryy/qq/codec"
+	"github.com/JFryy/qq/codec/parquet"
 	"github.com/JFryy/qq/internal/tui"
 	"github.com/goccy/go-json"
 	"github.com/itchyny/gojq"
@@ -23,8 +24,22 @@ func CreateRootCmd() *cobra.Command {
 	var help bool
 	var monochrome bool
 	var stream bool
+	var streamElement string
 	var slurp bool
 	var exitStatus bool
+	var xpathExpr, cssSelector string
+	var namespaces []string
+	var codecOptions []string
+	var noHeader bool
+	var columns string
+	var unflatten bool
+	var shellDialect, shellPrefix string
+	var export bool
+	var values bool
+	var protoFile, protoMessage, descriptorSet string
+	var rawProtobuf bool
+	var parquetSchema string
+	var avroSchema string
 	encodings := strings.Join(codec.GetSupportedExtensions(), ", ")
 	v := "v0.3.4"
 	desc := fmt.Sprintf("qq is a interoperable configuration format transcoder with jq querying ability powered by gojq. qq is multi modal, and can be used as a replacement for jq or be interacted with via a repl with autocomplete and realtime rendering preview for building queries. Supported formats include %s", encodings)
@@ -33,6 +48,58 @@ func CreateRootCmd() *cobra.Command {
 		Short: "qq - JQ processing with conversions for popular configuration formats.",
 
 		Long: desc,
+		// Positional args are an expression and a file, not subcommand names.
+		Args: cobra.ArbitraryArgs,
+		// --opt applies to the root command and every subcommand
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if noHeader {
+				codecOptions = append(codecOptions, "csv.no_header=true", "tsv.no_header=true")
+			}
+			if columns != "" {
+				codecOptions = append(codecOptions, "csv.columns="+columns, "tsv.columns="+columns, "parquet.columns="+columns)
+			}
+			if unflatten {
+				for _, name := range []string{"env", "properties", "ini", "csv", "tsv"} {
+					codecOptions = append(codecOptions, name+".unflatten=true")
+				}
+			}
+			if shellDialect != "" {
+				codecOptions = append(codecOptions, "shell.dialect="+shellDialect)
+			}
+			if shellPrefix != "" {
+				codecOptions = append(codecOptions, "shell.prefix="+shellPrefix)
+			}
+			if values {
+				codecOptions = append(codecOptions, "gron.values=true")
+			}
+			if export {
+				codecOptions = append(codecOptions, "shell.export=true", "env.export=true")
+			}
+			if protoFile != "" {
+				codecOptions = append(codecOptions, "protobuf.proto="+protoFile, "textproto.proto="+protoFile)
+			}
+			if descriptorSet != "" {
+				codecOptions = append(codecOptions, "protobuf.descriptor_set="+descriptorSet, "textproto.descriptor_set="+descriptorSet)
+			}
+			if protoMessage != "" {
+				codecOptions = append(codecOptions, "protobuf.message="+protoMessage, "textproto.message="+protoMessage)
+			}
+			if rawProtobuf {
+				codecOptions = append(codecOptions, "protobuf.raw=true")
+			}
+			if parquetSchema != "" {
+				codecOptions = append(codecOptions, "parquet.schema="+parquetSchema)
+			}
+			if avroSchema != "" {
+				codecOptions = append(codecOptions, "avro.schema="+avroSchema)
+			}
+			for _, opt := range codecOptions {
+				if err := codec.SetOption(opt); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			}
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			if version {
 				fmt.Println("qq version", v)
@@ -46,7 +113,31 @@ func CreateRootCmd() *cobra.Command {
 				}
 				os.Exit(0)
 			}
-			handleCommand(cmd, args, inputType, outputType, rawOutput, help, interactive, monochrome, stream, slurp, exitStatus)
+			// a JSONPath or JMESPath query takes the place of the jq expression
+			expression, ok, err := alternateQuery(cmd)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if ok {
+				if len(args) > 1 {
+					fmt.Println("Error: a jq expression cannot be combined with --jsonpath or --jmespath")
+					os.Exit(1)
+				}
+				args = append([]string{expression}, args...)
+			}
+			// --xpath and --css select nodes from the raw document instead of decoding it
+			var selectNodes nodeSelector
+			switch {
+			case xpathExpr != "" && cssSelector != "":
+				fmt.Println("Error: --xpath and --css flags cannot be used together")
+				os.Exit(1)
+			case xpathExpr != "":
+				selectNodes = xpathSelector(xpathExpr, namespaces)
+			case cssSelector != "":
+				selectNodes = cssNodeSelector(cssSelector)
+			}
+			handleCommand(cmd, args, inputType, outputType, rawOutput, help, interactive, monochrome, stream, streamElement, slurp, exitStatus, selectNodes)
 		},
 	}
 	cmd.Flags().StringVarP(&inputType, "input", "i", "json", "specify input file type, only required on parsing stdin.")
@@ -56,14 +147,38 @@ func CreateRootCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&version, "version", "v", false, "version for qq")
 	cmd.Flags().BoolVarP(&interactive, "interactive", "I", false, "interactive mode for qq")
 	cmd.Flags().BoolVarP(&monochrome, "monochrome-output", "M", false, "disable colored output")
-	cmd.Flags().BoolVar(&stream, "stream", false, "parse input in streaming fashion, emitting path-value pairs (supports: json, jsonl, yaml, csv, tsv, line)")
+	cmd.Flags().BoolVar(&stream, "stream", false, "parse input in streaming fashion, emitting path-value pairs (supports: json, jsonl, yaml, csv, tsv, line, xml, gron, parquet); for parquet, leading select(.[0][1] == \"col\") calls read only those columns, and comparisons of .[1] skip row groups; xml is read twice, keeping a bit per element, and xml from stdin is first copied to a temporary file")
+	cmd.Flags().StringVar(&streamElement, "stream-element", "", "parse xml input incrementally, passing each complete element with this name to the jq expression")
 	cmd.Flags().BoolVarP(&slurp, "slurp", "s", false, "read all inputs into an array and use it as the single input value")
 	cmd.Flags().BoolVarP(&exitStatus, "exit-status", "e", false, "set exit status code based on the output")
+	cmd.Flags().String("jsonpath", "", "query with an RFC 9535 JSONPath expression instead of jq, emitting each selected node")
+	cmd.Flags().String("jmespath", "", "query with a JMESPath expression instead of jq")
+	cmd.Flags().StringVar(&xpathExpr, "xpath", "", "select nodes of xml or html input with an XPath expression; each result is passed to the jq expression")
+	cmd.Flags().StringArrayVar(&namespaces, "ns", nil, "bind a namespace prefix for --xpath, as prefix=uri (repeatable)")
+	cmd.PersistentFlags().BoolVar(&noHeader, "no-header", false, "csv and tsv have no header row: input columns are named col1, col2... and output omits the header")
+	cmd.PersistentFlags().BoolVar(&unflatten, "unflatten", false, "rebuild nested values from flat keys such as a.b[0].c when reading env, properties, ini, csv and tsv")
+	cmd.PersistentFlags().StringVar(&columns, "columns", "", "comma separated columns to write in csv and tsv output, in order, or to read from parquet input")
+	cmd.PersistentFlags().StringVar(&shellDialect, "shell", "", "shell to write -o shell output for: bash (default), zsh, fish or posix")
+	cmd.PersistentFlags().StringVar(&shellPrefix, "prefix", "", "prefix for variable names in shell output, e.g. APP_")
+	cmd.PersistentFlags().BoolVar(&export, "export", false, "export the variables in shell and env output")
+	cmd.PersistentFlags().BoolVar(&values, "values", false, "write only the values of gron output, one per line, like gron --values")
+	cmd.PersistentFlags().StringVar(&protoFile, "proto", "", ".proto file declaring the message of protobuf and textproto input and output")
+	cmd.PersistentFlags().StringVar(&descriptorSet, "descriptor-set", "", "FileDescriptorSet declaring the message of protobuf and textproto input and output, instead of --proto")
+	cmd.PersistentFlags().StringVar(&protoMessage, "message", "", "full name of the protobuf message, e.g. pkg.Event; needed when the schema declares several")
+	cmd.PersistentFlags().BoolVar(&rawProtobuf, "raw", false, "read and write protobuf without a schema, as a list of field numbers and wire types like protoc --decode_raw")
+	cmd.PersistentFlags().StringVar(&parquetSchema, "schema", "", "JSON file of Parquet column names and types to write with, instead of inferring them")
+	cmd.PersistentFlags().StringVar(&avroSchema, "avro-schema", "", "Avro schema (.avsc) file to write avro output with, instead of inferring it")
+	cmd.PersistentFlags().StringArrayVar(&codecOptions, "opt", nil, "set a codec option as codec.key=value, e.g. html.mode=tables (repeatable)")
+	cmd.Flags().StringVar(&cssSelector, "css", "", "select elements of html input with a CSS selector; each {tag, attrs, text, html} result is passed to the jq expression")
+
+	cmd.AddCommand(newTypegenCmd())
+	cmd.AddCommand(newProfileCmd())
+	cmd.AddCommand(newSQLCmd())
 
 	return cmd
 }
 
-func handleCommand(cmd *cobra.Command, args []string, inputtype string, outputtype string, rawInput bool, help bool, interactive bool, monochrome bool, stream bool, slurp bool, exitStatus bool) {
+func handleCommand(cmd *cobra.Command, args []string, inputtype string, outputtype string, rawInput bool, help bool, interactive bool, monochrome bool, stream bool, streamElement string, slurp bool, exitStatus bool, selectNodes nodeSelector) {
 	var input []byte
 	var err error
 	var expression string
@@ -94,6 +209,22 @@ func handleCommand(cmd *cobra.Command, args []string, inputtype string, outputty
 		os.Exit(1)
 	}
 
+	// Validate: node selection needs the whole document
+	if selectNodes != nil && stream {
+		fmt.Println("Error: --xpath and --css cannot be used with --stream")
+		os.Exit(1)
+	}
+
+	// Validate: element streaming emits whole values, not path-value pairs
+	if streamElement != "" {
+		if stream || slurp || interactive || selectNodes != nil {
+			fmt.Println("Error: --stream-element cannot be used with --stream, --slurp, --interactive, --xpath or --css")
+			os.Exit(1)
+		}
+		// read the input incrementally, as --stream does
+		stream = true
+	}
+
 	// handle input with stdin or file
 	switch len(args) {
 	case 0:
@@ -190,25 +321,42 @@ func handleCommand(cmd *cobra.Command, args []string, inputtype string, outputty
 		}
 
 		// Execute streaming query
-		query, err := gojq.Parse(expression)
+		query, err := compileQuery(expression)
 		if err != nil {
 			fmt.Printf("Error parsing jq expression: %v\n", err)
 			os.Exit(1)
 		}
 
-		executeStreamingQuery(query, inputReader, inputCodec, outputCodec, rawInput, monochrome)
+		exitCode := 0
+		if streamElement != "" {
+			exitCode = executeElementQuery(query, inputReader, inputCodec, streamElement, outputCodec, rawInput, monochrome, exitStatus)
+		} else {
+			// parquet reads only the columns and row groups the query can keep
+			executeStreamingQuery(query, inputReader, inputCodec, scanFor(expression), outputCodec, rawInput, monochrome)
+		}
 
 		// Close file if it was opened
 		if file, ok := inputReader.(*os.File); ok && file != os.Stdin {
 			file.Close()
 		}
-		os.Exit(0)
+		os.Exit(exitCode)
 	}
 
 	// Standard (non-streaming) mode
 	var data any
 
-	if slurp {
+	if selectNodes != nil {
+		// the selected nodes replace the decoded document
+		data, err = selectNodes(input, inputCodec)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !slurp {
+			// run the jq expression once per selected node
+			expression = fmt.Sprintf(".[] | (%s)", expression)
+		}
+	} else if slurp {
 		// Slurp mode: read multiple JSON values and combine into array
 		data, err = slurpInputs(input, inputCodec)
 		if err != nil {
@@ -224,7 +372,7 @@ func handleCommand(cmd *cobra.Command, args []string, inputtype string, outputty
 	}
 
 	if !interactive {
-		query, err := gojq.Parse(expression)
+		query, err := compileQuery(expression)
 		if err != nil {
 			fmt.Printf("Error parsing jq expression: %v\n", err)
 			os.Exit(1)
@@ -241,7 +389,7 @@ func handleCommand(cmd *cobra.Command, args []string, inputtype string, outputty
 		os.Exit(1)
 	}
 
-	tui.Interact(s)
+	tui.Interact(s, builtins...)
 	os.Exit(0)
 }
 
@@ -272,47 +420,74 @@ func inferFileType(fName string) codec.EncodingType {
 	return codec.JSON
 }
 
-func executeQuery(query *gojq.Query, data any, fileType codec.EncodingType, rawOut bool, monochrome bool, exitStatus bool) int {
-	iter := query.Run(data)
-	var lastValue any
-	hasOutput := false
+func executeQuery(query *gojq.Code, data any, fileType codec.EncodingType, rawOut bool, monochrome bool, exitStatus bool) int {
+	var out queryOutput
+	if !out.write(query, data, fileType, rawOut, monochrome) {
+		return 1
+	}
+	return out.exitCode(exitStatus)
+}
+
+// queryOutput prints query results and remembers what the exit status
+// depends on, across one or more inputs.
+type queryOutput struct {
+	hasOutput bool
+	lastValue any
+}
 
+// write runs query on data and prints each result, reporting false after
+// printing an error.
+func (o *queryOutput) write(query *gojq.Code, data any, fileType codec.EncodingType, rawOut bool, monochrome bool) bool {
+	iter := query.Run(data)
 	for {
 		v, ok := iter.Next()
 		if !ok {
-			break
+			return true
 		}
 		if err, ok := v.(error); ok {
 			fmt.Printf("Error executing jq expression: %v\n", err)
-			return 1
+			return false
 		}
 
-		hasOutput = true
-		lastValue = v
+		o.hasOutput = true
+		o.lastValue = v
 
 		b, err := codec.Marshal(v, fileType)
 		if err != nil {
 			fmt.Printf("Error formatting result: %v\n", err)
-			return 1
+			return false
 		}
 
-		if codec.IsBinaryFormat(fileType) {
-			// For binary formats, write directly to stdout as raw bytes
-			os.Stdout.Write(b)
-		} else {
-			s := string(b)
-			r, _ := codec.PrettyFormat(s, fileType, rawOut, monochrome)
-			fmt.Println(r)
+		if err := writeOutput(os.Stdout, b, fileType, rawOut, monochrome); err != nil {
+			fmt.Printf("Error formatting result: %v\n", err)
+			return false
 		}
 	}
+}
+
+// writeOutput writes b, a value marshaled as fileType, to w: binary formats
+// as raw bytes and text formats pretty printed on a line of their own.
+func writeOutput(w io.Writer, b []byte, fileType codec.EncodingType, rawOut bool, monochrome bool) error {
+	if codec.IsBinaryFormat(fileType) {
+		_, err := w.Write(b)
+		return err
+	}
+	s, err := codec.PrettyFormat(string(b), fileType, rawOut, monochrome)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, s)
+	return err
+}
 
+func (o *queryOutput) exitCode(exitStatus bool) int {
 	// Handle exit status flag
 	if exitStatus {
-		if !hasOutput {
+		if !o.hasOutput {
 			return 4 // No output
 		}
 		// Check if last value is false or null
-		if lastValue == false || lastValue == nil {
+		if o.lastValue == false || o.lastValue == nil {
 			return 1
 		}
 	}
@@ -320,6 +495,22 @@ func executeQuery(query *gojq.Query, data any, fileType codec.EncodingType, rawO
 	return 0
 }
 
+// executeElementQuery runs query once per element streamed from reader.
+func executeElementQuery(query *gojq.Code, reader io.Reader, inputType codec.EncodingType, element string, outputType codec.EncodingType, rawOut bool, monochrome bool, exitStatus bool) int {
+	dataChan, errChan := codec.StreamElements(reader, inputType, element)
+	var out queryOutput
+	for v := range dataChan {
+		if !out.write(query, v, outputType, rawOut, monochrome) {
+			return 1
+		}
+	}
+	if err := <-errChan; err != nil {
+		fmt.Printf("Error parsing stream: %v\n", err)
+		return 1
+	}
+	return out.exitCode(exitStatus)
+}
+
 func slurpInputs(input []byte, inputCodec codec.EncodingType) (any, error) {
 	var values []any
 
@@ -387,9 +578,9 @@ func slurpInputs(input []byte, inputCodec codec.EncodingType) (any, error) {
 	return values, nil
 }
 
-func executeStreamingQuery(query *gojq.Query, reader io.Reader, inputType codec.EncodingType, outputType codec.EncodingType, rawOut bool, monochrome bool) {
+func executeStreamingQuery(query *gojq.Code, reader io.Reader, inputType codec.EncodingType, scan parquet.Scan, outputType codec.EncodingType, rawOut bool, monochrome bool) {
 	// Parse input in streaming mode (emits path-value pairs via channels)
-	dataChan, errChan := codec.StreamParser(reader, inputType)
+	dataChan, errChan := codec.StreamScan(reader, inputType, scan)
 
 	// Process stream elements as they arrive
 	for {
@@ -2,12 +2,16 @@ package cli
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/JFryy/qq/codec"
+	"github.com/goccy/go-json"
 )
 
 func TestInferFileType(t *testing.T) {
@@ -260,3 +264,331 @@ func TestHelpFlag(t *testing.T) {
 		t.Error("Help text should mention input/output flags")
 	}
 }
+
+func TestSubcommandsRegistered(t *testing.T) {
+	cmd := CreateRootCmd()
+
+	for _, name := range []string{"typegen", "profile", "sql"} {
+		sub, _, err := cmd.Find([]string{name})
+		if err != nil || sub == cmd {
+			t.Errorf("expected subcommand %q to be registered", name)
+		}
+	}
+
+	// jq expressions must still reach the root command
+	found, args, err := cmd.Find([]string{".foo", "file.json"})
+	if err != nil {
+		t.Fatalf("unexpected error finding root command: %v", err)
+	}
+	if found != cmd || len(args) != 2 {
+		t.Errorf("expected root command with 2 args, got %q with %v", found.Name(), args)
+	}
+}
+
+func TestSQLColumnOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows.json")
+	if err := os.WriteFile(path, []byte(`[{"a":1,"b":2,"c":3}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{"csv", "tsv"} {
+		cmd := CreateRootCmd()
+		cmd.SetArgs([]string{"sql", "-o", format, "-M", "SELECT c, a FROM '" + path + "'"})
+
+		old := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := cmd.Execute()
+		w.Close()
+		os.Stdout = old
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		sep := map[string]string{"csv": ",", "tsv": "\t"}[format]
+		header, _, _ := strings.Cut(buf.String(), "\n")
+		if want := "c" + sep + "a"; header != want {
+			t.Errorf("%s header = %q, expected %q", format, header, want)
+		}
+	}
+}
+
+// runCmd runs the root command with args and returns what it wrote to
+// stdout.
+func runCmd(t *testing.T, args ...string) []byte {
+	t.Helper()
+	cmd := CreateRootCmd()
+	cmd.SetArgs(args)
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- b
+	}()
+	err := cmd.Execute()
+	w.Close()
+	os.Stdout = old
+	out := <-done
+	if err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	return out
+}
+
+func TestSQLBinaryOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows.json")
+	if err := os.WriteFile(path, []byte(`[{"a":1,"b":"x"},{"a":2,"b":"y"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, enc := range []codec.EncodingType{codec.PARQUET, codec.AVRO} {
+		out := runCmd(t, "sql", "-o", enc.String(), "SELECT a, b FROM '"+path+"' ORDER BY a")
+		var rows any
+		if err := codec.Unmarshal(out, enc, &rows); err != nil {
+			t.Fatalf("%s: %v", enc, err)
+		}
+		// avro reads integers back as int64, so the rows are compared as text
+		if got, want := fmt.Sprint(rows), "[map[a:1 b:x] map[a:2 b:y]]"; got != want {
+			t.Errorf("%s: got %s, want %s", enc, got, want)
+		}
+	}
+}
+
+func TestProfileBinaryOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows.json")
+	if err := os.WriteFile(path, []byte(`[{"a":1},{"a":2}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, enc := range []codec.EncodingType{codec.PARQUET, codec.AVRO} {
+		out := runCmd(t, "profile", "-o", enc.String(), path)
+		var report any
+		if err := codec.Unmarshal(out, enc, &report); err != nil {
+			t.Fatalf("%s: %v", enc, err)
+		}
+	}
+}
+
+func TestTypegenTextproto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "t.txtpb")
+	if err := os.WriteFile(path, []byte("big: 9007199254740993\nsmall: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(strings.Fields(string(runCmd(t, "typegen", path))), " ")
+	// integers jq cannot hold exactly are read as json.Number
+	for _, want := range []string{"Big int64", "Small int64"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestTypegenTag(t *testing.T) {
+	tests := []struct {
+		enc      codec.EncodingType
+		expected string
+	}{
+		{codec.JSON, "json"},
+		{codec.YAML, "yaml"},
+		{codec.TOML, "toml"},
+		{codec.CSV, "json"},
+	}
+	for _, tt := range tests {
+		if got := typegenTag(tt.enc); got != tt.expected {
+			t.Errorf("typegenTag(%v) = %q, expected %q", tt.enc, got, tt.expected)
+		}
+	}
+}
+
+func runBuiltinQuery(t *testing.T, expression string, input any) []any {
+	t.Helper()
+	code, err := compileQuery(expression)
+	if err != nil {
+		t.Fatalf("compileQuery(%q) error: %v", expression, err)
+	}
+	var out []any
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return out
+		}
+		if err, ok := v.(error); ok {
+			t.Fatalf("%s: %v", expression, err)
+		}
+		out = append(out, v)
+	}
+}
+
+func TestJSONPathBuiltin(t *testing.T) {
+	input := map[string]any{"items": []any{
+		map[string]any{"status": "Ready", "name": "a"},
+		map[string]any{"status": "Pending", "name": "b"},
+		map[string]any{"status": "Ready", "name": "c"},
+	}}
+
+	out := runBuiltinQuery(t, builtinCall("jsonpath", `$.items[?@.status=="Ready"].name`), input)
+	if len(out) != 2 || out[0] != "a" || out[1] != "c" {
+		t.Errorf("unexpected jsonpath output %v", out)
+	}
+
+	out = runBuiltinQuery(t, `[jsonpath("$.items[*].name")] | map(ascii_upcase) | join(",")`, input)
+	if len(out) != 1 || out[0] != "A,B,C" {
+		t.Errorf("unexpected mixed output %v", out)
+	}
+
+	code, err := compileQuery(`jsonpath("$[")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := code.Run(input).Next(); v == nil {
+		t.Error("expected an error for an invalid JSONPath expression")
+	} else if _, ok := v.(error); !ok {
+		t.Errorf("expected an error, got %v", v)
+	}
+}
+
+func TestJMESPathBuiltin(t *testing.T) {
+	input := map[string]any{"Reservations": []any{
+		map[string]any{"Instances": []any{
+			map[string]any{"InstanceId": "i-1", "State": map[string]any{"Name": "running"}, "Cpu": 2},
+			map[string]any{"InstanceId": "i-2", "State": map[string]any{"Name": "stopped"}, "Cpu": 4},
+		}},
+	}}
+
+	out := runBuiltinQuery(t, builtinCall("jmespath", "Reservations[].Instances[?State.Name==`running`].InstanceId | []"), input)
+	if len(out) != 1 || !reflect.DeepEqual(out[0], []any{"i-1"}) {
+		t.Errorf("unexpected jmespath output %v", out)
+	}
+
+	// integers must survive the round trip through JMESPath's float64 numbers
+	out = runBuiltinQuery(t, `jmespath("sum(Reservations[].Instances[].Cpu)")`, input)
+	if len(out) != 1 || out[0] != 6 {
+		t.Errorf("expected 6, got %#v", out)
+	}
+
+	// msgpack decodes small integers to int8, uint16 and so on, and floats
+	// to float32
+	data, err := codec.Marshal(map[string]any{"a": int8(-3), "b": uint16(300), "c": int32(70000), "d": float32(87.2)}, codec.MSGPACK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded any
+	if err := codec.Unmarshal(data, codec.MSGPACK, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	out = runBuiltinQuery(t, `jmespath("[sum([a, b, c]), max([a, b, c]), d > `+"`87`"+`, d]")`, decoded)
+	if want := []any{70297, 70000, true, 87.2}; len(out) != 1 || !reflect.DeepEqual(out[0], want) {
+		t.Errorf("expected %v, got %#v", want, out)
+	}
+
+	// parquet and avro read decimals as json.Number
+	prices := []any{
+		map[string]any{"id": "a", "price": json.Number("19.90")},
+		map[string]any{"id": "b", "price": json.Number("120.00")},
+	}
+	out = runBuiltinQuery(t, `jmespath("max_by(@, &price).id")`, prices)
+	if len(out) != 1 || out[0] != "b" {
+		t.Errorf("expected b, got %#v", out)
+	}
+}
+
+func TestLegacyLiterals(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"a[?b==`running`]", "a[?b==`\"running\"`]"},
+		{"a[?b==`\"running\"`]", "a[?b==`\"running\"`]"},
+		{"a[?b==`1`]", "a[?b==`1`]"},
+		{"a[?b==`{\"x\": [1]}`]", "a[?b==`{\"x\": [1]}`]"},
+		{"a[?b=='`x`']", "a[?b=='`x`']"},
+		{"a[?b==`x\\`y`]", "a[?b==`\"x\\`y\"`]"},
+	}
+	for _, tt := range tests {
+		if got := legacyLiterals(tt.expr); got != tt.expected {
+			t.Errorf("legacyLiterals(%q) = %q, expected %q", tt.expr, got, tt.expected)
+		}
+	}
+}
+
+func TestXPathNamespaces(t *testing.T) {
+	feed := []byte(`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:m="urn:media">
+<entry><title>One</title><m:thumb url="a.png"/></entry>
+<entry><title>Two</title></entry>
+</feed>`)
+
+	nodes, err := xpathSelector("//a:entry/a:title", []string{"a=http://www.w3.org/2005/Atom"})(feed, codec.XML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := runBuiltinQuery(t, ".[] | .title", nodes)
+	if !reflect.DeepEqual(out, []any{"One", "Two"}) {
+		t.Errorf("unexpected titles %v", out)
+	}
+
+	nodes, err = xpathSelector("//m:thumb/@url", []string{"m=urn:media"})(feed, codec.XML)
+	if err != nil || !reflect.DeepEqual(nodes, []any{"a.png"}) {
+		t.Errorf("unexpected attributes %v, %v", nodes, err)
+	}
+
+	// an unbound prefix is an error rather than an empty result
+	if _, err := xpathSelector("//m:thumb", nil)(feed, codec.XML); err == nil {
+		t.Error("expected an error for an unbound prefix")
+	}
+	if _, err := xpathSelector("//a", []string{"a"})(feed, codec.XML); err == nil {
+		t.Error("expected an error for a binding without a uri")
+	}
+}
+
+func TestSelectCSSBuiltin(t *testing.T) {
+	page := `<table><tr><td>a</td><td>1</td></tr><tr><td>b</td><td>2</td></tr></table>`
+
+	out := runBuiltinQuery(t, `[select_css("tr") | [select_css("td") | .text]]`, page)
+	want := []any{[]any{[]any{"a", "1"}, []any{"b", "2"}}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("got %v, want %v", out, want)
+	}
+
+	code, err := compileQuery(`select_css("td")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := code.Run(42).Next(); v == nil {
+		t.Error("expected an error for non-HTML input")
+	} else if _, ok := v.(error); !ok {
+		t.Errorf("expected an error, got %v", v)
+	}
+}
+
+func TestFlattenKeysBuiltins(t *testing.T) {
+	input := map[string]any{"a": map[string]any{"b": []any{1, map[string]any{"c": "x"}}}}
+
+	out := runBuiltinQuery(t, `flatten_keys`, input)
+	want := map[string]any{"a.b[0]": 1, "a.b[1].c": "x"}
+	if len(out) != 1 || !reflect.DeepEqual(out[0], want) {
+		t.Errorf("flatten_keys: got %v", out)
+	}
+
+	out = runBuiltinQuery(t, `flatten_keys({separator: "__", index: "separator"}) | keys`, input)
+	if len(out) != 1 || !reflect.DeepEqual(out[0], []any{"a__b__0", "a__b__1__c"}) {
+		t.Errorf("flatten_keys options: got %v", out)
+	}
+
+	out = runBuiltinQuery(t, `(flatten_keys("/") | unflatten_keys("/")) == .`, input)
+	if len(out) != 1 || out[0] != true {
+		t.Errorf("round trip: got %v", out)
+	}
+
+	code, err := compileQuery(`unflatten_keys`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := code.Run(map[string]any{"a": 1, "a.b": 2}).Next(); v == nil {
+		t.Error("expected a conflict error")
+	} else if _, ok := v.(error); !ok {
+		t.Errorf("expected an error, got %v", v)
+	}
+}
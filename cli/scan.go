@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/JFryy/qq/codec/parquet"
+	"github.com/itchyny/gojq"
+)
+
+// scanFor works out from a --stream expression which path-value pairs of
+// Parquet input it can keep, from the select calls it starts with, such as
+//
+//	select(.[0][1] == "date" and .[1] >= "2024-01-01") | ...
+//
+// so that the other columns are not read, and row groups are skipped when
+// their statistics show that no value of the one column kept meets the
+// comparisons. Anything it does not understand reads every column.
+func scanFor(expression string) parquet.Scan {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return parquet.Scan{}
+	}
+	var kept condition
+	for q := query; q != nil; q = q.Right {
+		stage := q
+		if q.Op == gojq.OpPipe {
+			stage = q.Left
+		}
+		if len(q.Patterns) > 0 || stage.Term == nil || stage.Term.Type != gojq.TermTypeFunc ||
+			stage.Term.Func.Name != "select" || len(stage.Term.Func.Args) != 1 || len(stage.Term.SuffixList) > 0 {
+			break
+		}
+		found, complete := conjuncts(stage.Term.Func.Args[0])
+		kept = kept.and(found)
+		// pairs a later select is given depend on all of this one
+		if !complete || q.Op != gojq.OpPipe {
+			break
+		}
+	}
+
+	var scan parquet.Scan
+	if kept.columns != nil {
+		scan.Columns = make([]string, 0, len(kept.columns))
+		for name := range kept.columns {
+			scan.Columns = append(scan.Columns, name)
+		}
+		sort.Strings(scan.Columns)
+	}
+	// comparisons of the value only say which rows of a column are kept
+	if len(scan.Columns) == 1 {
+		for _, f := range kept.values {
+			f.Column = scan.Columns[0]
+			scan.Filters = append(scan.Filters, f)
+		}
+	}
+	return scan
+}
+
+// condition is what a pair must meet to be kept: a column among columns,
+// or any column when it is nil, and a value meeting each of values, whose
+// Column is left empty.
+type condition struct {
+	columns map[string]bool
+	values  []parquet.Filter
+}
+
+func (c condition) and(o condition) condition {
+	columns := c.columns
+	if columns == nil {
+		columns = o.columns
+	} else if o.columns != nil {
+		columns = make(map[string]bool)
+		for name := range c.columns {
+			if o.columns[name] {
+				columns[name] = true
+			}
+		}
+	}
+	return condition{columns: columns, values: append(c.values[:len(c.values):len(c.values)], o.values...)}
+}
+
+var flipped = map[gojq.Operator]gojq.Operator{
+	gojq.OpEq: gojq.OpEq, gojq.OpNe: gojq.OpNe,
+	gojq.OpLt: gojq.OpGt, gojq.OpGt: gojq.OpLt,
+	gojq.OpLe: gojq.OpGe, gojq.OpGe: gojq.OpLe,
+}
+
+// pushed are the comparisons of a pair's value that closing events, whose
+// value is null, fail, so that a row group with no value meeting one gives
+// no pair that does.
+var pushed = map[gojq.Operator]bool{gojq.OpEq: true, gojq.OpGt: true, gojq.OpGe: true}
+
+// conjuncts returns what a pair must meet for q to be true, and whether q
+// is only comparisons of its column or value with a literal. Comparisons
+// after one that is not are left out, as jq only runs what follows a false
+// one, so a skipped pair could hide an error.
+func conjuncts(q *gojq.Query) (condition, bool) {
+	if q.Term != nil && q.Term.Type == gojq.TermTypeQuery && len(q.Term.SuffixList) == 0 {
+		return conjuncts(q.Term.Query)
+	}
+	switch q.Op {
+	case gojq.OpAnd:
+		left, complete := conjuncts(q.Left)
+		if !complete {
+			return left, false
+		}
+		right, complete := conjuncts(q.Right)
+		return left.and(right), complete
+	case gojq.OpOr:
+		// only a choice of columns, as in .[0][1] == "a" or .[0][1] == "b"
+		left, leftComplete := conjuncts(q.Left)
+		right, rightComplete := conjuncts(q.Right)
+		if !leftComplete || !rightComplete || left.columns == nil || right.columns == nil ||
+			len(left.values) > 0 || len(right.values) > 0 {
+			return condition{}, false
+		}
+		columns := make(map[string]bool, len(left.columns)+len(right.columns))
+		for name := range left.columns {
+			columns[name] = true
+		}
+		for name := range right.columns {
+			columns[name] = true
+		}
+		return condition{columns: columns}, true
+	}
+	if _, ok := flipped[q.Op]; !ok {
+		return condition{}, false
+	}
+	term, lit, op := q.Left, q.Right, q.Op
+	if isConstant(q.Left) {
+		term, lit, op = q.Right, q.Left, flipped[q.Op]
+	}
+	if !isConstant(lit) {
+		return condition{}, false
+	}
+	v, isLiteral := literal(lit)
+	switch {
+	case isPairColumn(term):
+		if name, isString := v.(string); isString && op == gojq.OpEq {
+			return condition{columns: map[string]bool{name: true}}, true
+		}
+	case isPairValue(term):
+		if isLiteral && pushed[op] {
+			return condition{values: []parquet.Filter{{Op: op.String(), Value: v}}}, true
+		}
+	default:
+		return condition{}, false
+	}
+	// comparisons never fail, so one that is not used is still understood
+	return condition{}, true
+}
+
+// isPairColumn reports whether q is .[0][1], the column of a pair of a row.
+func isPairColumn(q *gojq.Query) bool {
+	t := q.Term
+	return t != nil && t.Type == gojq.TermTypeIndex && isIndex(t.Index, 0) &&
+		len(t.SuffixList) == 1 && isIndex(t.SuffixList[0].Index, 1)
+}
+
+// isPairValue reports whether q is .[1], the value of a pair.
+func isPairValue(q *gojq.Query) bool {
+	t := q.Term
+	return t != nil && t.Type == gojq.TermTypeIndex && isIndex(t.Index, 1) && len(t.SuffixList) == 0
+}
+
+// isIndex reports whether index is [n].
+func isIndex(index *gojq.Index, n float64) bool {
+	if index == nil || index.IsSlice || index.Start == nil {
+		return false
+	}
+	v, ok := literal(index.Start)
+	return ok && v == n
+}
+
+// isConstant reports whether q is a literal, null, true or false.
+func isConstant(q *gojq.Query) bool {
+	if _, ok := literal(q); ok {
+		return true
+	}
+	if q.Term == nil || len(q.Term.SuffixList) > 0 {
+		return false
+	}
+	switch q.Term.Type {
+	case gojq.TermTypeNull, gojq.TermTypeTrue, gojq.TermTypeFalse:
+		return true
+	}
+	return false
+}
+
+// literal returns the value of a string or number literal.
+func literal(q *gojq.Query) (any, bool) {
+	if q.Term == nil || len(q.Term.SuffixList) > 0 {
+		return nil, false
+	}
+	switch t := q.Term; t.Type {
+	case gojq.TermTypeString:
+		if len(t.Str.Queries) == 0 {
+			return t.Str.Str, true
+		}
+	case gojq.TermTypeNumber:
+		if f, err := strconv.ParseFloat(t.Number, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JFryy/qq/codec/parquet"
+)
+
+func TestScanColumns(t *testing.T) {
+	for expr, want := range map[string][]string{
+		`select(.[0][1] == "name")`:                                         {"name"},
+		`select("name" == .[0][1]) | .[1]`:                                  {"name"},
+		`select(.[0][1] == "a" or .[0][1] == "b")`:                          {"a", "b"},
+		`select((.[0][1] == "a" or .[0][1] == "b") and .[1] > 1)`:           {"a", "b"},
+		`select(.[0][1] == "a" or .[0][1] == "b") | select(.[0][1] == "b")`: {"b"},
+		`select(.[1] != null and .[0][1] == "a")`:                           {"a"},
+		`select(.[0][1] == "a" and .[0][1] == "b")`:                         {},
+		".":                                      nil,
+		`select(.[1] > 1)`:                       nil,
+		`select(.[0][1] != "a")`:                 nil,
+		`select(.[0][1] == "a" or .[1] > 1)`:     nil,
+		`select(.[0][2] == "a")`:                 nil,
+		`select(.[0][1] == "\(.x)")`:             nil,
+		`select(length == 2 and .[0][1] == "a")`: nil,
+		`.[0] | select(.[0][1] == "a")`:          nil,
+		`select(.[0][1] == "a"), .`:              nil,
+		`select(.[0][1] == "a") as $x | $x`:      nil,
+		`select(.[0][1] == "a" and length == 2) | select(.[0][1] == "b")`: {"a"},
+	} {
+		if got := scanFor(expr).Columns; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got columns %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestScanFilters(t *testing.T) {
+	for expr, want := range map[string][]parquet.Filter{
+		`select(.[0][1] == "date" and .[1] >= "2024-01-01")`: {{Column: "date", Op: ">=", Value: "2024-01-01"}},
+		`select(10 < .[1] and .[0][1] == "n") | select(.[1] == 12)`: {
+			{Column: "n", Op: ">", Value: float64(10)},
+			{Column: "n", Op: "==", Value: float64(12)},
+		},
+		// closing events have a null value, which meets these
+		`select(.[0][1] == "n" and .[1] < 10)`:  nil,
+		`select(.[0][1] == "n" and .[1] != 10)`: nil,
+		`select(.[0][1] == "n" and 10 > .[1])`:  nil,
+		// the comparison could be of any column
+		`select(.[1] > 10)`: nil,
+		`select((.[0][1] == "a" or .[0][1] == "b") and .[1] > 1)`: nil,
+		`select(.[0][1] == "n" and (.[1] | . > 1))`:               nil,
+		`select(.[0][1] == "n" and .[1] > null)`:                  nil,
+	} {
+		if got := scanFor(expr).Filters; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got filters %v, want %v", expr, got, want)
+		}
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"testing"
 
 	"github.com/JFryy/qq/codec"
-	"github.com/itchyny/gojq"
 )
 
 func TestSlurpInputs_MultipleJSON(t *testing.T) {
@@ -80,7 +79,7 @@ func TestExecuteQuery_WithSlurp(t *testing.T) {
 		t.Fatalf("slurpInputs failed: %v", err)
 	}
 
-	query, err := gojq.Parse("length")
+	query, err := compileQuery("length")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
@@ -109,7 +108,7 @@ func TestExecuteQuery_WithSlurp(t *testing.T) {
 }
 
 func TestExecuteQuery_ExitStatus_True(t *testing.T) {
-	query, err := gojq.Parse(".")
+	query, err := compileQuery(".")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
@@ -133,7 +132,7 @@ func TestExecuteQuery_ExitStatus_True(t *testing.T) {
 }
 
 func TestExecuteQuery_ExitStatus_False(t *testing.T) {
-	query, err := gojq.Parse(".")
+	query, err := compileQuery(".")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
@@ -157,7 +156,7 @@ func TestExecuteQuery_ExitStatus_False(t *testing.T) {
 }
 
 func TestExecuteQuery_ExitStatus_Null(t *testing.T) {
-	query, err := gojq.Parse(".")
+	query, err := compileQuery(".")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
@@ -168,7 +167,7 @@ func TestExecuteQuery_ExitStatus_Null(t *testing.T) {
 	os.Stdout = w
 
 	// Use a query that produces null without error
-	query, _ = gojq.Parse(".nonexistent")
+	query, _ = compileQuery(".nonexistent")
 	data := map[string]any{}
 	exitCode := executeQuery(query, data, codec.JSON, false, true, true)
 
@@ -184,7 +183,7 @@ func TestExecuteQuery_ExitStatus_Null(t *testing.T) {
 }
 
 func TestExecuteQuery_ExitStatus_NoOutput(t *testing.T) {
-	query, err := gojq.Parse("select(. > 10)")
+	query, err := compileQuery("select(. > 10)")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
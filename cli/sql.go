@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/JFryy/qq/codec"
+	"github.com/JFryy/qq/internal/sql"
+	"github.com/spf13/cobra"
+)
+
+func newSQLCmd() *cobra.Command {
+	var inputType, outputType string
+	var rawOut, monochrome, explain bool
+	cmd := &cobra.Command{
+		Use:   "sql <query>",
+		Short: "Query files with SQL",
+		Long: "Run a SELECT statement where table names are file paths, for example\n" +
+			"  qq sql \"SELECT region, sum(amount) FROM 'sales.csv' s JOIN 'regions.parquet' r ON s.rid = r.id GROUP BY region ORDER BY 2 DESC\"\n" +
+			"Each table is decoded with the codec for its extension; a top-level array provides the rows. " +
+			"Use '-' as a table name to read stdin with the codec given by -i. Nested fields are addressed with dots, e.g. s.customer.name.",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plan, err := sql.Compile(args[0])
+			if err != nil {
+				return err
+			}
+			if explain {
+				fmt.Println(plan.String())
+				return nil
+			}
+
+			rows, err := plan.Run(func(path string) (any, error) {
+				// -i only applies to stdin; files are decoded by extension
+				data, _, err := loadInput(path, inputType, false)
+				return data, err
+			})
+			if err != nil {
+				return err
+			}
+
+			outputCodec, err := codec.GetEncodingType(outputType)
+			if err != nil {
+				return err
+			}
+			// csv and tsv columns follow the select list
+			b, err := codec.MarshalColumns(rows, outputCodec, plan.Columns())
+			if err != nil {
+				return err
+			}
+			return writeOutput(os.Stdout, b, outputCodec, rawOut, monochrome)
+		},
+	}
+	cmd.Flags().StringVarP(&inputType, "input", "i", "json", "file type of tables read from stdin ('-').")
+	cmd.Flags().StringVarP(&outputType, "output", "o", "json", "specify output file type by extension name.")
+	cmd.Flags().BoolVarP(&rawOut, "raw-output", "r", false, "output strings without escapes and quotes.")
+	cmd.Flags().BoolVarP(&monochrome, "monochrome-output", "M", false, "disable colored output")
+	cmd.Flags().BoolVar(&explain, "explain", false, "print the evaluation plan instead of running the query")
+	return cmd
+}
@@ -4,17 +4,18 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/JFryy/qq/codec"
-	"github.com/itchyny/gojq"
+	"github.com/JFryy/qq/codec/parquet"
 )
 
 func TestExecuteStreamingQuery_BasicObject(t *testing.T) {
 	input := `{"name":"Alice","age":30}`
 	reader := strings.NewReader(input)
-	query, err := gojq.Parse(".")
+	query, err := compileQuery(".")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
@@ -24,7 +25,7 @@ func TestExecuteStreamingQuery_BasicObject(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	executeStreamingQuery(query, reader, codec.JSON, codec.JSON, false, true)
+	executeStreamingQuery(query, reader, codec.JSON, parquet.Scan{}, codec.JSON, false, true)
 
 	w.Close()
 	os.Stdout = old
@@ -45,7 +46,7 @@ func TestExecuteStreamingQuery_BasicObject(t *testing.T) {
 func TestExecuteStreamingQuery_Array(t *testing.T) {
 	input := `[1,2,3]`
 	reader := strings.NewReader(input)
-	query, err := gojq.Parse(".")
+	query, err := compileQuery(".")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
@@ -55,7 +56,7 @@ func TestExecuteStreamingQuery_Array(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	executeStreamingQuery(query, reader, codec.JSON, codec.JSON, false, true)
+	executeStreamingQuery(query, reader, codec.JSON, parquet.Scan{}, codec.JSON, false, true)
 
 	w.Close()
 	os.Stdout = old
@@ -74,7 +75,7 @@ func TestExecuteStreamingQuery_WithFilter(t *testing.T) {
 	input := `{"a":1,"b":2,"c":3}`
 	reader := strings.NewReader(input)
 	// Select only path-value pairs (length == 2), not the closing marker
-	query, err := gojq.Parse("select(length == 2)")
+	query, err := compileQuery("select(length == 2)")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
@@ -84,7 +85,7 @@ func TestExecuteStreamingQuery_WithFilter(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	executeStreamingQuery(query, reader, codec.JSON, codec.JSON, false, true)
+	executeStreamingQuery(query, reader, codec.JSON, parquet.Scan{}, codec.JSON, false, true)
 
 	w.Close()
 	os.Stdout = old
@@ -112,7 +113,7 @@ func TestExecuteStreamingQuery_WithFilter(t *testing.T) {
 func TestExecuteStreamingQuery_NestedStructure(t *testing.T) {
 	input := `{"user":{"name":"Bob","id":123}}`
 	reader := strings.NewReader(input)
-	query, err := gojq.Parse(".")
+	query, err := compileQuery(".")
 	if err != nil {
 		t.Fatalf("failed to parse query: %v", err)
 	}
@@ -122,7 +123,7 @@ func TestExecuteStreamingQuery_NestedStructure(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	executeStreamingQuery(query, reader, codec.JSON, codec.JSON, false, true)
+	executeStreamingQuery(query, reader, codec.JSON, parquet.Scan{}, codec.JSON, false, true)
 
 	w.Close()
 	os.Stdout = old
@@ -161,3 +162,56 @@ func TestStreamParser(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteElementQuery(t *testing.T) {
+	input := `<log><rec><n>1</n></rec><rec><n>2</n></rec><skip/><rec><n>3</n></rec></log>`
+	query, err := compileQuery(".n")
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	code := executeElementQuery(query, strings.NewReader(input), codec.XML, "rec", codec.JSON, false, true, true)
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	if got := strings.Fields(buf.String()); strings.Join(got, ",") != "1,2,3" {
+		t.Errorf("expected one result per element, got: %q", buf.String())
+	}
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+
+	code = executeElementQuery(query, strings.NewReader(`{}`), codec.JSON, "rec", codec.JSON, false, true, false)
+	if code != 1 {
+		t.Errorf("expected exit code 1 for json input, got %d", code)
+	}
+}
+
+func TestStreamParserParquet(t *testing.T) {
+	data, err := codec.Marshal([]any{map[string]any{"a": 1}, map[string]any{"a": 2}}, codec.PARQUET)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rows are path-value pairs under their index, as for csv
+	stream, err := codec.StreamParserCollect(bytes.NewReader(data), codec.PARQUET)
+	if err != nil {
+		t.Fatalf("StreamParser failed: %v", err)
+	}
+	want := []any{
+		[]any{[]any{0, "a"}, 1},
+		[]any{[]any{0, "a"}},
+		[]any{[]any{1, "a"}, 2},
+		[]any{[]any{1, "a"}},
+	}
+	if !reflect.DeepEqual(stream, want) {
+		t.Errorf("expected %v, got %v", want, stream)
+	}
+}
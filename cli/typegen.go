@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JFryy/qq/codec"
+	"github.com/JFryy/qq/internal/typegen"
+	"github.com/spf13/cobra"
+)
+
+func newTypegenCmd() *cobra.Command {
+	var inputType, lang, rootName, tag, pkg string
+	cmd := &cobra.Command{
+		Use:   "typegen [file]",
+		Short: "Generate Go, TypeScript, Python or Rust types from a sample document",
+		Long: fmt.Sprintf("Infer a type definition from a sample document, merging the structure of every array element. "+
+			"Nested types are named after their paths and struct tags follow the input format. Supported languages: %s",
+			strings.Join(typegen.Languages, ", ")),
+		Args:          cobra.MaximumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var path string
+			if len(args) == 1 {
+				path = args[0]
+			}
+			data, inputCodec, err := loadInput(path, inputType, cmd.Flags().Changed("input"))
+			if err != nil {
+				return err
+			}
+			if tag == "" {
+				tag = typegenTag(inputCodec)
+			}
+			out, err := typegen.Generate(data, typegen.Options{
+				Lang:     lang,
+				RootName: rootName,
+				Tag:      tag,
+				Package:  pkg,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Println(out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&inputType, "input", "i", "json", "specify input file type, only required on parsing stdin.")
+	cmd.Flags().StringVarP(&lang, "lang", "l", "go", fmt.Sprintf("target language (%s)", strings.Join(typegen.Languages, ", ")))
+	cmd.Flags().StringVar(&rootName, "name", "Root", "name of the top-level type")
+	cmd.Flags().StringVar(&tag, "tag", "", "struct tag / field metadata key (json, yaml, toml); defaults to the input format")
+	cmd.Flags().StringVar(&pkg, "package", "", "emit a Go package clause and imports with this package name")
+	return cmd
+}
+
+// typegenTag picks the serialization tag matching the input codec, falling back
+// to json for formats without a conventional Go tag.
+func typegenTag(enc codec.EncodingType) string {
+	switch enc {
+	case codec.YAML:
+		return "yaml"
+	case codec.TOML:
+		return "toml"
+	default:
+		return "json"
+	}
+}
@@ -3,14 +3,48 @@ package avro
 import (
 	"bytes"
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/goccy/go-json"
+	"github.com/hamba/avro/v2"
 	"github.com/hamba/avro/v2/ocf"
 )
 
-type Codec struct{}
+// Codec reads and writes Avro object container files. The writer infers the
+// schema from every record unless a schema file is given.
+type Codec struct {
+	// Schema is an .avsc file declaring the records to write.
+	Schema string
+	// Compression is the container's codec: null (the default), deflate,
+	// snappy or zstandard.
+	Compression ocf.CodecName
+}
+
+var compressions = map[string]ocf.CodecName{
+	"null":      ocf.Null,
+	"none":      ocf.Null,
+	"deflate":   ocf.Deflate,
+	"snappy":    ocf.Snappy,
+	"zstd":      ocf.ZStandard,
+	"zstandard": ocf.ZStandard,
+}
+
+// SetOption implements codec.Configurable for the avro options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "schema":
+		c.Schema = value
+	case "compression":
+		name, ok := compressions[strings.ToLower(value)]
+		if !ok {
+			return fmt.Errorf("unknown compression %q, expected null, deflate, snappy or zstd", value)
+		}
+		c.Compression = name
+	default:
+		return fmt.Errorf("unknown option %q, expected schema or compression", key)
+	}
+	return nil
+}
 
 func (c *Codec) Unmarshal(data []byte, v any) error {
 	dec, err := ocf.NewDecoder(bytes.NewReader(data))
@@ -18,19 +52,32 @@ func (c *Codec) Unmarshal(data []byte, v any) error {
 		return fmt.Errorf("error creating avro decoder: %v", err)
 	}
 
-	var records []map[string]any
+	var records []any
 	for dec.HasNext() {
-		var record map[string]any
+		var record any
 		if err := dec.Decode(&record); err != nil {
 			return fmt.Errorf("error decoding avro record: %v", err)
 		}
-		records = append(records, record)
+		converted, err := fromAvro(dec.Schema(), record)
+		if err != nil {
+			return fmt.Errorf("record %d: %v", len(records)+1, err)
+		}
+		records = append(records, converted)
 	}
 	if err := dec.Error(); err != nil {
 		return fmt.Errorf("avro decode error: %v", err)
 	}
 
-	// JSON roundtrip normalises numeric types (same approach as parquet codec)
+	// records are assigned as read when v can hold them, to keep decimals
+	// exact, and converted through JSON otherwise
+	switch out := v.(type) {
+	case *any:
+		*out = records
+		return nil
+	case *[]any:
+		*out = records
+		return nil
+	}
 	jsonData, err := json.Marshal(records)
 	if err != nil {
 		return fmt.Errorf("error marshaling to JSON: %v", err)
@@ -39,100 +86,68 @@ func (c *Codec) Unmarshal(data []byte, v any) error {
 }
 
 func (c *Codec) Marshal(v any) ([]byte, error) {
-	// Normalise to []map[string]any via JSON roundtrip
+	// Normalise via JSON roundtrip, keeping numbers as written so integers
+	// and floats can be told apart
 	jsonData, err := json.Marshal(v)
 	if err != nil {
 		return nil, err
 	}
-
-	var records []map[string]any
-	if err := json.Unmarshal(jsonData, &records); err != nil {
-		// Try single record
-		var record map[string]any
-		if err2 := json.Unmarshal(jsonData, &record); err2 != nil {
-			return nil, fmt.Errorf("avro output requires an array of objects or a single object")
-		}
-		records = []map[string]any{record}
+	var data any
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	var records []any
+	switch data := data.(type) {
+	case []any:
+		records = data
+	case map[string]any:
+		records = []any{data}
+	default:
+		return nil, fmt.Errorf("avro output requires an array of objects or a single object")
 	}
-
 	if len(records) == 0 {
 		return nil, fmt.Errorf("no data to write")
 	}
 
-	schemaStr, err := inferSchema(records[0])
-	if err != nil {
-		return nil, err
+	var schema avro.Schema
+	if c.Schema != "" {
+		if schema, err = avro.ParseFiles(c.Schema); err != nil {
+			return nil, fmt.Errorf("error reading avro schema %s: %v", c.Schema, err)
+		}
+	} else {
+		inferred, err := inferSchema(records)
+		if err != nil {
+			return nil, err
+		}
+		if schema, err = avro.Parse(inferred); err != nil {
+			return nil, fmt.Errorf("error inferring avro schema: %v", err)
+		}
 	}
 
+	compression := c.Compression
+	if compression == "" {
+		compression = ocf.Null
+	}
 	var buf bytes.Buffer
-	enc, err := ocf.NewEncoder(schemaStr, &buf)
+	enc, err := ocf.NewEncoderWithSchema(schema, &buf, ocf.WithCodec(compression))
 	if err != nil {
 		return nil, fmt.Errorf("error creating avro encoder: %v", err)
 	}
 
-	for _, record := range records {
-		if err := enc.Encode(stringifyComplex(record)); err != nil {
-			return nil, fmt.Errorf("error encoding avro record: %v", err)
+	for i, record := range records {
+		converted, err := toAvro(schema, record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", i+1, err)
+		}
+		if err := enc.Encode(converted); err != nil {
+			return nil, fmt.Errorf("error encoding avro record %d: %v", i+1, err)
 		}
 	}
-	if err := enc.Flush(); err != nil {
+	if err := enc.Close(); err != nil {
 		return nil, fmt.Errorf("error flushing avro encoder: %v", err)
 	}
 
 	return buf.Bytes(), nil
 }
-
-// stringifyComplex returns a copy of the record with any complex (non-scalar)
-// values JSON-serialised as strings, matching the schema produced by inferSchema.
-func stringifyComplex(record map[string]any) map[string]any {
-	out := make(map[string]any, len(record))
-	for k, v := range record {
-		switch v.(type) {
-		case nil, bool, float64, int64, string:
-			out[k] = v
-		default:
-			b, err := json.Marshal(v)
-			if err != nil {
-				out[k] = fmt.Sprintf("%v", v)
-			} else {
-				out[k] = string(b)
-			}
-		}
-	}
-	return out
-}
-
-// inferAvroFieldType returns an Avro type string for a Go value.
-// Complex types (objects, arrays) are stringified.
-func inferAvroFieldType(v any) string {
-	switch v.(type) {
-	case bool:
-		return `"boolean"`
-	case float64:
-		return `"double"`
-	case int64:
-		return `"long"`
-	case string:
-		return `"string"`
-	default:
-		return `"string"`
-	}
-}
-
-// inferSchema builds an Avro record schema from the first record's keys/types.
-// All fields are nullable (["null", <type>]) with a default of null.
-func inferSchema(sample map[string]any) (string, error) {
-	var fieldNames []string
-	for k := range sample {
-		fieldNames = append(fieldNames, k)
-	}
-	sort.Strings(fieldNames)
-
-	var fields []string
-	for _, k := range fieldNames {
-		avroType := inferAvroFieldType(sample[k])
-		fields = append(fields, fmt.Sprintf(`{"name":%q,"type":["null",%s],"default":null}`, k, avroType))
-	}
-
-	return fmt.Sprintf(`{"type":"record","name":"Root","fields":[%s]}`, strings.Join(fields, ",")), nil
-}
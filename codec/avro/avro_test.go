@@ -0,0 +1,198 @@
+package avro
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/hamba/avro/v2/ocf"
+)
+
+// roundTrip writes records with c and reads them back.
+func roundTrip(t *testing.T, c *Codec, records any) []any {
+	t.Helper()
+	data, err := c.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []any
+	if err := c.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func fromJSON(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestInferredSchema(t *testing.T) {
+	var records []any
+	if err := json.Unmarshal([]byte(`[
+		{"name":"a","n":1,"big":1,"addr":{"city":"x","zip":1},"tags":["p",1],"m":{"a-b":1},"none":null},
+		{"name":"b","n":2,"big":2.5,"addr":{"city":"y"},"tags":[],"m":{"c d":2},"extra":true}
+	]`), &records); err != nil {
+		t.Fatal(err)
+	}
+	var numbers []any
+	dec := json.NewDecoder(bytes.NewReader([]byte(`[{"addr":{"city":"x","zip":1}}]`)))
+	dec.UseNumber()
+	if err := dec.Decode(&numbers); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := inferSchema(numbers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"fields":[{"default":null,"name":"addr","type":["null",{"fields":[` +
+		`{"default":null,"name":"city","type":["null","string"]},` +
+		`{"default":null,"name":"zip","type":["null","long"]}],"name":"Root_addr","type":"record"}]}],` +
+		`"name":"Root","type":"record"}`
+	if schema != want {
+		t.Errorf("got schema\n%s\nwant\n%s", schema, want)
+	}
+
+	got := roundTrip(t, &Codec{}, records)
+	want2 := fromJSON(t, `[
+		{"name":"a","n":1,"big":1,"addr":{"city":"x","zip":1},"tags":["p",1],"m":{"a-b":1},"none":null,"extra":null},
+		{"name":"b","n":2,"big":2.5,"addr":{"city":"y","zip":null},"tags":[],"m":{"c d":2},"none":null,"extra":true}
+	]`)
+	if !reflect.DeepEqual(fromJSON(t, mustJSON(t, got)), want2) {
+		t.Errorf("got %s", mustJSON(t, got))
+	}
+
+	for _, bad := range []any{[]any{1, 2}, "x", []any{map[string]any{"a": 1}, nil}} {
+		if _, err := (&Codec{}).Marshal(bad); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+	if _, err := (&Codec{}).Marshal([]any{}); err == nil {
+		t.Errorf("expected an error for no records")
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+const eventSchema = `{"type":"record","name":"Event","namespace":"ex","fields":[
+	{"name":"id","type":"long"},
+	{"name":"kind","type":{"type":"enum","name":"Kind","symbols":["A","B"]}},
+	{"name":"hash","type":{"type":"fixed","name":"Hash","size":4}},
+	{"name":"price","type":{"type":"bytes","logicalType":"decimal","precision":20,"scale":2}},
+	{"name":"day","type":{"type":"int","logicalType":"date"}},
+	{"name":"at","type":{"type":"long","logicalType":"timestamp-millis"}},
+	{"name":"tm","type":{"type":"int","logicalType":"time-millis"}},
+	{"name":"f","type":"float"},
+	{"name":"tags","type":{"type":"array","items":"string"}},
+	{"name":"attrs","type":{"type":"map","values":"int"}},
+	{"name":"v","type":["null","long","string",{"type":"record","name":"Sub","fields":[{"name":"x","type":"int"}]}]},
+	{"name":"opt","type":["null","string"],"default":null},
+	{"name":"dflt","type":"long","default":7}
+]}`
+
+func TestSchemaFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.avsc")
+	if err := os.WriteFile(path, []byte(eventSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Codec{}
+	if err := c.SetOption("schema", path); err != nil {
+		t.Fatal(err)
+	}
+	records := fromJSON(t, `[
+		{"id":9007199254740993,"kind":"B","hash":"AQIDBA==","price":"123456789012345678.91","day":"2024-01-17",
+		 "at":"2024-01-17T12:00:00.123+02:00","tm":"13:45:00.250","f":87.2,"tags":["a"],"attrs":{"k":1},"v":{"x":3}},
+		{"id":1,"kind":"A","hash":"AAAAAA==","price":0.5,"day":19739,"at":0,"tm":0,"f":"NaN",
+		 "tags":[],"attrs":{},"v":"s","opt":"o","dflt":3},
+		{"id":2,"kind":"A","hash":"AAAAAA==","price":1,"day":0,"at":0,"tm":0,"f":1,"tags":[],"attrs":{},"v":{"string":"5"}}
+	]`)
+	// keep the long beyond 2^53 exact
+	records.([]any)[0].(map[string]any)["id"] = json.Number("9007199254740993")
+	got := roundTrip(t, c, records)
+	want := fromJSON(t, `[
+		{"id":"9007199254740993","kind":"B","hash":"AQIDBA==","price":123456789012345678.91,"day":"2024-01-17",
+		 "at":"2024-01-17T10:00:00.123Z","tm":"13:45:00.250","f":87.2,"tags":["a"],"attrs":{"k":1},"v":{"x":3},"opt":null,"dflt":7},
+		{"id":1,"kind":"A","hash":"AAAAAA==","price":0.50,"day":"2024-01-17","at":"1970-01-01T00:00:00Z","tm":"00:00:00.000",
+		 "f":"NaN","tags":[],"attrs":{},"v":"s","opt":"o","dflt":3},
+		{"id":2,"kind":"A","hash":"AAAAAA==","price":1.00,"day":"1970-01-01","at":"1970-01-01T00:00:00Z","tm":"00:00:00.000",
+		 "f":1,"tags":[],"attrs":{},"v":"5","opt":null,"dflt":7}
+	]`)
+	if !reflect.DeepEqual(fromJSON(t, mustJSON(t, got)), want) {
+		t.Errorf("got %s", mustJSON(t, got))
+	}
+	// decimals are exact, with the column's scale
+	for i, price := range []json.Number{"123456789012345678.91", "0.50", "1.00"} {
+		if p := got[i].(map[string]any)["price"]; p != price {
+			t.Errorf("record %d: got price %#v, want %s", i, p, price)
+		}
+	}
+
+	base := `"id":1,"kind":"A","hash":"AAAAAA==","price":1,"day":0,"at":0,"tm":0,"f":1,"tags":[],"attrs":{},"v":null`
+	for _, tc := range []struct{ record, err string }{
+		{`{` + base + `,"bogus":1}`, "bogus is not a field of ex.Event"},
+		{`{"kind":"A"}`, "missing field id of ex.Event"},
+		{`{` + strings.Replace(base, `"kind":"A"`, `"kind":"C"`, 1) + `}`, `kind: "C" is not a symbol of ex.Kind`},
+		{`{` + strings.Replace(base, `"AAAAAA=="`, `"AAA="`, 1) + `}`, "hash: ex.Hash holds 4 bytes, got 2"},
+		{`{` + strings.Replace(base, `"price":1`, `"price":1.234`, 1) + `}`, "price: 1.234 does not fit decimal(20, 2)"},
+		{`{` + strings.Replace(base, `"id":1`, `"id":1.5`, 1) + `}`, "id: 1.5 is not a 64-bit integer"},
+		{`{` + strings.Replace(base, `"v":null`, `"v":true`, 1) + `}`, "v: true matches no type of the union"},
+	} {
+		_, err := c.Marshal(fromJSON(t, tc.record))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want %q", tc.record, err, tc.err)
+		}
+	}
+
+	c.Schema = filepath.Join(t.TempDir(), "missing.avsc")
+	if _, err := c.Marshal(fromJSON(t, `{"id":1}`)); err == nil {
+		t.Errorf("expected an error for a missing schema file")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	records := []any{map[string]any{"s": strings.Repeat("same ", 100)}}
+	for option, want := range map[string]ocf.CodecName{
+		"null": ocf.Null, "none": ocf.Null, "deflate": ocf.Deflate, "snappy": ocf.Snappy, "zstd": ocf.ZStandard, "ZStandard": ocf.ZStandard,
+	} {
+		c := &Codec{}
+		if err := c.SetOption("compression", option); err != nil {
+			t.Fatal(err)
+		}
+		data, err := c.Marshal(records)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := ocf.NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ocf.CodecName(dec.Metadata()["avro.codec"]); got != want {
+			t.Errorf("%s: codec is %s, want %s", option, got, want)
+		}
+		if got := roundTrip(t, c, records); !reflect.DeepEqual(got, records) {
+			t.Errorf("%s: got %v", option, got)
+		}
+	}
+
+	c := &Codec{}
+	for _, opt := range [][2]string{{"compression", "lz4"}, {"nope", "1"}} {
+		if err := c.SetOption(opt[0], opt[1]); err == nil {
+			t.Errorf("%v: expected an error", opt)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package avro
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/goccy/go-json"
+	"github.com/hamba/avro/v2"
+)
+
+// fromAvro converts v, as the avro library decodes schema into an any, to
+// a JSON value: unions are their value alone, dates and timestamps RFC 3339
+// strings, times of day 15:04:05.000 strings, bytes and fixed base64, longs
+// that jq cannot hold exactly their decimal text, and decimals json.Number,
+// exact whatever their precision.
+func fromAvro(schema avro.Schema, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+	switch s := schema.(type) {
+	case *avro.UnionSchema:
+		// the avro library gives a union's value alone when every type of
+		// the union maps to a Go type, and wrapped in an object keyed by the
+		// type's name otherwise
+		if wrapped, ok := v.(map[string]any); ok && len(wrapped) == 1 {
+			for name, inner := range wrapped {
+				if branch, _ := s.Types().Get(name); branch != nil {
+					return fromAvro(branch, inner)
+				}
+			}
+		}
+		for _, branch := range s.Types() {
+			if branch.Type() != avro.Null && holds(branch, v) {
+				return fromAvro(branch, v)
+			}
+		}
+		return nil, fmt.Errorf("unexpected union value %T", v)
+	case *avro.RecordSchema:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected record value %T", v)
+		}
+		out := make(map[string]any, len(obj))
+		for _, f := range s.Fields() {
+			converted, err := fromAvro(f.Type(), obj[f.Name()])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name(), err)
+			}
+			out[f.Name()] = converted
+		}
+		return out, nil
+	case *avro.ArraySchema:
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected array value %T", v)
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			converted, err := fromAvro(s.Items(), item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+			out[i] = converted
+		}
+		return out, nil
+	case *avro.MapSchema:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected map value %T", v)
+		}
+		out := make(map[string]any, len(obj))
+		for k, item := range obj {
+			converted, err := fromAvro(s.Values(), item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			out[k] = converted
+		}
+		return out, nil
+	}
+
+	logical := logicalType(schema)
+	switch v := v.(type) {
+	case time.Time:
+		if logical == avro.Date {
+			return v.Format(time.DateOnly), nil
+		}
+		if logical == avro.LocalTimestampMillis || logical == avro.LocalTimestampMicros {
+			// local timestamps have no time zone to give
+			return strings.TrimSuffix(v.Format(time.RFC3339Nano), "Z"), nil
+		}
+		return v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return clock(v), nil
+	case *big.Rat:
+		d, ok := schema.(avro.LogicalTypeSchema).Logical().(*avro.DecimalLogicalSchema)
+		if !ok {
+			return nil, fmt.Errorf("unexpected decimal value for %s", schema.Type())
+		}
+		// with the column's scale, so every value of a column is the same
+		// kind of number
+		return json.Number(v.FloatString(d.Scale())), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case int64:
+		return util.Int64(v), nil
+	case int32:
+		return int(v), nil
+	case float32:
+		return util.Float32(v), nil
+	case float64:
+		return util.Float(v), nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		// fixed
+		data := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(data), rv)
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return v, nil
+}
+
+// holds reports whether v, a union's value given alone, can be of schema.
+func holds(schema avro.Schema, v any) bool {
+	logical := logicalType(schema)
+	switch v.(type) {
+	case time.Time:
+		return logical == avro.Date || logical == avro.TimestampMillis || logical == avro.TimestampMicros
+	case time.Duration:
+		return logical == avro.TimeMillis || logical == avro.TimeMicros
+	case *big.Rat:
+		return logical == avro.Decimal
+	}
+	_, ok := schema.(*avro.PrimitiveSchema)
+	return ok && (logical == "" || logical == avro.UUID)
+}
+
+// clock writes a time of day as 15:04:05 with as many fractional digits as
+// it needs, in threes.
+func clock(d time.Duration) string {
+	s := time.Time{}.Add(d).Format("15:04:05.000000")
+	return strings.TrimSuffix(s, "000")
+}
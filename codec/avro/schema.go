@@ -0,0 +1,176 @@
+package avro
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+
+	"github.com/goccy/go-json"
+)
+
+// shape is what inference has seen of the values at one place in the
+// records, decoded from JSON with numbers kept as json.Number.
+type shape struct {
+	null, boolean, long, double, str bool
+	// objects is set once an object is seen; fields holds the shape of
+	// each key and values of all values, for objects that must be maps.
+	objects    bool
+	fields     map[string]*shape
+	values     *shape
+	validNames bool
+	// items is the shape of array elements, set once an array is seen.
+	items *shape
+}
+
+// name is an Avro name, which record fields must be.
+var name = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (s *shape) observe(v any) {
+	switch v := v.(type) {
+	case nil:
+		s.null = true
+	case bool:
+		s.boolean = true
+	case json.Number:
+		if isLong(v) {
+			s.long = true
+		} else {
+			s.double = true
+		}
+	case string:
+		s.str = true
+	case []any:
+		if s.items == nil {
+			s.items = &shape{}
+		}
+		for _, item := range v {
+			s.items.observe(item)
+		}
+	case map[string]any:
+		if !s.objects {
+			s.objects, s.validNames = true, true
+			s.fields, s.values = make(map[string]*shape), &shape{}
+		}
+		for k, item := range v {
+			f := s.fields[k]
+			if f == nil {
+				f = &shape{}
+				s.fields[k] = f
+			}
+			f.observe(item)
+			s.values.observe(item)
+			if !name.MatchString(k) {
+				s.validNames = false
+			}
+		}
+	}
+}
+
+func isLong(n json.Number) bool {
+	if _, err := n.Int64(); err == nil {
+		return true
+	}
+	// whole numbers written as 1.0 or 1e3 are integers too, if they fit
+	f, ok := new(big.Float).SetString(n.String())
+	if !ok || !f.IsInt() {
+		return false
+	}
+	_, acc := f.Int64()
+	return acc == big.Exact
+}
+
+// namer hands out record names, unique within a schema.
+type namer map[string]bool
+
+func (n namer) name(base string) string {
+	candidate := base
+	for i := 2; n[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+	n[candidate] = true
+	return candidate
+}
+
+// inferSchema returns the schema of records, built from every record:
+// integers are longs unless a double is seen in the same place, objects
+// are records, or maps when their keys are not all Avro names, and values
+// of several kinds are unions. Every record field is nullable with a null
+// default, as a key may be missing from some records.
+func inferSchema(records []any) (string, error) {
+	root := &shape{}
+	for _, record := range records {
+		root.observe(record)
+	}
+	if !root.objects || root.null || root.boolean || root.long || root.double || root.str || root.items != nil {
+		return "", fmt.Errorf("avro output requires an array of objects or a single object")
+	}
+	schema := root.schema("Root", namer{}, false)
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// schema returns the Avro schema of s, as a value to write as JSON, with
+// null as the first branch when nullable.
+func (s *shape) schema(recordName string, names namer, nullable bool) any {
+	var branches []any
+	if s.null || nullable {
+		branches = append(branches, "null")
+	}
+	if s.boolean {
+		branches = append(branches, "boolean")
+	}
+	switch {
+	case s.double:
+		branches = append(branches, "double")
+	case s.long:
+		branches = append(branches, "long")
+	}
+	if s.str {
+		branches = append(branches, "string")
+	}
+	if s.items != nil {
+		branches = append(branches, map[string]any{
+			"type":  "array",
+			"items": s.items.schema(recordName, names, false),
+		})
+	}
+	if s.objects {
+		branches = append(branches, s.object(recordName, names))
+	}
+	if len(branches) == 0 || (len(branches) == 1 && branches[0] == "null") {
+		// nothing but nulls, or empty arrays, say nothing of the type
+		branches = append(branches, "string")
+	}
+	if len(branches) == 1 {
+		return branches[0]
+	}
+	return branches
+}
+
+func (s *shape) object(recordName string, names namer) any {
+	if !s.validNames || len(s.fields) == 0 {
+		return map[string]any{
+			"type":   "map",
+			"values": s.values.schema(recordName+"_value", names, len(s.fields) == 0),
+		}
+	}
+	keys := make([]string, 0, len(s.fields))
+	for k := range s.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fullName := names.name(recordName)
+	fields := make([]any, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, map[string]any{
+			"name":    k,
+			"type":    s.fields[k].schema(fullName+"_"+k, names, true),
+			"default": nil,
+		})
+	}
+	return map[string]any{"type": "record", "name": fullName, "fields": fields}
+}
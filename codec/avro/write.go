@@ -0,0 +1,302 @@
+package avro
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/hamba/avro/v2"
+)
+
+// toAvro converts v, as decoded from JSON with numbers kept as json.Number,
+// to the value the avro library encodes for schema: int32 and int64 for
+// int and long, base64 strings decoded for bytes and fixed, time.Time for
+// dates and timestamps given as RFC 3339 strings, *big.Rat for decimals
+// and single-key maps naming the branch for unions.
+func toAvro(schema avro.Schema, v any) (any, error) {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+	logical := logicalType(schema)
+	switch s := schema.(type) {
+	case *avro.NullSchema:
+		if v == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("expected null, got %s", util.Describe(v))
+	case *avro.UnionSchema:
+		return toUnion(s, v)
+	case *avro.RecordSchema:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object for %s, got %s", s.FullName(), util.Describe(v))
+		}
+		for k := range obj {
+			if fieldByName(s, k) == nil {
+				return nil, fmt.Errorf("%s is not a field of %s", k, s.FullName())
+			}
+		}
+		out := make(map[string]any, len(s.Fields()))
+		for _, f := range s.Fields() {
+			value, ok := obj[f.Name()]
+			if !ok && !nullable(f.Type()) {
+				if f.HasDefault() {
+					// the avro library writes the default
+					continue
+				}
+				return nil, fmt.Errorf("missing field %s of %s", f.Name(), s.FullName())
+			}
+			converted, err := toAvro(f.Type(), value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name(), err)
+			}
+			out[f.Name()] = converted
+		}
+		return out, nil
+	case *avro.ArraySchema:
+		items, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an array, got %s", util.Describe(v))
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			converted, err := toAvro(s.Items(), item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+			out[i] = converted
+		}
+		return out, nil
+	case *avro.MapSchema:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an object for a map, got %s", util.Describe(v))
+		}
+		out := make(map[string]any, len(obj))
+		for k, item := range obj {
+			converted, err := toAvro(s.Values(), item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			out[k] = converted
+		}
+		return out, nil
+	case *avro.EnumSchema:
+		symbol, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a symbol of %s, got %s", s.FullName(), util.Describe(v))
+		}
+		for _, sym := range s.Symbols() {
+			if sym == symbol {
+				return symbol, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a symbol of %s, expected one of %s", symbol, s.FullName(), strings.Join(s.Symbols(), ", "))
+	case *avro.FixedSchema:
+		if logical == avro.Decimal {
+			return decimalValue(s.Logical().(*avro.DecimalLogicalSchema), v)
+		}
+		data, err := bytesValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != s.Size() {
+			return nil, fmt.Errorf("%s holds %d bytes, got %d", s.FullName(), s.Size(), len(data))
+		}
+		fixed := reflect.New(reflect.ArrayOf(s.Size(), reflect.TypeOf(byte(0)))).Elem()
+		reflect.Copy(fixed, reflect.ValueOf(data))
+		return fixed.Interface(), nil
+	case *avro.PrimitiveSchema:
+		return toPrimitive(s, logical, v)
+	}
+	return nil, fmt.Errorf("unsupported schema %s", schema.Type())
+}
+
+func toPrimitive(s *avro.PrimitiveSchema, logical avro.LogicalType, v any) (any, error) {
+	switch s.Type() {
+	case avro.Boolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean, got %s", util.Describe(v))
+	case avro.String:
+		if str, ok := v.(string); ok {
+			return str, nil
+		}
+		return nil, fmt.Errorf("expected a string, got %s", util.Describe(v))
+	case avro.Bytes:
+		if logical == avro.Decimal {
+			return decimalValue(s.Logical().(*avro.DecimalLogicalSchema), v)
+		}
+		return bytesValue(v)
+	case avro.Int:
+		switch logical {
+		case avro.Date:
+			if str, ok := v.(string); ok {
+				t, err := timeValue(str)
+				if err != nil {
+					return nil, err
+				}
+				return int32(t.Unix() / 86400), nil
+			}
+		case avro.TimeMillis:
+			if str, ok := v.(string); ok {
+				d, err := timeOfDay(str)
+				return int32(d / time.Millisecond), err
+			}
+		}
+		n, err := util.ParseInt(v, 32)
+		return int32(n), err
+	case avro.Long:
+		switch logical {
+		case avro.TimestampMillis, avro.TimestampMicros, avro.LocalTimestampMillis, avro.LocalTimestampMicros:
+			if str, ok := v.(string); ok {
+				t, err := timeValue(str)
+				if err != nil {
+					return nil, err
+				}
+				if logical == avro.LocalTimestampMillis || logical == avro.LocalTimestampMicros {
+					// a local timestamp is the wall clock time, in no zone
+					t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+				}
+				if logical == avro.TimestampMillis || logical == avro.LocalTimestampMillis {
+					return t.UnixMilli(), nil
+				}
+				return t.UnixMicro(), nil
+			}
+		case avro.TimeMicros:
+			// the avro library takes a time.Duration for time-micros
+			if str, ok := v.(string); ok {
+				return timeOfDay(str)
+			}
+			n, err := util.ParseInt(v, 64)
+			return time.Duration(n) * time.Microsecond, err
+		}
+		return util.ParseInt(v, 64)
+	case avro.Float:
+		f, err := util.ParseFloat(v)
+		return float32(f), err
+	case avro.Double:
+		return util.ParseFloat(v)
+	}
+	return nil, fmt.Errorf("unsupported schema %s", s.Type())
+}
+
+// toUnion picks the branch of a union for v: the one named by an object
+// with a single key, as Avro's JSON encoding and the reader write unions
+// that could be ambiguous, or else the first branch v converts to.
+func toUnion(s *avro.UnionSchema, v any) (any, error) {
+	if obj, ok := v.(map[string]any); ok && len(obj) == 1 {
+		for k, inner := range obj {
+			if branch, _ := s.Types().Get(k); branch != nil && branch.Type() != avro.Null {
+				converted, err := toAvro(branch, inner)
+				if err == nil {
+					return map[string]any{k: converted}, nil
+				}
+			}
+		}
+	}
+	var errs []string
+	for _, branch := range s.Types() {
+		converted, err := toAvro(branch, v)
+		if err == nil {
+			return map[string]any{branchName(branch): converted}, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("%s matches no type of the union: %s", util.Describe(v), strings.Join(errs, "; "))
+}
+
+// branchName is the name a union knows a branch by.
+func branchName(schema avro.Schema) string {
+	if named, ok := schema.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	name := string(schema.Type())
+	if logical := logicalType(schema); logical != "" {
+		name += "." + string(logical)
+	}
+	return name
+}
+
+func logicalType(schema avro.Schema) avro.LogicalType {
+	if s, ok := schema.(avro.LogicalTypeSchema); ok && s.Logical() != nil {
+		return s.Logical().Type()
+	}
+	return ""
+}
+
+func nullable(schema avro.Schema) bool {
+	switch s := schema.(type) {
+	case *avro.NullSchema:
+		return true
+	case *avro.UnionSchema:
+		_, pos := s.Types().Get(string(avro.Null))
+		return pos >= 0
+	}
+	return false
+}
+
+func fieldByName(s *avro.RecordSchema, name string) *avro.Field {
+	for _, f := range s.Fields() {
+		if f.Name() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func bytesValue(v any) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected base64 for bytes, got %s", util.Describe(v))
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("bytes must be base64: %v", err)
+	}
+	return data, nil
+}
+
+// decimalValue parses v exactly and checks it fits the decimal's precision
+// and scale.
+func decimalValue(d *avro.DecimalLogicalSchema, v any) (*big.Rat, error) {
+	s, err := util.NumberText(v)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("expected a number, got %s", util.Describe(v))
+	}
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale())), nil)))
+	if !scaled.IsInt() || len(new(big.Int).Abs(scaled.Num()).String()) > d.Precision() {
+		return nil, fmt.Errorf("%s does not fit decimal(%d, %d)", s, d.Precision(), d.Scale())
+	}
+	return r, nil
+}
+
+// timeValue parses an RFC 3339 timestamp, one without a time zone, taken
+// as UTC, or a date.
+func timeValue(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected an RFC 3339 timestamp or a date, got %q", s)
+}
+
+// timeOfDay parses a time of day such as 13:45:00.250.
+func timeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04:05.999999999", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected a time of day such as 13:45:00.250, got %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond()), nil
+}
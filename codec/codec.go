@@ -25,6 +25,9 @@ import (
 	"github.com/JFryy/qq/codec/parquet"
 	"github.com/JFryy/qq/codec/properties"
 	proto "github.com/JFryy/qq/codec/proto"
+	"github.com/JFryy/qq/codec/protobuf"
+	"github.com/JFryy/qq/codec/shell"
+	"github.com/JFryy/qq/codec/textproto"
 	qqtoml "github.com/JFryy/qq/codec/toml"
 	"github.com/JFryy/qq/codec/tsv"
 	"github.com/JFryy/qq/codec/xml"
@@ -57,6 +60,9 @@ const (
 	BASE64
 	CBOR
 	AVRO
+	SHELL
+	PROTOBUF
+	TEXTPROTO
 )
 
 // String implements the Stringer interface, converting the enum to its canonical string name.
@@ -69,7 +75,7 @@ const (
 // is intentional for performance - O(1) array lookup here vs O(n) map iteration.
 // The array indices must match the iota order in the const block above.
 func (e EncodingType) String() string {
-	return [...]string{"json", "yaml", "toml", "hcl", "csv", "tsv", "xml", "ini", "gron", "html", "line", "txt", "proto", "env", "parquet", "msgpack", "properties", "jsonl", "jsonc", "base64", "cbor", "avro"}[e]
+	return [...]string{"json", "yaml", "toml", "hcl", "csv", "tsv", "xml", "ini", "gron", "html", "line", "txt", "proto", "env", "parquet", "msgpack", "properties", "jsonl", "jsonc", "base64", "cbor", "avro", "shell", "protobuf", "textproto"}[e]
 }
 
 // General Encoding struct to hold unmarshal/marshal functions and associated file extensions for each encoding type
@@ -142,6 +148,9 @@ var (
 	cborCodec       = cbor.Codec{}
 	avroCodec       = avro.Codec{}
 	tomlCodec       = qqtoml.Codec{}
+	shellCodec      = shell.Codec{}
+	protobufCodec   = protobuf.Codec{}
+	textprotoCodec  = textproto.Codec{}
 )
 
 var Codecs = map[EncodingType]Encoding{
@@ -154,7 +163,7 @@ var Codecs = map[EncodingType]Encoding{
 	XML:        {xmlCodec.Unmarshal, xmlCodec.Marshal, []string{"xml"}},
 	INI:        {iniCodec.Unmarshal, iniCodec.Marshal, []string{"ini"}},
 	GRON:       {gronCodec.Unmarshal, gronCodec.Marshal, []string{"gron"}},
-	HTML:       {htmlCodec.Unmarshal, xmlCodec.Marshal, []string{"html"}},
+	HTML:       {htmlCodec.Unmarshal, htmlCodec.Marshal, []string{"html"}},
 	LINE:       {lineCodec.Unmarshal, jsonCodec.Marshal, []string{"line"}},
 	TXT:        {lineCodec.Unmarshal, jsonCodec.Marshal, []string{"txt", "text"}},
 	PROTO:      {protoCodec.Unmarshal, jsonCodec.Marshal, []string{"proto"}},
@@ -167,6 +176,9 @@ var Codecs = map[EncodingType]Encoding{
 	BASE64:     {base64Codec.Unmarshal, base64Codec.Marshal, []string{"base64", "b64"}},
 	CBOR:       {cborCodec.Unmarshal, cborCodec.Marshal, []string{"cbor"}},
 	AVRO:       {avroCodec.Unmarshal, avroCodec.Marshal, []string{"avro"}},
+	SHELL:      {shellCodec.Unmarshal, shellCodec.Marshal, []string{"shell"}},
+	PROTOBUF:   {protobufCodec.Unmarshal, protobufCodec.Marshal, []string{"protobuf", "pb"}},
+	TEXTPROTO:  {textprotoCodec.Unmarshal, textprotoCodec.Marshal, []string{"textproto", "txtpb", "pbtxt"}},
 }
 
 func Unmarshal(input []byte, inputFileType EncodingType, data any) error {
@@ -198,6 +210,35 @@ func Marshal(v any, outputFileType EncodingType) ([]byte, error) {
 	return data, nil
 }
 
+// MarshalColumns is Marshal with csv and tsv columns written in the order
+// given, unless the columns option chose them. Nil columns leave the order
+// to the codec.
+func MarshalColumns(v any, outputFileType EncodingType, columns []string) ([]byte, error) {
+	var marshal func(any) ([]byte, error)
+	switch {
+	case columns == nil:
+		return Marshal(v, outputFileType)
+	case outputFileType == CSV && len(csvCodec.Columns) == 0:
+		c := csvCodec
+		c.Columns = columns
+		marshal = c.Marshal
+	case outputFileType == TSV && len(tsvCodec.Columns) == 0:
+		c := tsvCodec
+		c.Columns = columns
+		marshal = c.Marshal
+	default:
+		return Marshal(v, outputFileType)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("input data cannot be nil")
+	}
+	data, err := marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling result to %s: %v", outputFileType, err)
+	}
+	return data, nil
+}
+
 func IsBinaryFormat(fileType EncodingType) bool {
-	return fileType == PARQUET || fileType == MSGPACK || fileType == CBOR || fileType == AVRO
+	return fileType == PARQUET || fileType == MSGPACK || fileType == CBOR || fileType == AVRO || fileType == PROTOBUF
 }
@@ -314,3 +314,25 @@ func TestIsBinaryFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestSetOption(t *testing.T) {
+	defer func() { htmlCodec.Mode = "" }()
+
+	if err := SetOption("html.mode=tables"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var data any
+	if err := Unmarshal([]byte("<table><tr><th>a</th></tr><tr><td>1</td></tr></table>"), HTML, &data); err != nil {
+		t.Fatal(err)
+	}
+	rows, ok := data.([]any)
+	if !ok || len(rows) != 1 {
+		t.Fatalf("expected one record from the registered html codec, got %v", data)
+	}
+
+	for _, opt := range []string{"html.mode", "mode=tables", "nope.mode=x", "json.indent=2", "html.mode=nope"} {
+		if err := SetOption(opt); err == nil {
+			t.Errorf("expected error for %q", opt)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package csv
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -235,3 +236,202 @@ func TestCSVRoundTrip(t *testing.T) {
 		t.Errorf("Bool field mismatch: %v", first["BoolField"])
 	}
 }
+
+func readAll(t *testing.T, input string, d Dialect) []any {
+	t.Helper()
+	rows, err := ReadAll([]byte(input), d)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	return rows
+}
+
+func TestReaderQuotedFields(t *testing.T) {
+	input := "\xef\xbb\xbfid,name,note\n1,\"Smith, J\",\"multi\nline \"\"quoted\"\"\"\n"
+	got := readAll(t, input, Dialect{})
+	want := []any{map[string]any{"id": float64(1), "name": "Smith, J", "note": "multi\nline \"quoted\""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestReaderRagged(t *testing.T) {
+	input := "a,b\n1,2\n3\n4,5,6\n"
+	padded := readAll(t, input, Dialect{})
+	want := []any{
+		map[string]any{"a": float64(1), "b": float64(2)},
+		map[string]any{"a": float64(3), "b": ""},
+		map[string]any{"a": float64(4), "b": float64(5), "col3": float64(6)},
+	}
+	if !reflect.DeepEqual(padded, want) {
+		t.Errorf("pad: got %#v", padded)
+	}
+
+	if skipped := readAll(t, input, Dialect{Ragged: "skip"}); len(skipped) != 1 {
+		t.Errorf("skip: expected 1 row, got %v", skipped)
+	}
+
+	_, err := ReadAll([]byte(input), Dialect{Ragged: "error"})
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("error: expected an error naming line 3, got %v", err)
+	}
+}
+
+func TestReaderHeaderOptions(t *testing.T) {
+	input := "# exported\nreport v2\nx,y\n1,2\n"
+	got := readAll(t, input, Dialect{Comment: '#', HeaderOffset: 1})
+	want := []any{map[string]any{"x": float64(1), "y": float64(2)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("header_offset: got %#v", got)
+	}
+
+	got = readAll(t, "1,2\n3,4\n", Dialect{NoHeader: true})
+	want = []any{
+		map[string]any{"col1": float64(1), "col2": float64(2)},
+		map[string]any{"col1": float64(3), "col2": float64(4)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("no_header: got %#v", got)
+	}
+
+	got = readAll(t, "a,,a\n1,2,3\n", Dialect{})
+	want = []any{map[string]any{"a": float64(1), "col2": float64(2), "a_2": float64(3)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("header names: got %#v", got)
+	}
+}
+
+func TestReaderQuoteAndEscape(t *testing.T) {
+	input := "a;b\n'x;y';'it''s'\n'p\\'q';say \"hi\"\n"
+	got := readAll(t, input, Dialect{Quote: '\'', Escape: '\\'})
+	want := []any{
+		map[string]any{"a": "x;y", "b": "it's"},
+		map[string]any{"a": "p'q", "b": `say "hi"`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDialectSetOption(t *testing.T) {
+	d := &Dialect{}
+	for _, kv := range [][2]string{{"delimiter", "tab"}, {"quote", "'"}, {"escape", `\\`}, {"comment", "#"}, {"no_header", "true"}, {"header_offset", "2"}, {"ragged", "skip"}} {
+		if err := d.SetOption(kv[0], kv[1]); err != nil {
+			t.Errorf("%s=%s: %v", kv[0], kv[1], err)
+		}
+	}
+	want := Dialect{Delimiter: '\t', Quote: '\'', Escape: '\\', Comment: '#', NoHeader: true, HeaderOffset: 2, Ragged: "skip"}
+	if *d != want {
+		t.Errorf("got %+v", *d)
+	}
+	for _, kv := range [][2]string{{"delimiter", "ab"}, {"header_offset", "-1"}, {"ragged", "truncate"}, {"no_header", "maybe"}, {"columns", "a"}} {
+		if err := d.SetOption(kv[0], kv[1]); err == nil {
+			t.Errorf("expected error for %s=%s", kv[0], kv[1])
+		}
+	}
+}
+
+func writeString(t *testing.T, v any, d Dialect, f Format) string {
+	t.Helper()
+	b, err := Write(v, d, f)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	return string(b)
+}
+
+func TestWriterColumns(t *testing.T) {
+	rows := []any{
+		map[string]any{"id": float64(1), "name": "a"},
+		map[string]any{"id": float64(2), "created_at": "2024-01-02"},
+	}
+	if got, want := writeString(t, rows, Dialect{}, Format{}), "created_at,id,name\n,1,a\n2024-01-02,2,\n"; got != want {
+		t.Errorf("union: got %q, want %q", got, want)
+	}
+
+	f := Format{}
+	if err := f.SetOption("columns", "name, id,missing"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := writeString(t, rows, Dialect{NoHeader: true}, f), "a,1,\n,2,\n"; got != want {
+		t.Errorf("columns: got %q, want %q", got, want)
+	}
+
+	if got, want := writeString(t, []any{[]any{"a", float64(1)}, []any{"b", nil}}, Dialect{Delimiter: '\t'}, Format{}), "a\t1\nb\t\n"; got != want {
+		t.Errorf("arrays: got %q, want %q", got, want)
+	}
+	if _, err := Write([]any{map[string]any{}, "x"}, Dialect{}, Format{}); err == nil {
+		t.Error("expected an error for a row that is not an object")
+	}
+}
+
+func TestWriterNested(t *testing.T) {
+	rows := []any{map[string]any{
+		"id":   float64(1e6),
+		"user": map[string]any{"name": "a", "tags": []any{"x", "y"}},
+		"none": []any{},
+	}}
+	want := "id,none,user\n1000000,[],\"{\"\"name\"\":\"\"a\"\",\"\"tags\"\":[\"\"x\"\",\"\"y\"\"]}\"\n"
+	if got := writeString(t, rows, Dialect{}, Format{}); got != want {
+		t.Errorf("json: got %q, want %q", got, want)
+	}
+	want = "id,none,user.name,user.tags[0],user.tags[1]\n1000000,[],a,x,y\n"
+	got := writeString(t, rows, Dialect{}, Format{Nested: "flatten"})
+	if got != want {
+		t.Errorf("flatten: got %q, want %q", got, want)
+	}
+
+	d := Dialect{}
+	d.Keys.OnRead = true
+	if back := readAll(t, got, d); !reflect.DeepEqual(back, rows) {
+		t.Errorf("unflatten: got %#v", back)
+	}
+}
+
+func TestWriterQuoting(t *testing.T) {
+	rows := []any{map[string]any{"n": float64(1), "s": "it's, \"x\"", "b": true}}
+	cases := []struct {
+		d    Dialect
+		f    Format
+		want string
+	}{
+		{Dialect{}, Format{}, "b,n,s\ntrue,1,\"it's, \"\"x\"\"\"\n"},
+		{Dialect{}, Format{Quoting: "all", CRLF: true}, "\"b\",\"n\",\"s\"\r\n\"true\",\"1\",\"it's, \"\"x\"\"\"\r\n"},
+		{Dialect{}, Format{Quoting: "nonnumeric"}, "\"b\",\"n\",\"s\"\ntrue,1,\"it's, \"\"x\"\"\"\n"},
+		{Dialect{Quote: '\'', Escape: '\\'}, Format{}, "b,n,s\ntrue,1,'it\\'s, \"x\"'\n"},
+		{Dialect{Escape: '\\'}, Format{Quoting: "none"}, "b,n,s\ntrue,1,it's\\, \\\"x\\\"\n"},
+	}
+	for _, c := range cases {
+		got := writeString(t, rows, c.d, c.f)
+		if got != c.want {
+			t.Errorf("%+v %+v: got %q, want %q", c.d, c.f, got, c.want)
+			continue
+		}
+		d := c.d
+		d.Delimiter = ','
+		if back := readAll(t, got, d); !reflect.DeepEqual(back, rows) {
+			t.Errorf("%+v %+v: read back %#v", c.d, c.f, back)
+		}
+	}
+
+	if _, err := Write(rows, Dialect{}, Format{Quoting: "none"}); err == nil {
+		t.Error("expected an error for quoting=none without an escape character")
+	}
+}
+
+func TestOptionsSetOption(t *testing.T) {
+	o := &Options{}
+	for _, kv := range [][2]string{{"delimiter", ";"}, {"columns", "a,b"}, {"nested", "flatten"}, {"quoting", "all"}, {"crlf", "true"}} {
+		if err := o.SetOption(kv[0], kv[1]); err != nil {
+			t.Errorf("%s=%s: %v", kv[0], kv[1], err)
+		}
+	}
+	if o.Delimiter != ';' || !reflect.DeepEqual(o.Columns, []string{"a", "b"}) || o.Nested != "flatten" || o.Quoting != "all" || !o.CRLF {
+		t.Errorf("got %+v", *o)
+	}
+	for _, kv := range [][2]string{{"nested", "yaml"}, {"quoting", "some"}, {"crlf", "yes"}, {"sheet", "1"}} {
+		if err := o.SetOption(kv[0], kv[1]); err == nil {
+			t.Errorf("expected error for %s=%s", kv[0], kv[1])
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/JFryy/qq/internal/flatten"
+)
+
+// Dialect describes delimited text. The csv and tsv codecs share it, along
+// with the Reader built from it, in both normal and streaming mode.
+type Dialect struct {
+	// Delimiter separates fields; 0 detects it from the header line.
+	Delimiter rune
+	// Quote encloses fields, '"' by default.
+	Quote rune
+	// Escape escapes the next character, inside quoted fields or out; 0
+	// means quotes are escaped by doubling them, as in RFC 4180.
+	Escape rune
+	// Comment starts lines that are ignored; 0 disables comments.
+	Comment rune
+	// NoHeader treats the first row as data and names columns col1, col2...
+	NoHeader bool
+	// HeaderOffset is the number of rows skipped before the header.
+	HeaderOffset int
+	// Ragged is what happens to rows whose length differs from the header:
+	// "pad" (default) fills missing fields with "" and names extra ones
+	// colN, "error" fails and "skip" drops the row.
+	Ragged string
+	// Keys names the columns of nested values written with Format.Nested
+	// set to flatten, and rebuilds nested rows from them when reading with
+	// Keys.OnRead.
+	Keys flatten.Options
+	// TrimLeadingSpace and LazyQuotes are passed to encoding/csv.
+	TrimLeadingSpace bool
+	LazyQuotes       bool
+}
+
+// SetOption implements codec.Configurable for the reading options shared by
+// the csv and tsv codecs.
+func (d *Dialect) SetOption(key, value string) error {
+	switch key {
+	case "delimiter", "quote", "escape", "comment":
+		r, err := parseChar(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		switch key {
+		case "delimiter":
+			d.Delimiter = r
+		case "quote":
+			d.Quote = r
+		case "escape":
+			d.Escape = r
+		case "comment":
+			d.Comment = r
+		}
+	case "no_header":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("no_header must be true or false")
+		}
+		d.NoHeader = b
+	case "header_offset":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("header_offset must be a number of rows")
+		}
+		d.HeaderOffset = n
+	case "ragged":
+		switch value {
+		case "pad", "error", "skip":
+			d.Ragged = value
+		default:
+			return fmt.Errorf("unknown ragged policy %q, expected pad, error or skip", value)
+		}
+	default:
+		return fmt.Errorf("unknown option %q, expected %s", key, optionNames)
+	}
+	return nil
+}
+
+// parseChar reads a single character option, accepting "tab" and the
+// escapes \t and \\.
+func parseChar(value string) (rune, error) {
+	switch value {
+	case "tab", `\t`:
+		return '\t', nil
+	case `\\`:
+		return '\\', nil
+	case "":
+		return 0, nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || r == utf8.RuneError || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("expected a single character, got %q", value)
+	}
+	return r, nil
+}
+
+// detectDelimiter picks the most frequent candidate in the header line,
+// the first line after the skipped ones that is not blank or a comment.
+func detectDelimiter(head []byte, d Dialect) rune {
+	var line []byte
+	skip := d.HeaderOffset
+	for len(head) > 0 {
+		line, head, _ = bytes.Cut(head, []byte("\n"))
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 || (d.Comment != 0 && bytes.HasPrefix(trimmed, []byte(string(d.Comment)))) {
+			continue
+		}
+		if skip == 0 {
+			break
+		}
+		skip--
+	}
+	delimiters := []rune{',', ';', '\t', '|', ' '}
+	var maxDelimiter rune
+	maxCount := 0
+	for _, delimiter := range delimiters {
+		count := strings.Count(string(line), string(delimiter))
+		if count > maxCount {
+			maxCount = count
+			maxDelimiter = delimiter
+		}
+	}
+	if maxCount == 0 {
+		return ','
+	}
+	return maxDelimiter
+}
+
+// Reader reads rows as objects keyed by the header.
+type Reader struct {
+	d       Dialect
+	r       *csv.Reader
+	headers []string
+	first   []string // the first data row when the header is generated
+}
+
+// NewReader reads the header, skipping the leading rows and BOM.
+func NewReader(in io.Reader, d Dialect) (*Reader, error) {
+	br := bufio.NewReaderSize(in, 64*1024)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+	}
+	if d.Delimiter == 0 {
+		head, _ := br.Peek(64 * 1024)
+		d.Delimiter = detectDelimiter(head, d)
+	}
+	if d.Quote == 0 {
+		d.Quote = '"'
+	}
+	if d.Ragged == "" {
+		d.Ragged = "pad"
+	}
+	if d.Delimiter == d.Quote || (d.Comment != 0 && (d.Comment == d.Delimiter || d.Comment == d.Quote)) {
+		return nil, fmt.Errorf("delimiter, quote and comment characters must differ")
+	}
+
+	var src io.Reader = br
+	if d.Quote != '"' || d.Escape != 0 {
+		src = &dialectReader{r: br, d: d, lineStart: true}
+	}
+	r := csv.NewReader(src)
+	r.Comma = d.Delimiter
+	r.Comment = d.Comment
+	r.TrimLeadingSpace = d.TrimLeadingSpace
+	r.LazyQuotes = d.LazyQuotes
+	r.FieldsPerRecord = -1
+
+	rd := &Reader{d: d, r: r}
+	for i := 0; i < d.HeaderOffset; i++ {
+		if _, err := r.Read(); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("header_offset %d is past the end of the input", d.HeaderOffset)
+			}
+			return nil, err
+		}
+	}
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("no header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %v", err)
+	}
+	if d.NoHeader {
+		rd.first = header
+		header = make([]string, len(header))
+	}
+	rd.headers = columnNames(header)
+	return rd, nil
+}
+
+// Headers returns the column names in order.
+func (rd *Reader) Headers() []string {
+	return rd.headers
+}
+
+// columnNames fills in missing names and makes repeated names unique.
+func columnNames(header []string) []string {
+	names := make([]string, len(header))
+	seen := make(map[string]int)
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			h = fmt.Sprintf("col%d", i+1)
+		}
+		seen[h]++
+		if seen[h] > 1 {
+			h = fmt.Sprintf("%s_%d", h, seen[h])
+		}
+		names[i] = h
+	}
+	return names
+}
+
+// Read returns the next row, or io.EOF.
+func (rd *Reader) Read() (map[string]any, error) {
+	for {
+		var record []string
+		if rd.first != nil {
+			record, rd.first = rd.first, nil
+		} else {
+			var err error
+			if record, err = rd.r.Read(); err != nil {
+				if err == io.EOF {
+					return nil, err
+				}
+				return nil, fmt.Errorf("error reading record: %v", err)
+			}
+		}
+
+		if len(record) != len(rd.headers) {
+			switch rd.d.Ragged {
+			case "skip":
+				continue
+			case "error":
+				line, _ := rd.r.FieldPos(0)
+				return nil, fmt.Errorf("line %d has %d fields, expected %d", line, len(record), len(rd.headers))
+			}
+		}
+
+		row := make(map[string]any, max(len(record), len(rd.headers)))
+		for i, h := range rd.headers {
+			if i < len(record) {
+				row[h] = Infer(record[i])
+			} else {
+				row[h] = ""
+			}
+		}
+		for i := len(rd.headers); i < len(record); i++ {
+			row[fmt.Sprintf("col%d", i+1)] = Infer(record[i])
+		}
+		if rd.d.Keys.OnRead {
+			nested, err := rd.d.Keys.Unflatten(row)
+			if err != nil {
+				return nil, err
+			}
+			var ok bool
+			if row, ok = nested.(map[string]any); !ok {
+				return nil, fmt.Errorf("columns %v do not unflatten to an object", rd.headers)
+			}
+		}
+		return row, nil
+	}
+}
+
+// ReadAll reads every row of input.
+func ReadAll(input []byte, d Dialect) ([]any, error) {
+	rd, err := NewReader(bytes.NewReader(input), d)
+	if err != nil {
+		return nil, err
+	}
+	rows := []any{}
+	for {
+		row, err := rd.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// Infer types a field with util.ParseValue, keeping to the values a JSON
+// round trip produces: numbers are float64 and dates are RFC 3339 strings.
+func Infer(s string) any {
+	switch v := util.ParseValue(s).(type) {
+	case int:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// dialectReader rewrites a custom quote or escape character into RFC 4180
+// quoting, so encoding/csv can read it. Every unquoted field is quoted so
+// that literal '"' characters survive.
+type dialectReader struct {
+	r         *bufio.Reader
+	d         Dialect
+	out       bytes.Buffer
+	lineStart bool
+	state     int
+}
+
+const (
+	fieldStart = iota
+	inUnquoted
+	inQuoted
+	afterQuoted
+	inComment
+)
+
+func (t *dialectReader) Read(p []byte) (int, error) {
+	for t.out.Len() < len(p) {
+		r, _, err := t.r.ReadRune()
+		if err == io.EOF {
+			if t.state == inUnquoted {
+				t.out.WriteByte('"')
+				t.state = fieldStart
+			}
+			if t.out.Len() == 0 {
+				return 0, io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		t.step(r)
+	}
+	return t.out.Read(p)
+}
+
+func (t *dialectReader) step(r rune) {
+	newline := r == '\n' || r == '\r'
+	if t.lineStart && t.d.Comment != 0 && r == t.d.Comment {
+		t.state = inComment
+	}
+	t.lineStart = false
+
+	switch t.state {
+	case inComment:
+		t.out.WriteRune(r)
+		if r == '\n' {
+			t.state, t.lineStart = fieldStart, true
+		}
+	case fieldStart:
+		switch {
+		case t.d.TrimLeadingSpace && (r == ' ' || r == '\t') && r != t.d.Delimiter:
+			// encoding/csv would trim this, but the field is about to be quoted
+		case r == t.d.Quote:
+			t.out.WriteByte('"')
+			t.state = inQuoted
+		case t.d.Escape != 0 && r == t.d.Escape:
+			t.out.WriteByte('"')
+			t.state = inUnquoted
+			if next, _, err := t.r.ReadRune(); err == nil {
+				t.literal(next)
+			}
+		case r == t.d.Delimiter:
+			t.out.WriteRune(r)
+		case newline:
+			t.out.WriteRune(r)
+			t.lineStart = r == '\n'
+		default:
+			t.out.WriteByte('"')
+			t.state = inUnquoted
+			t.literal(r)
+		}
+	case inUnquoted:
+		switch {
+		case t.d.Escape != 0 && r == t.d.Escape:
+			if next, _, err := t.r.ReadRune(); err == nil {
+				t.literal(next)
+			}
+		case r == t.d.Delimiter:
+			t.out.WriteString(`"` + string(r))
+			t.state = fieldStart
+		case newline:
+			t.out.WriteString(`"` + string(r))
+			t.state, t.lineStart = fieldStart, r == '\n'
+		default:
+			t.literal(r)
+		}
+	case inQuoted:
+		switch {
+		case t.d.Escape != 0 && r == t.d.Escape && r != t.d.Quote:
+			if next, _, err := t.r.ReadRune(); err == nil {
+				t.literal(next)
+			}
+		case r == t.d.Quote:
+			if next, _, err := t.r.ReadRune(); err == nil {
+				if next == t.d.Quote {
+					t.literal(r)
+					return
+				}
+				t.r.UnreadRune()
+			}
+			t.out.WriteByte('"')
+			t.state = afterQuoted
+		default:
+			t.literal(r)
+		}
+	case afterQuoted:
+		t.out.WriteRune(r)
+		if r == t.d.Delimiter || newline {
+			t.state, t.lineStart = fieldStart, r == '\n'
+		}
+	}
+}
+
+// literal writes a character of field content, doubling '"'.
+func (t *dialectReader) literal(r rune) {
+	if r == '"' {
+		t.out.WriteString(`""`)
+		return
+	}
+	t.out.WriteRune(r)
+}
@@ -0,0 +1,276 @@
+package csv
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JFryy/qq/internal/flatten"
+)
+
+// Format holds the options for writing delimited text. The quote, escape,
+// delimiter and header settings come from the Dialect.
+type Format struct {
+	// Columns selects and orders the columns; by default every key of every
+	// row is written, sorted.
+	Columns []string
+	// Nested is "json" (default) to write objects and arrays as JSON, or
+	// "flatten" to spread them over columns named by their paths, such as
+	// user.tags[0], as set by the Dialect's Keys.
+	Nested string
+	// Quoting is "minimal" (default) to quote fields only when needed, "all",
+	// "nonnumeric" to quote everything but numbers and booleans, or "none"
+	// to escape special characters with the Dialect's escape character.
+	Quoting string
+	// CRLF ends lines with \r\n.
+	CRLF bool
+}
+
+const optionNames = "delimiter, quote, escape, comment, no_header, header_offset, ragged, separator, index, unflatten, columns, nested, quoting or crlf"
+
+// SetOption implements the writing options.
+func (f *Format) SetOption(key, value string) error {
+	switch key {
+	case "columns":
+		f.Columns = nil
+		for _, col := range strings.Split(value, ",") {
+			if col = strings.TrimSpace(col); col != "" {
+				f.Columns = append(f.Columns, col)
+			}
+		}
+	case "nested":
+		if value != "json" && value != "flatten" {
+			return fmt.Errorf("unknown nested mode %q, expected json or flatten", value)
+		}
+		f.Nested = value
+	case "quoting":
+		switch value {
+		case "minimal", "all", "nonnumeric", "none":
+			f.Quoting = value
+		default:
+			return fmt.Errorf("unknown quoting %q, expected minimal, all, nonnumeric or none", value)
+		}
+	case "crlf":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("crlf must be true or false")
+		}
+		f.CRLF = b
+	default:
+		return fmt.Errorf("unknown option %q, expected %s", key, optionNames)
+	}
+	return nil
+}
+
+func isFormatOption(key string) bool {
+	switch key {
+	case "columns", "nested", "quoting", "crlf":
+		return true
+	}
+	return false
+}
+
+// Options holds the reading and writing options of the csv and tsv codecs.
+type Options struct {
+	Dialect
+	Format
+}
+
+// SetOption implements codec.Configurable for the reading and writing
+// options.
+func (o *Options) SetOption(key, value string) error {
+	if isFormatOption(key) {
+		return o.Format.SetOption(key, value)
+	}
+	if flatten.IsOption(key) {
+		return o.Keys.SetOption(key, value)
+	}
+	return o.Dialect.SetOption(key, value)
+}
+
+// Write writes rows as delimited text. v is an array of objects, a single
+// object, or an array of arrays which are written as rows without a header.
+func Write(v any, d Dialect, f Format) ([]byte, error) {
+	if d.Delimiter == 0 {
+		d.Delimiter = ','
+	}
+	if d.Quote == 0 {
+		d.Quote = '"'
+	}
+	w := &writer{d: d, f: f}
+	if w.f.CRLF {
+		w.eol = "\r\n"
+	} else {
+		w.eol = "\n"
+	}
+
+	var items []any
+	switch v := v.(type) {
+	case []any:
+		items = v
+	case []map[string]any:
+		for _, m := range v {
+			items = append(items, m)
+		}
+	case map[string]any:
+		items = []any{v}
+	default:
+		return nil, fmt.Errorf("input data must be an array of objects, got %T", v)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no data to write")
+	}
+
+	if _, ok := items[0].([]any); ok {
+		for i, item := range items {
+			row, ok := item.([]any)
+			if !ok {
+				return nil, fmt.Errorf("row %d is not an array", i)
+			}
+			if err := w.row(row); err != nil {
+				return nil, err
+			}
+		}
+		return w.buf.Bytes(), nil
+	}
+
+	rows := make([]map[string]any, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("row %d is not an object", i)
+		}
+		if f.Nested == "flatten" {
+			var err error
+			if m, err = d.Keys.Map(m); err != nil {
+				return nil, fmt.Errorf("row %d: %v", i, err)
+			}
+		}
+		rows[i] = m
+	}
+
+	columns := f.Columns
+	if len(columns) == 0 {
+		seen := make(map[string]bool)
+		for _, m := range rows {
+			for k := range m {
+				if !seen[k] {
+					seen[k] = true
+					columns = append(columns, k)
+				}
+			}
+		}
+		slices.Sort(columns)
+	}
+
+	if !d.NoHeader {
+		header := make([]any, len(columns))
+		for i, col := range columns {
+			header[i] = col
+		}
+		if err := w.row(header); err != nil {
+			return nil, err
+		}
+	}
+	row := make([]any, len(columns))
+	for _, m := range rows {
+		for i, col := range columns {
+			row[i] = m[col]
+		}
+		if err := w.row(row); err != nil {
+			return nil, err
+		}
+	}
+	return w.buf.Bytes(), nil
+}
+
+type writer struct {
+	d   Dialect
+	f   Format
+	eol string
+	buf bytes.Buffer
+}
+
+func (w *writer) row(values []any) error {
+	for i, v := range values {
+		if i > 0 {
+			w.buf.WriteRune(w.d.Delimiter)
+		}
+		if err := w.field(v); err != nil {
+			return err
+		}
+	}
+	w.buf.WriteString(w.eol)
+	return nil
+}
+
+func (w *writer) field(v any) error {
+	s := flatten.String(v)
+	_, numeric := v.(float64)
+	switch v.(type) {
+	case int, int64, bool:
+		numeric = true
+	}
+
+	switch w.f.Quoting {
+	case "all":
+		w.quoted(s)
+	case "nonnumeric":
+		if numeric {
+			w.buf.WriteString(s)
+		} else {
+			w.quoted(s)
+		}
+	case "none":
+		for _, r := range s {
+			if r == w.d.Delimiter || r == w.d.Quote || r == '\n' || r == '\r' || (w.d.Escape != 0 && r == w.d.Escape) {
+				if w.d.Escape == 0 {
+					return fmt.Errorf("field %q needs quoting, set an escape character to write it with quoting=none", s)
+				}
+				w.buf.WriteRune(w.d.Escape)
+			}
+			w.buf.WriteRune(r)
+		}
+	default:
+		if w.needsQuotes(s) {
+			w.quoted(s)
+		} else {
+			w.buf.WriteString(s)
+		}
+	}
+	return nil
+}
+
+// needsQuotes follows encoding/csv: quote fields holding the delimiter, a
+// quote, a line break or leading space, and a lone \. which some readers
+// take as an end-of-data marker.
+func (w *writer) needsQuotes(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s == `\.` || s[0] == ' ' || s[0] == '\t' {
+		return true
+	}
+	if w.d.Comment != 0 && strings.HasPrefix(s, string(w.d.Comment)) {
+		return true
+	}
+	return strings.ContainsRune(s, w.d.Delimiter) || strings.ContainsRune(s, w.d.Quote) ||
+		strings.ContainsAny(s, "\r\n") || (w.d.Escape != 0 && strings.ContainsRune(s, w.d.Escape))
+}
+
+func (w *writer) quoted(s string) {
+	w.buf.WriteRune(w.d.Quote)
+	for _, r := range s {
+		if r == w.d.Quote || (w.d.Escape != 0 && r == w.d.Escape) {
+			if w.d.Escape != 0 {
+				w.buf.WriteRune(w.d.Escape)
+			} else {
+				w.buf.WriteRune(w.d.Quote)
+			}
+		}
+		w.buf.WriteRune(r)
+	}
+	w.buf.WriteRune(w.d.Quote)
+}
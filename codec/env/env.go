@@ -3,14 +3,61 @@ package env
 import (
 	"errors"
 	"fmt"
-	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/JFryy/qq/internal/flatten"
 	"github.com/goccy/go-json"
 )
 
-// Codec handles environment file parsing and marshaling
-type Codec struct{}
+// Codec handles environment file parsing and marshaling with the dotenv
+// semantics of docker compose. Nested values are written as flat keys
+// joined by Keys, DB__HOSTS__0 by default.
+type Codec struct {
+	// NoInterpolate reads $VAR and ${VAR} references literally.
+	NoInterpolate bool
+	// Environ resolves references to variables the file does not define
+	// from the process environment.
+	Environ bool
+	// Export writes each variable as "export KEY=value".
+	Export bool
+	Keys   flatten.Options
+}
+
+// SetOption implements codec.Configurable for the env options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "interpolate", "environ", "export":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		switch key {
+		case "interpolate":
+			c.NoInterpolate = !b
+		case "environ":
+			c.Environ = b
+		case "export":
+			c.Export = b
+		}
+		return nil
+	}
+	if flatten.IsOption(key) {
+		return c.Keys.SetOption(key, value)
+	}
+	return fmt.Errorf("unknown option %q, expected interpolate, environ, export, separator, index or unflatten", key)
+}
+
+func (c *Codec) keys() flatten.Options {
+	k := c.Keys
+	if k.Separator == "" {
+		k.Separator = "__"
+	}
+	if k.Index == "" {
+		k.Index = "separator"
+	}
+	return k
+}
 
 // Unmarshal parses environment file data into the provided interface
 func (c *Codec) Unmarshal(data []byte, v interface{}) error {
@@ -18,12 +65,29 @@ func (c *Codec) Unmarshal(data []byte, v interface{}) error {
 		return errors.New("v cannot be nil")
 	}
 
-	result, err := c.Parse(string(data))
+	p, err := c.parse(string(data))
 	if err != nil {
 		return err
 	}
 
-	jsonData, err := json.Marshal(result)
+	var value any = p.vars
+	if c.Keys.OnRead {
+		// quoted values are strings, as formatValue writes those that
+		// would read as another type
+		flat := make(map[string]any, len(p.vars))
+		for k, v := range p.vars {
+			if p.quoted[k] {
+				flat[k] = v
+			} else {
+				flat[k] = flatten.Infer(v)
+			}
+		}
+		if value, err = c.keys().Unflatten(flat); err != nil {
+			return err
+		}
+	}
+
+	jsonData, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
@@ -31,7 +95,8 @@ func (c *Codec) Unmarshal(data []byte, v interface{}) error {
 	return json.Unmarshal(jsonData, v)
 }
 
-// Marshal converts data back to environment file format
+// Marshal converts data back to environment file format, flattening
+// nested values and quoting values that need it
 func (c *Codec) Marshal(v interface{}) ([]byte, error) {
 	// Convert to our expected format first
 	data, err := json.Marshal(v)
@@ -39,128 +104,40 @@ func (c *Codec) Marshal(v interface{}) ([]byte, error) {
 		return nil, err
 	}
 
-	var envVars map[string]string
-	if err := json.Unmarshal(data, &envVars); err != nil {
-		return nil, errors.New("env format only supports simple key-value pairs, cannot convert complex nested structures")
-	}
-
-	var lines []string
-	for key, value := range envVars {
-		lines = append(lines, fmt.Sprintf("%s=%s", key, c.formatValue(value)))
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
 	}
 
-	return []byte(strings.Join(lines, "\n")), nil
-}
-
-// Parse processes environment file content into simple key-value pairs
-func (c *Codec) Parse(content string) (map[string]string, error) {
-	result := make(map[string]string)
-	lines := strings.Split(content, "\n")
-
-	// Pattern for parsing variable assignments
-	varPattern := regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Parse variable assignment
-		if matches := varPattern.FindStringSubmatch(line); matches != nil {
-			key := matches[1]
-			valueWithComment := matches[2]
-
-			// Extract just the value, ignoring comments
-			value := c.extractValue(valueWithComment)
-			result[key] = value
-		}
+	// Entries come in sorted key order
+	entries, err := c.keys().Flatten(value)
+	if err != nil {
+		return nil, err
 	}
-
-	return result, nil
-}
-
-// extractValue extracts the value part from a line, handling quotes and ignoring comments
-func (c *Codec) extractValue(input string) string {
-	input = strings.TrimSpace(input)
-
-	// Handle quoted strings first
-	if strings.HasPrefix(input, `"`) {
-		// Find the closing quote, handling escaped quotes
-		escaped := false
-		for i := 1; i < len(input); i++ {
-			if escaped {
-				escaped = false
-				continue
-			}
-			if input[i] == '\\' {
-				escaped = true
-				continue
-			}
-			if input[i] == '"' {
-				// Found end quote, remove outer quotes and handle escapes
-				unquoted := input[1:i]
-				// Handle specific escape sequences only
-				// Use a more careful approach to avoid double-processing
-				result := ""
-				for j := 0; j < len(unquoted); j++ {
-					if j < len(unquoted)-1 && unquoted[j] == '\\' {
-						switch unquoted[j+1] {
-						case '"':
-							result += `"`
-							j++ // skip next char
-						case 'n':
-							result += "\n"
-							j++ // skip next char
-						case 't':
-							result += "\t"
-							j++ // skip next char
-						case '\\':
-							result += `\`
-							j++ // skip next char
-						default:
-							result += string(unquoted[j])
-						}
-					} else {
-						result += string(unquoted[j])
-					}
-				}
-				return result
-			}
-		}
-		// No closing quote found, return as is without outer quotes
-		return input[1:]
+	if len(entries) == 1 && entries[0].Key == "" {
+		return nil, errors.New("env format needs an object of key-value pairs")
 	}
 
-	if strings.HasPrefix(input, "'") {
-		// Similar logic for single quotes (no escape processing)
-		for i := 1; i < len(input); i++ {
-			if input[i] == '\'' {
-				return input[1:i]
-			}
+	var lines []string
+	for _, e := range entries {
+		line := fmt.Sprintf("%s=%s", e.Key, c.formatValue(e.Value))
+		if c.Export {
+			line = "export " + line
 		}
-		return input[1:]
+		lines = append(lines, line)
 	}
 
-	// Unquoted value - look for comment and strip it
-	if idx := strings.Index(input, "#"); idx != -1 {
-		return strings.TrimSpace(input[:idx])
-	}
-
-	return input
+	return []byte(strings.Join(lines, "\n")), nil
 }
 
-// formatValue converts a string value back to env file format
-func (c *Codec) formatValue(value string) string {
-	// Quote if contains spaces or special characters
-	if strings.ContainsAny(value, " \t\n#=") || value == "" {
-		escaped := strings.ReplaceAll(value, `\`, `\\`)
-		escaped = strings.ReplaceAll(escaped, `"`, `\"`)
-		escaped = strings.ReplaceAll(escaped, "\n", `\n`)
-		escaped = strings.ReplaceAll(escaped, "\t", `\t`)
-		return fmt.Sprintf(`"%s"`, escaped)
+// formatValue writes a value so that it reads back unchanged: anything but
+// plain words is double quoted, with $ escaped so it is not interpolated,
+// and so are strings that unflattening would read as another type
+func (c *Codec) formatValue(v any) string {
+	value := flatten.String(v)
+	if value != "" && plainValue.MatchString(value) && !flatten.Retyped(v) {
+		return value
 	}
-	return value
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
 }
@@ -1,6 +1,8 @@
 package env
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/goccy/go-json"
@@ -54,7 +56,8 @@ export PATH="/usr/local/bin:$PATH"`
 	}
 
 	// Test export (simplified - we just get the value)
-	if result["PATH"] != "/usr/local/bin:$PATH" {
+	// unset variables expand to nothing, as in docker compose
+	if result["PATH"] != "/usr/local/bin:" {
 		t.Errorf("Expected PATH value, got %v", result["PATH"])
 	}
 }
@@ -238,3 +241,187 @@ TIMEOUT=30.5`
 		t.Error("JSON round-trip failed for PORT")
 	}
 }
+
+func TestNestedMarshaling(t *testing.T) {
+	original := map[string]any{
+		"DB":    map[string]any{"HOSTS": []any{"a", "b"}, "PORT": float64(5432)},
+		"DEBUG": true,
+	}
+
+	codec := Codec{}
+	data, err := codec.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to marshal nested env: %v", err)
+	}
+	expected := "DB__HOSTS__0=a\nDB__HOSTS__1=b\nDB__PORT=5432\nDEBUG=true"
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, data)
+	}
+
+	codec.Keys.OnRead = true
+	var result map[string]any
+	if err := codec.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to unmarshal with unflatten: %v", err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf("Round trip mismatch: %v", result)
+	}
+
+	if _, err := codec.Marshal("scalar"); err == nil {
+		t.Error("Expected an error for a scalar")
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	envContent := `DB_HOST=localhost
+DB_PORT = 5432
+DB_URL="postgres://${DB_HOST}:$DB_PORT/app"
+LITERAL='${DB_HOST}'
+ESCAPED="\${DB_HOST}"
+FALLBACK=${MISSING:-${DB_HOST}-fallback}
+EMPTY=
+NONEMPTY=${EMPTY:-x}
+SET=${EMPTY-x}
+ALT=${DB_HOST:+alt}
+UNKNOWN=$NOT_DEFINED_HERE/bin
+FROM_ENV=${QQ_ENV_TEST}
+UNSET="[${NOT_DEFINED_HERE}]"
+DEFAULT=${NOT_DEFINED_HERE-d}
+EMPTY_DEFAULT=${NOT_DEFINED_HERE:-d}
+NO_ALT=${NOT_DEFINED_HERE+alt}`
+
+	t.Setenv("QQ_ENV_TEST", "from-process")
+	codec := Codec{}
+	result, err := codec.Parse(envContent)
+	if err != nil {
+		t.Fatalf("Failed to parse env: %v", err)
+	}
+	expected := map[string]string{
+		"DB_HOST":       "localhost",
+		"DB_PORT":       "5432",
+		"DB_URL":        "postgres://localhost:5432/app",
+		"LITERAL":       "${DB_HOST}",
+		"ESCAPED":       "${DB_HOST}",
+		"FALLBACK":      "localhost-fallback",
+		"EMPTY":         "",
+		"NONEMPTY":      "x",
+		"SET":           "",
+		"ALT":           "alt",
+		"UNKNOWN":       "/bin",
+		"FROM_ENV":      "",
+		"UNSET":         "[]",
+		"DEFAULT":       "d",
+		"EMPTY_DEFAULT": "d",
+		"NO_ALT":        "",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	codec.Environ = true
+	result, err = codec.Parse(envContent)
+	if err != nil {
+		t.Fatalf("Failed to parse env: %v", err)
+	}
+	if result["FROM_ENV"] != "from-process" {
+		t.Errorf("Expected the process environment, got %q", result["FROM_ENV"])
+	}
+
+	codec = Codec{NoInterpolate: true}
+	result, _ = codec.Parse(envContent)
+	if result["DB_URL"] != "postgres://${DB_HOST}:$DB_PORT/app" {
+		t.Errorf("Expected no interpolation, got %q", result["DB_URL"])
+	}
+
+	if _, err := (&Codec{}).Parse("A=${B:?B is required}"); err == nil || err.Error() != "line 1: A: B: B is required" {
+		t.Errorf("Expected a required variable error, got %v", err)
+	}
+}
+
+func TestMultilineValues(t *testing.T) {
+	envContent := "# key\nPRIVATE_KEY=\"-----BEGIN KEY-----\nabc\\\"def\n-----END KEY-----\"\r\nSINGLE='a\nb' # comment\nNEXT=1"
+
+	codec := Codec{}
+	result, err := codec.Parse(envContent)
+	if err != nil {
+		t.Fatalf("Failed to parse env: %v", err)
+	}
+	if result["PRIVATE_KEY"] != "-----BEGIN KEY-----\nabc\"def\n-----END KEY-----" {
+		t.Errorf("Unexpected PRIVATE_KEY %q", result["PRIVATE_KEY"])
+	}
+	if result["SINGLE"] != "a\nb" || result["NEXT"] != "1" {
+		t.Errorf("Unexpected values %v", result)
+	}
+
+	if _, err := codec.Parse("OK=1\nBAD=\"open\nNEXT=1"); err == nil {
+		t.Error("Expected an error for an unterminated quote")
+	}
+}
+
+func TestExportOutput(t *testing.T) {
+	original := map[string]any{
+		"PLAIN":  "value",
+		"SPACED": "a b",
+		"DOLLAR": "$HOME",
+		"QUOTES": `say "hi"`,
+		"LINES":  "a\nb",
+		"EMPTY":  "",
+	}
+
+	codec := Codec{Export: true}
+	data, err := codec.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to marshal env: %v", err)
+	}
+	expected := `export DOLLAR="\$HOME"
+export EMPTY=""
+export LINES="a\nb"
+export PLAIN=value
+export QUOTES="say \"hi\""
+export SPACED="a b"`
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, data)
+	}
+
+	var result map[string]any
+	if err := codec.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to unmarshal env: %v", err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf("Round trip mismatch: %v", result)
+	}
+}
+
+func TestUnflattenKeepsStrings(t *testing.T) {
+	original := map[string]any{
+		"APP": map[string]any{"VERSION": "1.0", "ZIP": "007", "FLAG": "True", "PORT": float64(8080), "NONE": nil,
+			"ON": "true", "COUNT": "1"},
+	}
+
+	codec := Codec{}
+	data, err := codec.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	codec.Keys.OnRead = true
+	var result map[string]any
+	if err := codec.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to unmarshal with unflatten: %v", err)
+	}
+	// null has no form of its own in an env file and reads back empty
+	expected := map[string]any{
+		"APP": map[string]any{"VERSION": "1.0", "ZIP": "007", "FLAG": "True", "PORT": float64(8080), "NONE": "",
+			"ON": "true", "COUNT": "1"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Round trip mismatch: %v", result)
+	}
+	if !strings.Contains(string(data), `APP__ON="true"`) {
+		t.Errorf("expected the string true to be quoted:\n%s", data)
+	}
+
+	_, err = codec.Marshal(map[string]any{"A__B": 1, "A": map[string]any{"B": 2}})
+	if err == nil || !strings.Contains(err.Error(), `"A__B"`) {
+		t.Errorf("expected an error naming the key A__B, got %v", err)
+	}
+}
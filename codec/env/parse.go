@@ -0,0 +1,279 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	plainValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]+$`)
+)
+
+// parser reads a dotenv file. Quoted values may span several lines, so the
+// input is read as a whole rather than line by line.
+type parser struct {
+	c    *Codec
+	src  string
+	pos  int
+	vars map[string]string
+	// quoted holds the variables whose values were quoted, which are
+	// strings however they read
+	quoted map[string]bool
+}
+
+// Parse processes environment file content into key-value pairs, expanding
+// references to variables defined earlier in the file
+func (c *Codec) Parse(content string) (map[string]string, error) {
+	p, err := c.parse(content)
+	if err != nil {
+		return nil, err
+	}
+	return p.vars, nil
+}
+
+func (c *Codec) parse(content string) (*parser, error) {
+	p := &parser{c: c, src: strings.ReplaceAll(content, "\r\n", "\n"), vars: make(map[string]string), quoted: make(map[string]bool)}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.src) {
+			return p, nil
+		}
+		line := p.line()
+		if p.src[p.pos] == '#' {
+			p.skipLine()
+			continue
+		}
+
+		stmt := p.src[p.pos:]
+		if rest, ok := strings.CutPrefix(stmt, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			p.pos += len("export")
+			p.skipSpace()
+		}
+		eq := strings.IndexAny(p.src[p.pos:], "=\n")
+		if eq < 0 || p.src[p.pos+eq] != '=' {
+			// not an assignment
+			p.skipLine()
+			continue
+		}
+		key := strings.TrimSpace(p.src[p.pos : p.pos+eq])
+		p.pos += eq + 1
+		if !keyPattern.MatchString(key) {
+			p.skipLine()
+			continue
+		}
+
+		p.skipSpace()
+		p.quoted[key] = p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'')
+		value, err := p.value()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", line, key, err)
+		}
+		p.vars[key] = value
+	}
+}
+
+func (p *parser) skipBlank() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) skipLine() {
+	if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+		p.pos += end + 1
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+func (p *parser) line() int {
+	return strings.Count(p.src[:p.pos], "\n") + 1
+}
+
+// value reads the value after the =: single quoted values are literal,
+// double quoted ones have escapes and references expanded and unquoted
+// ones end at the line or at a # after whitespace.
+func (p *parser) value() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+		q := p.src[p.pos]
+		end := p.pos + 1
+		for ; end < len(p.src) && p.src[end] != q; end++ {
+			if q == '"' && p.src[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(p.src) {
+			return "", fmt.Errorf("unterminated %c quoted value", q)
+		}
+		raw := p.src[p.pos+1 : end]
+		p.pos = end + 1
+		p.skipLine() // anything after the closing quote is a comment
+		if q == '\'' {
+			return raw, nil
+		}
+		return p.expand(raw, true)
+	}
+
+	start := p.pos
+	p.skipLine()
+	raw := strings.TrimRight(p.src[start:p.pos], "\n")
+	for i := 1; i < len(raw); i++ {
+		if raw[i] == '#' && (raw[i-1] == ' ' || raw[i-1] == '\t') {
+			raw = raw[:i]
+			break
+		}
+	}
+	return p.expand(strings.TrimSpace(raw), false)
+}
+
+// expand replaces $VAR and ${VAR} references, with the ${VAR:-default},
+// ${VAR-default}, ${VAR:?error}, ${VAR?error}, ${VAR:+alt} and ${VAR+alt}
+// forms of docker compose. As there, references to unset variables expand
+// to nothing. With escapes, backslash escapes of double quoted values are handled too, so
+// \$ is a literal $.
+func (p *parser) expand(s string, escapes bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escapes && c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '"', '$':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		case c == '$' && !p.c.NoInterpolate && i+1 < len(s):
+			n, err := p.reference(s[i:], &b, escapes)
+			if err != nil {
+				return "", err
+			}
+			i += n - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// reference expands the reference at the start of s into b and returns
+// its length.
+func (p *parser) reference(s string, b *strings.Builder, escapes bool) (int, error) {
+	if s[1] != '{' {
+		n := 1
+		for n < len(s) && isNameChar(s[n], n == 1) {
+			n++
+		}
+		if n == 1 {
+			b.WriteByte('$')
+			return 1, nil
+		}
+		v, _ := p.lookup(s[1:n])
+		b.WriteString(v)
+		return n, nil
+	}
+
+	// find the closing brace, allowing nested references in the word
+	depth, end := 0, -1
+	for i := 1; i < len(s) && end < 0; i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated reference %q", s)
+	}
+	body := s[2:end]
+	name := body
+	op, word := "", ""
+	for i := 0; i < len(body); i++ {
+		if !isNameChar(body[i], i == 0) {
+			name, op = body[:i], body[i:]
+			break
+		}
+	}
+	if name == "" {
+		return 0, fmt.Errorf("invalid reference %q", s[:end+1])
+	}
+	if op != "" {
+		known := false
+		for _, o := range []string{":-", ":?", ":+", "-", "?", "+"} {
+			if strings.HasPrefix(op, o) {
+				op, word, known = o, op[len(o):], true
+				break
+			}
+		}
+		if !known {
+			return 0, fmt.Errorf("invalid reference %q", s[:end+1])
+		}
+	}
+
+	v, set := p.lookup(name)
+	useWord := false
+	switch op {
+	case ":-":
+		useWord = !set || v == ""
+	case "-":
+		useWord = !set
+	case ":+":
+		useWord, v = set && v != "", ""
+	case "+":
+		useWord, v = set, ""
+	case ":?", "?":
+		if !set || (op == ":?" && v == "") {
+			msg := word
+			if msg == "" {
+				msg = "not set"
+			}
+			return 0, fmt.Errorf("%s: %s", name, msg)
+		}
+	}
+	if useWord {
+		expanded, err := p.expand(word, escapes)
+		if err != nil {
+			return 0, err
+		}
+		v = expanded
+	}
+	b.WriteString(v)
+	return end + 1, nil
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (!first && c >= '0' && c <= '9')
+}
+
+// lookup finds a variable defined earlier in the file or, with Environ, in
+// the process environment.
+func (p *parser) lookup(name string) (string, bool) {
+	if v, ok := p.vars[name]; ok {
+		return v, true
+	}
+	if p.c.Environ {
+		return os.LookupEnv(name)
+	}
+	return "", false
+}
@@ -1,6 +1,7 @@
 package gron
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -33,3 +34,95 @@ func TestBasicGronMarshalUnmarshal(t *testing.T) {
 		t.Errorf("Expected name 'test', got %v", result["name"])
 	}
 }
+
+func TestMarshalMatchesGron(t *testing.T) {
+	input := map[string]any{
+		"b":     []any{1, map[string]any{"x": nil}},
+		"a b":   "x = y\";",
+		"a.c":   map[string]any{"z": true},
+		"class": 1.5,
+		"$d":    map[string]any{},
+		"s":     "tab\t<é>\u2028",
+	}
+	want := `json = {};
+json.$d = {};
+json.b = [];
+json.b[0] = 1;
+json.b[1] = {};
+json.b[1].x = null;
+json.s = "tab\t<é>\u2028";
+json["a b"] = "x = y\";";
+json["a.c"] = {};
+json["a.c"].z = true;
+json["class"] = 1.5;`
+
+	got, err := (&Codec{}).Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	var back map[string]any
+	if err := (&Codec{}).Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back["a b"] != "x = y\";" || back["s"] != "tab\t<é>\u2028" {
+		t.Errorf("strings did not round trip: %v", back)
+	}
+	if _, ok := back["a.c"].(map[string]any)["z"]; !ok {
+		t.Errorf("quoted key with a dot did not round trip: %v", back)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  any
+	}{
+		{"any order", "json.a[1] = 2;\njson.a[0] = 1;\njson = {};\n", map[string]any{"a": []any{1.0, 2.0}}},
+		{"root array", "json = [];\njson[0][\"k\"] = \"v\";", []any{map[string]any{"k": "v"}}},
+		{"root scalar", `json = "x";`, "x"},
+		{"without root", "a.b = true\n[\"c\"] = null", map[string]any{"a": map[string]any{"b": true}, "c": nil}},
+		{"spacing", "  json . a [ 0 ]=1 ;  ", map[string]any{"a": []any{1.0}}},
+	}
+	for _, tt := range tests {
+		var got any
+		if err := (&Codec{}).Unmarshal([]byte(tt.input), &got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{
+		"json.a = 1;\njson.a.b = 2;",
+		`json["a] = 1;`,
+		"json.a = ;",
+		"json.a = {\"b\": 1};",
+		"json.a == 1;",
+	} {
+		var got any
+		if err := (&Codec{}).Unmarshal([]byte(input), &got); err == nil {
+			t.Errorf("%q: expected an error, got %v", input, got)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	c := &Codec{}
+	if err := c.SetOption("values", "true"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Marshal(map[string]any{"a": []any{"x y", 2}, "b": map[string]any{"c": nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "x y\n2\nnull"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
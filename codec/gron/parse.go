@@ -0,0 +1,246 @@
+package gron
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+)
+
+// Statement is one gron assignment: the path from the root, made of string
+// keys and int indices, and the value assigned to it. Containers are
+// declared with an empty map or slice before their children.
+type Statement struct {
+	Path  []any
+	Value any
+}
+
+// ParseStatement reads one line of gron, such as json.a["b.c"][0] = "x";.
+// It returns false for blank lines. The root is named json; lines written
+// without it, as in a.b = 1 or [0].a = 1, are read relative to the root.
+func ParseStatement(line string) (Statement, bool, error) {
+	s := &scanner{src: strings.TrimSpace(line)}
+	if s.src == "" {
+		return Statement{}, false, nil
+	}
+	var st Statement
+	if s.peek() != '[' {
+		name := s.ident()
+		if name == "" {
+			return st, false, s.errorf("expected a path")
+		}
+		if name != "json" {
+			st.Path = append(st.Path, name)
+		}
+	}
+	for {
+		s.space()
+		switch s.peek() {
+		case '.':
+			s.pos++
+			s.space()
+			name := s.ident()
+			if name == "" {
+				return st, false, s.errorf("expected a key after .")
+			}
+			st.Path = append(st.Path, name)
+		case '[':
+			s.pos++
+			s.space()
+			key, err := s.subscript()
+			if err != nil {
+				return st, false, err
+			}
+			s.space()
+			if s.peek() != ']' {
+				return st, false, s.errorf("expected ]")
+			}
+			s.pos++
+			st.Path = append(st.Path, key)
+		case '=':
+			s.pos++
+			value, err := parseValue(s.src[s.pos:])
+			if err != nil {
+				return st, false, err
+			}
+			st.Value = value
+			return st, true, nil
+		default:
+			return st, false, s.errorf("expected ., [ or =")
+		}
+	}
+}
+
+// parseValue reads the JSON value after the =, with an optional ;.
+func parseValue(src string) (any, error) {
+	src = strings.TrimSpace(src)
+	src = strings.TrimSpace(strings.TrimSuffix(src, ";"))
+	switch src {
+	case "":
+		return nil, fmt.Errorf("missing value")
+	case "{}":
+		return map[string]any{}, nil
+	case "[]":
+		return []any{}, nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(src), &v); err != nil {
+		return nil, fmt.Errorf("invalid value %s", src)
+	}
+	switch v.(type) {
+	case map[string]any, []any:
+		return nil, fmt.Errorf("invalid value %s: only empty objects and arrays can be assigned", src)
+	}
+	return v, nil
+}
+
+type scanner struct {
+	src string
+	pos int
+}
+
+func (s *scanner) peek() byte {
+	if s.pos < len(s.src) {
+		return s.src[s.pos]
+	}
+	return 0
+}
+
+func (s *scanner) space() {
+	for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+		s.pos++
+	}
+}
+
+func (s *scanner) errorf(format string, args ...any) error {
+	return fmt.Errorf("column %d: %s", s.pos+1, fmt.Sprintf(format, args...))
+}
+
+// ident reads a bare key, which follows the identifier rules of JavaScript.
+func (s *scanner) ident() string {
+	start := s.pos
+	for s.pos < len(s.src) {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if !identRune(r, s.pos == start) {
+			break
+		}
+		s.pos += size
+	}
+	return s.src[start:s.pos]
+}
+
+// subscript reads an array index or a quoted key inside brackets.
+func (s *scanner) subscript() (any, error) {
+	if s.peek() == '"' {
+		end := s.pos + 1
+		for ; end < len(s.src) && s.src[end] != '"'; end++ {
+			if s.src[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s.src) {
+			return nil, s.errorf("unterminated key")
+		}
+		var key string
+		if err := json.Unmarshal([]byte(s.src[s.pos:end+1]), &key); err != nil {
+			return nil, s.errorf("invalid key %s", s.src[s.pos:end+1])
+		}
+		s.pos = end + 1
+		return key, nil
+	}
+	start := s.pos
+	for s.pos < len(s.src) && s.src[s.pos] >= '0' && s.src[s.pos] <= '9' {
+		s.pos++
+	}
+	index, err := strconv.Atoi(s.src[start:s.pos])
+	if err != nil {
+		return nil, s.errorf("expected an index or a quoted key")
+	}
+	return index, nil
+}
+
+func identRune(r rune, first bool) bool {
+	if r == '$' || r == '_' || unicode.In(r, unicode.Lu, unicode.Ll, unicode.Lm, unicode.Lo, unicode.Nl) {
+		return true
+	}
+	return !first && unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc)
+}
+
+// assign returns v with value assigned at path, creating the containers
+// on the way. Statements may come in any order.
+func assign(v any, path []any, value any, done []any) (any, error) {
+	if len(path) == 0 {
+		return merge(v, value), nil
+	}
+	if v == nil {
+		v = container(path[0])
+	}
+	done = append(done[:len(done):len(done)], path[0])
+	switch c := v.(type) {
+	case map[string]any:
+		k, ok := path[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is an object", pathString(done), pathString(done[:len(done)-1]))
+		}
+		child, err := assign(c[k], path[1:], value, done)
+		if err != nil {
+			return nil, err
+		}
+		c[k] = child
+		return c, nil
+	case []any:
+		idx, ok := path[0].(int)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is an array", pathString(done), pathString(done[:len(done)-1]))
+		}
+		for len(c) <= idx {
+			c = append(c, nil)
+		}
+		child, err := assign(c[idx], path[1:], value, done)
+		if err != nil {
+			return nil, err
+		}
+		c[idx] = child
+		return c, nil
+	default:
+		return nil, fmt.Errorf("%s: %s is not an object or array", pathString(done), pathString(done[:len(done)-1]))
+	}
+}
+
+// container makes the container that key indexes into.
+func container(key any) any {
+	if _, ok := key.(int); ok {
+		return []any{}
+	}
+	return map[string]any{}
+}
+
+// merge assigns value over old. Declaring a container that already holds
+// values keeps them.
+func merge(old, value any) any {
+	switch value.(type) {
+	case map[string]any:
+		if _, ok := old.(map[string]any); ok {
+			return old
+		}
+	case []any:
+		if _, ok := old.([]any); ok {
+			return old
+		}
+	}
+	return value
+}
+
+// pathString writes a path back in gron syntax.
+func pathString(path []any) string {
+	var b bytes.Buffer
+	b.WriteString("json")
+	for _, key := range path {
+		writeKey(&b, key)
+	}
+	return b.String()
+}
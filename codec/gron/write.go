@@ -0,0 +1,166 @@
+package gron
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode"
+
+	"github.com/goccy/go-json"
+)
+
+// write writes v as gron statements in the order gron sorts them: every
+// container is declared before its children, bare keys come before quoted
+// ones and array elements are in index order.
+func (c *Codec) write(buf *bytes.Buffer, path *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case map[string]any:
+		c.statement(buf, path.String(), "{}")
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			bi, bj := validIdentifier(keys[i]), validIdentifier(keys[j])
+			if bi != bj {
+				return bi
+			}
+			if bi {
+				return keys[i] < keys[j]
+			}
+			return quoteString(keys[i]) < quoteString(keys[j])
+		})
+		for _, k := range keys {
+			n := path.Len()
+			writeKey(path, k)
+			if err := c.write(buf, path, val[k]); err != nil {
+				return err
+			}
+			path.Truncate(n)
+		}
+	case []any:
+		c.statement(buf, path.String(), "[]")
+		for i, item := range val {
+			n := path.Len()
+			writeKey(path, i)
+			if err := c.write(buf, path, item); err != nil {
+				return err
+			}
+			path.Truncate(n)
+		}
+	default:
+		value, err := formatValue(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if c.Values {
+			// strings without their quotes, as gron --values prints them
+			if s, ok := v.(string); ok {
+				value = s
+			}
+			buf.WriteString(value + "\n")
+			return nil
+		}
+		c.statement(buf, path.String(), value)
+	}
+	return nil
+}
+
+func (c *Codec) statement(buf *bytes.Buffer, path, value string) {
+	if !c.Values {
+		buf.WriteString(path + " = " + value + ";\n")
+	}
+}
+
+// writeKey appends .key for keys that are valid identifiers, ["key"] for
+// other keys and [i] for indices.
+func writeKey(b *bytes.Buffer, key any) {
+	switch k := key.(type) {
+	case int:
+		b.WriteString("[" + strconv.Itoa(k) + "]")
+	case string:
+		if validIdentifier(k) {
+			b.WriteString("." + k)
+		} else {
+			b.WriteString("[" + quoteString(k) + "]")
+		}
+	}
+}
+
+func formatValue(v any) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return quoteString(val), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// quoteString quotes s as gron does: like JSON, but without escaping HTML
+// characters, and with U+2028 and U+2029 escaped for JavaScript.
+func quoteString(s string) string {
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\u2028':
+			b.WriteString(`\u2028`)
+		case '\u2029':
+			b.WriteString(`\u2029`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// validIdentifier reports whether key can be written as .key: a JavaScript
+// identifier that is not a reserved word.
+func validIdentifier(key string) bool {
+	if key == "" || reserved[key] {
+		return false
+	}
+	for i, r := range key {
+		if !identRune(r, i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+var reserved = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true,
+	"debugger": true, "default": true, "delete": true, "do": true, "else": true, "export": true,
+	"extends": true, "false": true, "finally": true, "for": true, "function": true, "if": true,
+	"import": true, "in": true, "instanceof": true, "new": true, "null": true, "return": true,
+	"super": true, "switch": true, "this": true, "throw": true, "true": true, "try": true,
+	"typeof": true, "var": true, "void": true, "while": true, "with": true, "yield": true,
+}
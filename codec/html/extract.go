@@ -0,0 +1,382 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/goccy/go-json"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// extractTables converts every <table> into an array of records keyed by its
+// header row(s). A single table yields its records directly, several tables
+// yield one array per table unless Table picks one.
+func (c *Codec) extractTables(data []byte) (any, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	tables := findAll(doc, func(n *html.Node) bool { return n.DataAtom == atom.Table })
+
+	if c.Table > 0 {
+		if c.Table > len(tables) {
+			return nil, fmt.Errorf("table %d not found, document has %d tables", c.Table, len(tables))
+		}
+		return tableRecords(tables[c.Table-1]), nil
+	}
+	if len(tables) == 1 {
+		return tableRecords(tables[0]), nil
+	}
+	out := make([]any, len(tables))
+	for i, t := range tables {
+		out[i] = tableRecords(t)
+	}
+	return out, nil
+}
+
+type tableCell struct {
+	text   string
+	header bool
+}
+
+type tableRow struct {
+	cells []*html.Node
+	head  bool // inside <thead>
+}
+
+// tableRows returns the rows that belong to t itself, not to nested tables.
+func tableRows(t *html.Node) []tableRow {
+	var rows []tableRow
+	var collect func(n *html.Node, head bool)
+	collect = func(n *html.Node, head bool) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			switch child.DataAtom {
+			case atom.Thead:
+				collect(child, true)
+			case atom.Tbody, atom.Tfoot:
+				collect(child, false)
+			case atom.Tr:
+				row := tableRow{head: head}
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						row.cells = append(row.cells, cell)
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	collect(t, false)
+	return rows
+}
+
+func spanAttr(n *html.Node, name string, limit int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(attr(n, name)))
+	if err != nil || v < 1 {
+		return 1
+	}
+	return min(v, limit)
+}
+
+// tableGrid lays the cells out on a grid, repeating cells that span several
+// rows or columns in every position they cover.
+func tableGrid(rows []tableRow) [][]*tableCell {
+	grid := make([][]*tableCell, len(rows))
+	set := func(r, col int, cell *tableCell) {
+		for len(grid[r]) <= col {
+			grid[r] = append(grid[r], nil)
+		}
+		grid[r][col] = cell
+	}
+	for r, row := range rows {
+		col := 0
+		for _, n := range row.cells {
+			for col < len(grid[r]) && grid[r][col] != nil {
+				col++
+			}
+			cell := &tableCell{text: textContent(n), header: n.DataAtom == atom.Th}
+			colspan := spanAttr(n, "colspan", 1000)
+			rowspan := min(spanAttr(n, "rowspan", 65534), len(rows)-r)
+			for dr := 0; dr < rowspan; dr++ {
+				for dc := 0; dc < colspan; dc++ {
+					set(r+dr, col+dc, cell)
+				}
+			}
+			col += colspan
+		}
+	}
+	return grid
+}
+
+func tableRecords(t *html.Node) []any {
+	rows := tableRows(t)
+	grid := tableGrid(rows)
+	width := 0
+	for _, row := range grid {
+		width = max(width, len(row))
+	}
+
+	// leading rows inside <thead> or made only of <th> cells are headers
+	headerRows := 0
+	for r, row := range grid {
+		isHeader := rows[r].head || len(row) > 0
+		if !rows[r].head {
+			for _, cell := range row {
+				if cell == nil || !cell.header {
+					isHeader = false
+					break
+				}
+			}
+		}
+		if !isHeader {
+			break
+		}
+		headerRows++
+	}
+	if headerRows == len(grid) && headerRows > 1 {
+		headerRows = 1
+	}
+
+	keys := make([]string, width)
+	seen := make(map[string]int)
+	for col := range keys {
+		var parts []string
+		for r := 0; r < headerRows; r++ {
+			if col < len(grid[r]) && grid[r][col] != nil {
+				text := grid[r][col].text
+				if text != "" && (len(parts) == 0 || parts[len(parts)-1] != text) {
+					parts = append(parts, text)
+				}
+			}
+		}
+		key := strings.Join(parts, " ")
+		if key == "" {
+			key = fmt.Sprintf("col%d", col+1)
+		}
+		seen[key]++
+		if seen[key] > 1 {
+			key = fmt.Sprintf("%s_%d", key, seen[key])
+		}
+		keys[col] = key
+	}
+
+	records := make([]any, 0, len(grid)-headerRows)
+	for _, row := range grid[headerRows:] {
+		rec := make(map[string]any, width)
+		for col, key := range keys {
+			if col < len(row) && row[col] != nil {
+				rec[key] = util.ParseValue(row[col].text)
+			} else {
+				rec[key] = nil
+			}
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// extractJSONLD collects the payloads of <script type="application/ld+json">
+// elements. Payloads that are arrays contribute each of their elements.
+func (c *Codec) extractJSONLD(data []byte) (any, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	scripts := findAll(doc, func(n *html.Node) bool {
+		return n.DataAtom == atom.Script && strings.EqualFold(strings.TrimSpace(attr(n, "type")), "application/ld+json")
+	})
+	out := []any{}
+	for i, s := range scripts {
+		var payload strings.Builder
+		for child := s.FirstChild; child != nil; child = child.NextSibling {
+			payload.WriteString(child.Data)
+		}
+		if strings.TrimSpace(payload.String()) == "" {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal([]byte(payload.String()), &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON-LD in script %d: %v", i+1, err)
+		}
+		if arr, ok := v.([]any); ok {
+			out = append(out, arr...)
+		} else {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// extractMeta collects the document title, <meta> tags keyed by property,
+// name or http-equiv, and top-level microdata items.
+func (c *Codec) extractMeta(data []byte) (any, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var title any
+	if t := findAll(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title }); len(t) > 0 {
+		title = textContent(t[0])
+	}
+
+	meta := make(map[string]any)
+	for _, n := range findAll(doc, func(n *html.Node) bool { return n.DataAtom == atom.Meta }) {
+		if charset := attr(n, "charset"); charset != "" {
+			addValue(meta, "charset", charset)
+			continue
+		}
+		key := attr(n, "property")
+		if key == "" {
+			key = attr(n, "name")
+		}
+		if key == "" {
+			key = attr(n, "http-equiv")
+		}
+		if key == "" {
+			continue // itemprop metas belong to microdata items
+		}
+		addValue(meta, key, attr(n, "content"))
+	}
+
+	items := []any{}
+	for _, n := range findAll(doc, func(n *html.Node) bool { return hasAttr(n, "itemscope") && !hasAttr(n, "itemprop") }) {
+		items = append(items, microdataItem(n))
+	}
+
+	return map[string]any{"title": title, "meta": meta, "microdata": items}, nil
+}
+
+// microdataItem converts an itemscope element into an object of its
+// properties, with nested items as nested objects.
+func microdataItem(n *html.Node) map[string]any {
+	item := make(map[string]any)
+	if t := attr(n, "itemtype"); t != "" {
+		item["@type"] = t
+	}
+	if id := attr(n, "itemid"); id != "" {
+		item["@id"] = id
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			if props := strings.Fields(attr(child, "itemprop")); len(props) > 0 {
+				var v any
+				if hasAttr(child, "itemscope") {
+					v = microdataItem(child)
+				} else {
+					v = microdataValue(child)
+				}
+				for _, p := range props {
+					addValue(item, p, v)
+				}
+			}
+			if !hasAttr(child, "itemscope") {
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	return item
+}
+
+// microdataValue returns a property's value as defined by the HTML microdata
+// specification: URLs for links and media, machine values for data and time.
+func microdataValue(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.Meta:
+		return attr(n, "content")
+	case atom.Audio, atom.Embed, atom.Iframe, atom.Img, atom.Source, atom.Track, atom.Video:
+		return attr(n, "src")
+	case atom.A, atom.Area, atom.Link:
+		return attr(n, "href")
+	case atom.Object:
+		return attr(n, "data")
+	case atom.Data, atom.Meter:
+		return attr(n, "value")
+	case atom.Time:
+		if hasAttr(n, "datetime") {
+			return attr(n, "datetime")
+		}
+	}
+	return textContent(n)
+}
+
+// addValue sets m[key], turning repeated keys into arrays.
+func addValue(m map[string]any, key string, v any) {
+	existing, ok := m[key]
+	if !ok {
+		m[key] = v
+		return
+	}
+	if arr, ok := existing.([]any); ok {
+		m[key] = append(arr, v)
+		return
+	}
+	m[key] = []any{existing, v}
+}
+
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	var out []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && match(n) {
+			out = append(out, n)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return out
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// textContent returns the text of n with whitespace collapsed, skipping
+// scripts and styles.
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+		case html.ElementNode:
+			if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+				return
+			}
+			if n.DataAtom == atom.Br {
+				b.WriteByte(' ')
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -2,6 +2,7 @@ package html
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/goccy/go-json"
 	"golang.org/x/net/html"
 	"regexp"
@@ -10,18 +11,58 @@ import (
 )
 
 /*
-HTML to Map Converter. These functions do not yet cover conversion to HTML, only from HTML to other arbitrary output formats at this time.
+HTML to Map Converter. The writer in writer.go converts back to HTML using the same conventions.
 This implementation may have some limitations and may not cover all edge cases.
 */
 
-type Codec struct{}
+type Codec struct {
+	// Mode selects what is decoded: "dom" (default) converts the element tree,
+	// "tables" extracts <table> records, "jsonld" collects JSON-LD payloads and
+	// "meta" collects the title, meta tags and microdata items.
+	Mode string
+	// Table picks a single table (1-based) in tables mode; 0 returns them all.
+	Table int
+}
+
+// SetOption implements codec.Configurable for the html.mode and html.table
+// options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "mode":
+		switch value {
+		case "dom", "tables", "jsonld", "meta":
+			c.Mode = value
+			return nil
+		}
+		return fmt.Errorf("unknown mode %q, expected dom, tables, jsonld or meta", value)
+	case "table":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("table must be a table number starting at 1")
+		}
+		c.Table = n
+		return nil
+	}
+	return fmt.Errorf("unknown option %q, expected mode or table", key)
+}
 
 func (c *Codec) Unmarshal(data []byte, v any) error {
-	htmlMap, err := c.HTMLToMap(data)
+	var decoded any
+	var err error
+	switch c.Mode {
+	case "tables":
+		decoded, err = c.extractTables(data)
+	case "jsonld":
+		decoded, err = c.extractJSONLD(data)
+	case "meta":
+		decoded, err = c.extractMeta(data)
+	default:
+		decoded, err = c.HTMLToMap(data)
+	}
 	if err != nil {
 		return err
 	}
-	b, err := json.Marshal(htmlMap)
+	b, err := json.Marshal(decoded)
 	if err != nil {
 		return err
 	}
@@ -59,14 +100,16 @@ func (c *Codec) HTMLToMap(htmlBytes []byte) (map[string]any, error) {
 		return nil, nil
 	}
 
-	result := c.nodeToMap(root)
+	result := c.NodeToMap(root)
 	if m, ok := result.(map[string]any); ok {
 		return map[string]any{"html": m}, nil
 	}
 	return nil, nil
 }
 
-func (c *Codec) nodeToMap(node *html.Node) any {
+// NodeToMap converts an element and its subtree using the "@attr", "#text" and
+// "#comment" conventions of the decoder.
+func (c *Codec) NodeToMap(node *html.Node) any {
 	m := make(map[string]any)
 
 	// Process attributes if present for node
@@ -101,7 +144,7 @@ func (c *Codec) nodeToMap(node *html.Node) any {
 				comments = append(comments, text)
 			}
 		case html.ElementNode:
-			childMap := c.nodeToMap(child)
+			childMap := c.NodeToMap(child)
 			if childMap != nil {
 				children[child.Data] = append(children[child.Data], childMap)
 			}
@@ -0,0 +1,212 @@
+package html
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const page = `<html><head><title>Stats</title>
+<meta charset="utf-8">
+<meta property="og:title" content="Stats page">
+<meta property="og:image" content="a.png">
+<meta property="og:image" content="b.png">
+<script type="application/ld+json">{"@type": "Organization", "name": "Acme"}</script>
+<script type="application/ld+json">[{"@type": "Thing"}, {"@type": "Place"}]</script>
+</head><body>
+<div itemscope itemtype="https://schema.org/Person">
+  <span itemprop="name">Jane</span>
+  <a itemprop="url" href="/jane">profile</a>
+  <div itemprop="address" itemscope itemtype="https://schema.org/PostalAddress">
+    <span itemprop="locality">Paris</span>
+  </div>
+</div>
+<table>
+  <thead>
+    <tr><th rowspan="2">Region</th><th colspan="2">Q1</th></tr>
+    <tr><th>Rev</th><th>Cost</th></tr>
+  </thead>
+  <tbody>
+    <tr><td>North</td><td>10</td><td>4</td></tr>
+    <tr><td rowspan="2">South</td><td>7</td><td>2.5</td></tr>
+    <tr><td>8</td></tr>
+  </tbody>
+</table>
+<table><tr><td>a</td><td>b</td></tr><tr><td>c</td><td>d</td></tr></table>
+</body></html>`
+
+func decode(t *testing.T, c *Codec) any {
+	t.Helper()
+	var v any
+	if err := c.Unmarshal([]byte(page), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestTablesMode(t *testing.T) {
+	got := decode(t, &Codec{Mode: "tables", Table: 1})
+	want := []any{
+		map[string]any{"Region": "North", "Q1 Rev": float64(10), "Q1 Cost": float64(4)},
+		map[string]any{"Region": "South", "Q1 Rev": float64(7), "Q1 Cost": 2.5},
+		map[string]any{"Region": "South", "Q1 Rev": float64(8), "Q1 Cost": nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+
+	all := decode(t, &Codec{Mode: "tables"}).([]any)
+	if len(all) != 2 {
+		t.Fatalf("expected 2 tables, got %d", len(all))
+	}
+	// a table without header cells gets numbered columns
+	want = []any{
+		map[string]any{"col1": "a", "col2": "b"},
+		map[string]any{"col1": "c", "col2": "d"},
+	}
+	if !reflect.DeepEqual(all[1], want) {
+		t.Errorf("got %#v\nwant %#v", all[1], want)
+	}
+
+	var v any
+	if err := (&Codec{Mode: "tables", Table: 3}).Unmarshal([]byte(page), &v); err == nil {
+		t.Error("expected error for missing table")
+	}
+}
+
+func TestJSONLDMode(t *testing.T) {
+	got := decode(t, &Codec{Mode: "jsonld"})
+	want := []any{
+		map[string]any{"@type": "Organization", "name": "Acme"},
+		map[string]any{"@type": "Thing"},
+		map[string]any{"@type": "Place"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestMetaMode(t *testing.T) {
+	got := decode(t, &Codec{Mode: "meta"})
+	want := map[string]any{
+		"title": "Stats",
+		"meta": map[string]any{
+			"charset":  "utf-8",
+			"og:title": "Stats page",
+			"og:image": []any{"a.png", "b.png"},
+		},
+		"microdata": []any{
+			map[string]any{
+				"@type": "https://schema.org/Person",
+				"name":  "Jane",
+				"url":   "/jane",
+				"address": map[string]any{
+					"@type":    "https://schema.org/PostalAddress",
+					"locality": "Paris",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestSetOption(t *testing.T) {
+	c := &Codec{}
+	if err := c.SetOption("mode", "tables"); err != nil || c.Mode != "tables" {
+		t.Errorf("mode not set: %v", err)
+	}
+	if err := c.SetOption("table", "2"); err != nil || c.Table != 2 {
+		t.Errorf("table not set: %v", err)
+	}
+	for _, kv := range [][2]string{{"mode", "rows"}, {"table", "x"}, {"table", "-1"}, {"colour", "red"}} {
+		if err := c.SetOption(kv[0], kv[1]); err == nil {
+			t.Errorf("expected error for %s=%s", kv[0], kv[1])
+		}
+	}
+}
+
+func TestMarshalElements(t *testing.T) {
+	c := &Codec{}
+	v := map[string]any{
+		"div": map[string]any{
+			"@class":   "note",
+			"#comment": "a -- b",
+			"p":        []any{"one & two", map[string]any{"@id": "x", "#text": "<three>"}},
+			"br":       "",
+			"img":      map[string]any{"@src": "a.png", "@alt": `say "hi"`},
+			"script":   "if (a < b) {}",
+		},
+	}
+	got, err := c.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<div class="note">
+  <!-- a - - b -->
+  <br>
+  <img alt="say &#34;hi&#34;" src="a.png">
+  <p>one &amp; two</p>
+  <p id="x">&lt;three&gt;</p>
+  <script>if (a < b) {}</script>
+</div>
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	c := &Codec{}
+	src := `<html><head><title>T</title></head><body><div id="main"><p>hello</p><p>world</p></div></body></html>`
+	var first any
+	if err := c.Unmarshal([]byte(src), &first); err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.Marshal(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "<!DOCTYPE html>\n<html>") {
+		t.Errorf("expected a document, got:\n%s", out)
+	}
+	var second any
+	if err := c.Unmarshal(out, &second); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("round trip mismatch:\n%#v\n%#v", first, second)
+	}
+}
+
+func TestMarshalTable(t *testing.T) {
+	c := &Codec{}
+	out, err := c.Marshal([]any{
+		map[string]any{"name": "a<b", "n": float64(1)},
+		map[string]any{"name": "c", "tags": []any{"x"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	for _, part := range []string{
+		"<!DOCTYPE html>", "<style>",
+		"<tr><th>n</th><th>name</th><th>tags</th></tr>",
+		"<tr><td>1</td><td>a&lt;b</td><td></td></tr>",
+		`<tr><td></td><td>c</td><td>[&#34;x&#34;]</td></tr>`,
+	} {
+		if !strings.Contains(s, part) {
+			t.Errorf("missing %q in:\n%s", part, s)
+		}
+	}
+
+	// the table reads back in tables mode
+	var v any
+	if err := (&Codec{Mode: "tables"}).Unmarshal(out, &v); err != nil {
+		t.Fatal(err)
+	}
+	if rows, ok := v.([]any); !ok || len(rows) != 2 {
+		t.Errorf("expected 2 records, got %v", v)
+	}
+}
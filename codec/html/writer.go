@@ -0,0 +1,280 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"golang.org/x/net/html"
+)
+
+// voidElements have no content and no end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements hold text that must not be escaped.
+var rawTextElements = map[string]bool{"script": true, "style": true}
+
+var tagName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+const tableStyle = `body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 4px 10px; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+tr:nth-child(even) td { background: #fafbfc; }`
+
+// Marshal writes HTML. Objects are read with the conventions of the decoder:
+// keys are element names, "@name" keys are attributes, "#text" is text
+// content and "#comment" holds comments. An array of objects is written as a
+// styled <table> document with one column per key.
+func (c *Codec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	switch v := v.(type) {
+	case []any:
+		if records, ok := asRecords(v); ok {
+			writeTableDocument(&buf, records)
+			return buf.Bytes(), nil
+		}
+		writeList(&buf, v, 0)
+	case map[string]any:
+		if _, ok := v["html"]; ok && len(v) == 1 {
+			buf.WriteString("<!DOCTYPE html>\n")
+		}
+		for _, key := range childOrder(v) {
+			writeElements(&buf, key, v[key], 0)
+		}
+	default:
+		buf.WriteString(html.EscapeString(scalarText(v)))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func asRecords(v []any) ([]map[string]any, bool) {
+	if len(v) == 0 {
+		return nil, false
+	}
+	records := make([]map[string]any, len(v))
+	for i, item := range v {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		records[i] = m
+	}
+	return records, true
+}
+
+func writeTableDocument(buf *bytes.Buffer, records []map[string]any) {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		for k := range rec {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	buf.WriteString("<!DOCTYPE html>\n<html>\n  <head>\n    <meta charset=\"utf-8\">\n    <style>\n")
+	for _, line := range strings.Split(tableStyle, "\n") {
+		buf.WriteString("      " + line + "\n")
+	}
+	buf.WriteString("    </style>\n  </head>\n  <body>\n    <table>\n      <thead>\n        <tr>")
+	for _, col := range columns {
+		fmt.Fprintf(buf, "<th>%s</th>", html.EscapeString(col))
+	}
+	buf.WriteString("</tr>\n      </thead>\n      <tbody>\n")
+	for _, rec := range records {
+		buf.WriteString("        <tr>")
+		for _, col := range columns {
+			fmt.Fprintf(buf, "<td>%s</td>", html.EscapeString(scalarText(rec[col])))
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("      </tbody>\n    </table>\n  </body>\n</html>\n")
+}
+
+// writeList writes an array that is not a list of records as <ul>.
+func writeList(buf *bytes.Buffer, items []any, depth int) {
+	indent := strings.Repeat("  ", depth)
+	buf.WriteString(indent + "<ul>\n")
+	for _, item := range items {
+		switch item := item.(type) {
+		case map[string]any:
+			buf.WriteString(indent + "  <li>\n")
+			for _, key := range childOrder(item) {
+				writeElements(buf, key, item[key], depth+2)
+			}
+			buf.WriteString(indent + "  </li>\n")
+		case []any:
+			buf.WriteString(indent + "  <li>\n")
+			writeList(buf, item, depth+2)
+			buf.WriteString(indent + "  </li>\n")
+		default:
+			fmt.Fprintf(buf, "%s  <li>%s</li>\n", indent, html.EscapeString(scalarText(item)))
+		}
+	}
+	buf.WriteString(indent + "</ul>\n")
+}
+
+// writeElements writes one element, or one per item when v is an array.
+func writeElements(buf *bytes.Buffer, name string, v any, depth int) {
+	if strings.HasPrefix(name, "@") {
+		return // attributes are written by the parent
+	}
+	if name == "#comment" {
+		writeComments(buf, v, strings.Repeat("  ", depth))
+		return
+	}
+	if name == "#text" {
+		buf.WriteString(strings.Repeat("  ", depth) + html.EscapeString(scalarText(v)) + "\n")
+		return
+	}
+	if items, ok := v.([]any); ok {
+		for _, item := range items {
+			writeElement(buf, name, item, depth)
+		}
+		return
+	}
+	writeElement(buf, name, v, depth)
+}
+
+func writeElement(buf *bytes.Buffer, name string, v any, depth int) {
+	indent := strings.Repeat("  ", depth)
+	tag, attrs := name, ""
+	if !tagName.MatchString(name) {
+		// keys that are not element names are kept as a data attribute
+		tag, attrs = "div", fmt.Sprintf(` data-key="%s"`, html.EscapeString(name))
+	}
+	tag = strings.ToLower(tag)
+
+	m, isMap := v.(map[string]any)
+	if isMap {
+		attrs += attributes(m)
+	}
+	buf.WriteString(indent + "<" + tag + attrs + ">")
+	if voidElements[tag] {
+		buf.WriteByte('\n')
+		return
+	}
+
+	if !isMap {
+		buf.WriteString(text(tag, v))
+		buf.WriteString("</" + tag + ">\n")
+		return
+	}
+
+	children := childOrder(m)
+	textValue, hasText := m["#text"]
+	if len(children) == 0 || (len(children) == 1 && hasText) {
+		// text only: keep it inline so no whitespace is added
+		if hasText {
+			buf.WriteString(text(tag, textValue))
+		}
+		buf.WriteString("</" + tag + ">\n")
+		return
+	}
+
+	buf.WriteByte('\n')
+	if hasText {
+		buf.WriteString(indent + "  " + text(tag, textValue) + "\n")
+	}
+	for _, key := range children {
+		if key == "#text" {
+			continue
+		}
+		writeElements(buf, key, m[key], depth+1)
+	}
+	buf.WriteString(indent + "</" + tag + ">\n")
+}
+
+func text(tag string, v any) string {
+	if rawTextElements[tag] {
+		return scalarText(v)
+	}
+	return html.EscapeString(scalarText(v))
+}
+
+func attributes(m map[string]any) string {
+	var keys []string
+	for k := range m {
+		if strings.HasPrefix(k, "@") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, ` %s="%s"`, k[1:], html.EscapeString(scalarText(m[k])))
+	}
+	return b.String()
+}
+
+func writeComments(buf *bytes.Buffer, v any, indent string) {
+	comments, ok := v.([]any)
+	if !ok {
+		comments = []any{v}
+	}
+	for _, c := range comments {
+		// "--" may not appear inside a comment
+		body := strings.ReplaceAll(scalarText(c), "--", "- -")
+		buf.WriteString(indent + "<!-- " + body + " -->\n")
+	}
+}
+
+// childOrder returns the content keys of an element: comments first, then
+// head before body, then the remaining elements alphabetically, as the
+// decoder does not preserve document order.
+func childOrder(m map[string]any) []string {
+	var keys []string
+	for k := range m {
+		if !strings.HasPrefix(k, "@") {
+			keys = append(keys, k)
+		}
+	}
+	rank := func(k string) int {
+		switch k {
+		case "#comment":
+			return 0
+		case "head":
+			return 1
+		case "body":
+			return 3
+		}
+		return 2
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra - rb
+		}
+		return strings.Compare(a, b)
+	})
+	return keys
+}
+
+// scalarText renders a value as text; nested values are written as JSON.
+func scalarText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]any, []any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,303 @@
+package ini
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/JFryy/qq/internal/flatten"
+)
+
+// decoder builds the map for an INI document line by line.
+type decoder struct {
+	c       *Codec
+	root    map[string]any
+	section map[string]any
+	pending []string // comment lines waiting for the next key or section
+}
+
+func (c *Codec) decode(input []byte) (map[string]any, error) {
+	input = bytes.TrimPrefix(input, []byte("\xef\xbb\xbf"))
+	d := &decoder{c: c, root: make(map[string]any)}
+	d.section = d.root
+	for n, line := range strings.Split(string(input), "\n") {
+		line = strings.TrimSpace(line)
+		var err error
+		switch {
+		case line == "":
+		case line[0] == ';' || line[0] == '#':
+			d.pending = append(d.pending, line)
+		case line[0] == '[':
+			err = d.header(line)
+		default:
+			err = d.keyValue(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+	}
+	if c.Keys.OnRead {
+		if err := c.unflatten(d.root); err != nil {
+			return nil, err
+		}
+	}
+	return d.root, nil
+}
+
+// header opens the section named by a [name] or [name "sub"] line.
+func (d *decoder) header(line string) error {
+	name, rest, err := sectionName(line[1:])
+	if err != nil {
+		return err
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != ';' && rest[0] != '#' {
+		return fmt.Errorf("unexpected %q after section header", rest)
+	}
+
+	path := []string{strings.TrimSpace(name.base)}
+	if d.c.nest() {
+		path = strings.Split(name.base, ".")
+		for i := range path {
+			path[i] = strings.TrimSpace(path[i])
+		}
+		if name.hasSub {
+			path = append(path, name.sub)
+		}
+	} else if name.hasSub {
+		path[0] += ` "` + name.sub + `"`
+	}
+
+	section := d.root
+	for _, key := range path {
+		switch v := section[key].(type) {
+		case nil:
+			child := make(map[string]any)
+			section[key] = child
+			section = child
+		case map[string]any:
+			section = v
+		default:
+			return fmt.Errorf("section [%s] conflicts with key %q", strings.Join(path, "."), key)
+		}
+	}
+	d.section = section
+	if d.c.Comments && len(d.pending) > 0 {
+		section["#"] = strings.Join(d.pending, "\n")
+	}
+	d.pending = nil
+	return nil
+}
+
+type headerName struct {
+	base, sub string
+	hasSub    bool
+}
+
+// sectionName reads a section name up to the closing bracket, with an
+// optional quoted subsection as written by git config.
+func sectionName(s string) (headerName, string, error) {
+	var name headerName
+	end := strings.IndexAny(s, `"]`)
+	if end < 0 {
+		return name, "", fmt.Errorf("unterminated section header")
+	}
+	name.base = strings.TrimSpace(s[:end])
+	if s[end] == ']' {
+		return name, s[end+1:], nil
+	}
+	sub, rest, ok := unquote(s[end:])
+	if !ok {
+		return name, "", fmt.Errorf("unterminated subsection name")
+	}
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, "]") {
+		return name, "", fmt.Errorf("unterminated section header")
+	}
+	name.sub, name.hasSub = sub, true
+	return name, rest[1:], nil
+}
+
+// keyValue reads a key = value or key: value line, or a bare key, which is
+// true.
+func (d *decoder) keyValue(line string) error {
+	sep := strings.IndexAny(line, "=:")
+	var key string
+	var value any = true
+	var comment string
+	if sep < 0 {
+		key = line
+	} else {
+		key = strings.TrimSpace(line[:sep])
+		raw := strings.TrimSpace(line[sep+1:])
+		var s string
+		var quoted bool
+		s, comment, quoted = splitValue(raw)
+		if quoted {
+			value = s
+		} else {
+			value = flatten.Infer(s)
+		}
+	}
+	if key == "" {
+		return fmt.Errorf("missing key")
+	}
+
+	switch old := d.section[key].(type) {
+	case nil:
+		d.section[key] = value
+	case map[string]any:
+		return fmt.Errorf("key %q conflicts with a section", key)
+	case []any:
+		if d.c.Duplicates == "last" {
+			d.section[key] = value
+		} else {
+			d.section[key] = append(old, value)
+		}
+	default:
+		if d.c.Duplicates == "last" {
+			d.section[key] = value
+		} else {
+			d.section[key] = []any{old, value}
+		}
+	}
+
+	if d.c.Comments {
+		lines := d.pending
+		if comment != "" {
+			lines = append(lines, comment)
+		}
+		if len(lines) > 0 {
+			n := 0
+			if items, ok := d.section[key].([]any); ok {
+				n = len(items) - 1
+			}
+			d.comment(key, n, strings.Join(lines, "\n"))
+		}
+	}
+	d.pending = nil
+	return nil
+}
+
+// comment keeps the comment of the nth occurrence of key under #key: a
+// string while only the first has one, and otherwise an array with an
+// item per occurrence, empty for those without a comment. With
+// duplicates=last the comments of every occurrence are joined.
+func (d *decoder) comment(key string, n int, text string) {
+	switch prev := d.section["#"+key].(type) {
+	case string:
+		if n == 0 || d.c.Duplicates == "last" {
+			d.section["#"+key] = prev + "\n" + text
+			return
+		}
+		d.section["#"+key] = occurrences([]any{prev}, n, text)
+	case []any:
+		d.section["#"+key] = occurrences(prev, n, text)
+	default:
+		if n == 0 {
+			d.section["#"+key] = text
+			return
+		}
+		d.section["#"+key] = occurrences(nil, n, text)
+	}
+}
+
+func occurrences(comments []any, n int, text string) []any {
+	for len(comments) <= n {
+		comments = append(comments, "")
+	}
+	comments[n] = text
+	return comments
+}
+
+// splitValue separates a value from an inline comment, which starts with ;
+// or # after whitespace. Quoted values are unquoted.
+func splitValue(s string) (value, comment string, quoted bool) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		if v, rest, ok := unquote(s); ok {
+			rest = strings.TrimSpace(rest)
+			if rest == "" || rest[0] == ';' || rest[0] == '#' {
+				return v, rest, true
+			}
+		}
+	}
+	for i := 1; i < len(s); i++ {
+		if (s[i] == ';' || s[i] == '#') && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimSpace(s[:i]), s[i:], false
+		}
+	}
+	return s, "", false
+}
+
+// unquote reads a double quoted string with backslash escapes, or a single
+// quoted one taken literally, and returns what follows it.
+func unquote(s string) (string, string, bool) {
+	q := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == q:
+			return b.String(), s[i+1:], true
+		case q == '"' && s[i] == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", false
+}
+
+// unflatten rebuilds the flat keys of m and of every section below it.
+// Section names and comment keys are left as they are.
+func (c *Codec) unflatten(m map[string]any) error {
+	flat := make(map[string]any, len(m))
+	sections := make(map[string]map[string]any)
+	for k, v := range m {
+		if strings.HasPrefix(k, "#") {
+			continue
+		}
+		if sub, ok := v.(map[string]any); ok {
+			if err := c.unflatten(sub); err != nil {
+				return err
+			}
+			sections[k] = sub
+		} else {
+			flat[k] = v
+		}
+		delete(m, k)
+	}
+	nested, err := c.Keys.Unflatten(flat)
+	if err != nil {
+		return err
+	}
+	obj, ok := nested.(map[string]any)
+	if !ok {
+		return fmt.Errorf("keys %v do not unflatten to an object", flat)
+	}
+	for k, v := range obj {
+		m[k] = v
+	}
+	for k, sub := range sections {
+		switch v := m[k].(type) {
+		case nil:
+			m[k] = sub
+		case map[string]any:
+			// keys such as a.b=1 next to an [a] section
+			for sk, sv := range sub {
+				v[sk] = sv
+			}
+		default:
+			return fmt.Errorf("section [%s] conflicts with key %q", k, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package ini
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JFryy/qq/internal/flatten"
+)
+
+// line is a key = value line with the comment written above it.
+type line struct {
+	comment, key, value string
+}
+
+type encoder struct {
+	c   *Codec
+	buf bytes.Buffer
+	err error
+}
+
+// encode writes top-level keys first, then sections in key order. With
+// nested subsections, objects inside sections become [parent.child]
+// sections; otherwise they are flattened into keys.
+func (c *Codec) encode(data map[string]any) ([]byte, error) {
+	e := &encoder{c: c}
+	if comment, ok := data["#"].(string); ok {
+		e.comment(comment)
+	}
+	e.lines(data, false)
+	for _, k := range sortedKeys(data) {
+		if m, ok := data[k].(map[string]any); ok && !strings.HasPrefix(k, "#") {
+			e.section([]string{k}, m)
+		}
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf.Bytes(), nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (e *encoder) section(path []string, m map[string]any) {
+	comment, hasComment := m["#"].(string)
+	var subsections []string
+	if e.c.nest() {
+		for _, k := range sortedKeys(m) {
+			if _, ok := m[k].(map[string]any); ok && !strings.HasPrefix(k, "#") {
+				subsections = append(subsections, k)
+			}
+		}
+	}
+
+	// a section holding only subsections needs no header of its own
+	if hasComment || len(m) == 0 || len(m) > len(subsections) {
+		if e.buf.Len() > 0 {
+			e.buf.WriteByte('\n')
+		}
+		if hasComment {
+			e.comment(comment)
+		}
+		e.buf.WriteString("[" + e.header(path) + "]\n")
+		e.lines(m, true)
+	}
+	for _, k := range subsections {
+		e.section(append(path[:len(path):len(path)], k), m[k].(map[string]any))
+	}
+}
+
+// header names a section: dotted when nesting, with a last part that holds
+// a dot or a quote written as a quoted subsection.
+func (e *encoder) header(path []string) string {
+	if !e.c.nest() || len(path) == 1 {
+		return strings.Join(path, ".")
+	}
+	last := path[len(path)-1]
+	if strings.ContainsAny(last, `." []`) {
+		return strings.Join(path[:len(path)-1], ".") + " " + quote(last)
+	}
+	return strings.Join(path, ".")
+}
+
+// lines writes the keys of m that are not sections, aligned on the =.
+func (e *encoder) lines(m map[string]any, inSection bool) {
+	var out []line
+	seen := make(map[string]bool)
+	for _, k := range sortedKeys(m) {
+		if strings.HasPrefix(k, "#") {
+			continue
+		}
+		v := m[k]
+		// top-level objects are sections, and so are nested ones unless flat
+		if _, ok := v.(map[string]any); ok && (!inSection || e.c.nest()) {
+			continue
+		}
+		comments := keyComments(m["#"+k])
+		var kv []line
+		if items, ok := v.([]any); ok && len(items) > 1 && e.c.Duplicates != "last" && allScalars(items) {
+			// repeated keys, read back as an array, each with its comment
+			for i, item := range items {
+				kv = append(kv, line{key: k, value: formatValue(item)})
+				if i < len(comments) {
+					kv[i].comment = comments[i]
+				}
+			}
+		} else {
+			entries, err := e.c.Keys.Flatten(map[string]any{k: v})
+			if err != nil {
+				e.fail(err)
+				continue
+			}
+			for _, entry := range entries {
+				kv = append(kv, line{key: entry.Key, value: formatValue(entry.Value)})
+			}
+			var joined []string
+			for _, c := range comments {
+				if c != "" {
+					joined = append(joined, c)
+				}
+			}
+			kv[0].comment = strings.Join(joined, "\n")
+		}
+		for i, l := range kv {
+			// the lines of a repeated key share it
+			if seen[l.key] && (i == 0 || kv[i-1].key != l.key) {
+				e.fail(fmt.Errorf("more than one value flattens to the key %q", l.key))
+			}
+			seen[l.key] = true
+		}
+		out = append(out, kv...)
+	}
+
+	width := 0
+	for _, l := range out {
+		width = max(width, len(l.key))
+	}
+	for _, l := range out {
+		if l.comment != "" {
+			e.comment(l.comment)
+		}
+		e.buf.WriteString(l.key + strings.Repeat(" ", width-len(l.key)) + " =")
+		if l.value != "" {
+			e.buf.WriteString(" " + l.value)
+		}
+		e.buf.WriteByte('\n')
+	}
+}
+
+// fail keeps the first error met while encoding.
+func (e *encoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// keyComments returns the comments kept under #key: one, or one for each
+// occurrence of a repeated key, with empty ones for those without.
+func keyComments(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		comments := make([]string, len(v))
+		for i, c := range v {
+			comments[i], _ = c.(string)
+		}
+		return comments
+	}
+	return nil
+}
+
+func allScalars(items []any) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]any, []any:
+			return false
+		}
+	}
+	return true
+}
+
+// comment writes comment lines, adding a ; to lines that lack a marker.
+func (e *encoder) comment(text string) {
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); !strings.HasPrefix(l, ";") && !strings.HasPrefix(l, "#") {
+			l = "; " + l
+		}
+		e.buf.WriteString(l + "\n")
+	}
+}
+
+// formatValue writes a value so that it reads back the same: strings that
+// would be read as another type, or that hold comment characters, quotes or
+// surrounding space, are quoted.
+func formatValue(v any) string {
+	s := flatten.String(v)
+	str, isString := v.(string)
+	if !isString {
+		return s
+	}
+	if str == "" || str != strings.TrimSpace(str) || strings.ContainsAny(str, ";#\n\r") ||
+		strings.HasPrefix(str, `"`) || strings.HasPrefix(str, "'") {
+		return quote(str)
+	}
+	if flatten.Retyped(str) {
+		return quote(str)
+	}
+	return str
+}
+
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -2,32 +2,69 @@ package ini
 
 import (
 	"fmt"
-	"github.com/JFryy/qq/codec/util"
+	"strconv"
+
+	"github.com/JFryy/qq/internal/flatten"
 	"github.com/mitchellh/mapstructure"
-	"gopkg.in/ini.v1"
-	"strings"
 )
 
-type Codec struct{}
-
-func (c *Codec) Unmarshal(input []byte, v any) error {
-	cfg, err := ini.Load(input)
-	if err != nil {
-		return fmt.Errorf("error unmarshaling INI: %v", err)
-	}
+// Codec converts between INI and maps. Keys before the first section are
+// top-level keys and sections are objects. The zero value nests dotted and
+// [section "sub"] sections, decodes repeated keys as arrays and drops
+// comments.
+type Codec struct {
+	// Subsections is "nest" (default) to map [a.b] and [a "b"] to nested
+	// objects, or "flat" to keep section names as written.
+	Subsections string
+	// Duplicates is "array" (default) to decode repeated keys as an array,
+	// or "last" to keep the last value.
+	Duplicates string
+	// Comments keeps comments when decoding, under "#" for the comment
+	// above a section and "#key" for the comment above or after a key.
+	// Such keys are always written back as comments.
+	Comments bool
+	// Keys flattens values that sections cannot hold, such as arrays of
+	// objects, and rebuilds them when reading with Keys.OnRead.
+	Keys flatten.Options
+}
 
-	data := make(map[string]any)
-	for _, section := range cfg.Sections() {
-		if section.Name() == ini.DefaultSection {
-			continue
+// SetOption implements codec.Configurable for the ini options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "subsections":
+		if value != "nest" && value != "flat" {
+			return fmt.Errorf("unknown subsections mode %q, expected nest or flat", value)
 		}
-		sectionMap := make(map[string]any)
-		for _, key := range section.Keys() {
-			sectionMap[key.Name()] = util.ParseValue(key.Value())
+		c.Subsections = value
+	case "duplicates":
+		if value != "array" && value != "last" {
+			return fmt.Errorf("unknown duplicates mode %q, expected array or last", value)
 		}
-		data[section.Name()] = sectionMap
+		c.Duplicates = value
+	case "comments":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("comments must be true or false")
+		}
+		c.Comments = b
+	default:
+		if flatten.IsOption(key) {
+			return c.Keys.SetOption(key, value)
+		}
+		return fmt.Errorf("unknown option %q, expected subsections, duplicates, comments, separator, index or unflatten", key)
 	}
+	return nil
+}
 
+func (c *Codec) nest() bool {
+	return c.Subsections != "flat"
+}
+
+func (c *Codec) Unmarshal(input []byte, v any) error {
+	data, err := c.decode(input)
+	if err != nil {
+		return fmt.Errorf("error unmarshaling INI: %v", err)
+	}
 	return mapstructure.Decode(data, v)
 }
 
@@ -36,50 +73,5 @@ func (c *Codec) Marshal(v any) ([]byte, error) {
 	if !ok {
 		return nil, fmt.Errorf("input data is not a map")
 	}
-
-	cfg := ini.Empty()
-	defaultSection := cfg.Section("")
-
-	for section, sectionValue := range data {
-		sectionMap, ok := sectionValue.(map[string]any)
-		if !ok {
-			// Handle scalar values by putting them in the default section
-			var valueStr string
-			if sectionValue == nil {
-				valueStr = ""
-			} else {
-				valueStr = fmt.Sprintf("%v", sectionValue)
-			}
-			_, err := defaultSection.NewKey(section, valueStr)
-			if err != nil {
-				return nil, err
-			}
-			continue
-		}
-
-		sec, err := cfg.NewSection(section)
-		if err != nil {
-			return nil, err
-		}
-
-		for key, value := range sectionMap {
-			var valueStr string
-			if value == nil {
-				valueStr = ""
-			} else {
-				valueStr = fmt.Sprintf("%v", value)
-			}
-			_, err := sec.NewKey(key, valueStr)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	var b strings.Builder
-	_, err := cfg.WriteTo(&b)
-	if err != nil {
-		return nil, fmt.Errorf("error writing INI data: %v", err)
-	}
-	return []byte(b.String()), nil
+	return c.encode(data)
 }
@@ -0,0 +1,180 @@
+package ini
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const phpIni = `; global comment
+engine = On
+# about extensions
+extension = curl
+extension = gd ; image support
+path = "/usr/lib ; not a comment"
+flag
+
+; session settings
+[session]
+name = PHPSESSID
+lifetime = 0
+[session.redis]
+host = localhost
+[remote "origin"]
+url = git@example.com:x.git
+`
+
+func TestDecode(t *testing.T) {
+	c := &Codec{}
+	got, err := c.decode([]byte(phpIni))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"engine":    "On",
+		"extension": []any{"curl", "gd"},
+		"path":      "/usr/lib ; not a comment",
+		"flag":      true,
+		"session": map[string]any{
+			"name":     "PHPSESSID",
+			"lifetime": 0,
+			"redis":    map[string]any{"host": "localhost"},
+		},
+		"remote": map[string]any{"origin": map[string]any{"url": "git@example.com:x.git"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+
+	c = &Codec{Subsections: "flat", Duplicates: "last"}
+	got, err = c.decode([]byte(phpIni))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["extension"] != "gd" {
+		t.Errorf("duplicates=last: got %v", got["extension"])
+	}
+	if _, ok := got["session.redis"]; !ok {
+		t.Errorf("subsections=flat: got %v", got)
+	}
+	if _, ok := got[`remote "origin"`]; !ok {
+		t.Errorf("subsections=flat: got %v", got)
+	}
+
+	if _, err := (&Codec{}).decode([]byte("a = 1\n[a]\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a conflict on line 2, got %v", err)
+	}
+}
+
+func TestCommentsRoundTrip(t *testing.T) {
+	c := &Codec{Comments: true}
+	data, err := c.decode([]byte(phpIni))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["#engine"] != "; global comment" {
+		t.Errorf("key comment: got %q", data["#engine"])
+	}
+	if want := []any{"# about extensions", "; image support"}; !reflect.DeepEqual(data["#extension"], want) {
+		t.Errorf("repeated key comments: got %q, want %q", data["#extension"], want)
+	}
+	if data["session"].(map[string]any)["#"] != "; session settings" {
+		t.Errorf("section comment: got %v", data["session"])
+	}
+
+	b, err := c.encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, s := range []string{"; global comment\nengine", "; session settings\n[session]\n", `[remote.origin]`, "# about extensions\nextension = curl\n; image support\nextension = gd\n"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output lacks %q:\n%s", s, out)
+		}
+	}
+	again, err := c.decode([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, data) {
+		t.Errorf("round trip changed the data:\n%#v\n%#v", again, data)
+	}
+}
+
+func TestRepeatedKeyComments(t *testing.T) {
+	c := &Codec{Comments: true}
+	input := "host = a\nhost = b ; backup\nhost = c\n; last\nhost = d ; really\n"
+	data, err := c.decode([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{"", "; backup", "", "; last\n; really"}; !reflect.DeepEqual(data["#host"], want) {
+		t.Errorf("got %q, want %q", data["#host"], want)
+	}
+
+	b, err := c.encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	want := "host = a\n; backup\nhost = b\nhost = c\n; last\n; really\nhost = d\n"
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+	again, err := c.decode([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, data) {
+		t.Errorf("round trip changed the data:\n%#v\n%#v", again, data)
+	}
+
+	c.Duplicates = "last"
+	if data, _ = c.decode([]byte(input)); data["#host"] != "; backup\n; last\n; really" {
+		t.Errorf("duplicates=last: got %q", data["#host"])
+	}
+}
+
+func TestEncode(t *testing.T) {
+	c := &Codec{}
+	data := map[string]any{
+		"port":  "8080",
+		"empty": "",
+		"owner": map[string]any{"name": "x", "addr": map[string]any{"city": "y"}},
+		"sites": map[string]any{"example.com": map[string]any{"on": true}},
+		"users": map[string]any{"list": []any{map[string]any{"n": 1.0}}},
+	}
+	want := `empty = ""
+port  = "8080"
+
+[owner]
+name = x
+
+[owner.addr]
+city = y
+
+[sites "example.com"]
+on = true
+
+[users]
+list[0].n = 1
+`
+	if got, err := c.encode(data); err != nil || string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	c.Keys.OnRead = true
+	back, err := c.decode([]byte(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, map[string]any{
+		"port":  "8080",
+		"empty": "",
+		"owner": map[string]any{"name": "x", "addr": map[string]any{"city": "y"}},
+		"sites": map[string]any{"example.com": map[string]any{"on": true}},
+		"users": map[string]any{"list": []any{map[string]any{"n": 1}}},
+	}) {
+		t.Errorf("read back %#v", back)
+	}
+}
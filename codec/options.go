@@ -0,0 +1,55 @@
+package codec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Configurable is implemented by codecs that accept options, given on the
+// command line as --opt <codec>.<key>=<value>.
+type Configurable interface {
+	SetOption(key, value string) error
+}
+
+// configurable maps encodings to the codec instances behind Codecs. The
+// Codecs entries are bound to these same instances, so options set here
+// apply to every later Unmarshal and Marshal.
+var configurable = map[EncodingType]Configurable{
+	HTML:       &htmlCodec,
+	XML:        &xmlCodec,
+	CSV:        &csvCodec,
+	TSV:        &tsvCodec,
+	ENV:        &envCodec,
+	INI:        &iniCodec,
+	GRON:       &gronCodec,
+	PROPERTIES: &propertiesCodec,
+	SHELL:      &shellCodec,
+	PROTOBUF:   &protobufCodec,
+	TEXTPROTO:  &textprotoCodec,
+	PARQUET:    &parquetCodec,
+	AVRO:       &avroCodec,
+}
+
+// SetOption applies a single "codec.key=value" option.
+func SetOption(opt string) error {
+	name, value, ok := strings.Cut(opt, "=")
+	if !ok {
+		return fmt.Errorf("invalid option %q, expected codec.key=value", opt)
+	}
+	codecName, key, ok := strings.Cut(name, ".")
+	if !ok || codecName == "" || key == "" {
+		return fmt.Errorf("invalid option %q, expected codec.key=value", opt)
+	}
+	encType, err := GetEncodingType(codecName)
+	if err != nil {
+		return fmt.Errorf("invalid option %q: %v", opt, err)
+	}
+	c, ok := configurable[encType]
+	if !ok {
+		return fmt.Errorf("invalid option %q: %s codec has no options", opt, encType)
+	}
+	if err := c.SetOption(strings.ToLower(key), value); err != nil {
+		return fmt.Errorf("invalid option %q: %v", opt, err)
+	}
+	return nil
+}
@@ -2,21 +2,83 @@ package parquet
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/apache/arrow/go/v16/arrow"
 	"github.com/apache/arrow/go/v16/arrow/array"
 	"github.com/apache/arrow/go/v16/arrow/memory"
 	"github.com/apache/arrow/go/v16/parquet"
 	"github.com/apache/arrow/go/v16/parquet/compress"
-	"github.com/apache/arrow/go/v16/parquet/file"
 	"github.com/apache/arrow/go/v16/parquet/pqarrow"
 	"github.com/goccy/go-json"
 )
 
-type Codec struct{}
+// Codec reads and writes Parquet files of records. The writer infers the
+// column types from every record unless a schema file is given; the reader
+// goes a batch of rows at a time, see Stream.
+type Codec struct {
+	// Schema is a file declaring the columns to write, see readSchema.
+	Schema string
+	// Compression is snappy (the default), zstd, gzip, brotli or none.
+	Compression string
+	// RowGroupSize is the most rows a row group holds; 0 leaves it to the
+	// Parquet library.
+	RowGroupSize int64
+	// NoDictionary turns off dictionary encoding.
+	NoDictionary bool
+	// Columns are the only top-level columns to read, when set.
+	Columns []string
+}
+
+var compressions = map[string]compress.Compression{
+	"snappy": compress.Codecs.Snappy,
+	"zstd":   compress.Codecs.Zstd,
+	"gzip":   compress.Codecs.Gzip,
+	"brotli": compress.Codecs.Brotli,
+	"none":   compress.Codecs.Uncompressed,
+}
+
+// SetOption implements codec.Configurable for the parquet options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "schema":
+		c.Schema = value
+	case "compression":
+		value = strings.ToLower(value)
+		if value == "uncompressed" {
+			value = "none"
+		}
+		if _, ok := compressions[value]; !ok {
+			return fmt.Errorf("unknown compression %q, expected snappy, zstd, gzip, brotli or none", value)
+		}
+		c.Compression = value
+	case "row_group_size":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 1 {
+			return fmt.Errorf("row_group_size must be a positive number of rows")
+		}
+		c.RowGroupSize = n
+	case "dictionary":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		c.NoDictionary = !b
+	case "columns":
+		c.Columns = nil
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				c.Columns = append(c.Columns, name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown option %q, expected schema, compression, row_group_size, dictionary or columns", key)
+	}
+	return nil
+}
 
 func (c *Codec) Marshal(v any) ([]byte, error) {
 	rv := reflect.ValueOf(v)
@@ -28,23 +90,65 @@ func (c *Codec) Marshal(v any) ([]byte, error) {
 		return nil, fmt.Errorf("no data to write")
 	}
 
-	firstElem := rv.Index(0).Interface()
-	firstElemValue, ok := firstElem.(map[string]any)
-	if !ok {
+	// Normalise to []map[string]any via JSON roundtrip, keeping numbers as
+	// written so integers, floats and decimals can be told apart
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var records []map[string]any
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.UseNumber()
+	if err := dec.Decode(&records); err != nil {
 		return nil, fmt.Errorf("slice elements must be of type map[string]any")
 	}
 
-	mem := memory.NewGoAllocator()
-	var fields []arrow.Field
+	var schema *arrow.Schema
+	if c.Schema != "" {
+		if schema, err = readSchema(c.Schema); err != nil {
+			return nil, err
+		}
+	} else {
+		schema = inferSchema(records)
+	}
+	if len(schema.Fields()) == 0 {
+		return nil, fmt.Errorf("records have no fields to write")
+	}
 
-	for key := range firstElemValue {
-		fields = append(fields, arrow.Field{Name: key, Type: arrow.BinaryTypes.String, Nullable: true})
+	mem := memory.NewGoAllocator()
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+	for i, record := range records {
+		for name := range record {
+			if !schema.HasField(name) {
+				return nil, fmt.Errorf("row %d: column %s is not in the schema", i+1, name)
+			}
+		}
+		for j, field := range schema.Fields() {
+			value := record[field.Name]
+			if value == nil && !field.Nullable {
+				return nil, fmt.Errorf("row %d: column %s is not nullable", i+1, field.Name)
+			}
+			if err := appendValue(builder.Field(j), value); err != nil {
+				return nil, fmt.Errorf("row %d: column %s: %v", i+1, field.Name, err)
+			}
+		}
 	}
 
-	schema := arrow.NewSchema(fields, nil)
+	compression := compressions["snappy"]
+	if c.Compression != "" {
+		compression = compressions[c.Compression]
+	}
+	opts := []parquet.WriterProperty{
+		parquet.WithCompression(compression),
+		parquet.WithDictionaryDefault(!c.NoDictionary),
+	}
+	if c.RowGroupSize > 0 {
+		opts = append(opts, parquet.WithMaxRowGroupLength(c.RowGroupSize))
+	}
 
 	var buf bytes.Buffer
-	props := parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy))
+	props := parquet.NewWriterProperties(opts...)
 	arrowProps := pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema())
 
 	writer, err := pqarrow.NewFileWriter(schema, &buf, props, arrowProps)
@@ -53,28 +157,7 @@ func (c *Codec) Marshal(v any) ([]byte, error) {
 	}
 	defer writer.Close()
 
-	builders := make([]array.Builder, len(fields))
-	for i := range fields {
-		builders[i] = array.NewStringBuilder(mem)
-	}
-
-	for i := 0; i < rv.Len(); i++ {
-		recordMap := rv.Index(i).Interface().(map[string]any)
-		for j, field := range fields {
-			if value, ok := recordMap[field.Name]; ok {
-				builders[j].(*array.StringBuilder).Append(fmt.Sprintf("%v", value))
-			} else {
-				builders[j].(*array.StringBuilder).AppendNull()
-			}
-		}
-	}
-
-	columns := make([]arrow.Array, len(builders))
-	for i, builder := range builders {
-		columns[i] = builder.NewArray()
-	}
-
-	record := array.NewRecord(schema, columns, int64(rv.Len()))
+	record := builder.NewRecord()
 	defer record.Release()
 
 	if err := writer.Write(record); err != nil {
@@ -89,78 +172,42 @@ func (c *Codec) Marshal(v any) ([]byte, error) {
 }
 
 func (c *Codec) Unmarshal(input []byte, v any) error {
-	reader := bytes.NewReader(input)
-
-	parquetFile, err := file.NewParquetReader(reader)
-	if err != nil {
-		return fmt.Errorf("error creating parquet reader: %v", err)
-	}
-	defer parquetFile.Close()
-
-	fileReader, err := pqarrow.NewFileReader(parquetFile, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
-	if err != nil {
-		return fmt.Errorf("error creating arrow file reader: %v", err)
-	}
-
-	// Read the whole table
-	table, err := fileReader.ReadTable(context.Background())
-	if err != nil {
-		return fmt.Errorf("error reading table: %v", err)
-	}
-	defer table.Release()
-
-	// Convert table to records
-	tableReader := array.NewTableReader(table, 1000) // batch size
-	defer tableReader.Release()
-
-	var records []map[string]any
-
-	for tableReader.Next() {
-		record := tableReader.Record()
-		schema := record.Schema()
-		numRows := record.NumRows()
-		numCols := record.NumCols()
-
-		for i := range numRows {
-			rowMap := make(map[string]any)
-			for j := 0; j < int(numCols); j++ {
-				field := schema.Field(j)
-				column := record.Column(j)
-
-				if column.IsNull(int(i)) {
-					rowMap[field.Name] = nil
-				} else {
-					switch arr := column.(type) {
-					case *array.String:
-						rowMap[field.Name] = arr.Value(int(i))
-					case *array.Int64:
-						rowMap[field.Name] = arr.Value(int(i))
-					case *array.Float64:
-						rowMap[field.Name] = arr.Value(int(i))
-					case *array.Boolean:
-						rowMap[field.Name] = arr.Value(int(i))
-					case *array.Int32:
-						rowMap[field.Name] = arr.Value(int(i))
-					case *array.Float32:
-						rowMap[field.Name] = arr.Value(int(i))
-					default:
-						rowMap[field.Name] = fmt.Sprintf("%v", column.GetOneForMarshal(int(i)))
-					}
-				}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("provided value must be a non-nil pointer")
+	}
+	records := []any{}
+	if err := c.Stream(bytes.NewReader(input), Scan{}, func(_ int, row any) { records = append(records, row) }); err != nil {
+		return err
+	}
+
+	// rows are assigned as Stream gives them when v can hold them, to keep
+	// their types, and converted through JSON otherwise
+	out := rv.Elem()
+	if reflect.TypeOf(records).AssignableTo(out.Type()) {
+		out.Set(reflect.ValueOf(records))
+		return nil
+	}
+	if out.Kind() == reflect.Slice {
+		rows := reflect.MakeSlice(out.Type(), 0, len(records))
+		for _, row := range records {
+			if !reflect.TypeOf(row).AssignableTo(out.Type().Elem()) {
+				rows = reflect.Value{}
+				break
 			}
-			records = append(records, rowMap)
+			rows = reflect.Append(rows, reflect.ValueOf(row))
+		}
+		if rows.IsValid() {
+			out.Set(rows)
+			return nil
 		}
 	}
-
-	// Always use JSON marshaling for consistent type handling
 	jsonData, err := json.Marshal(records)
 	if err != nil {
 		return fmt.Errorf("error marshaling to JSON: %v", err)
 	}
-
 	if err := json.Unmarshal(jsonData, v); err != nil {
 		return fmt.Errorf("error unmarshaling JSON: %v", err)
 	}
-
 	return nil
 }
@@ -1,7 +1,21 @@
 package parquet
 
 import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+	"github.com/apache/arrow/go/v16/parquet/compress"
+	"github.com/apache/arrow/go/v16/parquet/file"
+	"github.com/apache/arrow/go/v16/parquet/pqarrow"
+	"github.com/goccy/go-json"
 )
 
 func TestBasicParquetMarshalUnmarshal(t *testing.T) {
@@ -58,24 +72,37 @@ func TestBasicParquetMarshalUnmarshal(t *testing.T) {
 
 	// Verify first record structure
 	first := result[0]
-	if first["ID"] != "1" {
-		t.Errorf("Expected ID '1', got %v", first["ID"])
+	if first["ID"] != 1 {
+		t.Errorf("Expected ID 1, got %v", first["ID"])
 	}
 	if first["Name"] != "Alice" {
 		t.Errorf("Expected Name 'Alice', got %v", first["Name"])
 	}
-	if first["Age"] != "30" {
-		t.Errorf("Expected Age '30', got %v", first["Age"])
+	if first["Age"] != 30 {
+		t.Errorf("Expected Age 30, got %v", first["Age"])
 	}
-	if first["Active"] != "true" {
-		t.Errorf("Expected Active 'true', got %v", first["Active"])
+	if first["Active"] != true {
+		t.Errorf("Expected Active true, got %v", first["Active"])
 	}
-	if first["Score"] != "95.5" {
-		t.Errorf("Expected Score '95.5', got %v", first["Score"])
+	if first["Score"] != 95.5 {
+		t.Errorf("Expected Score 95.5, got %v", first["Score"])
 	}
 	if first["Department"] != "Engineering" {
 		t.Errorf("Expected Department 'Engineering', got %v", first["Department"])
 	}
+
+	// Test unmarshaling into structs, which rows are converted to
+	var people []struct {
+		ID    int
+		Name  string
+		Score float64
+	}
+	if err := codec.Unmarshal(data, &people); err != nil {
+		t.Fatalf("Failed to unmarshal parquet data into structs: %v", err)
+	}
+	if len(people) != 3 || people[1].ID != 2 || people[1].Name != "Bob" || people[1].Score != 87.2 {
+		t.Errorf("Unexpected structs: %+v", people)
+	}
 }
 
 func TestEmptyDataHandling(t *testing.T) {
@@ -127,8 +154,8 @@ func TestNilValueHandling(t *testing.T) {
 
 	// The nil value should be handled (converted to null string or similar)
 	first := result[0]
-	if first["ID"] != "1" {
-		t.Errorf("Expected ID '1', got %v", first["ID"])
+	if first["ID"] != 1 {
+		t.Errorf("Expected ID 1, got %v", first["ID"])
 	}
 
 	// Second record should have the value
@@ -228,10 +255,10 @@ func TestLargeDataSet(t *testing.T) {
 	}
 
 	// Spot check a few records
-	if result[0]["ID"] != "1" {
+	if result[0]["ID"] != 1 {
 		t.Errorf("First record ID incorrect: %v", result[0]["ID"])
 	}
-	if result[999]["ID"] != "1000" {
+	if result[999]["ID"] != 1000 {
 		t.Errorf("Last record ID incorrect: %v", result[999]["ID"])
 	}
 }
@@ -317,3 +344,343 @@ func TestRoundTripConsistency(t *testing.T) {
 		}
 	}
 }
+
+// fileSchema returns the Arrow schema and metadata of a written file.
+func fileSchema(t *testing.T, data []byte) (*arrow.Schema, *file.Reader) {
+	t.Helper()
+	pf, err := file.NewParquetReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := fr.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema, pf
+}
+
+func TestInferredTypes(t *testing.T) {
+	records := []any{
+		map[string]any{"id": 1, "price": 1.5, "ok": true, "at": "2024-01-02T03:04:05Z", "day": "2024-01-02", "tags": []any{"a"}},
+		map[string]any{"id": 2, "price": 2, "ok": nil, "at": "2024-01-02", "day": "2024-01-03", "tags": []any{},
+			"owner": map[string]any{"name": "x", "age": 3}, "mixed": 1, "amount": "0"},
+		map[string]any{"id": 3, "mixed": "one", "late": 1, "amount": 1,
+			"exact": json.Number("12345678901234567.25"), "owner": map[string]any{"name": "y", "team": "z"}},
+	}
+	data, err := (&Codec{}).Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, _ := fileSchema(t, data)
+	want := map[string]string{
+		"id":     "int64",
+		"price":  "float64",
+		"ok":     "bool",
+		"at":     "timestamp[us, tz=UTC]",
+		"day":    "date32",
+		"tags":   "list<list: utf8, nullable>",
+		"owner":  "struct<age: int64, name: utf8, team: utf8>",
+		"mixed":  "utf8",
+		"late":   "int64",
+		"amount": "utf8",
+		"exact":  "decimal(19, 2)",
+	}
+	if len(schema.Fields()) != len(want) {
+		t.Errorf("got columns %v", schema)
+	}
+	for _, f := range schema.Fields() {
+		if got := f.Type.String(); got != want[f.Name] {
+			t.Errorf("column %s is %s, want %s", f.Name, got, want[f.Name])
+		}
+	}
+}
+
+func TestSchemaFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	schemaJSON := `[
+		{"name": "id", "type": "int32", "nullable": false},
+		{"name": "price", "type": "decimal(10, 2)"},
+		{"name": "at", "type": "timestamp(ms, UTC)"},
+		{"name": "attrs", "type": "map<string, list<int16>>"},
+		{"name": "raw", "type": "binary"},
+		{"name": "point", "type": "struct<x: float32, y: float32>"}
+	]`
+	if err := os.WriteFile(path, []byte(schemaJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Codec{}
+	if err := c.SetOption("schema", path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.Marshal([]any{
+		map[string]any{"id": 1, "price": "19.99", "at": "2024-01-02T03:04:05.123Z", "attrs": map[string]any{"a": []any{1, 2}}, "raw": "AAE=", "point": map[string]any{"x": 1.5}},
+		map[string]any{"id": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, _ := fileSchema(t, data)
+	var types []string
+	for _, f := range schema.Fields() {
+		types = append(types, f.Name+" "+f.Type.String())
+	}
+	want := "id int32, price decimal(10, 2), at timestamp[ms, tz=UTC], attrs map<utf8, list<list: int16, nullable>, items_nullable>, raw binary, point struct<x: float32, y: float32>"
+	if got := strings.Join(types, ", "); got != want {
+		t.Errorf("got schema\n%s\nwant\n%s", got, want)
+	}
+	if schema.Field(0).Nullable {
+		t.Errorf("id should not be nullable")
+	}
+
+	for _, tt := range []struct {
+		record map[string]any
+		err    string
+	}{
+		{map[string]any{"price": 1}, "column id is not nullable"},
+		{map[string]any{"id": 1, "other": 1}, "column other is not in the schema"},
+		{map[string]any{"id": 1, "price": "123456789.5"}, "does not fit"},
+		{map[string]any{"id": 1 << 40}, "is not a 32-bit integer"},
+		{map[string]any{"id": 1, "raw": "%%"}, "must be base64"},
+		{map[string]any{"id": 1, "point": map[string]any{"z": 1}}, "z is not a field"},
+	} {
+		_, err := c.Marshal([]any{tt.record})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want %q", tt.record, err, tt.err)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	for _, bad := range []string{"", "int128", "list<int64", "decimal(0)", "decimal(5, 6)", "timestamp(h)", "struct<>", "map<string>", "int64 x"} {
+		if _, err := parseType(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+	dt, err := parseType("decimal(50, 4)")
+	if err != nil || dt.String() != "decimal256(50, 4)" {
+		t.Errorf("got %v, %v", dt, err)
+	}
+}
+
+func TestWriterOptions(t *testing.T) {
+	records := make([]any, 10)
+	for i := range records {
+		records[i] = map[string]any{"n": i, "s": "same"}
+	}
+	c := &Codec{}
+	for _, opt := range [][2]string{{"compression", "zstd"}, {"row_group_size", "4"}, {"dictionary", "false"}} {
+		if err := c.SetOption(opt[0], opt[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := c.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pf := fileSchema(t, data)
+	if n := pf.NumRowGroups(); n != 3 {
+		t.Errorf("got %d row groups, want 3", n)
+	}
+	col, err := pf.MetaData().RowGroup(0).ColumnChunk(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if col.Compression() != compress.Codecs.Zstd {
+		t.Errorf("compression is %v, want zstd", col.Compression())
+	}
+	if col.HasDictionaryPage() {
+		t.Errorf("expected no dictionary page")
+	}
+
+	for _, opt := range [][2]string{{"compression", "lzma"}, {"row_group_size", "0"}, {"dictionary", "maybe"}, {"nope", "1"}} {
+		if err := c.SetOption(opt[0], opt[1]); err == nil {
+			t.Errorf("%v: expected an error", opt)
+		}
+	}
+}
+
+func TestReadTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	schemaJSON := `[
+		{"name": "at", "type": "timestamp(ms, Asia/Tokyo)"},
+		{"name": "naive", "type": "timestamp(ns)"},
+		{"name": "day", "type": "date"},
+		{"name": "price", "type": "decimal(10, 2)"},
+		{"name": "wide", "type": "decimal(38, 10)"},
+		{"name": "raw", "type": "binary"},
+		{"name": "ratio", "type": "float32"},
+		{"name": "big", "type": "int64"},
+		{"name": "tags", "type": "list<string>"},
+		{"name": "attrs", "type": "map<int32, list<int16>>"},
+		{"name": "point", "type": "struct<x: float64, label: string>"}
+	]`
+	if err := os.WriteFile(path, []byte(schemaJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Codec{}
+	if err := c.SetOption("schema", path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.Marshal([]any{map[string]any{
+		"at":    "2024-01-02T03:04:05.123Z",
+		"naive": "2024-01-02T03:04:05.000000001Z",
+		"day":   "2024-02-29",
+		"price": "-19.90",
+		"wide":  "1234567890123456789012345678.0123456789",
+		"raw":   "AP8=",
+		"ratio": 87.2,
+		"big":   json.Number("9007199254740993"),
+		"tags":  []any{"a", nil},
+		"attrs": map[string]any{"2": []any{1}, "10": []any{}},
+		"point": map[string]any{"x": 1.5},
+	}, map[string]any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []any
+	if err := (&Codec{}).Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		map[string]any{
+			"at":    "2024-01-02T12:04:05.123+09:00",
+			"naive": "2024-01-02T03:04:05.000000001Z",
+			"day":   "2024-02-29",
+			"price": json.Number("-19.90"),
+			"wide":  json.Number("1234567890123456789012345678.0123456789"),
+			"raw":   "AP8=",
+			"ratio": 87.2,
+			"big":   "9007199254740993",
+			"tags":  []any{"a", nil},
+			"attrs": map[string]any{"2": []any{1}, "10": []any{}},
+			"point": map[string]any{"x": 1.5, "label": nil},
+		},
+		map[string]any{
+			"at": nil, "naive": nil, "day": nil, "price": nil, "wide": nil, "raw": nil,
+			"ratio": nil, "big": nil, "tags": nil, "attrs": nil, "point": nil,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	for _, tt := range []struct {
+		n     int64
+		scale int32
+		want  json.Number
+	}{
+		{1990, 2, "19.90"},
+		{-5, 3, "-0.005"},
+		{12, -3, "12000"},
+		{1234567890123456789, 4, "123456789012345.6789"},
+		{-1, 20, "-0.00000000000000000001"},
+		{-12345678901234567, 20, "-0.00012345678901234567"},
+	} {
+		if got := decimal(big.NewInt(tt.n), tt.scale); got != tt.want {
+			t.Errorf("decimal(%d, %d) = %v, want %v", tt.n, tt.scale, got, tt.want)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	// three row groups of four rows: days 1-4, 5-8 and 9-12 of January
+	var records []any
+	for i := 1; i <= 12; i++ {
+		record := map[string]any{
+			"day":   fmt.Sprintf("2024-01-%02d", i),
+			"n":     i,
+			"ratio": float64(i) / 2,
+			"name":  fmt.Sprintf("row%02d", i),
+			"tags":  []any{"x"},
+		}
+		if i == 6 {
+			record["n"] = nil
+		}
+		records = append(records, record)
+	}
+	c := &Codec{}
+	if err := c.SetOption("row_group_size", "4"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		filters []Filter
+		rows    int
+	}{
+		{nil, 12},
+		{[]Filter{{"day", ">=", "2024-01-09"}}, 4},
+		{[]Filter{{"day", ">", "2024-01-12"}}, 0},
+		{[]Filter{{"day", "<", "2024-01-05"}}, 4},
+		{[]Filter{{"day", "==", "2024-01-06"}}, 4},
+		{[]Filter{{"name", "!=", "row01"}}, 12},
+		{[]Filter{{"n", ">=", float64(5)}, {"n", "<=", float64(8)}}, 4},
+		// null is less than any number, so the group with a null stays
+		{[]Filter{{"n", "<", float64(5)}}, 8},
+		// NaN would be a string, greater than any number
+		{[]Filter{{"ratio", ">", float64(100)}}, 12},
+		{[]Filter{{"ratio", "<=", float64(1)}}, 4},
+		// a string never equals a number
+		{[]Filter{{"day", "==", float64(1)}}, 12},
+		{[]Filter{{"tags", "==", "x"}}, 12},
+		{[]Filter{{"missing", "==", "x"}}, 12},
+	} {
+		rows := 0
+		if err := (&Codec{}).Stream(bytes.NewReader(data), Scan{Filters: tt.filters}, func(int, any) { rows++ }); err != nil {
+			t.Fatal(err)
+		}
+		if rows != tt.rows {
+			t.Errorf("%v: read %d rows, want %d", tt.filters, rows, tt.rows)
+		}
+	}
+
+	// rows keep their index in the file
+	var indices []int
+	scan := Scan{Filters: []Filter{{"day", ">=", "2024-01-09"}}}
+	if err := (&Codec{}).Stream(bytes.NewReader(data), scan, func(index int, _ any) { indices = append(indices, index) }); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{8, 9, 10, 11}; !reflect.DeepEqual(indices, want) {
+		t.Errorf("got indices %v, want %v", indices, want)
+	}
+
+	var got []any
+	scan = Scan{Columns: []string{"name", "nope"}, Filters: []Filter{{"day", "<", "2024-01-02"}}}
+	if err := (&Codec{}).Stream(bytes.NewReader(data), scan, func(_ int, row any) { got = append(got, row) }); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		map[string]any{"name": "row01"}, map[string]any{"name": "row02"},
+		map[string]any{"name": "row03"}, map[string]any{"name": "row04"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// the codec's columns are checked and win over the query's
+	strict := &Codec{}
+	if err := strict.SetOption("columns", "n, day"); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	if err := strict.Stream(bytes.NewReader(data), Scan{Columns: []string{"name"}}, func(_ int, row any) { got = append(got, row) }); err != nil {
+		t.Fatal(err)
+	}
+	if first := got[0]; !reflect.DeepEqual(first, map[string]any{"n": 1, "day": "2024-01-01"}) {
+		t.Errorf("got %v", first)
+	}
+	strict.SetOption("columns", "nope")
+	if err := strict.Stream(bytes.NewReader(data), Scan{}, func(int, any) {}); err == nil || !strings.Contains(err.Error(), "column nope is not in the file") {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package parquet
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/goccy/go-json"
+)
+
+// value converts row i of arr to a JSON value. Lists become arrays, structs
+// and maps objects, dates and timestamps RFC 3339 strings in the column's
+// time zone, and binary base64. Integers that jq cannot hold exactly are
+// their decimal text, and decimals are json.Number, exact whatever their
+// precision. Other numbers are ints and float64s, as jq takes them.
+func value(arr arrow.Array, i int) (any, error) {
+	if arr.IsNull(i) {
+		return nil, nil
+	}
+	switch arr := arr.(type) {
+	case *array.Boolean:
+		return arr.Value(i), nil
+	case *array.String:
+		return arr.Value(i), nil
+	case *array.LargeString:
+		return arr.Value(i), nil
+	case *array.Binary:
+		return base64.StdEncoding.EncodeToString(arr.Value(i)), nil
+	case *array.LargeBinary:
+		return base64.StdEncoding.EncodeToString(arr.Value(i)), nil
+	case *array.FixedSizeBinary:
+		return base64.StdEncoding.EncodeToString(arr.Value(i)), nil
+	case *array.Int8:
+		return int(arr.Value(i)), nil
+	case *array.Int16:
+		return int(arr.Value(i)), nil
+	case *array.Int32:
+		return int(arr.Value(i)), nil
+	case *array.Int64:
+		return util.Int64(arr.Value(i)), nil
+	case *array.Uint8:
+		return int(arr.Value(i)), nil
+	case *array.Uint16:
+		return int(arr.Value(i)), nil
+	case *array.Uint32:
+		return int(arr.Value(i)), nil
+	case *array.Uint64:
+		return util.Uint64(arr.Value(i)), nil
+	case *array.Float16:
+		return util.Float32(arr.Value(i).Float32()), nil
+	case *array.Float32:
+		return util.Float32(arr.Value(i)), nil
+	case *array.Float64:
+		return util.Float(arr.Value(i)), nil
+	case *array.Decimal128:
+		dt := arr.DataType().(*arrow.Decimal128Type)
+		return decimal(arr.Value(i).BigInt(), dt.Scale), nil
+	case *array.Decimal256:
+		dt := arr.DataType().(*arrow.Decimal256Type)
+		return decimal(arr.Value(i).BigInt(), dt.Scale), nil
+	case *array.Date32:
+		return arr.Value(i).ToTime().Format(time.DateOnly), nil
+	case *array.Date64:
+		return arr.Value(i).ToTime().Format(time.DateOnly), nil
+	case *array.Time32:
+		unit := arr.DataType().(*arrow.Time32Type).Unit
+		return arr.Value(i).FormattedString(unit), nil
+	case *array.Time64:
+		unit := arr.DataType().(*arrow.Time64Type).Unit
+		return arr.Value(i).FormattedString(unit), nil
+	case *array.Timestamp:
+		toTime, err := arr.DataType().(*arrow.TimestampType).GetToTimeFunc()
+		if err != nil {
+			return nil, err
+		}
+		return toTime(arr.Value(i)).Format(time.RFC3339Nano), nil
+	case *array.Duration:
+		unit := arr.DataType().(*arrow.DurationType).Unit
+		return (time.Duration(arr.Value(i)) * unit.Multiplier()).String(), nil
+	case *array.Map:
+		// a map is also a list, of key and value structs, so it comes first
+		start, end := arr.ValueOffsets(i)
+		obj := make(map[string]any, end-start)
+		for j := int(start); j < int(end); j++ {
+			k, err := value(arr.Keys(), j)
+			if err != nil {
+				return nil, err
+			}
+			item, err := value(arr.Items(), j)
+			if err != nil {
+				return nil, err
+			}
+			obj[keyText(k)] = item
+		}
+		return obj, nil
+	case array.ListLike:
+		start, end := arr.ValueOffsets(i)
+		items := make([]any, 0, end-start)
+		for j := int(start); j < int(end); j++ {
+			item, err := value(arr.ListValues(), j)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case *array.Struct:
+		dt := arr.DataType().(*arrow.StructType)
+		obj := make(map[string]any, arr.NumField())
+		for j, f := range dt.Fields() {
+			v, err := value(arr.Field(j), i)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name, err)
+			}
+			obj[f.Name] = v
+		}
+		return obj, nil
+	case *array.Dictionary:
+		return value(arr.Dictionary(), arr.GetValueIndex(i))
+	case array.ExtensionArray:
+		return value(arr.Storage(), i)
+	}
+	return arr.GetOneForMarshal(i), nil
+}
+
+// decimal returns the decimal n × 10^-scale as its exact text, with the
+// column's scale, so every value of a column is the same kind of number.
+func decimal(n *big.Int, scale int32) json.Number {
+	digits := new(big.Int).Abs(n).String()
+	sign := ""
+	if n.Sign() < 0 {
+		sign = "-"
+	}
+	var s string
+	switch {
+	case scale <= 0:
+		s = sign + digits + strings.Repeat("0", int(-scale))
+	case int(scale) < len(digits):
+		s = sign + digits[:len(digits)-int(scale)] + "." + digits[len(digits)-int(scale):]
+	default:
+		s = sign + "0." + strings.Repeat("0", int(scale)-len(digits)) + digits
+	}
+	return json.Number(s)
+}
+
+// keyText is a map key as an object key: strings as they are and other
+// values as JSON writes them.
+func keyText(k any) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	s, err := text(k)
+	if err != nil {
+		return fmt.Sprint(k)
+	}
+	return s
+}
@@ -0,0 +1,443 @@
+package parquet
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/goccy/go-json"
+)
+
+// schemaField is a column of a schema file. The file is a JSON array of
+// columns, or an object holding one under "fields":
+//
+//	[{"name": "id", "type": "int64", "nullable": false},
+//	 {"name": "price", "type": "decimal(10, 2)"},
+//	 {"name": "tags", "type": "list<string>"}]
+type schemaField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable *bool  `json:"nullable"`
+}
+
+func readSchema(path string) (*arrow.Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema: %v", err)
+	}
+	var fields []schemaField
+	if err := json.Unmarshal(data, &fields); err != nil {
+		var wrapped struct {
+			Fields []schemaField `json:"fields"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil || wrapped.Fields == nil {
+			return nil, fmt.Errorf("error reading schema %s: expected an array of {name, type} columns", path)
+		}
+		fields = wrapped.Fields
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("schema %s has no columns", path)
+	}
+	columns := make([]arrow.Field, len(fields))
+	seen := make(map[string]bool)
+	for i, f := range fields {
+		if f.Name == "" {
+			return nil, fmt.Errorf("schema %s: column %d has no name", path, i+1)
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("schema %s: column %s is declared twice", path, f.Name)
+		}
+		seen[f.Name] = true
+		dt, err := parseType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("schema %s: column %s: %v", path, f.Name, err)
+		}
+		columns[i] = arrow.Field{Name: f.Name, Type: dt, Nullable: f.Nullable == nil || *f.Nullable}
+	}
+	return arrow.NewSchema(columns, nil), nil
+}
+
+// parseType parses a column type: bool, int8 to int64, uint8 to uint64,
+// float32, float64, string, binary, date, timestamp, timestamp(unit) or
+// timestamp(unit, zone), decimal(precision, scale), list<T>, map<K, V> or
+// struct<name: T, ...>.
+func parseType(s string) (arrow.DataType, error) {
+	p := &typeParser{s: s}
+	dt, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q in type %q", p.s[p.pos:], s)
+	}
+	return dt, nil
+}
+
+type typeParser struct {
+	s   string
+	pos int
+}
+
+func (p *typeParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *typeParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (isWordByte(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '-' || c == '/' || c == '+' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// accept consumes c if it comes next.
+func (p *typeParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *typeParser) expect(c byte) error {
+	if !p.accept(c) {
+		return fmt.Errorf("expected %q in type %q", c, p.s)
+	}
+	return nil
+}
+
+func (p *typeParser) parse() (arrow.DataType, error) {
+	name := strings.ToLower(p.word())
+	switch name {
+	case "bool", "boolean":
+		return arrow.FixedWidthTypes.Boolean, nil
+	case "int8":
+		return arrow.PrimitiveTypes.Int8, nil
+	case "int16":
+		return arrow.PrimitiveTypes.Int16, nil
+	case "int32", "int":
+		return arrow.PrimitiveTypes.Int32, nil
+	case "int64", "long":
+		return arrow.PrimitiveTypes.Int64, nil
+	case "uint8":
+		return arrow.PrimitiveTypes.Uint8, nil
+	case "uint16":
+		return arrow.PrimitiveTypes.Uint16, nil
+	case "uint32":
+		return arrow.PrimitiveTypes.Uint32, nil
+	case "uint64":
+		return arrow.PrimitiveTypes.Uint64, nil
+	case "float32", "float":
+		return arrow.PrimitiveTypes.Float32, nil
+	case "float64", "double":
+		return arrow.PrimitiveTypes.Float64, nil
+	case "string", "utf8":
+		return arrow.BinaryTypes.String, nil
+	case "binary", "bytes":
+		return arrow.BinaryTypes.Binary, nil
+	case "date", "date32":
+		return arrow.FixedWidthTypes.Date32, nil
+	case "timestamp":
+		t := &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+		if !p.accept('(') {
+			return t, nil
+		}
+		unit, ok := timeUnits[p.word()]
+		if !ok {
+			return nil, fmt.Errorf("unknown timestamp unit in type %q, expected s, ms, us or ns", p.s)
+		}
+		t.Unit, t.TimeZone = unit, ""
+		if p.accept(',') {
+			t.TimeZone = p.word()
+			if _, err := time.LoadLocation(t.TimeZone); err != nil {
+				return nil, fmt.Errorf("unknown time zone %q", t.TimeZone)
+			}
+		}
+		return t, p.expect(')')
+	case "decimal":
+		if err := p.expect('('); err != nil {
+			return nil, err
+		}
+		precision, err := strconv.Atoi(p.word())
+		if err != nil {
+			return nil, fmt.Errorf("invalid precision in type %q", p.s)
+		}
+		scale := 0
+		if p.accept(',') {
+			if scale, err = strconv.Atoi(p.word()); err != nil {
+				return nil, fmt.Errorf("invalid scale in type %q", p.s)
+			}
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		switch {
+		case precision < 1 || precision > 76 || scale < 0 || scale > precision:
+			return nil, fmt.Errorf("invalid decimal(%d, %d): precision is 1 to 76 and scale 0 to the precision", precision, scale)
+		case precision > 38:
+			return &arrow.Decimal256Type{Precision: int32(precision), Scale: int32(scale)}, nil
+		}
+		return &arrow.Decimal128Type{Precision: int32(precision), Scale: int32(scale)}, nil
+	case "list":
+		if err := p.expect('<'); err != nil {
+			return nil, err
+		}
+		elem, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		return arrow.ListOf(elem), p.expect('>')
+	case "map":
+		if err := p.expect('<'); err != nil {
+			return nil, err
+		}
+		key, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+		value, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		return arrow.MapOf(key, value), p.expect('>')
+	case "struct":
+		if err := p.expect('<'); err != nil {
+			return nil, err
+		}
+		var fields []arrow.Field
+		for !p.accept('>') {
+			if len(fields) > 0 {
+				if err := p.expect(','); err != nil {
+					return nil, err
+				}
+			}
+			name := p.word()
+			if name == "" {
+				return nil, fmt.Errorf("expected a field name in type %q", p.s)
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			dt, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, arrow.Field{Name: name, Type: dt, Nullable: true})
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("struct in type %q has no fields", p.s)
+		}
+		return arrow.StructOf(fields...), nil
+	case "":
+		return nil, fmt.Errorf("expected a type in %q", p.s)
+	}
+	return nil, fmt.Errorf("unknown type %q", name)
+}
+
+var timeUnits = map[string]arrow.TimeUnit{
+	"s":  arrow.Second,
+	"ms": arrow.Millisecond,
+	"us": arrow.Microsecond,
+	"ns": arrow.Nanosecond,
+}
+
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBool
+	kindInt
+	kindDecimal
+	kindFloat
+	kindDate
+	kindTimestamp
+	kindString
+	kindList
+	kindStruct
+)
+
+// inferred is the type of a column, widened as values are added: integers
+// widen to decimals and floats, dates to timestamps, and values that agree
+// on nothing else to strings, which hold them as JSON.
+type inferred struct {
+	kind kind
+	// digits before and after the point, for decimals
+	intDigits, scale int
+	elem             *inferred
+	fields           map[string]*inferred
+}
+
+// maxDecimal is the precision of decimal128, the widest decimal inferred.
+const maxDecimal = 38
+
+func inferSchema(records []map[string]any) *arrow.Schema {
+	columns := &inferred{kind: kindStruct, fields: make(map[string]*inferred)}
+	for _, r := range records {
+		columns.add(map[string]any(r))
+	}
+	fields := columns.structFields()
+	return arrow.NewSchema(fields, nil)
+}
+
+func (t *inferred) add(v any) {
+	if v == nil {
+		return
+	}
+	next := valueKind(v)
+	switch {
+	case t.kind == kindNull:
+		*t = inferred{kind: next.kind, intDigits: next.intDigits, scale: next.scale}
+		if next.kind == kindList {
+			t.elem = &inferred{}
+		}
+		if next.kind == kindStruct {
+			t.fields = make(map[string]*inferred)
+		}
+	case t.kind == next.kind:
+		if t.kind == kindDecimal {
+			t.widenDecimal(next)
+		}
+	case isNumber(t.kind) && isNumber(next.kind):
+		switch {
+		case t.kind == kindFloat || next.kind == kindFloat:
+			t.kind = kindFloat
+		default:
+			// an integer and a decimal
+			t.kind = kindDecimal
+			t.widenDecimal(next)
+		}
+	case (t.kind == kindDate && next.kind == kindTimestamp) || (t.kind == kindTimestamp && next.kind == kindDate):
+		t.kind = kindTimestamp
+	default:
+		*t = inferred{kind: kindString}
+	}
+	if t.kind == kindDecimal && t.intDigits+t.scale > maxDecimal {
+		t.kind = kindFloat
+	}
+
+	switch t.kind {
+	case kindList:
+		for _, item := range v.([]any) {
+			t.elem.add(item)
+		}
+	case kindStruct:
+		for k, item := range v.(map[string]any) {
+			f, ok := t.fields[k]
+			if !ok {
+				f = &inferred{}
+				t.fields[k] = f
+			}
+			f.add(item)
+		}
+	}
+}
+
+func (t *inferred) widenDecimal(next inferred) {
+	t.intDigits = max(t.intDigits, next.intDigits)
+	t.scale = max(t.scale, next.scale)
+}
+
+func isNumber(k kind) bool {
+	return k == kindInt || k == kindDecimal || k == kindFloat
+}
+
+// valueKind is the type of a single value. Numbers are decimals when a
+// float64 cannot hold them exactly, and strings are dates or timestamps
+// when they all parse as one.
+func valueKind(v any) inferred {
+	switch v := v.(type) {
+	case bool:
+		return inferred{kind: kindBool}
+	case json.Number:
+		return numberKind(v.String())
+	case string:
+		if _, err := time.Parse(time.DateOnly, v); err == nil {
+			return inferred{kind: kindDate}
+		}
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return inferred{kind: kindTimestamp}
+		}
+		return inferred{kind: kindString}
+	case []any:
+		return inferred{kind: kindList}
+	case map[string]any:
+		return inferred{kind: kindStruct}
+	}
+	return inferred{kind: kindString}
+}
+
+// float64Digits is how many significant digits a float64 always holds.
+const float64Digits = 15
+
+func numberKind(s string) inferred {
+	if strings.ContainsAny(s, "eE") {
+		return inferred{kind: kindFloat}
+	}
+	digits := strings.TrimLeft(strings.TrimPrefix(s, "-"), "0")
+	intPart, frac, isFrac := strings.Cut(digits, ".")
+	if !isFrac {
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return inferred{kind: kindInt, intDigits: len(intPart)}
+		}
+		return inferred{kind: kindDecimal, intDigits: len(intPart)}
+	}
+	significant := strings.TrimLeft(intPart+frac, "0")
+	if len(significant) > float64Digits {
+		return inferred{kind: kindDecimal, intDigits: len(intPart), scale: len(frac)}
+	}
+	return inferred{kind: kindFloat}
+}
+
+func (t *inferred) structFields() []arrow.Field {
+	names := make([]string, 0, len(t.fields))
+	for name := range t.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]arrow.Field, len(names))
+	for i, name := range names {
+		fields[i] = arrow.Field{Name: name, Type: t.fields[name].dataType(), Nullable: true}
+	}
+	return fields
+}
+
+func (t *inferred) dataType() arrow.DataType {
+	switch t.kind {
+	case kindBool:
+		return arrow.FixedWidthTypes.Boolean
+	case kindInt:
+		return arrow.PrimitiveTypes.Int64
+	case kindDecimal:
+		return &arrow.Decimal128Type{Precision: int32(max(t.intDigits+t.scale, 1)), Scale: int32(t.scale)}
+	case kindFloat:
+		return arrow.PrimitiveTypes.Float64
+	case kindDate:
+		return arrow.FixedWidthTypes.Date32
+	case kindTimestamp:
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case kindList:
+		return arrow.ListOf(t.elem.dataType())
+	case kindStruct:
+		// parquet has no empty groups, so empty objects are kept as JSON
+		if len(t.fields) > 0 {
+			return arrow.StructOf(t.structFields()...)
+		}
+	}
+	// columns of strings, of values of mixed types and of only nulls
+	return arrow.BinaryTypes.String
+}
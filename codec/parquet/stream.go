@@ -0,0 +1,291 @@
+package parquet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+	"github.com/apache/arrow/go/v16/parquet"
+	"github.com/apache/arrow/go/v16/parquet/file"
+	"github.com/apache/arrow/go/v16/parquet/metadata"
+	"github.com/apache/arrow/go/v16/parquet/pqarrow"
+)
+
+// batchSize is the number of rows read at a time.
+const batchSize = 4096
+
+// Scan describes what a query reads of a file, so that Stream can leave
+// out the rest.
+type Scan struct {
+	// Columns are the top-level columns the query uses; nil reads them
+	// all. Names that are not in the file are ignored. The codec's own
+	// Columns take precedence.
+	Columns []string
+	// Filters are conditions every row the query keeps meets. Row groups
+	// whose statistics show that no row meets one are skipped; rows are
+	// not filtered, so the query must still test them.
+	Filters []Filter
+}
+
+// Filter compares a top-level column with a string or float64 Value, as
+// jq does, with Op one of ==, !=, <, <=, > or >=.
+type Filter struct {
+	Column string
+	Op     string
+	Value  any
+}
+
+// Stream reads r one batch of rows at a time, holding only the current
+// batch in memory, and calls emit with each row as Unmarshal lays it out and
+// its index in the file, counting the rows of skipped row groups.
+func (c *Codec) Stream(r parquet.ReaderAtSeeker, scan Scan, emit func(index int, row any)) error {
+	mem := memory.NewGoAllocator()
+	props := parquet.NewReaderProperties(mem)
+	// read pages as they are needed rather than whole column chunks
+	props.BufferedStreamEnabled = true
+	parquetFile, err := file.NewParquetReader(r, file.WithReadProps(props))
+	if err != nil {
+		return fmt.Errorf("error creating parquet reader: %v", err)
+	}
+	defer parquetFile.Close()
+
+	fileReader, err := pqarrow.NewFileReader(parquetFile, pqarrow.ArrowReadProperties{BatchSize: batchSize}, mem)
+	if err != nil {
+		return fmt.Errorf("error creating arrow file reader: %v", err)
+	}
+	schema, err := fileReader.Schema()
+	if err != nil {
+		return fmt.Errorf("error reading schema: %v", err)
+	}
+	if len(schema.Fields()) == 0 {
+		return nil
+	}
+
+	fields, err := c.project(schema, scan.Columns)
+	if err != nil {
+		return err
+	}
+	var leaves []int
+	if fields != nil {
+		if leaves, err = fileReader.Manifest.GetFieldIndices(fields); err != nil {
+			return err
+		}
+	}
+	groups, err := rowGroups(fileReader, scan.Filters)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	records, err := fileReader.GetRecordReader(context.Background(), leaves, groups)
+	if err != nil {
+		return fmt.Errorf("error reading row groups: %v", err)
+	}
+	defer records.Release()
+	// rows are numbered in the file, counting those of skipped groups
+	md := fileReader.ParquetReader().MetaData()
+	first := make([]int, fileReader.ParquetReader().NumRowGroups())
+	for g := 1; g < len(first); g++ {
+		first[g] = first[g-1] + int(md.RowGroup(g-1).NumRows())
+	}
+	group, inGroup := 0, 0
+	for records.Next() {
+		record := records.Record()
+		for i := range int(record.NumRows()) {
+			for inGroup == int(md.RowGroup(groups[group]).NumRows()) {
+				group, inGroup = group+1, 0
+			}
+			index := first[groups[group]] + inGroup
+			obj := make(map[string]any, record.NumCols())
+			for j, field := range record.Schema().Fields() {
+				v, err := value(record.Column(j), i)
+				if err != nil {
+					return fmt.Errorf("row %d: column %s: %v", index+1, field.Name, err)
+				}
+				obj[field.Name] = v
+			}
+			emit(index, obj)
+			inGroup++
+		}
+	}
+	if err := records.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("error reading records: %v", err)
+	}
+	return nil
+}
+
+// project returns the indices of the top-level fields to read: the codec's
+// Columns, which must all be in the file, or else the query's, or nil for
+// every field.
+func (c *Codec) project(schema *arrow.Schema, queried []string) ([]int, error) {
+	columns, strict := c.Columns, true
+	if len(columns) == 0 {
+		columns, strict = queried, false
+	}
+	if columns == nil {
+		return nil, nil
+	}
+	var fields []int
+	for _, name := range columns {
+		indices := schema.FieldIndices(name)
+		if len(indices) == 0 && strict {
+			return nil, fmt.Errorf("column %s is not in the file", name)
+		}
+		fields = append(fields, indices...)
+	}
+	if len(fields) == 0 {
+		// no column is needed, but every row still is
+		fields = []int{0}
+	}
+	return fields, nil
+}
+
+// rowGroups returns the row groups that may hold rows meeting filters.
+func rowGroups(fileReader *pqarrow.FileReader, filters []Filter) ([]int, error) {
+	md := fileReader.ParquetReader().MetaData()
+	numRowGroups := fileReader.ParquetReader().NumRowGroups()
+	var groups []int
+	for g := range numRowGroups {
+		skip := false
+		for _, f := range filters {
+			excluded, err := f.excludes(fileReader.Manifest, md.RowGroup(g))
+			if err != nil {
+				return nil, err
+			}
+			if excluded {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+// excludes reports whether the statistics of a row group show that none of
+// its rows meets f. Only top-level string, date, integer and float columns
+// are considered, and their bounds are converted as value converts rows.
+func (f Filter) excludes(manifest *pqarrow.SchemaManifest, rg *metadata.RowGroupMetaData) (bool, error) {
+	var field *pqarrow.SchemaField
+	for i := range manifest.Fields {
+		if manifest.Fields[i].Field.Name == f.Column {
+			field = &manifest.Fields[i]
+			break
+		}
+	}
+	if field == nil || field.ColIndex < 0 {
+		return false, nil
+	}
+	chunk, err := rg.ColumnChunk(field.ColIndex)
+	if err != nil {
+		return false, err
+	}
+	stats, err := chunk.Statistics()
+	if err != nil || stats == nil || !stats.HasMinMax() {
+		return false, err
+	}
+	min, max, ok := bounds(field.Field.Type, stats)
+	if !ok {
+		return false, nil
+	}
+	if _, isFloat := min.(float64); isFloat && isFloatType(field.Field.Type) {
+		// NaN and infinities are strings, which are greater than any
+		// number, so only an upper bound on the numbers says anything
+		if f.Op != "==" && f.Op != "<" && f.Op != "<=" {
+			return false, nil
+		}
+	}
+	lo, okLo := compare(min, f.Value)
+	hi, okHi := compare(max, f.Value)
+	if !okLo || !okHi {
+		return false, nil
+	}
+	// null is less than any value, so it meets != and the less than tests
+	nulls := !stats.HasNullCount() || stats.NullCount() > 0
+	switch f.Op {
+	case "==":
+		return lo > 0 || hi < 0, nil
+	case "!=":
+		return !nulls && lo == 0 && hi == 0, nil
+	case "<":
+		return !nulls && lo >= 0, nil
+	case "<=":
+		return !nulls && lo > 0, nil
+	case ">":
+		return hi <= 0, nil
+	case ">=":
+		return hi < 0, nil
+	}
+	return false, nil
+}
+
+// bounds returns the smallest and largest value of a column chunk as value
+// gives them, a string or a float64.
+func bounds(dt arrow.DataType, stats metadata.TypedStatistics) (any, any, bool) {
+	switch s := stats.(type) {
+	case *metadata.ByteArrayStatistics:
+		if dt.ID() == arrow.STRING || dt.ID() == arrow.LARGE_STRING {
+			return string(s.Min()), string(s.Max()), true
+		}
+	case *metadata.Int32Statistics:
+		switch dt.ID() {
+		case arrow.DATE32:
+			lo := arrow.Date32(s.Min()).ToTime().Format(time.DateOnly)
+			hi := arrow.Date32(s.Max()).ToTime().Format(time.DateOnly)
+			// dates outside years 0 to 9999 do not sort as their text does
+			if len(lo) == len(time.DateOnly) && len(hi) == len(time.DateOnly) && !strings.HasPrefix(lo, "-") {
+				return lo, hi, true
+			}
+		case arrow.INT8, arrow.INT16, arrow.INT32:
+			return float64(s.Min()), float64(s.Max()), true
+		}
+	case *metadata.Int64Statistics:
+		// larger integers are strings
+		if dt.ID() == arrow.INT64 && s.Min() >= -util.MaxExact && s.Max() <= util.MaxExact {
+			return float64(s.Min()), float64(s.Max()), true
+		}
+	case *metadata.Float32Statistics:
+		lo, okLo := util.Float32(s.Min()).(float64)
+		hi, okHi := util.Float32(s.Max()).(float64)
+		return lo, hi, dt.ID() == arrow.FLOAT32 && okLo && okHi
+	case *metadata.Float64Statistics:
+		ok := dt.ID() == arrow.FLOAT64 && !math.IsNaN(s.Min()) && !math.IsNaN(s.Max())
+		return s.Min(), s.Max(), ok
+	}
+	return nil, nil, false
+}
+
+func isFloatType(dt arrow.DataType) bool {
+	return dt.ID() == arrow.FLOAT32 || dt.ID() == arrow.FLOAT64
+}
+
+// compare compares a with b when both are strings or both numbers.
+func compare(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
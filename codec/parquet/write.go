@@ -0,0 +1,230 @@
+package parquet
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/decimal128"
+	"github.com/apache/arrow/go/v16/arrow/decimal256"
+	"github.com/goccy/go-json"
+)
+
+// appendValue appends v, as decoded from JSON with numbers kept as
+// json.Number, to a builder of any of the types parseType and inferSchema
+// give.
+func appendValue(b array.Builder, v any) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	switch b := b.(type) {
+	case *array.StringBuilder:
+		s, err := text(v)
+		if err != nil {
+			return err
+		}
+		b.Append(s)
+	case *array.BinaryBuilder:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected base64 for a binary value, got %v", v)
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("binary values must be base64: %v", err)
+		}
+		b.Append(data)
+	case *array.BooleanBuilder:
+		switch v := v.(type) {
+		case bool:
+			b.Append(v)
+		case string:
+			x, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("expected a bool, got %q", v)
+			}
+			b.Append(x)
+		default:
+			return fmt.Errorf("expected a bool, got %v", v)
+		}
+	case *array.Int8Builder:
+		n, err := util.ParseInt(v, 8)
+		b.Append(int8(n))
+		return err
+	case *array.Int16Builder:
+		n, err := util.ParseInt(v, 16)
+		b.Append(int16(n))
+		return err
+	case *array.Int32Builder:
+		n, err := util.ParseInt(v, 32)
+		b.Append(int32(n))
+		return err
+	case *array.Int64Builder:
+		n, err := util.ParseInt(v, 64)
+		b.Append(n)
+		return err
+	case *array.Uint8Builder:
+		n, err := util.ParseUint(v, 8)
+		b.Append(uint8(n))
+		return err
+	case *array.Uint16Builder:
+		n, err := util.ParseUint(v, 16)
+		b.Append(uint16(n))
+		return err
+	case *array.Uint32Builder:
+		n, err := util.ParseUint(v, 32)
+		b.Append(uint32(n))
+		return err
+	case *array.Uint64Builder:
+		n, err := util.ParseUint(v, 64)
+		b.Append(n)
+		return err
+	case *array.Float32Builder:
+		f, err := util.ParseFloat(v)
+		b.Append(float32(f))
+		return err
+	case *array.Float64Builder:
+		f, err := util.ParseFloat(v)
+		b.Append(f)
+		return err
+	case *array.Decimal128Builder:
+		dt := b.Type().(*arrow.Decimal128Type)
+		s, err := util.NumberText(v)
+		if err != nil {
+			return err
+		}
+		n, err := decimal128.FromString(s, dt.Precision, dt.Scale)
+		if err != nil {
+			return fmt.Errorf("%s does not fit %s", s, dt)
+		}
+		b.Append(n)
+	case *array.Decimal256Builder:
+		dt := b.Type().(*arrow.Decimal256Type)
+		s, err := util.NumberText(v)
+		if err != nil {
+			return err
+		}
+		n, err := decimal256.FromString(s, dt.Precision, dt.Scale)
+		if err != nil {
+			return fmt.Errorf("%s does not fit %s", s, dt)
+		}
+		b.Append(n)
+	case *array.Date32Builder:
+		t, err := timeValue(v)
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Date32FromTime(t))
+	case *array.TimestampBuilder:
+		dt := b.Type().(*arrow.TimestampType)
+		if n, ok := v.(json.Number); ok {
+			// a number is already in the column's unit
+			i, err := n.Int64()
+			if err != nil {
+				return fmt.Errorf("expected a timestamp, got %v", v)
+			}
+			b.Append(arrow.Timestamp(i))
+			return nil
+		}
+		t, err := timeValue(v)
+		if err != nil {
+			return err
+		}
+		ts, err := arrow.TimestampFromTime(t, dt.Unit)
+		if err != nil {
+			return err
+		}
+		b.Append(ts)
+	case *array.MapBuilder:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object for a map, got %v", v)
+		}
+		b.Append(true)
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := appendValue(b.KeyBuilder(), keyValue(b.KeyBuilder(), k)); err != nil {
+				return fmt.Errorf("key %q: %v", k, err)
+			}
+			if err := appendValue(b.ItemBuilder(), m[k]); err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+		}
+	case *array.ListBuilder:
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("expected an array, got %v", v)
+		}
+		b.Append(true)
+		for i, item := range items {
+			if err := appendValue(b.ValueBuilder(), item); err != nil {
+				return fmt.Errorf("[%d]: %v", i, err)
+			}
+		}
+	case *array.StructBuilder:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object, got %v", v)
+		}
+		dt := b.Type().(*arrow.StructType)
+		for k := range m {
+			if _, ok := dt.FieldIdx(k); !ok {
+				return fmt.Errorf("%s is not a field of %s", k, dt)
+			}
+		}
+		b.Append(true)
+		for i, f := range dt.Fields() {
+			if err := appendValue(b.FieldBuilder(i), m[f.Name]); err != nil {
+				return fmt.Errorf("%s: %v", f.Name, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported column type %s", b.Type())
+	}
+	return nil
+}
+
+// keyValue converts a map key, always a string in JSON, to a number when
+// the map's keys are numbers.
+func keyValue(b array.Builder, k string) any {
+	if _, ok := b.(*array.StringBuilder); ok {
+		return k
+	}
+	return json.Number(k)
+}
+
+// text is v as a string column holds it: strings as they are and other
+// values as JSON.
+func text(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// timeValue parses an RFC 3339 timestamp or a date.
+func timeValue(v any) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse(time.DateOnly, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected an RFC 3339 timestamp or a date, got %v", v)
+}
@@ -4,15 +4,26 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"sort"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/JFryy/qq/internal/flatten"
 	"github.com/goccy/go-json"
 )
 
-// Codec handles Java properties file parsing and marshaling
-type Codec struct{}
+// Codec handles Java properties file parsing and marshaling. Nested values
+// are written as flat keys joined by Keys, spring.datasource.hosts[0] by
+// default.
+type Codec struct {
+	Keys flatten.Options
+}
+
+// SetOption implements codec.Configurable for the key flattening options.
+func (c *Codec) SetOption(key, value string) error {
+	return c.Keys.SetOption(key, value)
+}
 
 // Unmarshal parses properties file data into the provided interface
 func (c *Codec) Unmarshal(data []byte, v interface{}) error {
@@ -20,12 +31,29 @@ func (c *Codec) Unmarshal(data []byte, v interface{}) error {
 		return errors.New("v cannot be nil")
 	}
 
-	result, err := c.Parse(string(data))
+	result, raw, err := c.parse(string(data))
 	if err != nil {
 		return err
 	}
 
-	jsonData, err := json.Marshal(result)
+	var value any = result
+	if c.Keys.OnRead {
+		// values are typed as written, so one escaped by escapeValue, such
+		// as \u0074rue, stays a string
+		flat := make(map[string]any, len(result))
+		for k, v := range result {
+			if _, ok := flatten.Infer(raw[k]).(string); ok {
+				flat[k] = v
+			} else {
+				flat[k] = flatten.Infer(v)
+			}
+		}
+		if value, err = c.Keys.Unflatten(flat); err != nil {
+			return err
+		}
+	}
+
+	jsonData, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
@@ -33,7 +61,8 @@ func (c *Codec) Unmarshal(data []byte, v interface{}) error {
 	return json.Unmarshal(jsonData, v)
 }
 
-// Marshal converts data back to properties file format
+// Marshal converts data back to properties file format, flattening nested
+// values
 func (c *Codec) Marshal(v interface{}) ([]byte, error) {
 	// Convert to our expected format first
 	data, err := json.Marshal(v)
@@ -41,22 +70,29 @@ func (c *Codec) Marshal(v interface{}) ([]byte, error) {
 		return nil, err
 	}
 
-	var props map[string]string
-	if err := json.Unmarshal(data, &props); err != nil {
-		return nil, errors.New("properties format only supports simple key-value pairs, cannot convert complex nested structures")
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
 	}
 
-	var lines []string
-	// Sort keys for consistent output
-	keys := make([]string, 0, len(props))
-	for key := range props {
-		keys = append(keys, key)
+	entries, err := c.Keys.Flatten(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].Key == "" {
+		return nil, errors.New("properties format needs an object of key-value pairs")
 	}
-	sort.Strings(keys)
 
-	for _, key := range keys {
-		value := props[key]
-		lines = append(lines, fmt.Sprintf("%s=%s", c.escapeKey(key), c.escapeValue(value)))
+	// Entries come in sorted key order, with array items by index
+	var lines []string
+	for _, e := range entries {
+		value := c.escapeValue(flatten.String(e.Value))
+		if flatten.Retyped(e.Value) {
+			// escape the first character, to read back as a string
+			r, size := utf8.DecodeRuneInString(value)
+			value = fmt.Sprintf(`\u%04x`, r) + value[size:]
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s", c.escapeKey(e.Key), value))
 	}
 
 	return []byte(strings.Join(lines, "\n")), nil
@@ -64,7 +100,15 @@ func (c *Codec) Marshal(v interface{}) ([]byte, error) {
 
 // Parse processes properties file content into key-value pairs
 func (c *Codec) Parse(content string) (map[string]string, error) {
+	result, _, err := c.parse(content)
+	return result, err
+}
+
+// parse returns the values of content, and their text as written, before
+// escapes are handled.
+func (c *Codec) parse(content string) (map[string]string, map[string]string, error) {
 	result := make(map[string]string)
+	raw := make(map[string]string)
 	scanner := bufio.NewScanner(strings.NewReader(content))
 
 	var continuedLine strings.Builder
@@ -99,23 +143,25 @@ func (c *Codec) Parse(content string) (map[string]string, error) {
 		if found {
 			if continuedKey != "" {
 				// This is continuation of a previous value
-				result[continuedKey] = result[continuedKey] + value
+				result[continuedKey] = result[continuedKey] + c.unescapeString(value)
+				raw[continuedKey] += value
 				continuedKey = ""
 			} else {
-				result[key] = value
+				result[key] = c.unescapeString(value)
+				raw[key] = value
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error scanning properties: %v", err)
+		return nil, nil, fmt.Errorf("error scanning properties: %v", err)
 	}
 
-	return result, nil
+	return result, raw, nil
 }
 
-// parseKeyValue extracts key and value from a properties line
-// Handles: key=value, key:value, key value
+// parseKeyValue extracts key and value, with its escapes, from a properties
+// line. Handles: key=value, key:value, key value
 func (c *Codec) parseKeyValue(line string) (string, string, bool) {
 	// Skip leading whitespace
 	line = strings.TrimLeftFunc(line, unicode.IsSpace)
@@ -165,7 +211,7 @@ func (c *Codec) parseKeyValue(line string) (string, string, bool) {
 		return "", "", false
 	}
 
-	return c.unescapeString(key.String()), c.unescapeString(strings.TrimSpace(value)), true
+	return c.unescapeString(key.String()), strings.TrimSpace(value), true
 }
 
 // unescapeString handles Java properties escape sequences
@@ -194,6 +240,14 @@ func (c *Codec) unescapeString(s string) string {
 				result.WriteByte(':')
 			case ' ':
 				result.WriteByte(' ')
+			case 'u':
+				n, err := strconv.ParseUint(s[i+1:min(i+5, len(s))], 16, 16)
+				if err != nil || i+5 > len(s) {
+					result.WriteString(`\u`)
+					break
+				}
+				result.WriteRune(rune(n))
+				i += 4
 			default:
 				// Unknown escape, keep as-is
 				result.WriteByte('\\')
@@ -1,6 +1,7 @@
 package properties
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -252,3 +253,65 @@ key2=value2
 		t.Fatalf("Expected 2 properties, got %d", len(result))
 	}
 }
+
+func TestPropertiesNestedRoundTrip(t *testing.T) {
+	original := map[string]any{
+		"spring": map[string]any{
+			"datasource": map[string]any{"url": "jdbc:h2:mem", "hosts": []any{"a", "b"}},
+		},
+		"server": map[string]any{"port": float64(8080)},
+	}
+
+	codec := &Codec{}
+	data, err := codec.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to marshal nested data: %v", err)
+	}
+	expected := "server.port=8080\nspring.datasource.hosts[0]=a\nspring.datasource.hosts[1]=b\nspring.datasource.url=jdbc:h2:mem"
+	if string(data) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, data)
+	}
+
+	codec.Keys.OnRead = true
+	var result map[string]any
+	if err := codec.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to unmarshal with unflatten: %v", err)
+	}
+	if !reflect.DeepEqual(result, original) {
+		t.Errorf("Round trip mismatch: %v", result)
+	}
+}
+
+func TestPropertiesUnflattenKeepsStrings(t *testing.T) {
+	original := map[string]any{
+		"app": map[string]any{"version": "1.0", "zip": "007", "flag": "True", "port": float64(8080), "none": nil,
+			"on": "true", "count": "1"},
+	}
+
+	codec := &Codec{}
+	data, err := codec.Marshal(original)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	codec.Keys.OnRead = true
+	var result map[string]any
+	if err := codec.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to unmarshal with unflatten: %v", err)
+	}
+	// null has no form of its own in a properties file and reads back empty
+	expected := map[string]any{
+		"app": map[string]any{"version": "1.0", "zip": "007", "flag": "True", "port": float64(8080), "none": "",
+			"on": "true", "count": "1"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Round trip mismatch: %v", result)
+	}
+	if !strings.Contains(string(data), `app.on=\u0074rue`) {
+		t.Errorf("expected the string true to be escaped:\n%s", data)
+	}
+
+	_, err = codec.Marshal(map[string]any{"a.b": 1, "a": map[string]any{"b": 2}})
+	if err == nil || !strings.Contains(err.Error(), `"a.b"`) {
+		t.Errorf("expected an error naming the key a.b, got %v", err)
+	}
+}
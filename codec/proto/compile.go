@@ -0,0 +1,260 @@
+package codec
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// FileDescriptorProto converts f to the descriptor protoc would produce for
+// it, named path. Type names of imported types are left relative, for
+// protodesc to resolve against the imports. Options that are not declared
+// in descriptor.proto, such as custom options, are dropped.
+func (f *File) FileDescriptorProto(path string) (*descriptorpb.FileDescriptorProto, error) {
+	fd := &descriptorpb.FileDescriptorProto{Name: proto.String(path)}
+	if f.Package != "" {
+		fd.Package = proto.String(f.Package)
+	}
+	switch f.Syntax {
+	case "proto3":
+		fd.Syntax = proto.String("proto3")
+	case "editions":
+		edition, ok := descriptorpb.Edition_value["EDITION_"+f.Edition]
+		if !ok {
+			return nil, fmt.Errorf("unknown edition %q", f.Edition)
+		}
+		fd.Syntax = proto.String("editions")
+		fd.Edition = descriptorpb.Edition(edition).Enum()
+	}
+	for i, imp := range f.Imports {
+		fd.Dependency = append(fd.Dependency, imp.Path)
+		if imp.Public {
+			fd.PublicDependency = append(fd.PublicDependency, int32(i))
+		}
+		if imp.Weak {
+			fd.WeakDependency = append(fd.WeakDependency, int32(i))
+		}
+	}
+	c := &compiler{}
+	fd.Options = &descriptorpb.FileOptions{}
+	c.options(fd.Options, f.Options)
+	for _, m := range f.Messages {
+		fd.MessageType = append(fd.MessageType, c.message(m))
+	}
+	for _, e := range f.Enums {
+		fd.EnumType = append(fd.EnumType, c.enum(e))
+	}
+	for _, s := range f.Services {
+		fd.Service = append(fd.Service, c.service(s))
+	}
+	for _, ext := range f.Extensions {
+		fd.Extension = append(fd.Extension, c.field(ext, nil))
+	}
+	return fd, c.err
+}
+
+// compiler keeps the first error met while converting options.
+type compiler struct {
+	err error
+}
+
+func (c *compiler) message(m *Message) *descriptorpb.DescriptorProto {
+	d := &descriptorpb.DescriptorProto{Name: proto.String(m.Name), Options: &descriptorpb.MessageOptions{}}
+	c.options(d.Options, m.Options)
+	oneofs := make(map[string]int32)
+	for _, o := range m.Oneofs {
+		oneofs[o.Name] = int32(len(d.OneofDecl))
+		od := &descriptorpb.OneofDescriptorProto{Name: proto.String(o.Name), Options: &descriptorpb.OneofOptions{}}
+		c.options(od.Options, o.Options)
+		d.OneofDecl = append(d.OneofDecl, od)
+	}
+	for _, f := range m.Fields {
+		fd := c.field(f, oneofs)
+		if f.Proto3Optional {
+			// proto3 optional fields are each alone in a synthetic oneof,
+			// declared after the real ones
+			fd.OneofIndex = proto.Int32(int32(len(d.OneofDecl)))
+			d.OneofDecl = append(d.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String(syntheticOneof(m, f.Name))})
+		}
+		d.Field = append(d.Field, fd)
+	}
+	for _, nested := range m.Messages {
+		d.NestedType = append(d.NestedType, c.message(nested))
+	}
+	for _, e := range m.Enums {
+		d.EnumType = append(d.EnumType, c.enum(e))
+	}
+	for _, ext := range m.Extensions {
+		d.Extension = append(d.Extension, c.field(ext, nil))
+	}
+	// message ranges are half open in descriptors
+	for _, r := range m.ExtensionRanges {
+		d.ExtensionRange = append(d.ExtensionRange, &descriptorpb.DescriptorProto_ExtensionRange{Start: proto.Int32(int32(r.Start)), End: proto.Int32(int32(r.End) + 1)})
+	}
+	for _, r := range m.ReservedRanges {
+		d.ReservedRange = append(d.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{Start: proto.Int32(int32(r.Start)), End: proto.Int32(int32(r.End) + 1)})
+	}
+	d.ReservedName = m.ReservedNames
+	return d
+}
+
+// syntheticOneof names the oneof of a proto3 optional field as protoc does:
+// _name, prefixed with more underscores if that is taken.
+func syntheticOneof(m *Message, field string) string {
+	name := "_" + field
+	for taken(m, name) {
+		name = "_" + name
+	}
+	return name
+}
+
+func taken(m *Message, name string) bool {
+	for _, f := range m.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	for _, o := range m.Oneofs {
+		if o.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compiler) field(f *Field, oneofs map[string]int32) *descriptorpb.FieldDescriptorProto {
+	d := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(f.Name),
+		Number:   proto.Int32(int32(f.Number)),
+		JsonName: proto.String(f.JSONName),
+		Options:  &descriptorpb.FieldOptions{},
+	}
+	if label, ok := descriptorpb.FieldDescriptorProto_Label_value["LABEL_"+strings.ToUpper(f.Label)]; ok {
+		d.Label = descriptorpb.FieldDescriptorProto_Label(label).Enum()
+	}
+	if typ, ok := descriptorpb.FieldDescriptorProto_Type_value["TYPE_"+strings.ToUpper(f.Type)]; ok {
+		d.Type = descriptorpb.FieldDescriptorProto_Type(typ).Enum()
+	}
+	if f.TypeName != "" {
+		d.TypeName = proto.String(f.TypeName)
+	}
+	if f.Extendee != "" {
+		d.Extendee = proto.String(f.Extendee)
+	}
+	if f.DefaultValue != "" {
+		d.DefaultValue = proto.String(f.DefaultValue)
+	}
+	if i, ok := oneofs[f.Oneof]; ok && f.Oneof != "" {
+		d.OneofIndex = proto.Int32(i)
+	}
+	if f.Proto3Optional {
+		d.Proto3Optional = proto.Bool(true)
+	}
+	c.options(d.Options, f.Options)
+	return d
+}
+
+func (c *compiler) enum(e *Enum) *descriptorpb.EnumDescriptorProto {
+	d := &descriptorpb.EnumDescriptorProto{Name: proto.String(e.Name), Options: &descriptorpb.EnumOptions{}}
+	c.options(d.Options, e.Options)
+	for _, v := range e.Values {
+		vd := &descriptorpb.EnumValueDescriptorProto{Name: proto.String(v.Name), Number: proto.Int32(int32(v.Number)), Options: &descriptorpb.EnumValueOptions{}}
+		c.options(vd.Options, v.Options)
+		d.Value = append(d.Value, vd)
+	}
+	// enum ranges are inclusive, as written
+	for _, r := range e.ReservedRanges {
+		d.ReservedRange = append(d.ReservedRange, &descriptorpb.EnumDescriptorProto_EnumReservedRange{Start: proto.Int32(int32(r.Start)), End: proto.Int32(int32(r.End))})
+	}
+	d.ReservedName = e.ReservedNames
+	return d
+}
+
+func (c *compiler) service(s *Service) *descriptorpb.ServiceDescriptorProto {
+	d := &descriptorpb.ServiceDescriptorProto{Name: proto.String(s.Name), Options: &descriptorpb.ServiceOptions{}}
+	c.options(d.Options, s.Options)
+	for _, m := range s.Methods {
+		md := &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(m.Name),
+			InputType:  proto.String(m.InputType),
+			OutputType: proto.String(m.OutputType),
+			Options:    &descriptorpb.MethodOptions{},
+		}
+		if m.ClientStreaming {
+			md.ClientStreaming = proto.Bool(true)
+		}
+		if m.ServerStreaming {
+			md.ServerStreaming = proto.Bool(true)
+		}
+		c.options(md.Options, m.Options)
+		d.Method = append(d.Method, md)
+	}
+	return d
+}
+
+// options sets the options declared in descriptor.proto on msg, following
+// dotted names such as features.field_presence into nested messages.
+func (c *compiler) options(msg proto.Message, opts map[string]any) {
+	for name, value := range opts {
+		if strings.HasPrefix(name, "(") {
+			continue
+		}
+		m := msg.ProtoReflect()
+		parts := strings.Split(name, ".")
+		for i, part := range parts {
+			fd := m.Descriptor().Fields().ByName(protoreflect.Name(part))
+			if fd == nil || fd.IsList() || fd.IsMap() {
+				break
+			}
+			if i < len(parts)-1 {
+				if fd.Kind() != protoreflect.MessageKind {
+					break
+				}
+				m = m.Mutable(fd).Message()
+				continue
+			}
+			v, err := optionValue(fd, value)
+			if err != nil {
+				if c.err == nil {
+					c.err = fmt.Errorf("option %s: %v", name, err)
+				}
+				break
+			}
+			m.Set(fd, v)
+		}
+	}
+}
+
+func optionValue(fd protoreflect.FieldDescriptor, value any) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := value.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.StringKind:
+		if s, ok := value.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.EnumKind:
+		if s, ok := value.(string); ok {
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+				return protoreflect.ValueOfEnum(ev.Number()), nil
+			}
+		}
+	case protoreflect.Int32Kind:
+		if n, ok := value.(int64); ok {
+			return protoreflect.ValueOfInt32(int32(n)), nil
+		}
+	case protoreflect.DoubleKind:
+		switch n := value.(type) {
+		case int64:
+			return protoreflect.ValueOfFloat64(float64(n)), nil
+		case float64:
+			return protoreflect.ValueOfFloat64(n), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported value %v", value)
+}
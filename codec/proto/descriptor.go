@@ -0,0 +1,121 @@
+package codec
+
+// The document a .proto file decodes to. It follows descriptor.proto, the
+// schema protoc hands to plugins, with a few changes to make it easier to
+// query: names are fully qualified as they are resolved, labels and types
+// are written in lower case, oneofs are named rather than indexed, ranges
+// are inclusive as written and comments are kept next to what they
+// document.
+
+// File is a parsed .proto file.
+type File struct {
+	Syntax     string         `json:"syntax"`
+	Edition    string         `json:"edition,omitempty"`
+	Package    string         `json:"package,omitempty"`
+	Imports    []Import       `json:"imports,omitempty"`
+	Options    map[string]any `json:"options,omitempty"`
+	Messages   []*Message     `json:"messages,omitempty"`
+	Enums      []*Enum        `json:"enums,omitempty"`
+	Services   []*Service     `json:"services,omitempty"`
+	Extensions []*Field       `json:"extensions,omitempty"`
+}
+
+// Import is an import statement. Public imports are re-exported to files
+// that import this one and weak imports may be missing.
+type Import struct {
+	Path   string `json:"path"`
+	Public bool   `json:"public,omitempty"`
+	Weak   bool   `json:"weak,omitempty"`
+}
+
+// Message is a message, or the message a group or map field declares.
+type Message struct {
+	Name            string         `json:"name"`
+	FullName        string         `json:"full_name"`
+	Comment         string         `json:"comment,omitempty"`
+	Fields          []*Field       `json:"fields"`
+	Oneofs          []*Oneof       `json:"oneofs,omitempty"`
+	Messages        []*Message     `json:"messages,omitempty"`
+	Enums           []*Enum        `json:"enums,omitempty"`
+	Extensions      []*Field       `json:"extensions,omitempty"`
+	ExtensionRanges []Range        `json:"extension_ranges,omitempty"`
+	ReservedRanges  []Range        `json:"reserved_ranges,omitempty"`
+	ReservedNames   []string       `json:"reserved_names,omitempty"`
+	Options         map[string]any `json:"options,omitempty"`
+
+	scope string // the package or message the message is declared in
+}
+
+// Field is a message field or an extension. Type is a scalar type name,
+// "message", "enum" or "group"; it is empty when TypeName refers to a type
+// that is not declared in the file, such as one from an import.
+type Field struct {
+	Name           string         `json:"name"`
+	Number         int            `json:"number"`
+	Label          string         `json:"label,omitempty"`
+	Type           string         `json:"type,omitempty"`
+	TypeName       string         `json:"type_name,omitempty"`
+	Extendee       string         `json:"extendee,omitempty"`
+	JSONName       string         `json:"json_name"`
+	DefaultValue   string         `json:"default_value,omitempty"`
+	Oneof          string         `json:"oneof,omitempty"`
+	Proto3Optional bool           `json:"proto3_optional,omitempty"`
+	Comment        string         `json:"comment,omitempty"`
+	Options        map[string]any `json:"options,omitempty"`
+
+	scope string // where TypeName and Extendee are resolved from
+}
+
+// Oneof is a oneof declaration; its fields name it in Field.Oneof.
+type Oneof struct {
+	Name    string         `json:"name"`
+	Comment string         `json:"comment,omitempty"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// Enum is an enum type.
+type Enum struct {
+	Name           string         `json:"name"`
+	FullName       string         `json:"full_name"`
+	Comment        string         `json:"comment,omitempty"`
+	Values         []*EnumValue   `json:"values"`
+	ReservedRanges []Range        `json:"reserved_ranges,omitempty"`
+	ReservedNames  []string       `json:"reserved_names,omitempty"`
+	Options        map[string]any `json:"options,omitempty"`
+}
+
+// EnumValue is a value of an enum.
+type EnumValue struct {
+	Name    string         `json:"name"`
+	Number  int            `json:"number"`
+	Comment string         `json:"comment,omitempty"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// Service is a service with its RPC methods.
+type Service struct {
+	Name     string         `json:"name"`
+	FullName string         `json:"full_name"`
+	Comment  string         `json:"comment,omitempty"`
+	Methods  []*Method      `json:"methods"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+// Method is an RPC method of a service.
+type Method struct {
+	Name            string         `json:"name"`
+	InputType       string         `json:"input_type"`
+	OutputType      string         `json:"output_type"`
+	ClientStreaming bool           `json:"client_streaming,omitempty"`
+	ServerStreaming bool           `json:"server_streaming,omitempty"`
+	Comment         string         `json:"comment,omitempty"`
+	Options         map[string]any `json:"options,omitempty"`
+
+	scope string
+}
+
+// Range is an inclusive range of field or enum numbers.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
@@ -0,0 +1,307 @@
+package codec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokFloat
+	tokString
+	tokSymbol
+)
+
+// token is a lexical token of a .proto file. Comments are attached to the
+// tokens around them: leading is the comment on the lines before the token
+// and trailing the comment after it on the same line.
+type token struct {
+	kind      tokenKind
+	text      string // the token as written; the decoded value for strings
+	line, col int
+	leading   string
+	trailing  string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of file"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return t.text
+}
+
+// lex splits a .proto file into tokens.
+func lex(src string) ([]token, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+	var tokens []token
+	var pending []string // comments waiting for the next token
+	lastLine := 0
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.src) {
+			tokens = append(tokens, token{kind: tokEOF, line: l.line, col: l.col, leading: joinComments(pending)})
+			return tokens, nil
+		}
+		if l.blankLine {
+			// a blank line detaches comments from the next token
+			pending = nil
+		}
+		if strings.HasPrefix(l.src[l.pos:], "//") || strings.HasPrefix(l.src[l.pos:], "/*") {
+			line := l.line
+			text, err := l.comment()
+			if err != nil {
+				return nil, err
+			}
+			if n := len(tokens); n > 0 && line == lastLine && len(pending) == 0 {
+				tokens[n-1].trailing = joinComments([]string{tokens[n-1].trailing, text})
+			} else {
+				pending = append(pending, text)
+			}
+			continue
+		}
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		t.leading = joinComments(pending)
+		pending = nil
+		tokens = append(tokens, t)
+		lastLine = l.line
+	}
+}
+
+func joinComments(comments []string) string {
+	var parts []string
+	for _, c := range comments {
+		if c != "" {
+			parts = append(parts, c)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+type lexer struct {
+	src       string
+	pos       int
+	line, col int
+	blankLine bool // whether skipSpace passed an empty line
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return fmt.Errorf("%d:%d: %s", l.line, l.col, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) advance(n int) {
+	for _, c := range l.src[l.pos : l.pos+n] {
+		if c == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
+	l.pos += n
+}
+
+func (l *lexer) skipSpace() {
+	l.blankLine = false
+	newlines := 0
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n\f\v", l.src[l.pos]) >= 0 {
+		if l.src[l.pos] == '\n' {
+			if newlines++; newlines > 1 {
+				l.blankLine = true
+			}
+		}
+		l.advance(1)
+	}
+}
+
+// comment reads a // or /* */ comment and returns its text without the
+// markers.
+func (l *lexer) comment() (string, error) {
+	if strings.HasPrefix(l.src[l.pos:], "//") {
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		if end < 0 {
+			end = len(l.src) - l.pos
+		}
+		text := l.src[l.pos+2 : l.pos+end]
+		l.advance(end)
+		return strings.TrimSpace(text), nil
+	}
+	end := strings.Index(l.src[l.pos+2:], "*/")
+	if end < 0 {
+		return "", l.errorf("unterminated comment")
+	}
+	text := l.src[l.pos+2 : l.pos+2+end]
+	l.advance(end + 4)
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+func (l *lexer) next() (token, error) {
+	t := token{line: l.line, col: l.col}
+	c := l.src[l.pos]
+	switch {
+	case isLetter(c):
+		end := l.pos
+		for end < len(l.src) && (isLetter(l.src[end]) || isDigit(l.src[end])) {
+			end++
+		}
+		t.kind, t.text = tokIdent, l.src[l.pos:end]
+		l.advance(end - l.pos)
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		return l.number(t)
+	case c == '"' || c == '\'':
+		s, err := l.str()
+		if err != nil {
+			return t, err
+		}
+		t.kind, t.text = tokString, s
+	case strings.IndexByte(";{}[]()<>=,.:-+", c) >= 0:
+		t.kind, t.text = tokSymbol, string(c)
+		l.advance(1)
+	default:
+		return t, l.errorf("unexpected character %q", c)
+	}
+	return t, nil
+}
+
+func (l *lexer) number(t token) (token, error) {
+	end := l.pos
+	t.kind = tokInt
+	if strings.HasPrefix(strings.ToLower(l.src[l.pos:]), "0x") {
+		end += 2
+		for end < len(l.src) && strings.IndexByte("0123456789abcdefABCDEF", l.src[end]) >= 0 {
+			end++
+		}
+	} else {
+		for end < len(l.src) && isDigit(l.src[end]) {
+			end++
+		}
+		if end < len(l.src) && l.src[end] == '.' {
+			t.kind = tokFloat
+			end++
+			for end < len(l.src) && isDigit(l.src[end]) {
+				end++
+			}
+		}
+		if end < len(l.src) && (l.src[end] == 'e' || l.src[end] == 'E') {
+			t.kind = tokFloat
+			end++
+			if end < len(l.src) && (l.src[end] == '+' || l.src[end] == '-') {
+				end++
+			}
+			for end < len(l.src) && isDigit(l.src[end]) {
+				end++
+			}
+		}
+	}
+	if end < len(l.src) && isLetter(l.src[end]) {
+		return t, l.errorf("invalid number %q", l.src[l.pos:end+1])
+	}
+	t.text = l.src[l.pos:end]
+	l.advance(end - l.pos)
+	return t, nil
+}
+
+// str reads a quoted string with the escapes of the protobuf language.
+func (l *lexer) str() (string, error) {
+	q := l.src[l.pos]
+	var b strings.Builder
+	i := l.pos + 1
+	for ; i < len(l.src) && l.src[i] != q; i++ {
+		c := l.src[i]
+		if c == '\n' {
+			return "", l.errorf("unterminated string")
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i++; i >= len(l.src) {
+			break
+		}
+		switch e := l.src[i]; e {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x', 'X':
+			n := 0
+			for n < 2 && i+1+n < len(l.src) && strings.IndexByte("0123456789abcdefABCDEF", l.src[i+1+n]) >= 0 {
+				n++
+			}
+			v, err := strconv.ParseUint(l.src[i+1:i+1+n], 16, 8)
+			if err != nil {
+				return "", l.errorf("invalid escape \\%c", e)
+			}
+			b.WriteByte(byte(v))
+			i += n
+		case 'u', 'U':
+			n := 4
+			if e == 'U' {
+				n = 8
+			}
+			if i+n >= len(l.src) {
+				return "", l.errorf("invalid escape \\%c", e)
+			}
+			v, err := strconv.ParseUint(l.src[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", l.errorf("invalid escape \\%c", e)
+			}
+			b.WriteRune(rune(v))
+			i += n
+		default:
+			if e >= '0' && e <= '7' {
+				n := 1
+				for n < 3 && i+n < len(l.src) && l.src[i+n] >= '0' && l.src[i+n] <= '7' {
+					n++
+				}
+				v, err := strconv.ParseUint(l.src[i:i+n], 8, 8)
+				if err != nil {
+					return "", l.errorf("invalid octal escape")
+				}
+				b.WriteByte(byte(v))
+				i += n - 1
+			} else {
+				b.WriteByte(e)
+			}
+		}
+	}
+	if i >= len(l.src) {
+		return "", l.errorf("unterminated string")
+	}
+	l.advance(i + 1 - l.pos)
+	return b.String(), nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,1081 @@
+package codec
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Parse parses the source of a .proto file in proto2, proto3 or editions
+// syntax. Type names are resolved against the declarations in the file;
+// names from imports are left as written.
+func Parse(src string) (*File, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, file: &File{Syntax: "proto2"}}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	p.resolve()
+	return p.file, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	file   *File
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is reports whether the current token is the symbol or keyword text.
+func (p *parser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == tokSymbol || t.kind == tokIdent) && t.text == text
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("%d:%d: %s", t.line, t.col, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(text string) (token, error) {
+	t := p.next()
+	if (t.kind != tokSymbol && t.kind != tokIdent) || t.text != text {
+		return t, p.errorf(t, "expected %q, found %s", text, t)
+	}
+	return t, nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", p.errorf(t, "expected a name, found %s", t)
+	}
+	return t.text, nil
+}
+
+// fullIdent reads a dotted name, such as foo.bar.Baz or .foo.Bar.
+func (p *parser) fullIdent() (string, error) {
+	var b strings.Builder
+	if p.is(".") {
+		p.next()
+		b.WriteByte('.')
+	}
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(name)
+		if !p.is(".") {
+			return b.String(), nil
+		}
+		p.next()
+		b.WriteByte('.')
+	}
+}
+
+func (p *parser) str() (string, error) {
+	t := p.next()
+	if t.kind != tokString {
+		return "", p.errorf(t, "expected a string, found %s", t)
+	}
+	s := t.text
+	// adjacent strings are concatenated, as in C
+	for p.peek().kind == tokString {
+		s += p.next().text
+	}
+	return s, nil
+}
+
+func (p *parser) integer() (int, error) {
+	t := p.peek()
+	neg := false
+	if p.is("-") {
+		p.next()
+		neg = true
+	}
+	n := p.next()
+	if n.kind != tokInt {
+		return 0, p.errorf(n, "expected an integer, found %s", n)
+	}
+	v, err := strconv.ParseInt(n.text, 0, 64)
+	if err != nil || v > math.MaxInt32+1 || (!neg && v > math.MaxInt32) {
+		return 0, p.errorf(t, "integer %s out of range", n.text)
+	}
+	if neg {
+		v = -v
+	}
+	return int(v), nil
+}
+
+// comment returns the comment documenting the element that starts with
+// start and ends with end: the comment above it, or else the one after it.
+func comment(start, end token) string {
+	if start.leading != "" {
+		return start.leading
+	}
+	return end.trailing
+}
+
+func (p *parser) parseFile() error {
+	f := p.file
+	first := true
+	for p.peek().kind != tokEOF {
+		start := p.peek()
+		switch {
+		case p.is(";"):
+			p.next()
+		case first && (p.is("syntax") || p.is("edition")):
+			keyword := p.next().text
+			if _, err := p.expect("="); err != nil {
+				return err
+			}
+			value, err := p.str()
+			if err != nil {
+				return err
+			}
+			if keyword == "syntax" {
+				if value != "proto2" && value != "proto3" {
+					return p.errorf(start, "unknown syntax %q", value)
+				}
+				f.Syntax = value
+			} else {
+				f.Syntax, f.Edition = "editions", value
+			}
+			if _, err := p.expect(";"); err != nil {
+				return err
+			}
+		case p.is("package"):
+			p.next()
+			if f.Package != "" {
+				return p.errorf(start, "multiple package statements")
+			}
+			name, err := p.fullIdent()
+			if err != nil {
+				return err
+			}
+			f.Package = name
+			if _, err := p.expect(";"); err != nil {
+				return err
+			}
+		case p.is("import"):
+			p.next()
+			var imp Import
+			if p.is("public") {
+				p.next()
+				imp.Public = true
+			} else if p.is("weak") {
+				p.next()
+				imp.Weak = true
+			}
+			path, err := p.str()
+			if err != nil {
+				return err
+			}
+			imp.Path = path
+			f.Imports = append(f.Imports, imp)
+			if _, err := p.expect(";"); err != nil {
+				return err
+			}
+		case p.is("option"):
+			if err := p.optionStatement(&f.Options); err != nil {
+				return err
+			}
+		case p.is("message"):
+			m, err := p.message(f.Package)
+			if err != nil {
+				return err
+			}
+			f.Messages = append(f.Messages, m)
+		case p.is("enum"):
+			e, err := p.enum(f.Package)
+			if err != nil {
+				return err
+			}
+			f.Enums = append(f.Enums, e)
+		case p.is("service"):
+			s, err := p.service()
+			if err != nil {
+				return err
+			}
+			f.Services = append(f.Services, s)
+		case p.is("extend"):
+			fields, messages, err := p.extend(f.Package)
+			if err != nil {
+				return err
+			}
+			f.Extensions = append(f.Extensions, fields...)
+			f.Messages = append(f.Messages, messages...)
+		default:
+			return p.errorf(start, "unexpected %s", start)
+		}
+		first = false
+	}
+	return nil
+}
+
+// optionStatement reads option name = value; into opts.
+func (p *parser) optionStatement(opts *map[string]any) error {
+	if _, err := p.expect("option"); err != nil {
+		return err
+	}
+	if err := p.option(opts); err != nil {
+		return err
+	}
+	_, err := p.expect(";")
+	return err
+}
+
+// option reads name = value into opts.
+func (p *parser) option(opts *map[string]any) error {
+	name, err := p.optionName()
+	if err != nil {
+		return err
+	}
+	if _, err := p.expect("="); err != nil {
+		return err
+	}
+	value, err := p.constant()
+	if err != nil {
+		return err
+	}
+	if *opts == nil {
+		*opts = make(map[string]any)
+	}
+	(*opts)[name] = value
+	return nil
+}
+
+// optionName reads a name such as deprecated, (my.ext) or (my.ext).field,
+// which is returned as written.
+func (p *parser) optionName() (string, error) {
+	var b strings.Builder
+	for {
+		if p.is("(") {
+			p.next()
+			name, err := p.fullIdent()
+			if err != nil {
+				return "", err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return "", err
+			}
+			b.WriteString("(" + name + ")")
+		} else {
+			name, err := p.ident()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(name)
+		}
+		if !p.is(".") {
+			return b.String(), nil
+		}
+		p.next()
+		b.WriteByte('.')
+	}
+}
+
+// options reads the [name = value, ...] options of a field or value.
+func (p *parser) options() (map[string]any, error) {
+	if !p.is("[") {
+		return nil, nil
+	}
+	p.next()
+	var opts map[string]any
+	for {
+		if err := p.option(&opts); err != nil {
+			return nil, err
+		}
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	if _, err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// constant reads an option value: a literal, an identifier such as an
+// enum value, or a {...} message literal in text format.
+func (p *parser) constant() (any, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokString:
+		return p.str()
+	case p.is("{") || p.is("<"):
+		return p.aggregate()
+	case p.is("-") || p.is("+") || t.kind == tokInt || t.kind == tokFloat:
+		return p.number()
+	case t.kind == tokIdent:
+		name, err := p.fullIdent()
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return name, nil
+	}
+	return nil, p.errorf(t, "expected a value, found %s", t)
+}
+
+func (p *parser) number() (any, error) {
+	neg := false
+	if p.is("-") || p.is("+") {
+		neg = p.next().text == "-"
+	}
+	t := p.next()
+	switch {
+	case t.kind == tokIdent && (t.text == "inf" || t.text == "nan"):
+		// JSON has no infinity or NaN, so they stay names
+		if neg {
+			return "-" + t.text, nil
+		}
+		return t.text, nil
+	case t.kind == tokInt:
+		if neg {
+			if v, err := strconv.ParseInt("-"+t.text, 0, 64); err == nil {
+				return v, nil
+			}
+		} else if v, err := strconv.ParseUint(t.text, 0, 64); err == nil {
+			if v <= math.MaxInt64 {
+				return int64(v), nil
+			}
+			return v, nil
+		}
+		return nil, p.errorf(t, "integer %s out of range", t.text)
+	case t.kind == tokFloat:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %s", t.text)
+		}
+		if neg {
+			v = -v
+		}
+		return v, nil
+	}
+	return nil, p.errorf(t, "expected a number, found %s", t)
+}
+
+// aggregate reads a message literal such as { name: "x" tags: [1, 2] }.
+// Repeated fields become arrays.
+func (p *parser) aggregate() (map[string]any, error) {
+	open := p.next()
+	closing := "}"
+	if open.text == "<" {
+		closing = ">"
+	}
+	m := make(map[string]any)
+	for !p.is(closing) {
+		if p.peek().kind == tokEOF {
+			return nil, p.errorf(open, "unterminated %s", open.text)
+		}
+		var name string
+		if p.is("[") {
+			// an extension or Any type URL
+			p.next()
+			var b strings.Builder
+			for !p.is("]") {
+				t := p.next()
+				if t.kind == tokEOF {
+					return nil, p.errorf(open, "unterminated [")
+				}
+				b.WriteString(t.text)
+			}
+			p.next()
+			name = "[" + b.String() + "]"
+		} else {
+			var err error
+			if name, err = p.ident(); err != nil {
+				return nil, err
+			}
+		}
+		hasColon := p.is(":")
+		if hasColon {
+			p.next()
+		}
+		var value any
+		var err error
+		switch {
+		case p.is("["):
+			value, err = p.list()
+		case p.is("{") || p.is("<"):
+			value, err = p.aggregate()
+		case !hasColon:
+			return nil, p.errorf(p.peek(), "expected \":\" after %s", name)
+		default:
+			value, err = p.constant()
+		}
+		if err != nil {
+			return nil, err
+		}
+		addValue(m, name, value)
+		if p.is(",") || p.is(";") {
+			p.next()
+		}
+	}
+	p.next()
+	return m, nil
+}
+
+func (p *parser) list() ([]any, error) {
+	p.next()
+	items := []any{}
+	for !p.is("]") {
+		v, err := p.constant()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	if _, err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func addValue(m map[string]any, name string, value any) {
+	old, ok := m[name]
+	if !ok {
+		m[name] = value
+		return
+	}
+	items, isList := old.([]any)
+	if !isList {
+		items = []any{old}
+	}
+	if more, ok := value.([]any); ok {
+		m[name] = append(items, more...)
+	} else {
+		m[name] = append(items, value)
+	}
+}
+
+func join(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *parser) message(scope string) (*Message, error) {
+	start, _ := p.expect("message")
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{Name: name, FullName: join(scope, name), Fields: []*Field{}, scope: scope}
+	end, err := p.messageBody(m)
+	if err != nil {
+		return nil, err
+	}
+	m.Comment = comment(start, end)
+	return m, nil
+}
+
+// messageBody reads the { ... } of a message or group and returns the
+// opening brace.
+func (p *parser) messageBody(m *Message) (token, error) {
+	open, err := p.expect("{")
+	if err != nil {
+		return open, err
+	}
+	for !p.is("}") {
+		start := p.peek()
+		switch {
+		case start.kind == tokEOF:
+			return open, p.errorf(open, "unterminated message %s", m.Name)
+		case p.is(";"):
+			p.next()
+		case p.is("option"):
+			if err := p.optionStatement(&m.Options); err != nil {
+				return open, err
+			}
+		case p.is("message"):
+			nested, err := p.message(m.FullName)
+			if err != nil {
+				return open, err
+			}
+			m.Messages = append(m.Messages, nested)
+		case p.is("enum"):
+			e, err := p.enum(m.FullName)
+			if err != nil {
+				return open, err
+			}
+			m.Enums = append(m.Enums, e)
+		case p.is("extend"):
+			fields, messages, err := p.extend(m.FullName)
+			if err != nil {
+				return open, err
+			}
+			m.Extensions = append(m.Extensions, fields...)
+			m.Messages = append(m.Messages, messages...)
+		case p.is("extensions"):
+			p.next()
+			ranges, err := p.ranges(536870911)
+			if err != nil {
+				return open, err
+			}
+			if _, err := p.options(); err != nil {
+				return open, err
+			}
+			if _, err := p.expect(";"); err != nil {
+				return open, err
+			}
+			m.ExtensionRanges = append(m.ExtensionRanges, ranges...)
+		case p.is("reserved"):
+			ranges, names, err := p.reserved(536870911)
+			if err != nil {
+				return open, err
+			}
+			m.ReservedRanges = append(m.ReservedRanges, ranges...)
+			m.ReservedNames = append(m.ReservedNames, names...)
+		case p.is("oneof"):
+			if err := p.oneof(m); err != nil {
+				return open, err
+			}
+		default:
+			if err := p.field(m, m.FullName, ""); err != nil {
+				return open, err
+			}
+		}
+	}
+	p.next()
+	return open, nil
+}
+
+var labels = map[string]bool{"optional": true, "required": true, "repeated": true}
+
+// field reads a field, map field or group into m. Extensions are read the
+// same way, into m.Extensions when extendee is set.
+func (p *parser) field(m *Message, scope, extendee string) error {
+	start := p.peek()
+	f := &Field{scope: scope, Extendee: extendee}
+	if labels[start.text] && start.kind == tokIdent {
+		f.Label = p.next().text
+		if f.Label == "optional" && p.file.Syntax == "proto3" {
+			f.Proto3Optional = true
+		}
+	}
+
+	var entry *Message
+	switch {
+	case p.is("map") && p.tokens[p.pos+1].text == "<":
+		p.next()
+		p.next()
+		keyType, err := p.fullIdent()
+		if err != nil {
+			return err
+		}
+		if _, err := p.expect(","); err != nil {
+			return err
+		}
+		valueType, err := p.fullIdent()
+		if err != nil {
+			return err
+		}
+		if _, err := p.expect(">"); err != nil {
+			return err
+		}
+		if f.Name, err = p.ident(); err != nil {
+			return err
+		}
+		entryName := mapEntryName(f.Name)
+		entry = &Message{
+			Name:     entryName,
+			FullName: join(m.FullName, entryName),
+			Fields: []*Field{
+				{Name: "key", Number: 1, Label: "optional", Type: keyType, JSONName: "key", scope: m.FullName},
+				{Name: "value", Number: 2, Label: "optional", TypeName: valueType, JSONName: "value", scope: m.FullName},
+			},
+			Options: map[string]any{"map_entry": true},
+			scope:   m.FullName,
+		}
+		if scalarTypes[valueType] {
+			entry.Fields[1].Type, entry.Fields[1].TypeName = valueType, ""
+		}
+		f.Label, f.Type, f.TypeName = "repeated", "message", "."+entry.FullName
+	case p.is("group"):
+		p.next()
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		entry = &Message{Name: name, FullName: join(scope, name), Fields: []*Field{}, scope: scope}
+		f.Name, f.Type, f.TypeName = strings.ToLower(name), "group", "."+entry.FullName
+	default:
+		typeName, err := p.fullIdent()
+		if err != nil {
+			return err
+		}
+		if scalarTypes[typeName] {
+			f.Type = typeName
+		} else {
+			f.TypeName = typeName
+		}
+		if f.Name, err = p.ident(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.expect("="); err != nil {
+		return err
+	}
+	number, err := p.integer()
+	if err != nil {
+		return err
+	}
+	f.Number = number
+	if f.Options, err = p.options(); err != nil {
+		return err
+	}
+	f.JSONName = jsonName(f.Name)
+	if v, ok := f.Options["json_name"].(string); ok {
+		f.JSONName = v
+		delete(f.Options, "json_name")
+	}
+	if v, ok := f.Options["default"]; ok {
+		f.DefaultValue = formatDefault(v)
+		delete(f.Options, "default")
+	}
+	if len(f.Options) == 0 {
+		f.Options = nil
+	}
+	if f.Label == "" {
+		f.Label = "optional"
+	}
+
+	var end token
+	if f.Type == "group" {
+		if end, err = p.messageBody(entry); err != nil {
+			return err
+		}
+	} else if end, err = p.expect(";"); err != nil {
+		return err
+	}
+	f.Comment = comment(start, end)
+
+	if extendee != "" {
+		m.Extensions = append(m.Extensions, f)
+	} else {
+		m.Fields = append(m.Fields, f)
+	}
+	if entry != nil {
+		m.Messages = append(m.Messages, entry)
+	}
+	return nil
+}
+
+var scalarTypes = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+	"bool": true, "string": true, "bytes": true,
+}
+
+// jsonName is the default JSON name of a field: lowerCamelCase, as protoc
+// derives it.
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper && c >= 'a' && c <= 'z':
+			b.WriteRune(c - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(c)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// mapEntryName is the name of the message protoc declares for a map field.
+func mapEntryName(field string) string {
+	name := jsonName(field)
+	if name != "" && name[0] >= 'a' && name[0] <= 'z' {
+		name = string(name[0]-'a'+'A') + name[1:]
+	}
+	return name + "Entry"
+}
+
+func formatDefault(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func (p *parser) oneof(m *Message) error {
+	start, _ := p.expect("oneof")
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	o := &Oneof{Name: name}
+	open, err := p.expect("{")
+	if err != nil {
+		return err
+	}
+	for !p.is("}") {
+		switch {
+		case p.peek().kind == tokEOF:
+			return p.errorf(open, "unterminated oneof %s", name)
+		case p.is(";"):
+			p.next()
+		case p.is("option"):
+			if err := p.optionStatement(&o.Options); err != nil {
+				return err
+			}
+		default:
+			n := len(m.Fields)
+			if err := p.field(m, m.FullName, ""); err != nil {
+				return err
+			}
+			m.Fields[n].Oneof = name
+		}
+	}
+	p.next()
+	o.Comment = comment(start, open)
+	m.Oneofs = append(m.Oneofs, o)
+	return nil
+}
+
+// ranges reads a list of numbers and ranges such as 2, 9 to 11, 40 to max.
+func (p *parser) ranges(max int) ([]Range, error) {
+	var ranges []Range
+	for {
+		start, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		r := Range{Start: start, End: start}
+		if p.is("to") {
+			p.next()
+			if p.is("max") {
+				p.next()
+				r.End = max
+			} else if r.End, err = p.integer(); err != nil {
+				return nil, err
+			}
+		}
+		ranges = append(ranges, r)
+		if !p.is(",") {
+			return ranges, nil
+		}
+		p.next()
+	}
+}
+
+// reserved reads a reserved statement of numbers or of names, which are
+// strings, or identifiers in editions.
+func (p *parser) reserved(max int) ([]Range, []string, error) {
+	p.next()
+	var ranges []Range
+	var names []string
+	t := p.peek()
+	if t.kind == tokString || t.kind == tokIdent {
+		for {
+			t := p.next()
+			if t.kind != tokString && t.kind != tokIdent {
+				return nil, nil, p.errorf(t, "expected a reserved name, found %s", t)
+			}
+			names = append(names, t.text)
+			if !p.is(",") {
+				break
+			}
+			p.next()
+		}
+	} else {
+		var err error
+		if ranges, err = p.ranges(max); err != nil {
+			return nil, nil, err
+		}
+	}
+	_, err := p.expect(";")
+	return ranges, names, err
+}
+
+func (p *parser) enum(scope string) (*Enum, error) {
+	start, _ := p.expect("enum")
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	e := &Enum{Name: name, FullName: join(scope, name), Values: []*EnumValue{}}
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	for !p.is("}") {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF:
+			return nil, p.errorf(open, "unterminated enum %s", name)
+		case p.is(";"):
+			p.next()
+		case p.is("option"):
+			if err := p.optionStatement(&e.Options); err != nil {
+				return nil, err
+			}
+		case p.is("reserved"):
+			ranges, names, err := p.reserved(math.MaxInt32)
+			if err != nil {
+				return nil, err
+			}
+			e.ReservedRanges = append(e.ReservedRanges, ranges...)
+			e.ReservedNames = append(e.ReservedNames, names...)
+		default:
+			v := &EnumValue{}
+			if v.Name, err = p.ident(); err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("="); err != nil {
+				return nil, err
+			}
+			if v.Number, err = p.integer(); err != nil {
+				return nil, err
+			}
+			if v.Options, err = p.options(); err != nil {
+				return nil, err
+			}
+			end, err := p.expect(";")
+			if err != nil {
+				return nil, err
+			}
+			v.Comment = comment(t, end)
+			e.Values = append(e.Values, v)
+		}
+	}
+	p.next()
+	e.Comment = comment(start, open)
+	return e, nil
+}
+
+func (p *parser) service() (*Service, error) {
+	start, _ := p.expect("service")
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{Name: name, FullName: join(p.file.Package, name), Methods: []*Method{}}
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, err
+	}
+	for !p.is("}") {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF:
+			return nil, p.errorf(open, "unterminated service %s", name)
+		case p.is(";"):
+			p.next()
+		case p.is("option"):
+			if err := p.optionStatement(&s.Options); err != nil {
+				return nil, err
+			}
+		case p.is("rpc"):
+			m, err := p.method()
+			if err != nil {
+				return nil, err
+			}
+			s.Methods = append(s.Methods, m)
+		default:
+			return nil, p.errorf(t, "unexpected %s in service %s", t, name)
+		}
+	}
+	p.next()
+	s.Comment = comment(start, open)
+	return s, nil
+}
+
+func (p *parser) method() (*Method, error) {
+	start := p.next()
+	m := &Method{scope: p.file.Package}
+	var err error
+	if m.Name, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if m.ClientStreaming, m.InputType, err = p.methodType(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect("returns"); err != nil {
+		return nil, err
+	}
+	if m.ServerStreaming, m.OutputType, err = p.methodType(); err != nil {
+		return nil, err
+	}
+	end := p.peek()
+	if p.is("{") {
+		p.next()
+		for !p.is("}") {
+			switch {
+			case p.peek().kind == tokEOF:
+				return nil, p.errorf(end, "unterminated rpc %s", m.Name)
+			case p.is(";"):
+				p.next()
+			default:
+				if err := p.optionStatement(&m.Options); err != nil {
+					return nil, err
+				}
+			}
+		}
+		p.next()
+		if p.is(";") {
+			p.next()
+		}
+	} else if end, err = p.expect(";"); err != nil {
+		return nil, err
+	}
+	m.Comment = comment(start, end)
+	return m, nil
+}
+
+// methodType reads ( [stream] Type ).
+func (p *parser) methodType() (bool, string, error) {
+	if _, err := p.expect("("); err != nil {
+		return false, "", err
+	}
+	stream := false
+	// stream is only a keyword when a type name follows it
+	if p.is("stream") && p.tokens[p.pos+1].text != ")" {
+		p.next()
+		stream = true
+	}
+	name, err := p.fullIdent()
+	if err != nil {
+		return false, "", err
+	}
+	_, err = p.expect(")")
+	return stream, name, err
+}
+
+// extend reads extend Type { ... } and returns its fields, and the
+// messages of any groups among them.
+func (p *parser) extend(scope string) ([]*Field, []*Message, error) {
+	p.next()
+	extendee, err := p.fullIdent()
+	if err != nil {
+		return nil, nil, err
+	}
+	holder := &Message{FullName: scope}
+	open, err := p.expect("{")
+	if err != nil {
+		return nil, nil, err
+	}
+	for !p.is("}") {
+		switch {
+		case p.peek().kind == tokEOF:
+			return nil, nil, p.errorf(open, "unterminated extend %s", extendee)
+		case p.is(";"):
+			p.next()
+		default:
+			if err := p.field(holder, scope, extendee); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	p.next()
+	return holder.Extensions, holder.Messages, nil
+}
+
+// resolve qualifies the type names that refer to declarations in the file
+// and sets the type of fields that use them.
+func (p *parser) resolve() {
+	kinds := make(map[string]string)
+	var collect func(messages []*Message, enums []*Enum)
+	collect = func(messages []*Message, enums []*Enum) {
+		for _, m := range messages {
+			kinds[m.FullName] = "message"
+			collect(m.Messages, m.Enums)
+		}
+		for _, e := range enums {
+			kinds[e.FullName] = "enum"
+		}
+	}
+	collect(p.file.Messages, p.file.Enums)
+
+	lookup := func(scope, name string) (string, string) {
+		if strings.HasPrefix(name, ".") {
+			if kind, ok := kinds[name[1:]]; ok {
+				return name, kind
+			}
+			return name, ""
+		}
+		for {
+			if kind, ok := kinds[join(scope, name)]; ok {
+				return "." + join(scope, name), kind
+			}
+			if scope == "" {
+				return name, ""
+			}
+			if i := strings.LastIndexByte(scope, '.'); i >= 0 {
+				scope = scope[:i]
+			} else {
+				scope = ""
+			}
+		}
+	}
+	resolveField := func(f *Field) {
+		if f.Extendee != "" {
+			f.Extendee, _ = lookup(f.scope, f.Extendee)
+		}
+		if f.TypeName == "" || f.Type == "group" || f.Type == "message" {
+			return
+		}
+		name, kind := lookup(f.scope, f.TypeName)
+		f.TypeName, f.Type = name, kind
+	}
+	var walk func(messages []*Message)
+	walk = func(messages []*Message) {
+		for _, m := range messages {
+			for _, f := range m.Fields {
+				resolveField(f)
+			}
+			for _, f := range m.Extensions {
+				resolveField(f)
+			}
+			walk(m.Messages)
+		}
+	}
+	walk(p.file.Messages)
+	for _, f := range p.file.Extensions {
+		resolveField(f)
+	}
+	for _, s := range p.file.Services {
+		for _, m := range s.Methods {
+			m.InputType, _ = lookup(m.scope, m.InputType)
+			m.OutputType, _ = lookup(m.scope, m.OutputType)
+		}
+	}
+}
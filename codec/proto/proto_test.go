@@ -0,0 +1,219 @@
+package codec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testProto = `syntax = "proto3";
+package acme.api.v1;
+
+import "google/protobuf/timestamp.proto";
+
+option go_package = "github.com/acme/api/v1;apiv1";
+
+// A user.
+message User {
+  string user_id = 1 [json_name = "id"];
+  optional string nick = 2; // the nickname
+  map<string, Role> roles = 3;
+  google.protobuf.Timestamp created_at = 4;
+  oneof contact {
+    string phone = 5;
+    Address address = 6;
+  }
+  message Address { string city = 1; }
+  reserved 7, 10 to 12;
+  reserved "old";
+}
+
+enum Role {
+  ROLE_UNSPECIFIED = 0;
+  ADMIN = 1 [deprecated = true];
+}
+
+service Users {
+  rpc Get(User) returns (User) {
+    option (google.api.http) = { get: "/v1/users/{user_id}" additional_bindings { get: "/v1/me" } };
+  }
+  rpc Watch(stream User) returns (stream User);
+}
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse(testProto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Syntax != "proto3" || f.Package != "acme.api.v1" || f.Options["go_package"] != "github.com/acme/api/v1;apiv1" {
+		t.Errorf("unexpected file header: %+v", f)
+	}
+	if len(f.Imports) != 1 || f.Imports[0].Path != "google/protobuf/timestamp.proto" {
+		t.Errorf("unexpected imports: %+v", f.Imports)
+	}
+
+	user := f.Messages[0]
+	if user.FullName != "acme.api.v1.User" || user.Comment != "A user." {
+		t.Errorf("unexpected message: %s %q", user.FullName, user.Comment)
+	}
+	fields := map[string]*Field{}
+	for _, field := range user.Fields {
+		fields[field.Name] = field
+	}
+	tests := []struct {
+		name string
+		want Field
+	}{
+		{"user_id", Field{Name: "user_id", Number: 1, Label: "optional", Type: "string", JSONName: "id"}},
+		{"nick", Field{Name: "nick", Number: 2, Label: "optional", Type: "string", JSONName: "nick", Proto3Optional: true, Comment: "the nickname"}},
+		{"roles", Field{Name: "roles", Number: 3, Label: "repeated", Type: "message", TypeName: ".acme.api.v1.User.RolesEntry", JSONName: "roles"}},
+		{"created_at", Field{Name: "created_at", Number: 4, Label: "optional", TypeName: "google.protobuf.Timestamp", JSONName: "createdAt"}},
+		{"address", Field{Name: "address", Number: 6, Label: "optional", Type: "message", TypeName: ".acme.api.v1.User.Address", JSONName: "address", Oneof: "contact"}},
+	}
+	for _, tt := range tests {
+		got := *fields[tt.name]
+		got.scope = ""
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+
+	entry := user.Messages[0]
+	if entry.Name != "RolesEntry" || entry.Options["map_entry"] != true || entry.Fields[1].Type != "enum" || entry.Fields[1].TypeName != ".acme.api.v1.Role" {
+		t.Errorf("unexpected map entry: %+v %+v", entry, entry.Fields[1])
+	}
+	if !reflect.DeepEqual(user.ReservedRanges, []Range{{7, 7}, {10, 12}}) || !reflect.DeepEqual(user.ReservedNames, []string{"old"}) {
+		t.Errorf("unexpected reserved: %v %v", user.ReservedRanges, user.ReservedNames)
+	}
+	if f.Enums[0].Values[1].Options["deprecated"] != true {
+		t.Errorf("unexpected enum value options: %+v", f.Enums[0].Values[1])
+	}
+
+	get, watch := f.Services[0].Methods[0], f.Services[0].Methods[1]
+	if get.InputType != ".acme.api.v1.User" || get.ClientStreaming || !watch.ClientStreaming || !watch.ServerStreaming {
+		t.Errorf("unexpected methods: %+v %+v", get, watch)
+	}
+	http := get.Options["(google.api.http)"].(map[string]any)
+	if http["get"] != "/v1/users/{user_id}" || http["additional_bindings"].(map[string]any)["get"] != "/v1/me" {
+		t.Errorf("unexpected method options: %v", http)
+	}
+}
+
+func TestParseProto2(t *testing.T) {
+	f, err := Parse(`syntax = "proto2";
+package p;
+message M {
+  required string q = 1 [default = "a\x41\101"];
+  repeated group Result = 2 { required string url = 3; }
+  extensions 100 to max;
+}
+extend M { optional int32 ext = 100; }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := f.Messages[0]
+	if m.Fields[0].Label != "required" || m.Fields[0].DefaultValue != "aAA" {
+		t.Errorf("unexpected field: %+v", m.Fields[0])
+	}
+	if g := m.Fields[1]; g.Name != "result" || g.Type != "group" || g.TypeName != ".p.M.Result" || m.Messages[0].Fields[0].Name != "url" {
+		t.Errorf("unexpected group: %+v", g)
+	}
+	if !reflect.DeepEqual(m.ExtensionRanges, []Range{{100, 536870911}}) {
+		t.Errorf("unexpected extension ranges: %v", m.ExtensionRanges)
+	}
+	if ext := f.Extensions[0]; ext.Extendee != ".p.M" || ext.Type != "int32" {
+		t.Errorf("unexpected extension: %+v", ext)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{"syntax = \"proto3\";\nmessage M {\n  string a = 1\n}", "4:1: expected \";\""},
+		{"message M { string a = 1; ", "unterminated message M"},
+		{"syntax = \"proto4\";", "unknown syntax"},
+		{"message M { string a = \"x\"; }", "expected an integer"},
+		{"/* open", "unterminated comment"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: got error %v, want %q", tt.src, err, tt.err)
+		}
+	}
+}
+
+func TestFileDescriptorProto(t *testing.T) {
+	f, err := Parse(testProto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdp, err := f.FileDescriptorProto("user.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := fd.Messages().ByName("User")
+	if got := user.Fields().ByName("user_id").JSONName(); got != "id" {
+		t.Errorf("json name of user_id is %q, want id", got)
+	}
+	if nick := user.Fields().ByName("nick"); !nick.HasOptionalKeyword() || nick.ContainingOneof().Name() != "_nick" {
+		t.Errorf("nick is not a proto3 optional field")
+	}
+	if roles := user.Fields().ByName("roles"); !roles.IsMap() || roles.MapValue().Enum().FullName() != "acme.api.v1.Role" {
+		t.Errorf("roles is not a map of Role")
+	}
+	if got := user.Fields().ByName("created_at").Message().FullName(); got != "google.protobuf.Timestamp" {
+		t.Errorf("created_at is a %s", got)
+	}
+	if got := user.Fields().ByName("address").ContainingOneof().Name(); got != "contact" {
+		t.Errorf("address is in oneof %q, want contact", got)
+	}
+	if !user.ReservedRanges().Has(11) || !user.ReservedNames().Has("old") {
+		t.Errorf("reserved ranges or names are missing")
+	}
+	if got := fd.Options().(*descriptorpb.FileOptions).GetGoPackage(); got != "github.com/acme/api/v1;apiv1" {
+		t.Errorf("go_package is %q", got)
+	}
+	if !fd.Services().Get(0).Methods().ByName("Watch").IsStreamingClient() {
+		t.Errorf("Watch is not client streaming")
+	}
+
+	f, err = Parse(`syntax = "proto2";
+package p;
+message M {
+  optional string q = 1 [default = "x"];
+  repeated group Result = 2 { required string url = 3; }
+  extensions 100 to max;
+}
+extend M { optional int32 ext = 100; }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fdp, err = f.FileDescriptorProto("p.proto"); err != nil {
+		t.Fatal(err)
+	}
+	if fd, err = protodesc.NewFile(fdp, nil); err != nil {
+		t.Fatal(err)
+	}
+	m := fd.Messages().ByName("M")
+	if m.Fields().ByName("result").Kind() != protoreflect.GroupKind || m.Fields().ByName("q").Default().String() != "x" {
+		t.Errorf("unexpected fields of M")
+	}
+	if fd.Extensions().ByName("ext").ContainingMessage() != m {
+		t.Errorf("ext does not extend M")
+	}
+}
@@ -0,0 +1,99 @@
+package protobuf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/goccy/go-json"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Codec reads and writes binary protobuf messages. With a schema, from a
+// .proto file or a descriptor set, a message decodes to the object protojson
+// gives for it; in raw mode no schema is needed and it decodes to the list
+// of its fields, like protoc --decode_raw.
+type Codec struct {
+	Schema
+	// Raw decodes and encodes without a schema.
+	Raw bool
+}
+
+// SetOption implements codec.Configurable for the protobuf options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "proto", "descriptor_set", "message", "import_path":
+		return c.Schema.SetOption(key, value)
+	case "raw":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		c.Raw = b
+	default:
+		return fmt.Errorf("unknown option %q, expected proto, descriptor_set, message, import_path or raw", key)
+	}
+	return nil
+}
+
+func (c *Codec) Unmarshal(input []byte, v any) error {
+	var data []byte
+	if c.Raw {
+		fields, err := decodeRaw(input)
+		if err != nil {
+			return fmt.Errorf("error decoding protobuf: %v", err)
+		}
+		// marshaled as objects, as go-json fails on the values of nested
+		// rawFields
+		if data, err = json.Marshal(objects(fields)); err != nil {
+			return err
+		}
+	} else {
+		s, err := c.Load()
+		if err != nil {
+			return err
+		}
+		msg := dynamicpb.NewMessage(s.Message)
+		if err := proto.Unmarshal(input, msg); err != nil {
+			return fmt.Errorf("error decoding protobuf %s: %v", s.Message.FullName(), err)
+		}
+		opts := protojson.MarshalOptions{Resolver: dynamicpb.NewTypes(s.Files)}
+		if data, err = opts.Marshal(msg); err != nil {
+			return fmt.Errorf("error decoding protobuf %s: %v", s.Message.FullName(), err)
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (c *Codec) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if c.Raw {
+		// numbers are kept as written, to encode large ones exactly
+		var fields []rawField
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&fields); err != nil {
+			return nil, fmt.Errorf("raw protobuf output requires an array of fields like those --raw decodes to")
+		}
+		return encodeRaw(fields)
+	}
+	s, err := c.Load()
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(s.Message)
+	opts := protojson.UnmarshalOptions{Resolver: dynamicpb.NewTypes(s.Files)}
+	if err := opts.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("error encoding protobuf %s: %v", s.Message.FullName(), err)
+	}
+	out, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding protobuf %s: %v", s.Message.FullName(), err)
+	}
+	return out, nil
+}
@@ -0,0 +1,188 @@
+package protobuf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const eventProto = `syntax = "proto3";
+package demo;
+
+import "google/protobuf/timestamp.proto";
+import "common.proto";
+
+enum Level { LEVEL_UNSPECIFIED = 0; INFO = 1; WARN = 2; }
+
+message Event {
+  string id = 1;
+  Level level = 2;
+  google.protobuf.Timestamp at = 3;
+  map<string, int64> counts = 4;
+  repeated Tag tags = 5;
+  common.Origin origin = 6;
+}
+
+message Tag { string key = 1; }
+`
+
+const commonProto = `syntax = "proto3";
+package common;
+message Origin { string host = 1; uint32 port = 2; }
+`
+
+// writeSchema writes the test schema to a directory, with common.proto in
+// a subdirectory that has to be given as an import path.
+func writeSchema(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	if err := os.Mkdir(lib, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "event.proto"), []byte(eventProto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(lib, "common.proto"), []byte(commonProto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "event.proto"), lib
+}
+
+func TestRoundTrip(t *testing.T) {
+	path, lib := writeSchema(t)
+	c := &Codec{Schema: Schema{Proto: path, ImportPaths: []string{lib}, Message: "Event"}}
+	event := map[string]any{
+		"id":     "e1",
+		"level":  "WARN",
+		"at":     "2024-01-02T03:04:05Z",
+		"counts": map[string]any{"a": "9007199254740993"},
+		"tags":   []any{map[string]any{"key": "k"}},
+		"origin": map[string]any{"host": "h", "port": float64(80)},
+	}
+	data, err := c.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got any
+	if err := c.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, any(event)) {
+		t.Errorf("got %v, want %v", got, event)
+	}
+
+	// the same message, with the schema from a descriptor set
+	s, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, name := range []string{"google/protobuf/timestamp.proto", "common.proto", "event.proto"} {
+		fd, err := s.Files.FindFileByPath(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setPath := filepath.Join(t.TempDir(), "set.binpb")
+	if err := os.WriteFile(setPath, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c = &Codec{Schema: Schema{DescriptorSet: setPath, Message: "demo.Event"}}
+	got = nil
+	if err := c.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, any(event)) {
+		t.Errorf("descriptor set: got %v, want %v", got, event)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	path, lib := writeSchema(t)
+	tests := []struct {
+		codec *Codec
+		err   string
+	}{
+		{&Codec{}, "needs a schema"},
+		{&Codec{Schema: Schema{Proto: path}}, `import "common.proto" not found`},
+		{&Codec{Schema: Schema{Proto: path, ImportPaths: []string{lib}}}, "choose one with --message: demo.Event, demo.Tag"},
+		{&Codec{Schema: Schema{Proto: path, ImportPaths: []string{lib}, Message: "Missing"}}, "message Missing not found"},
+		{&Codec{Schema: Schema{Proto: path, ImportPaths: []string{lib}, Message: "demo.Level"}}, "demo.Level is not a message"},
+	}
+	for _, tt := range tests {
+		var v any
+		err := tt.codec.Unmarshal(nil, &v)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%+v: got error %v, want %q", tt.codec, err, tt.err)
+		}
+	}
+}
+
+func TestRaw(t *testing.T) {
+	c := &Codec{Raw: true}
+	// 1: 150, 2: "testing", 3: {1: 150}, 4: fixed32 1, 5: fixed64 2^63,
+	// 6: bytes ff
+	input := []byte{
+		0x08, 0x96, 0x01,
+		0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g',
+		0x1a, 0x03, 0x08, 0x96, 0x01,
+		0x25, 0x01, 0x00, 0x00, 0x00,
+		0x29, 0, 0, 0, 0, 0, 0, 0, 0x80,
+		0x32, 0x01, 0xff,
+	}
+	var got any
+	if err := c.Unmarshal(input, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		map[string]any{"field": float64(1), "wire_type": "varint", "value": float64(150)},
+		map[string]any{"field": float64(2), "wire_type": "len", "string": "testing"},
+		map[string]any{"field": float64(3), "wire_type": "len", "message": []any{
+			map[string]any{"field": float64(1), "wire_type": "varint", "value": float64(150)},
+		}},
+		map[string]any{"field": float64(4), "wire_type": "i32", "value": float64(1)},
+		map[string]any{"field": float64(5), "wire_type": "i64", "value": "9223372036854775808"},
+		map[string]any{"field": float64(6), "wire_type": "len", "bytes": "/w=="},
+	}
+	if !reflect.DeepEqual(got, any(want)) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	out, err := c.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, input) {
+		t.Errorf("encoded %x, want %x", out, input)
+	}
+
+	// negative numbers are written in two's complement, and fractions as
+	// doubles
+	out, err = c.Marshal([]any{
+		map[string]any{"field": 1, "wire_type": "varint", "value": -1},
+		map[string]any{"field": 2, "wire_type": "i64", "value": 1.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want64 := []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x11, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f}
+	if !bytes.Equal(out, want64) {
+		t.Errorf("encoded %x, want %x", out, want64)
+	}
+
+	if err := c.Unmarshal([]byte{0x08}, &got); err == nil {
+		t.Errorf("expected an error for a truncated varint")
+	}
+}
@@ -0,0 +1,268 @@
+package protobuf
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/goccy/go-json"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawField is a field of a message decoded without its schema. The wire
+// type says how the value was encoded: varint, i64 and i32 values are in
+// Value, and a len value is in String when it is printable text, in
+// Message when it parses as a message and in Bytes otherwise. Group holds
+// the fields of a group.
+type rawField struct {
+	Field    protowire.Number `json:"field"`
+	WireType string           `json:"wire_type"`
+	Value    any              `json:"value,omitempty"`
+	String   *string          `json:"string,omitempty"`
+	Message  []rawField       `json:"message,omitempty"`
+	Bytes    []byte           `json:"bytes,omitempty"`
+	Group    []rawField       `json:"group,omitempty"`
+}
+
+// object returns f as the object it is written as in JSON, with the fields
+// it does not use left out.
+func (f rawField) object() map[string]any {
+	obj := map[string]any{"field": int(f.Field), "wire_type": f.WireType}
+	if f.Value != nil {
+		obj["value"] = f.Value
+	}
+	if f.String != nil {
+		obj["string"] = *f.String
+	}
+	if len(f.Message) > 0 {
+		obj["message"] = objects(f.Message)
+	}
+	if len(f.Bytes) > 0 {
+		obj["bytes"] = base64.StdEncoding.EncodeToString(f.Bytes)
+	}
+	if len(f.Group) > 0 {
+		obj["group"] = objects(f.Group)
+	}
+	return obj
+}
+
+func objects(fields []rawField) []any {
+	out := make([]any, len(fields))
+	for i, f := range fields {
+		out[i] = f.object()
+	}
+	return out
+}
+
+var wireTypes = map[protowire.Type]string{
+	protowire.VarintType:     "varint",
+	protowire.Fixed64Type:    "i64",
+	protowire.BytesType:      "len",
+	protowire.StartGroupType: "group",
+	protowire.Fixed32Type:    "i32",
+}
+
+// decodeRaw decodes the fields of a message without its schema.
+func decodeRaw(b []byte) ([]rawField, error) {
+	fields, rest, err := decodeFields(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected end group")
+	}
+	return fields, nil
+}
+
+// decodeFields decodes fields until the end of b or, inside a group, the
+// end group tag of the group, and returns what follows it.
+func decodeFields(b []byte, group protowire.Number) ([]rawField, []byte, error) {
+	fields := []rawField{}
+	offset := 0
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, nil, fmt.Errorf("at byte %d: %v", offset, protowire.ParseError(n))
+		}
+		if typ == protowire.EndGroupType {
+			if num != group {
+				return nil, nil, fmt.Errorf("at byte %d: unexpected end group %d", offset, num)
+			}
+			return fields, b[n:], nil
+		}
+		f := rawField{Field: num, WireType: wireTypes[typ]}
+		offset += n
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			f.Value = util.Uint64(v)
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(b)
+			f.Value = util.Uint64(v)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.Value = v
+		case protowire.BytesType:
+			var v []byte
+			if v, n = protowire.ConsumeBytes(b); n >= 0 {
+				decodeLen(&f, v)
+			}
+		case protowire.StartGroupType:
+			group, rest, err := decodeFields(b, num)
+			if err != nil {
+				return nil, nil, err
+			}
+			f.Group = group
+			n = len(b) - len(rest)
+		default:
+			return nil, nil, fmt.Errorf("at byte %d: unknown wire type %d", offset, typ)
+		}
+		if n < 0 {
+			return nil, nil, fmt.Errorf("at byte %d: %v", offset, protowire.ParseError(n))
+		}
+		fields = append(fields, f)
+		offset += n
+		b = b[n:]
+	}
+	if group != 0 {
+		return nil, nil, fmt.Errorf("group %d is not ended", group)
+	}
+	return fields, nil, nil
+}
+
+// decodeLen guesses what a length-delimited value holds. Text is tried
+// before messages, since short strings often happen to parse as one.
+func decodeLen(f *rawField, v []byte) {
+	if printable(v) {
+		s := string(v)
+		f.String = &s
+		return
+	}
+	if fields, err := decodeRaw(v); err == nil {
+		f.Message = fields
+		return
+	}
+	f.Bytes = v
+}
+
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeRaw encodes fields as decodeRaw gives them.
+func encodeRaw(fields []rawField) ([]byte, error) {
+	var b []byte
+	for _, f := range fields {
+		var err error
+		if b, err = appendField(b, f); err != nil {
+			return nil, fmt.Errorf("field %d: %v", f.Field, err)
+		}
+	}
+	return b, nil
+}
+
+func appendField(b []byte, f rawField) ([]byte, error) {
+	if f.Field < protowire.MinValidNumber || f.Field > protowire.MaxValidNumber {
+		return nil, fmt.Errorf("invalid field number")
+	}
+	switch f.WireType {
+	case "varint":
+		v, err := intValue(f.Value, 64, false)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, f.Field, protowire.VarintType)
+		return protowire.AppendVarint(b, v), nil
+	case "i64":
+		v, err := intValue(f.Value, 64, true)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, f.Field, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, v), nil
+	case "i32":
+		v, err := intValue(f.Value, 32, true)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, f.Field, protowire.Fixed32Type)
+		return protowire.AppendFixed32(b, uint32(v)), nil
+	case "len":
+		var v []byte
+		switch {
+		case f.String != nil:
+			v = []byte(*f.String)
+		case f.Message != nil:
+			var err error
+			if v, err = encodeRaw(f.Message); err != nil {
+				return nil, err
+			}
+		default:
+			v = f.Bytes
+		}
+		b = protowire.AppendTag(b, f.Field, protowire.BytesType)
+		return protowire.AppendBytes(b, v), nil
+	case "group":
+		group, err := encodeRaw(f.Group)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, f.Field, protowire.StartGroupType)
+		b = append(b, group...)
+		return protowire.AppendTag(b, f.Field, protowire.EndGroupType), nil
+	}
+	return nil, fmt.Errorf("unknown wire type %q, expected varint, i64, len, group or i32", f.WireType)
+}
+
+// intValue reads the value of a varint, i64 or i32 field. Integers may be
+// negative and are then written in two's complement. In i64 and i32
+// fields, numbers with a fraction or exponent are written as a double or a
+// float.
+func intValue(value any, bits int, fixed bool) (uint64, error) {
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	case nil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("value %v is not a number", value)
+	}
+	if u, err := strconv.ParseUint(s, 10, bits); err == nil {
+		return u, nil
+	}
+	if i, err := strconv.ParseInt(s, 10, bits); err == nil {
+		if bits == 32 {
+			return uint64(uint32(int32(i))), nil
+		}
+		return uint64(i), nil
+	}
+	if fixed && strings.ContainsAny(s, ".eE") {
+		if f, err := strconv.ParseFloat(s, bits); err == nil {
+			if bits == 32 {
+				return uint64(math.Float32bits(float32(f))), nil
+			}
+			return math.Float64bits(f), nil
+		}
+	}
+	return 0, fmt.Errorf("value %s is not a %d-bit number", s, bits)
+}
@@ -0,0 +1,279 @@
+package protobuf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	protoidl "github.com/JFryy/qq/codec/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	// the well-known types, for schemas that import them
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/apipb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/sourcecontextpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/typepb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Schema names a message type declared in a .proto file or a descriptor
+// set. The textproto codec reads its schema from here too.
+type Schema struct {
+	// Proto is the .proto file declaring the message. Its imports are
+	// looked up next to it and in ImportPaths.
+	Proto string
+	// DescriptorSet is a FileDescriptorSet, as written by
+	// protoc --descriptor_set_out or buf build, to use instead of Proto.
+	DescriptorSet string
+	// Message is the full name of the message, such as pkg.Event. It may
+	// be left out when the schema declares a single message.
+	Message string
+	// ImportPaths are more directories to look for imports in.
+	ImportPaths []string
+
+	loaded *Loaded
+}
+
+// Loaded is the message type a Schema names, with the files it was found
+// in, which resolve extensions and the types inside Any fields.
+type Loaded struct {
+	Message protoreflect.MessageDescriptor
+	Files   *protoregistry.Files
+}
+
+// SetOption sets the proto, descriptor_set, message and import_path
+// options.
+func (s *Schema) SetOption(key, value string) error {
+	switch key {
+	case "proto":
+		s.Proto = value
+	case "descriptor_set":
+		s.DescriptorSet = value
+	case "message":
+		s.Message = value
+	case "import_path":
+		for _, dir := range strings.Split(value, ",") {
+			if dir != "" {
+				s.ImportPaths = append(s.ImportPaths, dir)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown option %q, expected proto, descriptor_set, message or import_path", key)
+	}
+	s.loaded = nil
+	return nil
+}
+
+// IsSet reports whether a .proto file or descriptor set is given.
+func (s *Schema) IsSet() bool {
+	return s.Proto != "" || s.DescriptorSet != ""
+}
+
+// Load finds the message type in the .proto file or descriptor set.
+func (s *Schema) Load() (*Loaded, error) {
+	if s.loaded != nil {
+		return s.loaded, nil
+	}
+	var files *protoregistry.Files
+	var root []protoreflect.FileDescriptor // where to look for a message when none is named
+	var err error
+	switch {
+	case s.Proto != "" && s.DescriptorSet != "":
+		return nil, fmt.Errorf("use either a .proto file or a descriptor set, not both")
+	case s.Proto != "":
+		l := &loader{files: new(protoregistry.Files), paths: append([]string{filepath.Dir(s.Proto)}, s.ImportPaths...)}
+		fd, err := l.loadFile(filepath.Base(s.Proto), nil)
+		if err != nil {
+			return nil, err
+		}
+		files, root = l.files, []protoreflect.FileDescriptor{fd}
+	case s.DescriptorSet != "":
+		if files, err = readDescriptorSet(s.DescriptorSet); err != nil {
+			return nil, err
+		}
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			root = append(root, fd)
+			return true
+		})
+	default:
+		return nil, fmt.Errorf("protobuf needs a schema: give a .proto file with --proto or a descriptor set with --descriptor-set, or use --raw")
+	}
+
+	md, err := s.findMessage(files, root)
+	if err != nil {
+		return nil, err
+	}
+	s.loaded = &Loaded{Message: md, Files: files}
+	return s.loaded, nil
+}
+
+// findMessage finds the message named by s.Message, by its full name or,
+// when that is unambiguous, by its name alone. Without a name, the schema
+// must declare a single top-level message.
+func (s *Schema) findMessage(files *protoregistry.Files, root []protoreflect.FileDescriptor) (protoreflect.MessageDescriptor, error) {
+	if s.Message != "" {
+		name := strings.TrimPrefix(s.Message, ".")
+		if d, err := files.FindDescriptorByName(protoreflect.FullName(name)); err == nil {
+			if md, ok := d.(protoreflect.MessageDescriptor); ok {
+				return md, nil
+			}
+			return nil, fmt.Errorf("%s is not a message", name)
+		}
+		var matches []protoreflect.MessageDescriptor
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			walkMessages(fd.Messages(), func(md protoreflect.MessageDescriptor) {
+				if string(md.Name()) == name || strings.HasSuffix(string(md.FullName()), "."+name) {
+					matches = append(matches, md)
+				}
+			})
+			return true
+		})
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("message %s not found in the schema", name)
+		case 1:
+			return matches[0], nil
+		}
+		return nil, fmt.Errorf("message name %s is ambiguous: %s", name, messageNames(matches))
+	}
+
+	var candidates []protoreflect.MessageDescriptor
+	for _, fd := range root {
+		for i := 0; i < fd.Messages().Len(); i++ {
+			candidates = append(candidates, fd.Messages().Get(i))
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("the schema has no messages")
+	case 1:
+		return candidates[0], nil
+	}
+	return nil, fmt.Errorf("the schema has several messages, choose one with --message: %s", messageNames(candidates))
+}
+
+func walkMessages(messages protoreflect.MessageDescriptors, fn func(protoreflect.MessageDescriptor)) {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		fn(md)
+		walkMessages(md.Messages(), fn)
+	}
+}
+
+func messageNames(messages []protoreflect.MessageDescriptor) string {
+	names := make([]string, len(messages))
+	for i, md := range messages {
+		names[i] = string(md.FullName())
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func readDescriptorSet(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading descriptor set: %v", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error reading descriptor set %s: %v", path, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("error reading descriptor set %s: %v", path, err)
+	}
+	return files, nil
+}
+
+// loader parses .proto files and their imports, which are looked up in
+// the import paths and then among the well-known types.
+type loader struct {
+	files *protoregistry.Files
+	paths []string
+}
+
+func (l *loader) loadFile(name string, importing []string) (protoreflect.FileDescriptor, error) {
+	if fd, err := l.files.FindFileByPath(name); err == nil {
+		return fd, nil
+	}
+	for _, p := range importing {
+		if p == name {
+			return nil, fmt.Errorf("import cycle: %s -> %s", strings.Join(importing, " -> "), name)
+		}
+	}
+
+	src, found, err := l.read(name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if fd, err := protoregistry.GlobalFiles.FindFileByPath(name); err == nil {
+			return fd, l.register(fd)
+		}
+		return nil, fmt.Errorf("import %q not found in %s", name, strings.Join(l.paths, ", "))
+	}
+
+	file, err := protoidl.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", name, err)
+	}
+	for _, imp := range file.Imports {
+		if _, err := l.loadFile(imp.Path, append(importing, name)); err != nil {
+			if imp.Weak {
+				continue
+			}
+			return nil, err
+		}
+	}
+	fdp, err := file.FileDescriptorProto(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	fd, err := protodesc.FileOptions{AllowUnresolvable: false}.New(fdp, l.files)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return fd, l.register(fd)
+}
+
+// register adds fd, and first the files it imports, unless they are there
+// already.
+func (l *loader) register(fd protoreflect.FileDescriptor) error {
+	if _, err := l.files.FindFileByPath(fd.Path()); err == nil {
+		return nil
+	}
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		if err := l.register(imports.Get(i).FileDescriptor); err != nil {
+			return err
+		}
+	}
+	return l.files.RegisterFile(fd)
+}
+
+func (l *loader) read(name string) (string, bool, error) {
+	for _, dir := range l.paths {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(data), true, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", false, err
+		}
+	}
+	return "", false, nil
+}
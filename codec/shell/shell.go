@@ -0,0 +1,214 @@
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JFryy/qq/internal/flatten"
+	"github.com/goccy/go-json"
+)
+
+// Codec writes variable assignments that a shell can eval. Nested keys are
+// joined with _ into upper case identifiers, so {"db": {"host": "x"}}
+// becomes DB_HOST='x'.
+type Codec struct {
+	// Dialect is "bash" (default), "zsh", "fish" or "posix".
+	Dialect string
+	// Prefix is prepended to every name, such as APP_.
+	Prefix string
+	// Export exports the variables.
+	Export bool
+	// KeepCase keeps the case of keys instead of upper casing them.
+	KeepCase bool
+	// JoinArrays writes arrays of scalars as space separated strings even
+	// when the dialect has arrays. posix always joins them.
+	JoinArrays bool
+}
+
+// SetOption implements codec.Configurable for the shell options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "dialect":
+		switch value {
+		case "bash", "zsh", "fish", "posix":
+			c.Dialect = value
+		case "sh":
+			c.Dialect = "posix"
+		default:
+			return fmt.Errorf("unknown shell %q, expected bash, zsh, fish or posix", value)
+		}
+	case "prefix":
+		if value != "" && !validName.MatchString(value) {
+			return fmt.Errorf("prefix %q is not a valid variable name", value)
+		}
+		c.Prefix = value
+	case "export", "keep_case", "join_arrays":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		switch key {
+		case "export":
+			c.Export = b
+		case "keep_case":
+			c.KeepCase = b
+		case "join_arrays":
+			c.JoinArrays = b
+		}
+	default:
+		return fmt.Errorf("unknown option %q, expected dialect, prefix, export, keep_case or join_arrays", key)
+	}
+	return nil
+}
+
+var (
+	validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	nameChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+)
+
+func (c *Codec) Unmarshal(input []byte, v any) error {
+	return fmt.Errorf("shell is an output format only")
+}
+
+type variable struct {
+	path  string
+	name  string
+	value any // a scalar, or a []any of scalars
+}
+
+// Marshal writes one assignment per scalar, or per array of scalars, in
+// key order.
+func (c *Codec) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	if _, ok := value.(map[string]any); !ok {
+		return nil, fmt.Errorf("shell output needs an object of key-value pairs")
+	}
+
+	var vars []variable
+	var walk func(path []string, v any)
+	walk = func(path []string, v any) {
+		switch val := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(val))
+			for k := range val {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(append(path[:len(path):len(path)], k), val[k])
+			}
+			return
+		case []any:
+			if !scalars(val) {
+				for i, item := range val {
+					walk(append(path[:len(path):len(path)], strconv.Itoa(i)), item)
+				}
+				return
+			}
+		}
+		vars = append(vars, variable{path: strings.Join(path, "."), name: c.name(path), value: v})
+	}
+	walk(nil, value)
+
+	seen := make(map[string]string)
+	lines := make([]string, 0, len(vars))
+	for _, vr := range vars {
+		if prev, ok := seen[vr.name]; ok {
+			return nil, fmt.Errorf("keys %q and %q both become %s", prev, vr.path, vr.name)
+		}
+		seen[vr.name] = vr.path
+		lines = append(lines, c.assignment(vr))
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func scalars(items []any) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]any, []any:
+			return false
+		}
+	}
+	return true
+}
+
+// name turns a key path into an identifier.
+func (c *Codec) name(path []string) string {
+	name := nameChars.ReplaceAllString(strings.Join(path, "_"), "_")
+	if !c.KeepCase {
+		name = strings.ToUpper(name)
+	}
+	name = c.Prefix + name
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+func (c *Codec) assignment(vr variable) string {
+	items, isArray := vr.value.([]any)
+	if c.Dialect == "fish" {
+		scope := "-g"
+		if c.Export {
+			scope = "-gx"
+		}
+		if !isArray {
+			items = []any{vr.value}
+		} else if c.JoinArrays {
+			items = []any{join(items)}
+		}
+		line := "set " + scope + " " + vr.name
+		for _, item := range items {
+			line += " " + fishQuote(flatten.String(item))
+		}
+		return line
+	}
+
+	var value string
+	switch {
+	case !isArray:
+		value = quote(flatten.String(vr.value))
+	case c.Dialect == "posix" || c.JoinArrays:
+		value = quote(join(items))
+	default:
+		// bash and zsh arrays cannot be exported, so they are only assigned
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			quoted[i] = quote(flatten.String(item))
+		}
+		return vr.name + "=(" + strings.Join(quoted, " ") + ")"
+	}
+	if c.Export {
+		return "export " + vr.name + "=" + value
+	}
+	return vr.name + "=" + value
+}
+
+func join(items []any) string {
+	s := make([]string, len(items))
+	for i, item := range items {
+		s[i] = flatten.String(item)
+	}
+	return strings.Join(s, " ")
+}
+
+// quote single quotes s for sh, bash and zsh. Nothing inside single quotes
+// is special, so a quote in s ends the quoting, is escaped and reopens it.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote single quotes s for fish, where \ and ' are escaped with \.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
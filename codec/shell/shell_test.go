@@ -0,0 +1,85 @@
+package shell
+
+import (
+	"testing"
+)
+
+func TestQuoting(t *testing.T) {
+	input := map[string]any{"name": "it's $HOME `ls`", "path": `C:\dir`}
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{"bash", `NAME='it'\''s $HOME ` + "`ls`" + `'` + "\n" + `PATH='C:\dir'`},
+		{"posix", `NAME='it'\''s $HOME ` + "`ls`" + `'` + "\n" + `PATH='C:\dir'`},
+		{"fish", `set -g NAME 'it\'s $HOME ` + "`ls`" + `'` + "\n" + `set -g PATH 'C:\\dir'`},
+	}
+	for _, tt := range tests {
+		c := Codec{Dialect: tt.dialect}
+		got, err := c.Marshal(input)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.dialect, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestNamesAndArrays(t *testing.T) {
+	input := map[string]any{
+		"db":      map[string]any{"host": "localhost", "port": 5432},
+		"tags":    []any{"a", "b c"},
+		"servers": []any{map[string]any{"ip": "10.0.0.1"}},
+		"9lives":  true,
+		"x-y.z":   nil,
+	}
+	tests := []struct {
+		codec Codec
+		want  string
+	}{
+		{Codec{}, `_9LIVES='true'
+DB_HOST='localhost'
+DB_PORT='5432'
+SERVERS_0_IP='10.0.0.1'
+TAGS=('a' 'b c')
+X_Y_Z=''`},
+		{Codec{Prefix: "APP_", Export: true, KeepCase: true, JoinArrays: true}, `export APP_9lives='true'
+export APP_db_host='localhost'
+export APP_db_port='5432'
+export APP_servers_0_ip='10.0.0.1'
+export APP_tags='a b c'
+export APP_x_y_z=''`},
+		{Codec{Dialect: "fish", Export: true}, `set -gx _9LIVES 'true'
+set -gx DB_HOST 'localhost'
+set -gx DB_PORT '5432'
+set -gx SERVERS_0_IP '10.0.0.1'
+set -gx TAGS 'a' 'b c'
+set -gx X_Y_Z ''`},
+	}
+	for _, tt := range tests {
+		got, err := tt.codec.Marshal(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%+v:\ngot\n%s\nwant\n%s", tt.codec, got, tt.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := Codec{}
+	if _, err := c.Marshal(map[string]any{"a-b": 1, "a_b": 2}); err == nil {
+		t.Error("expected an error for keys with the same name")
+	}
+	if _, err := c.Marshal([]any{1}); err == nil {
+		t.Error("expected an error for a top-level array")
+	}
+	if err := c.SetOption("dialect", "csh"); err == nil {
+		t.Error("expected an error for an unknown shell")
+	}
+	if err := c.SetOption("prefix", "1X"); err == nil {
+		t.Error("expected an error for an invalid prefix")
+	}
+}
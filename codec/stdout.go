@@ -11,8 +11,14 @@ import (
 	"github.com/mattn/go-isatty"
 )
 
+// isText reports whether output of fileType is lines of text, such as shell
+// assignments, with no strings for -r to unquote.
+func isText(fileType EncodingType) bool {
+	return fileType == SHELL || (fileType == GRON && gronCodec.Values)
+}
+
 func PrettyFormat(s string, fileType EncodingType, raw bool, monochrome bool) (string, error) {
-	if raw {
+	if raw && !isText(fileType) {
 		var v any
 		err := Unmarshal([]byte(s), fileType, &v)
 		if err != nil {
@@ -38,7 +44,7 @@ func PrettyFormat(s string, fileType EncodingType, raw bool, monochrome bool) (s
 
 	var lexer chroma.Lexer
 	// this a workaround for json lexer while we don't have a marshal function dedicated for these formats.
-	if fileType == CSV || fileType == HTML || fileType == LINE || fileType == TXT || fileType == ENV || fileType == PARQUET || fileType == MSGPACK {
+	if fileType == CSV || fileType == LINE || fileType == TXT || fileType == ENV || fileType == PARQUET || fileType == MSGPACK {
 		lexer = lexers.Get("json")
 	} else {
 		lexer = lexers.Get(fileType.String())
@@ -2,10 +2,16 @@ package codec
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strings"
 
+	"github.com/JFryy/qq/codec/csv"
+	"github.com/JFryy/qq/codec/gron"
+	"github.com/JFryy/qq/codec/parquet"
 	"github.com/goccy/go-json"
 )
 
@@ -13,6 +19,14 @@ import (
 // For JSON: matches jq's --stream behavior exactly
 // For other formats: converts each record/document to path-value pairs
 func StreamParser(reader io.Reader, inputType EncodingType) (<-chan any, <-chan error) {
+	return StreamScan(reader, inputType, parquet.Scan{})
+}
+
+// StreamScan is StreamParser for a query that uses only what scan
+// describes: Parquet input leaves out the other columns and the row groups
+// no row the query keeps can be in, and so the pairs they give. Rows keep
+// their index in the file. Other input is streamed in full.
+func StreamScan(reader io.Reader, inputType EncodingType, scan parquet.Scan) (<-chan any, <-chan error) {
 	dataChan := make(chan any, 100) // Buffer for performance
 	errChan := make(chan error, 1)
 
@@ -31,9 +45,15 @@ func StreamParser(reader io.Reader, inputType EncodingType) (<-chan any, <-chan
 		case LINE, TXT:
 			err = streamLines(reader, dataChan)
 		case CSV:
-			err = streamCSV(reader, dataChan)
+			err = streamDelimited(reader, csvCodec.ReaderDialect(), dataChan)
 		case TSV:
-			err = streamTSV(reader, dataChan)
+			err = streamDelimited(reader, tsvCodec.ReaderDialect(), dataChan)
+		case XML:
+			err = xmlCodec.StreamEvents(reader, func(v any) { dataChan <- v })
+		case GRON:
+			err = streamGron(reader, dataChan)
+		case PARQUET:
+			err = streamParquet(reader, scan, dataChan)
 		default:
 			// For unsupported formats, read all and convert to stream
 			data, readErr := io.ReadAll(reader)
@@ -61,6 +81,70 @@ func StreamParser(reader io.Reader, inputType EncodingType) (<-chan any, <-chan
 	return dataChan, errChan
 }
 
+// StreamElements parses input incrementally and emits the value of every
+// element called name, one complete element at a time. Only xml input is
+// supported.
+func StreamElements(reader io.Reader, inputType EncodingType, name string) (<-chan any, <-chan error) {
+	dataChan := make(chan any, 100)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+
+		if inputType != XML {
+			errChan <- fmt.Errorf("element streaming is not supported for %s input, only xml", inputType)
+			return
+		}
+		if err := xmlCodec.StreamElements(reader, name, func(v any) { dataChan <- v }); err != nil {
+			errChan <- err
+		}
+	}()
+
+	return dataChan, errChan
+}
+
+// streamParquet reads Parquet a batch of rows at a time and emits the
+// path-value pairs of each row as it is read, as for csv, reading only the
+// columns and row groups scan calls for.
+func streamParquet(reader io.Reader, scan parquet.Scan, dataChan chan<- any) error {
+	r, err := readerAt(reader)
+	if err != nil {
+		return err
+	}
+	return parquetCodec.Stream(r, scan, func(index int, row any) {
+		for _, item := range convertToStream(row, []any{index}) {
+			dataChan <- item
+		}
+	})
+}
+
+type readerAtSeeker interface {
+	io.ReaderAt
+	io.Seeker
+}
+
+// readerAt returns reader as one that can be read at any offset, reading
+// input that cannot, such as stdin, into memory first.
+func readerAt(reader io.Reader) (readerAtSeeker, error) {
+	r, ok := reader.(readerAtSeeker)
+	if f, isFile := reader.(*os.File); !ok || (isFile && !isRegular(f)) {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+	return r, nil
+}
+
+// isRegular reports whether f is a regular file, which unlike a pipe can
+// be read at any offset.
+func isRegular(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode().IsRegular()
+}
+
 // Legacy function that collects all stream elements (for backward compatibility)
 func StreamParserCollect(reader io.Reader, inputType EncodingType) ([]any, error) {
 	dataChan, errChan := StreamParser(reader, inputType)
@@ -209,99 +293,93 @@ func streamLines(reader io.Reader, dataChan chan<- any) error {
 	return nil
 }
 
-// streamCSV parses CSV in streaming mode
-// Reads header once, then streams each row immediately
-func streamCSV(reader io.Reader, dataChan chan<- any) error {
-	scanner := bufio.NewScanner(reader)
-
-	// Read header row
-	if !scanner.Scan() {
-		return fmt.Errorf("empty CSV file")
-	}
-
-	headerLine := scanner.Text()
-	headers := strings.Split(headerLine, ",")
-
-	// Trim spaces from headers
-	for i := range headers {
-		headers[i] = strings.TrimSpace(headers[i])
+// streamDelimited parses CSV or TSV in streaming mode with the same
+// reader and type inference as the codecs, emitting each row immediately
+func streamDelimited(reader io.Reader, dialect csv.Dialect, dataChan chan<- any) error {
+	r, err := csv.NewReader(reader, dialect)
+	if err != nil {
+		return err
 	}
-
-	rowIndex := 0
-
-	// Stream each data row immediately
-	for scanner.Scan() {
-		line := scanner.Text()
-		values := strings.Split(line, ",")
-
-		// Create object from row
-		row := make(map[string]any)
-		for i, header := range headers {
-			if i < len(values) {
-				row[header] = strings.TrimSpace(values[i])
-			}
+	for rowIndex := 0; ; rowIndex++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
 		}
-
-		// Convert and emit row immediately
-		rowStream := convertToStream(row, []any{rowIndex})
-		for _, item := range rowStream {
+		if err != nil {
+			return err
+		}
+		for _, item := range convertToStream(row, []any{rowIndex}) {
 			dataChan <- item
 		}
-		rowIndex++
-	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading CSV: %v", err)
 	}
-
-	return nil
 }
 
-// streamTSV parses TSV in streaming mode
-// Reads header once, then streams each row immediately
-func streamTSV(reader io.Reader, dataChan chan<- any) error {
-	scanner := bufio.NewScanner(reader)
-
-	// Read header row
-	if !scanner.Scan() {
-		return fmt.Errorf("empty TSV file")
+// streamGron emits each gron statement as it is read, so statements can
+// come in any order, as they do after grep or sort. Whether a container is
+// empty, and which child closes it, is only known at the end of the input,
+// so empty containers and closing events are emitted last, deepest first.
+func streamGron(reader io.Reader, dataChan chan<- any) error {
+	type container struct {
+		path     []any
+		value    any // the declared {} or [], if any
+		last     any // the last key, or the highest index
+		children bool
 	}
-
-	headerLine := scanner.Text()
-	headers := strings.Split(headerLine, "\t")
-
-	// Trim spaces from headers
-	for i := range headers {
-		headers[i] = strings.TrimSpace(headers[i])
+	containers := make(map[string]*container)
+	var order []*container
+	lookup := func(path []any) *container {
+		id, _ := json.Marshal(path)
+		c, ok := containers[string(id)]
+		if !ok {
+			c = &container{path: path}
+			containers[string(id)] = c
+			order = append(order, c)
+		}
+		return c
 	}
 
-	rowIndex := 0
-
-	// Stream each data row immediately
-	for scanner.Scan() {
-		line := scanner.Text()
-		values := strings.Split(line, "\t")
-
-		// Create object from row
-		row := make(map[string]any)
-		for i, header := range headers {
-			if i < len(values) {
-				row[header] = strings.TrimSpace(values[i])
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		st, ok, err := gron.ParseStatement(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("error parsing gron on line %d: %v", line, err)
+		}
+		if !ok {
+			continue
+		}
+		if st.Path == nil {
+			st.Path = []any{}
+		}
+		for i, key := range st.Path {
+			parent := lookup(st.Path[:i:i])
+			parent.children = true
+			if idx, isIndex := key.(int); !isIndex || parent.last == nil || idx > parent.last.(int) {
+				parent.last = key
 			}
 		}
-
-		// Convert and emit row immediately
-		rowStream := convertToStream(row, []any{rowIndex})
-		for _, item := range rowStream {
-			dataChan <- item
+		switch st.Value.(type) {
+		case map[string]any, []any:
+			lookup(st.Path).value = st.Value
+			continue
 		}
-		rowIndex++
+		dataChan <- []any{st.Path, st.Value}
 	}
-
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading TSV: %v", err)
+		return fmt.Errorf("error reading gron: %v", err)
 	}
 
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(order[i].path) > len(order[j].path)
+	})
+	for _, c := range order {
+		switch {
+		case c.children:
+			dataChan <- []any{append(c.path[:len(c.path):len(c.path)], c.last)}
+		case c.value != nil:
+			dataChan <- []any{c.path, c.value}
+		}
+	}
 	return nil
 }
 
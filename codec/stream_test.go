@@ -1,8 +1,12 @@
 package codec
 
 import (
+	"bytes"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/JFryy/qq/codec/parquet"
 )
 
 func TestStreamParser_SimpleObject(t *testing.T) {
@@ -306,3 +310,84 @@ func TestStreamParser_TSV(t *testing.T) {
 		t.Errorf("Expected [path, value] pair, got %v", first)
 	}
 }
+
+func TestStreamParser_CSVMatchesUnmarshal(t *testing.T) {
+	input := "id,name,note\n1,\"Smith, J\",\"multi\nline\"\n2,Bob\n"
+
+	var want any
+	if err := Unmarshal([]byte(input), CSV, &want); err != nil {
+		t.Fatal(err)
+	}
+	events, err := StreamParserCollect(strings.NewReader(input), CSV)
+	if err != nil {
+		t.Fatalf("StreamParser failed: %v", err)
+	}
+
+	rows := want.([]any)
+	for _, ev := range events {
+		pair := ev.([]any)
+		if len(pair) != 2 {
+			continue
+		}
+		path := pair[0].([]any)
+		row := rows[path[0].(int)].(map[string]any)
+		if !reflect.DeepEqual(row[path[1].(string)], pair[1]) {
+			t.Errorf("%v: stream value %#v, decoded %#v", path, pair[1], row[path[1].(string)])
+		}
+	}
+}
+
+func TestStreamParser_GronInterleaved(t *testing.T) {
+	// the elements of a gron --stream array, interleaved
+	input := `json[1].name = "b";
+json[0].name = "a";
+json[1] = {};
+json[0].tags = [];
+json[0] = {};
+json = [];
+`
+	result, err := StreamParserCollect(strings.NewReader(input), GRON)
+	if err != nil {
+		t.Fatalf("StreamParser failed: %v", err)
+	}
+	want := []any{
+		[]any{[]any{1, "name"}, "b"},
+		[]any{[]any{0, "name"}, "a"},
+		[]any{[]any{0, "tags"}, []any{}},
+		[]any{[]any{1, "name"}},
+		[]any{[]any{0, "tags"}},
+		[]any{[]any{1}},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("got %v, want %v", result, want)
+	}
+}
+
+func TestStreamScan_Parquet(t *testing.T) {
+	var rows []any
+	for i := range 4 {
+		rows = append(rows, map[string]any{"n": i, "name": "x"})
+	}
+	data, err := (&parquet.Codec{RowGroupSize: 2}).Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first row group is skipped, and the rows after it keep their index
+	scan := parquet.Scan{Columns: []string{"n"}, Filters: []parquet.Filter{{Column: "n", Op: ">=", Value: float64(3)}}}
+	dataChan, errChan := StreamScan(bytes.NewReader(data), PARQUET, scan)
+	var got []any
+	for item := range dataChan {
+		got = append(got, item)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		[]any{[]any{2, "n"}, 2}, []any{[]any{2, "n"}},
+		[]any{[]any{3, "n"}, 3}, []any{[]any{3, "n"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,368 @@
+package textproto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/JFryy/qq/codec/protobuf"
+	"github.com/JFryy/qq/codec/util"
+	"github.com/goccy/go-json"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// untyped converts a message read without a schema. A field written once
+// is a single value and a field written several times, or in list syntax,
+// an array. Integers too large for jq to hold exactly are json.Number, and
+// enum values and other bare identifiers strings.
+func untyped(m *message) (map[string]any, error) {
+	obj := make(map[string]any)
+	for _, f := range m.fields {
+		if f.value == nil {
+			// an empty list
+			if _, ok := obj[f.name]; !ok {
+				obj[f.name] = []any{}
+			}
+			continue
+		}
+		var v any
+		var err error
+		if sub, ok := f.value.(*message); ok {
+			v, err = untyped(sub)
+		} else {
+			v, err = untypedScalar(f.value.(literal))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%d:%d: %s: %v", f.line, f.col, f.name, err)
+		}
+		prev, ok := obj[f.name]
+		switch {
+		case !ok && f.list:
+			obj[f.name] = []any{v}
+		case !ok:
+			obj[f.name] = v
+		default:
+			// values are never arrays themselves, so an array here is the
+			// field's earlier values
+			if arr, isArr := prev.([]any); isArr {
+				obj[f.name] = append(arr, v)
+			} else {
+				obj[f.name] = []any{prev, v}
+			}
+		}
+	}
+	return obj, nil
+}
+
+func untypedScalar(l literal) (any, error) {
+	switch l.kind {
+	case litString:
+		return l.text, nil
+	case litIdent:
+		switch l.text {
+		case "true", "True":
+			return true, nil
+		case "false", "False":
+			return false, nil
+		}
+		// enum values, and inf and nan, which JSON has no numbers for
+		return l.text, nil
+	}
+	if i, err := strconv.ParseInt(l.text, 0, 64); err == nil {
+		return integer64(i), nil
+	}
+	if u, err := strconv.ParseUint(l.text, 0, 64); err == nil {
+		return json.Number(strconv.FormatUint(u, 10)), nil
+	}
+	return parseFloat(l.text)
+}
+
+// integer64 returns n, or n as a json.Number when a float64 cannot hold it
+// exactly, which jq keeps as written.
+func integer64(n int64) any {
+	if n > util.MaxExact || n < -util.MaxExact {
+		return json.Number(strconv.FormatInt(n, 10))
+	}
+	return int(n)
+}
+
+// parseFloat parses a float, which may have an f suffix.
+func parseFloat(s string) (float64, error) {
+	if !strings.HasPrefix(strings.TrimPrefix(strings.ToLower(s), "-"), "0x") {
+		s = strings.TrimRight(s, "fF")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", s)
+	}
+	return f, nil
+}
+
+// typed converts a message read with a schema. Repeated fields are always
+// arrays, map fields are objects, bytes are base64, enums are their value
+// names and extensions and Any values are written as protojson writes
+// them. Fields keep their names as written, and well-known types such as
+// Timestamp stay messages, as in text format.
+type typed struct {
+	schema *protobuf.Loaded
+}
+
+func (t *typed) message(m *message, md protoreflect.MessageDescriptor) (map[string]any, error) {
+	if md.FullName() == "google.protobuf.Any" && len(m.fields) == 1 && strings.Contains(m.fields[0].name, "/") {
+		return t.any(m.fields[0])
+	}
+	obj := make(map[string]any)
+	for _, f := range m.fields {
+		fd, key, err := t.field(md, f)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.set(obj, fd, key, f); err != nil {
+			return nil, fmt.Errorf("%d:%d: %s: %v", f.line, f.col, f.name, err)
+		}
+	}
+	return obj, nil
+}
+
+// any expands an Any written as [type.googleapis.com/pkg.Message] { ... }
+// into the message with its type under "@type", as protojson does.
+func (t *typed) any(f field) (map[string]any, error) {
+	url := strings.Trim(f.name, "[]")
+	name := url[strings.LastIndex(url, "/")+1:]
+	d, err := t.schema.Files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("%d:%d: unknown type %s in Any", f.line, f.col, name)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	sub, isMsg := f.value.(*message)
+	if !ok || !isMsg {
+		return nil, fmt.Errorf("%d:%d: %s is not a message", f.line, f.col, name)
+	}
+	obj, err := t.message(sub, md)
+	if err != nil {
+		return nil, err
+	}
+	obj["@type"] = url
+	return obj, nil
+}
+
+// field finds the field written as f.name and the key it is stored under.
+func (t *typed) field(md protoreflect.MessageDescriptor, f field) (protoreflect.FieldDescriptor, string, error) {
+	if strings.HasPrefix(f.name, "[") {
+		name := protoreflect.FullName(strings.Trim(f.name, "[]"))
+		d, err := t.schema.Files.FindDescriptorByName(name)
+		if xd, ok := d.(protoreflect.FieldDescriptor); err == nil && ok && xd.IsExtension() && xd.ContainingMessage().FullName() == md.FullName() {
+			return xd, f.name, nil
+		}
+		return nil, "", fmt.Errorf("%d:%d: unknown extension %s of %s", f.line, f.col, name, md.FullName())
+	}
+	fd := md.Fields().ByName(protoreflect.Name(f.name))
+	if fd == nil {
+		// groups are written with the name of their type
+		fd = md.Fields().ByName(protoreflect.Name(strings.ToLower(f.name)))
+		if fd != nil && (fd.Kind() != protoreflect.GroupKind || string(fd.Message().Name()) != f.name) {
+			fd = nil
+		}
+	}
+	if fd == nil {
+		return nil, "", fmt.Errorf("%d:%d: unknown field %s in %s", f.line, f.col, f.name, md.FullName())
+	}
+	return fd, string(fd.Name()), nil
+}
+
+func (t *typed) set(obj map[string]any, fd protoreflect.FieldDescriptor, key string, f field) error {
+	prev, seen := obj[key]
+	switch {
+	case fd.IsMap():
+		entries, _ := prev.(map[string]any)
+		if entries == nil {
+			entries = make(map[string]any)
+			obj[key] = entries
+		}
+		if f.value == nil {
+			return nil
+		}
+		entry, ok := f.value.(*message)
+		if !ok {
+			return fmt.Errorf("expected a map entry")
+		}
+		var k, v any = nil, nil
+		for _, ef := range entry.fields {
+			var err error
+			switch ef.name {
+			case "key":
+				k, err = t.value(fd.MapKey(), ef.value)
+			case "value":
+				v, err = t.value(fd.MapValue(), ef.value)
+			default:
+				err = fmt.Errorf("unknown field %s in map entry", ef.name)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if v == nil {
+			v = zero(fd.MapValue())
+		}
+		if k == nil {
+			k = zero(fd.MapKey())
+		}
+		entries[fmt.Sprint(k)] = v
+	case fd.IsList():
+		arr, _ := prev.([]any)
+		if arr == nil {
+			arr = []any{}
+		}
+		if f.value != nil {
+			v, err := t.value(fd, f.value)
+			if err != nil {
+				return err
+			}
+			arr = append(arr, v)
+		}
+		obj[key] = arr
+	case f.list:
+		return fmt.Errorf("%s is not repeated", fd.Name())
+	case seen:
+		return fmt.Errorf("%s is not repeated but is set more than once", fd.Name())
+	default:
+		v, err := t.value(fd, f.value)
+		if err != nil {
+			return err
+		}
+		obj[key] = v
+	}
+	return nil
+}
+
+// value converts a literal or message to the type of fd.
+func (t *typed) value(fd protoreflect.FieldDescriptor, value any) (any, error) {
+	if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		m, ok := value.(*message)
+		if !ok {
+			return nil, fmt.Errorf("expected a message")
+		}
+		return t.message(m, fd.Message())
+	}
+	l, ok := value.(literal)
+	if !ok {
+		return nil, fmt.Errorf("expected a %s, found a message", fd.Kind())
+	}
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if l.kind == litString {
+			return l.text, nil
+		}
+	case protoreflect.BytesKind:
+		if l.kind == litString {
+			return base64.StdEncoding.EncodeToString([]byte(l.text)), nil
+		}
+	case protoreflect.BoolKind:
+		if l.kind == litString {
+			break
+		}
+		switch l.text {
+		case "true", "True", "t", "1":
+			return true, nil
+		case "false", "False", "f", "0":
+			return false, nil
+		}
+	case protoreflect.EnumKind:
+		switch l.kind {
+		case litIdent:
+			if fd.Enum().Values().ByName(protoreflect.Name(l.text)) != nil {
+				return l.text, nil
+			}
+			return nil, fmt.Errorf("unknown value %s of %s", l.text, fd.Enum().FullName())
+		case litNumber:
+			n, err := strconv.ParseInt(l.text, 0, 32)
+			if err != nil {
+				break
+			}
+			if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(n)); ev != nil {
+				return string(ev.Name()), nil
+			}
+			return n, nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if l.kind == litNumber {
+			if n, err := strconv.ParseInt(l.text, 0, 32); err == nil {
+				return n, nil
+			}
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if l.kind == litNumber {
+			if n, err := strconv.ParseUint(l.text, 0, 32); err == nil {
+				return n, nil
+			}
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		// 64-bit integers are strings, as protojson and so the protobuf
+		// codec write them
+		if l.kind == litNumber {
+			if n, err := strconv.ParseInt(l.text, 0, 64); err == nil {
+				return strconv.FormatInt(n, 10), nil
+			}
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if l.kind == litNumber {
+			if n, err := strconv.ParseUint(l.text, 0, 64); err == nil {
+				return strconv.FormatUint(n, 10), nil
+			}
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		switch l.kind {
+		case litNumber:
+			if n, err := strconv.ParseInt(l.text, 0, 64); err == nil {
+				return float64(n), nil
+			}
+			return parseFloat(l.text)
+		case litIdent:
+			// JSON has no numbers for these, so they are written as
+			// protojson writes them
+			switch strings.ToLower(l.text) {
+			case "inf", "infinity":
+				return "Infinity", nil
+			case "-inf", "-infinity":
+				return "-Infinity", nil
+			case "nan", "-nan":
+				return "NaN", nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid %s value %s", fd.Kind(), literalString(l))
+}
+
+func bits(fd protoreflect.FieldDescriptor) int {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return 32
+	}
+	return 64
+}
+
+func literalString(l literal) string {
+	if l.kind == litString {
+		return strconv.Quote(l.text)
+	}
+	return l.text
+}
+
+// zero is the value of a map key or value left out of its entry.
+func zero(fd protoreflect.FieldDescriptor) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return map[string]any{}
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(0); ev != nil {
+			return string(ev.Name())
+		}
+		return 0
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return ""
+	case protoreflect.BoolKind:
+		return false
+	}
+	return 0
+}
@@ -0,0 +1,438 @@
+package textproto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// message is a message as written in text format, before it is typed. A
+// field written several times, or with the [a, b] list syntax, appears
+// once per value.
+type message struct {
+	fields []field
+}
+
+type field struct {
+	// name is the field name, or for extensions and expanded Any values
+	// the bracketed name, such as [pkg.ext] or [type.googleapis.com/pkg.M].
+	name string
+	// value is a literal or a *message.
+	value any
+	// list is set for values written in list syntax, which are repeated
+	// even when there is only one.
+	list      bool
+	line, col int
+}
+
+type literalKind int
+
+const (
+	litString literalKind = iota
+	litNumber
+	litIdent
+)
+
+// literal is a scalar value. Numbers and identifiers keep their text, with
+// a leading - when negative; strings are decoded.
+type literal struct {
+	kind literalKind
+	text string
+}
+
+// parse parses a text format message.
+func parse(src string) (*message, error) {
+	p := &parser{lex: lexer{src: src, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	m, err := p.fields("")
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	t, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%d:%d: %s", p.tok.line, p.tok.col, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) is(symbol string) bool {
+	return p.tok.kind == tokSymbol && p.tok.text == symbol
+}
+
+func (p *parser) expect(symbol string) error {
+	if !p.is(symbol) {
+		return p.errorf("expected %q, found %s", symbol, p.tok)
+	}
+	return p.advance()
+}
+
+// fields parses fields up to the closing symbol, or the end of the input
+// when close is empty.
+func (p *parser) fields(close string) (*message, error) {
+	m := &message{}
+	for {
+		if close == "" && p.tok.kind == tokEOF {
+			return m, nil
+		}
+		if close != "" && p.is(close) {
+			return m, p.advance()
+		}
+		if p.tok.kind == tokEOF {
+			return nil, p.errorf("expected %q, found end of input", close)
+		}
+		fields, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		m.fields = append(m.fields, fields...)
+		if p.is(",") || p.is(";") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// field parses a field and returns one entry per value.
+func (p *parser) field() ([]field, error) {
+	f := field{line: p.tok.line, col: p.tok.col}
+	switch {
+	case p.tok.kind == tokIdent:
+		f.name = p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	case p.is("["):
+		name, err := p.extensionName()
+		if err != nil {
+			return nil, err
+		}
+		f.name = name
+	default:
+		return nil, p.errorf("expected a field name, found %s", p.tok)
+	}
+
+	colon := p.is(":")
+	if colon {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.is("[") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var fields []field
+		for !p.is("]") {
+			if len(fields) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			v, err := p.value(true)
+			if err != nil {
+				return nil, err
+			}
+			item := f
+			item.value, item.list = v, true
+			fields = append(fields, item)
+		}
+		if len(fields) == 0 {
+			// an empty list still says the field is repeated
+			f.list = true
+			f.value = nil
+			fields = append(fields, f)
+		}
+		return fields, p.advance()
+	}
+	if !colon && !p.is("{") && !p.is("<") {
+		return nil, p.errorf("expected \":\" after %s, found %s", f.name, p.tok)
+	}
+	v, err := p.value(true)
+	if err != nil {
+		return nil, err
+	}
+	f.value = v
+	return []field{f}, nil
+}
+
+// extensionName parses a bracketed name: [pkg.ext] or
+// [type.googleapis.com/pkg.Message].
+func (p *parser) extensionName() (string, error) {
+	var b strings.Builder
+	b.WriteString("[")
+	if err := p.advance(); err != nil {
+		return "", err
+	}
+	for !p.is("]") {
+		if p.tok.kind != tokIdent && !p.is(".") && !p.is("/") {
+			return "", p.errorf("expected \"]\", found %s", p.tok)
+		}
+		b.WriteString(p.tok.text)
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+	}
+	b.WriteString("]")
+	return b.String(), p.advance()
+}
+
+// value parses a scalar or, when messages are allowed, a message.
+func (p *parser) value(messages bool) (any, error) {
+	switch {
+	case messages && (p.is("{") || p.is("<")):
+		close := "}"
+		if p.tok.text == "<" {
+			close = ">"
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		return p.fields(close)
+	case p.tok.kind == tokString:
+		// adjacent strings are concatenated
+		var b strings.Builder
+		for p.tok.kind == tokString {
+			b.WriteString(p.tok.text)
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		return literal{kind: litString, text: b.String()}, nil
+	case p.is("-"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokNumber && p.tok.kind != tokIdent {
+			return nil, p.errorf("expected a number after \"-\", found %s", p.tok)
+		}
+		v, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		l := v.(literal)
+		l.text = "-" + l.text
+		return l, nil
+	case p.tok.kind == tokNumber:
+		l := literal{kind: litNumber, text: p.tok.text}
+		return l, p.advance()
+	case p.tok.kind == tokIdent:
+		l := literal{kind: litIdent, text: p.tok.text}
+		return l, p.advance()
+	}
+	return nil, p.errorf("expected a value, found %s", p.tok)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind      tokenKind
+	text      string // the token as written; the decoded value for strings
+	line, col int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type lexer struct {
+	src       string
+	pos       int
+	line, col int
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return fmt.Errorf("%d:%d: %s", l.line, l.col, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) advance(n int) {
+	for _, c := range l.src[l.pos : l.pos+n] {
+		if c == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
+	l.pos += n
+}
+
+// skip skips whitespace and # comments.
+func (l *lexer) skip() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '#':
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			if end < 0 {
+				end = len(l.src) - l.pos
+			}
+			l.advance(end)
+		case strings.IndexByte(" \t\r\n\f\v", c) >= 0:
+			l.advance(1)
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skip()
+	t := token{line: l.line, col: l.col}
+	if l.pos >= len(l.src) {
+		return t, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case isLetter(c):
+		end := l.pos
+		for end < len(l.src) && (isLetter(l.src[end]) || isDigit(l.src[end])) {
+			end++
+		}
+		t.kind, t.text = tokIdent, l.src[l.pos:end]
+		l.advance(end - l.pos)
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		end := l.pos
+		for end < len(l.src) && (isLetter(l.src[end]) || isDigit(l.src[end]) || l.src[end] == '.' ||
+			((l.src[end] == '+' || l.src[end] == '-') && (l.src[end-1] == 'e' || l.src[end-1] == 'E') && !strings.HasPrefix(strings.ToLower(l.src[l.pos:]), "0x"))) {
+			end++
+		}
+		t.kind, t.text = tokNumber, l.src[l.pos:end]
+		l.advance(end - l.pos)
+	case c == '"' || c == '\'':
+		s, err := l.str()
+		if err != nil {
+			return t, err
+		}
+		t.kind, t.text = tokString, s
+	case strings.IndexByte("{}<>[]:,;-/.", c) >= 0:
+		t.kind, t.text = tokSymbol, string(c)
+		l.advance(1)
+	default:
+		return t, l.errorf("unexpected character %q", c)
+	}
+	return t, nil
+}
+
+// str reads a quoted string with the C escapes of text format.
+func (l *lexer) str() (string, error) {
+	q := l.src[l.pos]
+	var b strings.Builder
+	i := l.pos + 1
+	for ; i < len(l.src) && l.src[i] != q; i++ {
+		c := l.src[i]
+		if c == '\n' {
+			return "", l.errorf("unterminated string")
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i++; i >= len(l.src) {
+			break
+		}
+		switch e := l.src[i]; e {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			b.WriteByte(e)
+		case 'x':
+			n := 0
+			for n < 2 && i+1+n < len(l.src) && isHex(l.src[i+1+n]) {
+				n++
+			}
+			v, err := strconv.ParseUint(l.src[i+1:i+1+n], 16, 8)
+			if err != nil {
+				return "", l.errorf("invalid escape \\x")
+			}
+			b.WriteByte(byte(v))
+			i += n
+		case 'u', 'U':
+			n := 4
+			if e == 'U' {
+				n = 8
+			}
+			if i+n >= len(l.src) {
+				return "", l.errorf("invalid escape \\%c", e)
+			}
+			v, err := strconv.ParseUint(l.src[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", l.errorf("invalid escape \\%c", e)
+			}
+			b.WriteRune(rune(v))
+			i += n
+		default:
+			if e < '0' || e > '7' {
+				return "", l.errorf("invalid escape \\%c", e)
+			}
+			n := 1
+			for n < 3 && i+n < len(l.src) && l.src[i+n] >= '0' && l.src[i+n] <= '7' {
+				n++
+			}
+			v, err := strconv.ParseUint(l.src[i:i+n], 8, 8)
+			if err != nil {
+				return "", l.errorf("invalid octal escape")
+			}
+			b.WriteByte(byte(v))
+			i += n - 1
+		}
+	}
+	if i >= len(l.src) {
+		return "", l.errorf("unterminated string")
+	}
+	l.advance(i + 1 - l.pos)
+	return b.String(), nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
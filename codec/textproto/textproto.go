@@ -0,0 +1,87 @@
+package textproto
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/JFryy/qq/codec/protobuf"
+	"github.com/goccy/go-json"
+)
+
+// Codec reads and writes protobuf text format, as used by .textproto and
+// .pbtxt files. Without a schema, fields written more than once are
+// arrays and nested messages are objects; with one, from the same .proto
+// file or descriptor set as the protobuf codec, fields are typed as
+// declared.
+type Codec struct {
+	protobuf.Schema
+}
+
+// SetOption implements codec.Configurable for the textproto options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "proto", "descriptor_set", "message", "import_path":
+		return c.Schema.SetOption(key, value)
+	}
+	return fmt.Errorf("unknown option %q, expected proto, descriptor_set, message or import_path", key)
+}
+
+func (c *Codec) Unmarshal(input []byte, v any) error {
+	m, err := parse(string(input))
+	if err != nil {
+		return err
+	}
+	var obj map[string]any
+	if c.IsSet() {
+		s, err := c.Load()
+		if err != nil {
+			return err
+		}
+		t := &typed{schema: s}
+		if obj, err = t.message(m, s.Message); err != nil {
+			return err
+		}
+	} else {
+		if obj, err = untyped(m); err != nil {
+			return err
+		}
+		// assigned as is, to keep large integers as json.Number
+		if p, ok := v.(*any); ok {
+			*p = obj
+			return nil
+		}
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (c *Codec) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// numbers are kept as written, to write large ones exactly
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("textproto output requires an object")
+	}
+	w := &writer{}
+	if c.IsSet() {
+		if w.schema, err = c.Load(); err != nil {
+			return nil, err
+		}
+		err = w.message(obj, w.schema.Message, 0)
+	} else {
+		err = w.untyped(obj, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimSuffix(w.b.String(), "\n")), nil
+}
@@ -0,0 +1,289 @@
+package textproto
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JFryy/qq/codec/protobuf"
+	"github.com/goccy/go-json"
+)
+
+const config = `# envoy style
+node { id: "n1" cluster: 'c' }
+static_resources {
+  listeners {
+    name: "l1"
+    address { socket_address { port_value: 10000 } }
+  }
+  listeners < name: "l2" >
+}
+flags: [1, -2, 0x10]
+ratio: 1.5f;
+mode: FAST,
+enabled: true
+text: "a" 'b\n\x41\101\''
+big: 9007199254740993
+`
+
+func TestUnmarshal(t *testing.T) {
+	var got any
+	if err := (&Codec{}).Unmarshal([]byte(config), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"node": map[string]any{"id": "n1", "cluster": "c"},
+		"static_resources": map[string]any{"listeners": []any{
+			map[string]any{"name": "l1", "address": map[string]any{"socket_address": map[string]any{"port_value": 10000}}},
+			map[string]any{"name": "l2"},
+		}},
+		"flags":   []any{1, -2, 16},
+		"ratio":   1.5,
+		"mode":    "FAST",
+		"enabled": true,
+		"text":    "ab\nAA'",
+		"big":     json.Number("9007199254740993"),
+	}
+	if !reflect.DeepEqual(got, any(want)) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	out, err := (&Codec{}).Marshal(map[string]any{
+		"name":  "x\t\"y\" é\x01",
+		"ports": []any{80, 443},
+		"tls":   map[string]any{"enabled": true},
+		"empty": []any{},
+		"none":  nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `name: "x\t\"y\" é\001"
+ports: 80
+ports: 443
+tls {
+  enabled: true
+}`
+	if string(out) != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	for _, v := range []any{[]any{1}, map[string]any{"a-b": 1}, map[string]any{"a": []any{[]any{1}}}} {
+		if _, err := (&Codec{}).Marshal(v); err == nil {
+			t.Errorf("%v: expected an error", v)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := `big: 18446744073709551615
+mode: FAST
+name: "fast"
+neg: -9007199254740993
+sub {
+  mode: SLOW
+  ratio: -inf
+}`
+	c := &Codec{}
+	var v any
+	if err := c.Unmarshal([]byte(in), &v); err != nil {
+		t.Fatal(err)
+	}
+	out, err := c.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("got\n%s\nwant\n%s", out, in)
+	}
+
+	// what is written depends on the value alone, not on what was read
+	out, err = c.Marshal(map[string]any{"mode": "FAST", "name": "Fast", "nan": "nan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mode: FAST\nname: \"Fast\"\nnan: nan"; string(out) != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+const schema = `syntax = "proto2";
+package cfg;
+
+import "google/protobuf/any.proto";
+
+enum Mode { SLOW = 0; FAST = 1; }
+
+message Config {
+  optional string name = 1;
+  repeated int32 ports = 2;
+  optional Mode mode = 3;
+  map<string, Backend> backends = 4;
+  optional bytes key = 5;
+  repeated group Rule = 6 { optional string path = 7; }
+  optional google.protobuf.Any detail = 8;
+  optional double weight = 9;
+  extensions 100 to 199;
+}
+
+message Backend { optional string host = 1; optional uint64 id = 2; }
+
+extend Config { optional string owner = 100; }
+`
+
+func TestSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfg.proto")
+	if err := os.WriteFile(path, []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Codec{}
+	for _, opt := range [][2]string{{"proto", path}, {"message", "Config"}} {
+		if err := c.SetOption(opt[0], opt[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := `name: "api"
+ports: 80
+mode: 1
+backends { key: "b" value { host: "h" id: 18446744073709551615 } }
+key: "\377\000"
+Rule { path: "/" }
+detail { [type.googleapis.com/cfg.Backend] { host: "d" } }
+weight: -inf
+[cfg.owner]: "me"
+`
+	var got any
+	if err := c.Unmarshal([]byte(input), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"name":        "api",
+		"ports":       []any{float64(80)},
+		"mode":        "FAST",
+		"backends":    map[string]any{"b": map[string]any{"host": "h", "id": "18446744073709551615"}},
+		"key":         "/wA=",
+		"rule":        []any{map[string]any{"path": "/"}},
+		"detail":      map[string]any{"@type": "type.googleapis.com/cfg.Backend", "host": "d"},
+		"weight":      "-Infinity",
+		"[cfg.owner]": "me",
+	}
+	if !reflect.DeepEqual(got, any(want)) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	out, err := c.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical := `name: "api"
+ports: 80
+mode: FAST
+backends {
+  key: "b"
+  value {
+    host: "h"
+    id: 18446744073709551615
+  }
+}
+key: "\377\000"
+Rule {
+  path: "/"
+}
+detail {
+  [type.googleapis.com/cfg.Backend] {
+    host: "d"
+  }
+}
+weight: -inf
+[cfg.owner]: "me"`
+	if string(out) != canonical {
+		t.Errorf("got\n%s\nwant\n%s", out, canonical)
+	}
+
+	for input, msg := range map[string]string{
+		"nope: 1":             "unknown field nope in cfg.Config",
+		"mode: MEDIUM":        "unknown value MEDIUM of cfg.Mode",
+		`name: "a" name: "b"`: "set more than once",
+		"ports: \"80\"":       "invalid int32 value",
+		"[cfg.other]: 1":      "unknown extension cfg.other",
+	} {
+		err := c.Unmarshal([]byte(input), &got)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: got error %v, want %q", input, err, msg)
+		}
+	}
+	if _, err := c.Marshal(map[string]any{"mode": "MEDIUM"}); err == nil || !strings.Contains(err.Error(), "unknown value MEDIUM") {
+		t.Errorf("got error %v, want an unknown enum value", err)
+	}
+}
+
+func TestIntegersAsProtobuf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ints.proto")
+	proto := `syntax = "proto3";
+message Ints {
+  int32 i32 = 1;
+  uint32 u32 = 2;
+  int64 i64 = 3;
+  sint64 s64 = 4;
+  uint64 u64 = 5;
+  fixed64 f64 = 6;
+  repeated int64 many = 7;
+}
+`
+	if err := os.WriteFile(path, []byte(proto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	schema := protobuf.Schema{Proto: path}
+	var got any
+	input := "i32: -5 u32: 7 i64: 42 s64: -42 u64: 18446744073709551615 f64: 0x10 many: [1, 2]"
+	if err := (&Codec{Schema: schema}).Unmarshal([]byte(input), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"i32": float64(-5), "u32": float64(7), "i64": "42", "s64": "-42",
+		"u64": "18446744073709551615", "f64": "16", "many": []any{"1", "2"},
+	}
+	if !reflect.DeepEqual(got, any(want)) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// the protobuf codec reads the same message back the same
+	pb := &protobuf.Codec{Schema: schema}
+	data, err := pb.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again any
+	if err := pb.Unmarshal(data, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, got) {
+		t.Errorf("protobuf gives %v, textproto %v", again, got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for input, msg := range map[string]string{
+		"a {":         `expected "}"`,
+		"a 1":         `expected ":" after a`,
+		`a: "x`:       "unterminated string",
+		"a: [1 2]":    `expected ","`,
+		"a: @":        "unexpected character",
+		`a: "\q"`:     `invalid escape \q`,
+		"a: 1 }":      "expected a field name",
+		"a: 1e":       "invalid number",
+		"[a.b: 1":     `expected "]"`,
+		"a: - \"x\"":  `expected a number after "-"`,
+		"a: 1\nb {\n": "3:1: expected \"}\"",
+	} {
+		var v any
+		err := (&Codec{}).Unmarshal([]byte(input), &v)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%q: got error %v, want %q", input, err, msg)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package textproto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JFryy/qq/codec/protobuf"
+	"github.com/goccy/go-json"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// writer writes messages in the canonical text format protoc prints: one
+// field per line, nested messages as indented blocks and repeated fields
+// as one line per value. Without a schema fields are written in key order,
+// and with one in field number order.
+type writer struct {
+	b      strings.Builder
+	schema *protobuf.Loaded
+}
+
+func (w *writer) line(depth int, s string) {
+	w.b.WriteString(strings.Repeat("  ", depth))
+	w.b.WriteString(s)
+	w.b.WriteByte('\n')
+}
+
+func (w *writer) untyped(obj map[string]any, depth int) error {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !validName(k) {
+			return fmt.Errorf("key %q is not a field name", k)
+		}
+		values, ok := obj[k].([]any)
+		if !ok {
+			values = []any{obj[k]}
+		}
+		for _, v := range values {
+			if err := w.untypedValue(k, v, depth); err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+		}
+	}
+	return nil
+}
+
+// untypedValue writes v without a schema. Strings are quoted, except those
+// bare reports, which text format only accepts unquoted in enum and float
+// fields.
+func (w *writer) untypedValue(name string, v any, depth int) error {
+	switch v := v.(type) {
+	case nil:
+	case map[string]any:
+		w.line(depth, name+" {")
+		if err := w.untyped(v, depth+1); err != nil {
+			return err
+		}
+		w.line(depth, "}")
+	case []any:
+		return fmt.Errorf("arrays of arrays cannot be written")
+	case string:
+		if bare(v) {
+			w.line(depth, name+": "+v)
+		} else {
+			w.line(depth, name+": "+quote(v, false))
+		}
+	case bool:
+		w.line(depth, name+": "+strconv.FormatBool(v))
+	case json.Number:
+		w.line(depth, name+": "+v.String())
+	default:
+		return fmt.Errorf("unsupported value %v", v)
+	}
+	return nil
+}
+
+// validName reports whether name can be written as a field name: an
+// identifier, or an extension or Any type in brackets.
+func validName(name string) bool {
+	if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+		name = strings.Trim(name, "[]")
+		return name != "" && strings.Trim(name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_./") == ""
+	}
+	if name == "" || isDigit(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isLetter(name[i]) && !isDigit(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// bare reports whether s is written unquoted without a schema: an enum value
+// in upper snake case, as the style guide names them, such as FAST, or inf
+// or nan.
+func bare(s string) bool {
+	switch strings.ToLower(strings.TrimPrefix(s, "-")) {
+	case "inf", "infinity", "nan":
+		return true
+	}
+	if s == "" || s[0] < 'A' || s[0] > 'Z' {
+		return false
+	}
+	return strings.Trim(s, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_") == ""
+}
+
+func (w *writer) message(obj map[string]any, md protoreflect.MessageDescriptor, depth int) error {
+	if url, ok := obj["@type"].(string); ok && md.FullName() == "google.protobuf.Any" {
+		return w.any(obj, url, depth)
+	}
+	fields := md.Fields()
+	present := make(map[string]bool, len(obj))
+	var set []protoreflect.FieldDescriptor
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		for _, name := range []string{string(fd.Name()), fd.JSONName()} {
+			if _, ok := obj[name]; ok && !present[name] {
+				present[name] = true
+				set = append(set, fd)
+				break
+			}
+		}
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i].Number() < set[j].Number() })
+
+	var extensions []string
+	for k := range obj {
+		if present[k] {
+			continue
+		}
+		if !strings.HasPrefix(k, "[") {
+			return fmt.Errorf("unknown field %s in %s", k, md.FullName())
+		}
+		extensions = append(extensions, k)
+	}
+	sort.Strings(extensions)
+
+	for _, fd := range set {
+		v, ok := obj[string(fd.Name())]
+		if !ok {
+			v = obj[fd.JSONName()]
+		}
+		if err := w.field(fd, textName(fd), v, depth); err != nil {
+			return fmt.Errorf("%s: %v", fd.Name(), err)
+		}
+	}
+	for _, k := range extensions {
+		d, err := w.schema.Files.FindDescriptorByName(protoreflect.FullName(strings.Trim(k, "[]")))
+		xd, ok := d.(protoreflect.FieldDescriptor)
+		if err != nil || !ok || !xd.IsExtension() || xd.ContainingMessage().FullName() != md.FullName() {
+			return fmt.Errorf("unknown extension %s of %s", k, md.FullName())
+		}
+		if err := w.field(xd, k, obj[k], depth); err != nil {
+			return fmt.Errorf("%s: %v", k, err)
+		}
+	}
+	return nil
+}
+
+// textName is the name a field is written with: groups use the name of
+// their type.
+func textName(fd protoreflect.FieldDescriptor) string {
+	if fd.Kind() == protoreflect.GroupKind {
+		return string(fd.Message().Name())
+	}
+	return string(fd.Name())
+}
+
+func (w *writer) any(obj map[string]any, url string, depth int) error {
+	name := url[strings.LastIndex(url, "/")+1:]
+	d, err := w.schema.Files.FindDescriptorByName(protoreflect.FullName(name))
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if err != nil || !ok {
+		return fmt.Errorf("unknown type %s in Any", name)
+	}
+	inner := make(map[string]any, len(obj))
+	for k, v := range obj {
+		if k != "@type" {
+			inner[k] = v
+		}
+	}
+	w.line(depth, "["+url+"] {")
+	if err := w.message(inner, md, depth+1); err != nil {
+		return err
+	}
+	w.line(depth, "}")
+	return nil
+}
+
+func (w *writer) field(fd protoreflect.FieldDescriptor, name string, v any, depth int) error {
+	switch {
+	case v == nil:
+		return nil
+	case fd.IsMap():
+		entries, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object")
+		}
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			w.line(depth, name+" {")
+			if err := w.value(fd.MapKey(), "key", k, depth+1); err != nil {
+				return err
+			}
+			if err := w.value(fd.MapValue(), "value", entries[k], depth+1); err != nil {
+				return err
+			}
+			w.line(depth, "}")
+		}
+		return nil
+	case fd.IsList():
+		values, ok := v.([]any)
+		if !ok {
+			values = []any{v}
+		}
+		for _, item := range values {
+			if err := w.value(fd, name, item, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.value(fd, name, v, depth)
+}
+
+// value writes a single value of fd's type.
+func (w *writer) value(fd protoreflect.FieldDescriptor, name string, v any, depth int) error {
+	if v == nil {
+		return nil
+	}
+	if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object for %s", fd.Message().FullName())
+		}
+		w.line(depth, name+" {")
+		if err := w.message(obj, fd.Message(), depth+1); err != nil {
+			return err
+		}
+		w.line(depth, "}")
+		return nil
+	}
+	s, err := scalar(fd, v)
+	if err != nil {
+		return err
+	}
+	w.line(depth, name+": "+s)
+	return nil
+}
+
+// scalar formats v as a value of fd's scalar type. Numbers may be given as
+// strings, as protojson writes 64-bit integers.
+func scalar(fd protoreflect.FieldDescriptor, v any) (string, error) {
+	text := fmt.Sprint(v)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if s, ok := v.(string); ok {
+			return quote(s, false), nil
+		}
+	case protoreflect.BytesKind:
+		if s, ok := v.(string); ok {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return "", fmt.Errorf("bytes must be base64: %v", err)
+			}
+			return quote(string(b), true), nil
+		}
+	case protoreflect.BoolKind:
+		// map keys are strings
+		if text == "true" || text == "false" {
+			return text, nil
+		}
+	case protoreflect.EnumKind:
+		if s, ok := v.(string); ok {
+			if fd.Enum().Values().ByName(protoreflect.Name(s)) == nil {
+				return "", fmt.Errorf("unknown value %s of %s", s, fd.Enum().FullName())
+			}
+			return s, nil
+		}
+		if n, ok := integer(text); ok {
+			if _, err := strconv.ParseInt(n, 10, 32); err == nil {
+				return n, nil
+			}
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if n, ok := integer(text); ok {
+			if _, err := strconv.ParseInt(n, 10, bits(fd)); err == nil {
+				return n, nil
+			}
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if n, ok := integer(text); ok {
+			if _, err := strconv.ParseUint(n, 10, bits(fd)); err == nil {
+				return n, nil
+			}
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		switch text {
+		case "Infinity", "inf":
+			return "inf", nil
+		case "-Infinity", "-inf":
+			return "-inf", nil
+		case "NaN", "nan":
+			return "nan", nil
+		}
+		if _, isBool := v.(bool); !isBool {
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				return text, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid %s value %v", fd.Kind(), v)
+}
+
+// integer returns the decimal text of s when it is a whole number, such as
+// 3, "3" or 3e0.
+func integer(s string) (string, bool) {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return s, true
+	}
+	if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return s, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return "", false
+	}
+	return strconv.FormatInt(int64(f), 10), true
+}
+
+// quote writes s as a double quoted string with C escapes, as protoc does.
+// Bytes escape everything outside printable ASCII; strings keep valid
+// UTF-8 as it is.
+func quote(s string, bytes bool) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if c >= 0x20 && c < 0x7f {
+				b.WriteByte(c)
+				break
+			}
+			if r, size := utf8.DecodeRuneInString(s[i:]); !bytes && c >= 0x80 && !(r == utf8.RuneError && size == 1) {
+				b.WriteString(s[i : i+size])
+				i += size
+				continue
+			}
+			fmt.Fprintf(&b, `\%03o`, c)
+		}
+		i++
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/goccy/go-json"
+)
+
+// MaxExact is the largest integer a float64, and so jq, holds exactly.
+// Codecs give larger integers as their decimal text, so no digit is lost.
+const MaxExact = 1 << 53
+
+// Int64 returns n as an int, or as its decimal text when jq cannot hold it
+// exactly.
+func Int64(n int64) any {
+	if n > MaxExact || n < -MaxExact {
+		return strconv.FormatInt(n, 10)
+	}
+	return int(n)
+}
+
+// Uint64 returns n as an int, or as its decimal text when jq cannot hold it
+// exactly.
+func Uint64(n uint64) any {
+	if n > MaxExact {
+		return strconv.FormatUint(n, 10)
+	}
+	return int(n)
+}
+
+// Float returns f, or for the values JSON has no numbers for the strings
+// protojson and Spark use.
+func Float(f float64) any {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// Float32 widens f by its shortest decimal form, so 87.2 stays 87.2 rather
+// than becoming 87.19999694824219.
+func Float32(f float32) any {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return Float(float64(f))
+	}
+	wide, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return wide
+}
+
+// NumberText returns the text of v, a json.Number or a string holding a
+// number, as decoded with UseNumber.
+func NumberText(v any) (string, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String(), nil
+	case string:
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("expected a number, got %s", Describe(v))
+}
+
+// ParseInt parses v as a signed integer of the given size. Whole numbers
+// written with a fraction or exponent, such as 1.0, are accepted.
+func ParseInt(v any, bits int) (int64, error) {
+	s, err := NumberText(v)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := strconv.ParseInt(s, 10, bits); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil && f == math.Trunc(f) && f >= -math.Ldexp(1, bits-1) && f < math.Ldexp(1, bits-1) {
+		return int64(f), nil
+	}
+	return 0, fmt.Errorf("%s is not a %d-bit integer", s, bits)
+}
+
+// ParseUint parses v as an unsigned integer of the given size, as ParseInt
+// does.
+func ParseUint(v any, bits int) (uint64, error) {
+	s, err := NumberText(v)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := strconv.ParseUint(s, 10, bits); err == nil {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil && f == math.Trunc(f) && f >= 0 && f < math.Ldexp(1, bits) {
+		return uint64(f), nil
+	}
+	return 0, fmt.Errorf("%s is not an unsigned %d-bit integer", s, bits)
+}
+
+// ParseFloat parses v as a number, or as one of the strings Float gives for
+// the values JSON has no numbers for.
+func ParseFloat(v any) (float64, error) {
+	switch v {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	s, err := NumberText(v)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Describe names the JSON type of v for errors.
+func Describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		return strconv.Quote(v)
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,323 @@
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/JFryy/qq/codec/util"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+var declaredEncoding = regexp.MustCompile(`^\s*<\?xml[^>]*\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+type attribute struct {
+	name, value string
+}
+
+// element is a decoded element before it is turned into a map.
+type element struct {
+	name     string
+	attrs    []attribute
+	text     strings.Builder
+	cdata    strings.Builder
+	hasCDATA bool
+	children []*element
+}
+
+func lookupEncoding(label string) (encoding.Encoding, error) {
+	enc, _ := charset.Lookup(label)
+	if enc == nil {
+		return nil, fmt.Errorf("unknown encoding %q", label)
+	}
+	return enc, nil
+}
+
+// toUTF8 converts input to UTF-8 according to its XML declaration, so that
+// decoder offsets index the converted bytes.
+func toUTF8(input []byte) ([]byte, error) {
+	input = bytes.TrimPrefix(input, []byte("\xef\xbb\xbf"))
+	head := input[:min(len(input), 256)]
+	m := declaredEncoding.FindSubmatch(head)
+	if m == nil {
+		return input, nil
+	}
+	enc, err := lookupEncoding(string(m[1]))
+	if err != nil {
+		return nil, err
+	}
+	if _, name := charset.Lookup(string(m[1])); name == "utf-8" {
+		return input, nil
+	}
+	return enc.NewDecoder().Bytes(input)
+}
+
+// tokens reads raw tokens and tells CDATA sections apart from text, which
+// encoding/xml reports alike.
+type tokens struct {
+	d     *xml.Decoder
+	input []byte      // the whole input, when decoding from memory
+	mark  *markReader // the bytes of the current token, when streaming
+}
+
+func (t *tokens) next() (xml.Token, bool, error) {
+	offset := t.d.InputOffset()
+	if t.mark != nil {
+		t.mark.reset(offset)
+	}
+	tok, err := t.d.RawToken()
+	if _, ok := tok.(xml.CharData); !ok || err != nil {
+		return tok, false, err
+	}
+	prefix := []byte("<![CDATA[")
+	if t.mark != nil {
+		return tok, bytes.HasPrefix(t.mark.head, prefix), nil
+	}
+	return tok, bytes.HasPrefix(t.input[offset:], prefix), nil
+}
+
+// newDecoder returns a decoder for input that has been converted by toUTF8.
+func newDecoder(input []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(input))
+	d.CharsetReader = func(label string, r io.Reader) (io.Reader, error) {
+		return r, nil
+	}
+	return d
+}
+
+func (c *Codec) decode(input []byte) (map[string]any, error) {
+	input, err := toUTF8(input)
+	if err != nil {
+		return nil, err
+	}
+	t := &tokens{d: newDecoder(input), input: input}
+	for {
+		tok, _, err := t.next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root, err := c.readElement(t, start, nil)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{root.name: c.value(root, []string{root.name})}, nil
+		}
+	}
+}
+
+// readElement reads the content of start up to its end tag. scope maps the
+// namespace prefixes declared by the ancestors to their URIs.
+func (c *Codec) readElement(t *tokens, start xml.StartElement, scope map[string]string) (*element, error) {
+	scope = c.declare(start, scope)
+	e := &element{}
+	var err error
+	if e.name, err = c.name(start.Name, scope, true); err != nil {
+		return nil, err
+	}
+	for _, a := range start.Attr {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			if c.Namespaces == "expand" {
+				continue
+			}
+		}
+		name, err := c.name(a.Name, scope, false)
+		if err != nil {
+			return nil, err
+		}
+		e.attrs = append(e.attrs, attribute{name, a.Value})
+	}
+
+	for {
+		tok, isCDATA, err := t.next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("element <%s> is not closed", rawName(start.Name))
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			child, err := c.readElement(t, tok, scope)
+			if err != nil {
+				return nil, err
+			}
+			e.children = append(e.children, child)
+		case xml.EndElement:
+			if tok.Name != start.Name {
+				return nil, fmt.Errorf("element <%s> closed by </%s>", rawName(start.Name), rawName(tok.Name))
+			}
+			return e, nil
+		case xml.CharData:
+			if c.CDATA && isCDATA {
+				e.cdata.Write(tok)
+				e.hasCDATA = true
+			} else {
+				e.text.Write(tok)
+			}
+		}
+	}
+}
+
+// declare returns scope extended with the namespaces declared on start.
+func (c *Codec) declare(start xml.StartElement, scope map[string]string) map[string]string {
+	if c.Namespaces != "expand" {
+		return scope
+	}
+	var declared map[string]string
+	for _, a := range start.Attr {
+		prefix, ok := "", false
+		switch {
+		case a.Name.Space == "xmlns":
+			prefix, ok = a.Name.Local, true
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			ok = true
+		}
+		if !ok {
+			continue
+		}
+		if declared == nil {
+			declared = make(map[string]string, len(scope)+1)
+			for k, v := range scope {
+				declared[k] = v
+			}
+		}
+		declared[prefix] = a.Value
+	}
+	if declared == nil {
+		return scope
+	}
+	return declared
+}
+
+// name returns the key for an element or attribute name according to the
+// namespace mode. Unprefixed attributes are never in a namespace.
+func (c *Codec) name(n xml.Name, scope map[string]string, isElement bool) (string, error) {
+	switch c.Namespaces {
+	case "keep":
+		return rawName(n), nil
+	case "expand":
+		if n.Space == "" && !isElement {
+			return n.Local, nil
+		}
+		if n.Space == "xml" {
+			return "{" + xmlNamespace + "}" + n.Local, nil
+		}
+		uri, ok := scope[n.Space]
+		if !ok {
+			if n.Space == "" {
+				return n.Local, nil
+			}
+			return "", fmt.Errorf("undefined namespace prefix %q in <%s>", n.Space, rawName(n))
+		}
+		if uri == "" {
+			return n.Local, nil
+		}
+		return "{" + uri + "}" + n.Local, nil
+	}
+	return n.Local, nil
+}
+
+func rawName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// value converts a decoded element. Elements without attributes or
+// children become their text, typed with util.ParseValue.
+func (c *Codec) value(e *element, path []string) any {
+	text := strings.TrimSpace(e.text.String())
+	if len(e.attrs) == 0 && len(e.children) == 0 && !e.hasCDATA {
+		return util.ParseValue(text)
+	}
+
+	m := make(map[string]any, len(e.attrs)+len(e.children)+1)
+	for _, a := range e.attrs {
+		m[c.attrPrefix()+a.name] = util.ParseValue(a.value)
+	}
+	if text != "" {
+		m[c.textKey()] = util.ParseValue(text)
+	}
+	if e.hasCDATA {
+		m[cdataKey] = e.cdata.String()
+	}
+	for _, child := range e.children {
+		childPath := append(path[:len(path):len(path)], child.name)
+		v := c.value(child, childPath)
+		existing, ok := m[child.name]
+		switch {
+		case !ok && c.forced(childPath):
+			m[child.name] = []any{v}
+		case !ok:
+			m[child.name] = v
+		default:
+			// element values are never arrays, so an array holds earlier siblings
+			if arr, isArr := existing.([]any); isArr {
+				m[child.name] = append(arr, v)
+			} else {
+				m[child.name] = []any{existing, v}
+			}
+		}
+	}
+	return m
+}
+
+// forced reports whether the element at path matches a ForceArray path.
+func (c *Codec) forced(path []string) bool {
+	for _, p := range c.ForceArray {
+		segments := splitPath(p)
+		if len(segments) != len(path) {
+			continue
+		}
+		match := true
+		for i, s := range segments {
+			if s != "*" && s != path[i] && s != localName(path[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// localName strips the prefix or {uri} from a name.
+func localName(name string) string {
+	if i := strings.LastIndexAny(name, ":}"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// splitPath splits a dot separated path, ignoring dots inside {uri} names.
+func splitPath(p string) []string {
+	var segments []string
+	depth, start := 0, 0
+	for i, r := range p {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '.':
+			if depth == 0 {
+				segments = append(segments, p[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, p[start:])
+}
@@ -0,0 +1,223 @@
+package xml
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"golang.org/x/net/html/charset"
+)
+
+var elementName = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_.-]*(:[\p{L}_][\p{L}\p{N}_.-]*)?$`)
+
+func validName(s string) bool {
+	return elementName.MatchString(s)
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+)
+
+// encoder writes indented XML. ns numbers the prefixes generated for the
+// URIs of {uri}name keys.
+type encoder struct {
+	c   *Codec
+	buf bytes.Buffer
+	ns  int
+}
+
+func (c *Codec) Marshal(v any) ([]byte, error) {
+	e := &encoder{c: c}
+	encodingName := "UTF-8"
+	if c.Encoding != "" {
+		_, name := charset.Lookup(c.Encoding)
+		if name != "utf-8" {
+			encodingName = strings.ToUpper(c.Encoding)
+		}
+	}
+	if c.Declaration || encodingName != "UTF-8" {
+		fmt.Fprintf(&e.buf, "<?xml version=\"1.0\" encoding=\"%s\"?>\n", encodingName)
+	}
+
+	if name, value, ok := c.singleRoot(v); ok {
+		e.element(name, value, 0, nil)
+	} else {
+		e.element(c.root(v), v, 0, nil)
+	}
+	out := bytes.TrimSuffix(e.buf.Bytes(), []byte("\n"))
+
+	if encodingName != "UTF-8" {
+		enc, err := lookupEncoding(c.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		if out, err = enc.NewEncoder().Bytes(out); err != nil {
+			return nil, fmt.Errorf("cannot encode output as %s: %v", encodingName, err)
+		}
+	}
+	return out, nil
+}
+
+// singleRoot reports whether v is an object with a single element key that
+// can be written as the document element.
+func (c *Codec) singleRoot(v any) (string, any, bool) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", nil, false
+	}
+	for k, val := range m {
+		if _, isArr := val.([]any); isArr || c.isSpecial(k) {
+			return "", nil, false
+		}
+		return k, val, true
+	}
+	return "", nil, false
+}
+
+func (c *Codec) isSpecial(key string) bool {
+	return strings.HasPrefix(key, c.attrPrefix()) || key == c.textKey() || key == cdataKey
+}
+
+// element writes one element. An array value is written as Item children;
+// arrays under object keys are instead repeated by the caller.
+func (e *encoder) element(name string, v any, depth int, scope map[string]string) {
+	indent := strings.Repeat("  ", depth)
+	tag, scope, decls := e.qualify(name, scope, nil)
+
+	var attrs []attribute
+	var text, cdata *string
+	var children []string
+	m, isMap := v.(map[string]any)
+	if isMap {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			switch {
+			case k == e.c.textKey():
+				s := scalar(m[k])
+				text = &s
+			case k == cdataKey:
+				s := scalar(m[k])
+				cdata = &s
+			case strings.HasPrefix(k, e.c.attrPrefix()):
+				var attrName string
+				attrName, scope, decls = e.qualify(strings.TrimPrefix(k, e.c.attrPrefix()), scope, decls)
+				attrs = append(attrs, attribute{attrName, scalar(m[k])})
+			default:
+				children = append(children, k)
+			}
+		}
+	}
+
+	e.buf.WriteString(indent + "<" + tag)
+	for _, a := range append(decls, attrs...) {
+		e.buf.WriteString(" " + a.name + `="` + attrEscaper.Replace(a.value) + `"`)
+	}
+
+	var items []any
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteString("/>\n")
+		return
+	case map[string]any:
+	case []any:
+		items = v
+	default:
+		s := scalar(v)
+		text = &s
+	}
+
+	if len(children) == 0 && len(items) == 0 {
+		if (text == nil || *text == "") && cdata == nil {
+			e.buf.WriteString("/>\n")
+			return
+		}
+		e.buf.WriteString(">")
+		e.content(text, cdata)
+		e.buf.WriteString("</" + tag + ">\n")
+		return
+	}
+
+	e.buf.WriteString(">")
+	e.content(text, cdata)
+	e.buf.WriteString("\n")
+	for _, item := range items {
+		e.element(e.c.item(), item, depth+1, scope)
+	}
+	for _, k := range children {
+		if arr, ok := m[k].([]any); ok {
+			for _, item := range arr {
+				e.element(k, item, depth+1, scope)
+			}
+			continue
+		}
+		e.element(k, m[k], depth+1, scope)
+	}
+	e.buf.WriteString(indent + "</" + tag + ">\n")
+}
+
+func (e *encoder) content(text, cdata *string) {
+	if text != nil {
+		e.buf.WriteString(textEscaper.Replace(*text))
+	}
+	if cdata != nil {
+		// a CDATA section cannot contain "]]>", so split it across two
+		e.buf.WriteString("<![CDATA[" + strings.ReplaceAll(*cdata, "]]>", "]]]]><![CDATA[>") + "]]>")
+	}
+}
+
+// qualify turns a {uri}name key into prefix:name, declaring a new prefix
+// when the URI is not in scope yet. Other names are returned unchanged.
+func (e *encoder) qualify(name string, scope map[string]string, decls []attribute) (string, map[string]string, []attribute) {
+	if !strings.HasPrefix(name, "{") {
+		return name, scope, decls
+	}
+	end := strings.Index(name, "}")
+	if end < 0 {
+		return name, scope, decls
+	}
+	uri, local := name[1:end], name[end+1:]
+	if uri == xmlNamespace {
+		return "xml:" + local, scope, decls
+	}
+	if prefix, ok := scope[uri]; ok {
+		return prefix + ":" + local, scope, decls
+	}
+	prefix := "ns" + strconv.Itoa(e.ns)
+	e.ns++
+	extended := make(map[string]string, len(scope)+1)
+	for k, v := range scope {
+		extended[k] = v
+	}
+	extended[uri] = prefix
+	decls = append(decls, attribute{"xmlns:" + prefix, uri})
+	return prefix + ":" + local, extended, decls
+}
+
+// scalar formats a value as text; objects and arrays are written as JSON.
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]any, []any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
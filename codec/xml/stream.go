@@ -0,0 +1,438 @@
+package xml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/JFryy/qq/codec/util"
+)
+
+// markReader counts the bytes read by a decoder and keeps the first bytes
+// of the current token, so CDATA sections can be recognised without
+// holding the input.
+type markReader struct {
+	r    io.ByteReader
+	n    int64
+	from int64
+	head []byte
+	last []byte // recently read bytes, the decoder may read one ahead
+}
+
+const markSize = 9 // len("<![CDATA[")
+
+func newMarkReader(r io.Reader) *markReader {
+	return &markReader{r: bufio.NewReader(r)}
+}
+
+func (m *markReader) ReadByte() (byte, error) {
+	b, err := m.r.ReadByte()
+	if err != nil {
+		return b, err
+	}
+	if m.n >= m.from && len(m.head) < markSize {
+		m.head = append(m.head, b)
+	}
+	if len(m.last) == markSize {
+		m.last = append(m.last[:0], m.last[1:]...)
+	}
+	m.last = append(m.last, b)
+	m.n++
+	return b, nil
+}
+
+func (m *markReader) Read(p []byte) (int, error) {
+	for i := range p {
+		b, err := m.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		p[i] = b
+	}
+	return len(p), nil
+}
+
+// reset starts recording at offset, which may lie just behind the bytes
+// already read.
+func (m *markReader) reset(offset int64) {
+	m.from = offset
+	m.head = m.head[:0]
+	if behind := m.n - offset; behind > 0 && behind <= int64(len(m.last)) {
+		m.head = append(m.head, m.last[len(m.last)-int(behind):]...)
+	}
+}
+
+// streamTokens returns a token source reading r incrementally. Documents
+// declaring another encoding are converted to UTF-8 as they are read.
+func streamTokens(r io.Reader) *tokens {
+	mark := newMarkReader(r)
+	d := xml.NewDecoder(mark)
+	d.CharsetReader = func(label string, _ io.Reader) (io.Reader, error) {
+		enc, err := lookupEncoding(label)
+		if err != nil {
+			return nil, err
+		}
+		// keep counting bytes as the decoder sees them, after conversion
+		mark.r = bufio.NewReader(enc.NewDecoder().Reader(mark.r.(io.Reader)))
+		return mark, nil
+	}
+	return &tokens{d: d, mark: mark}
+}
+
+// StreamElements reads r incrementally and calls emit with the value of
+// every element whose name or local name is name, laid out as Unmarshal
+// lays out that element. Only the current element is held in memory.
+func (c *Codec) StreamElements(r io.Reader, name string, emit func(any)) error {
+	t := streamTokens(r)
+	scopes := []map[string]string{nil}
+	for {
+		tok, _, err := t.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			scope := scopes[len(scopes)-1]
+			inner := c.declare(tok, scope)
+			key, err := c.name(tok.Name, inner, true)
+			if err != nil {
+				return err
+			}
+			if key != name && localName(key) != name {
+				scopes = append(scopes, inner)
+				continue
+			}
+			e, err := c.readElement(t, tok, scope)
+			if err != nil {
+				return err
+			}
+			emit(c.value(e, []string{e.name}))
+		case xml.EndElement:
+			if len(scopes) == 1 {
+				return fmt.Errorf("unexpected end element </%s>", rawName(tok.Name))
+			}
+			scopes = scopes[:len(scopes)-1]
+		}
+	}
+}
+
+// event is a jq --stream event relative to the value of an element: a
+// [path, leaf] pair, or a closing [path] when leaf is false.
+type event struct {
+	path  []any
+	value any
+	leaf  bool
+}
+
+type sink func(event)
+
+func prefixed(s sink, keys ...any) sink {
+	return func(e event) {
+		e.path = append(append([]any{}, keys...), e.path...)
+		s(e)
+	}
+}
+
+// bits is a set of element ordinals, in document order.
+type bits []uint64
+
+func (b *bits) set(i int) {
+	for len(*b) <= i/64 {
+		*b = append(*b, 0)
+	}
+	(*b)[i/64] |= 1 << (i % 64)
+}
+
+func (b bits) has(i int) bool {
+	return i/64 < len(b) && b[i/64]&(1<<(i%64)) != 0
+}
+
+// siblings tracks the element children of an open element by name while
+// finding arrays: the ordinals of the first and last child of each name.
+type siblings struct {
+	scope map[string]string
+	names map[string][2]int
+	start xml.Name
+}
+
+// layout is what a first pass finds out about every element: whether it
+// is one of several siblings with the same name, and so an array item, and
+// whether it is the last of them.
+type layout struct {
+	items, last bits
+}
+
+// arrays reads a document through once and returns the layout of its
+// elements, holding only the names of the children of open elements.
+func (c *Codec) arrays(r io.Reader) (*layout, error) {
+	t := streamTokens(r)
+	l := &layout{}
+	var stack []*siblings
+	n := 0
+	for {
+		tok, _, err := t.next()
+		if err == io.EOF {
+			return l, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var scope map[string]string
+			if len(stack) > 0 {
+				scope = stack[len(stack)-1].scope
+			}
+			scope = c.declare(tok, scope)
+			name, err := c.name(tok.Name, scope, true)
+			if err != nil {
+				return nil, err
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				if parent.names == nil {
+					parent.names = make(map[string][2]int)
+				}
+				seen, ok := parent.names[name]
+				if !ok {
+					seen[0] = n
+				} else {
+					l.items.set(seen[0])
+					l.items.set(n)
+				}
+				seen[1] = n
+				parent.names[name] = seen
+			}
+			stack = append(stack, &siblings{scope: scope, start: tok.Name})
+			n++
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected end element </%s>", rawName(tok.Name))
+			}
+			f := stack[len(stack)-1]
+			if tok.Name != f.start {
+				return nil, fmt.Errorf("element <%s> closed by </%s>", rawName(f.start), rawName(tok.Name))
+			}
+			for _, seen := range f.names {
+				if l.items.has(seen[1]) {
+					l.last.set(seen[1])
+				}
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return l, nil
+			}
+		}
+	}
+}
+
+// rewindable returns r as a reader that can be read again from where it
+// is now, copying input that cannot seek, such as stdin, to a temporary
+// file. The returned function seeks back and done removes any copy.
+func rewindable(r io.Reader) (rs io.Reader, rewind func() error, done func(), err error) {
+	if s, ok := r.(io.ReadSeeker); ok {
+		if offset, err := s.Seek(0, io.SeekCurrent); err == nil {
+			rewind := func() error {
+				_, err := s.Seek(offset, io.SeekStart)
+				return err
+			}
+			return s, rewind, func() {}, nil
+		}
+	}
+	tmp, err := os.CreateTemp("", "qq-xml-*")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	done = func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		done()
+		return nil, nil, nil, err
+	}
+	rewind = func() error {
+		_, err := tmp.Seek(0, io.SeekStart)
+		return err
+	}
+	if err := rewind(); err != nil {
+		done()
+		return nil, nil, nil, err
+	}
+	return tmp, rewind, done, nil
+}
+
+// frame is an open element while streaming events.
+type frame struct {
+	start    xml.Name
+	path     []string
+	scope    map[string]string
+	out      sink
+	isMap    bool
+	lastKey  string
+	text     strings.Builder
+	cdata    strings.Builder
+	hasCDATA bool
+	counts   map[string]int
+	// endsArray is set on the last item of an array
+	endsArray bool
+}
+
+// StreamEvents reads r and calls emit with jq --stream style events for the
+// value Unmarshal would return, in document order. Whether an element is
+// one of several siblings with the same name, and so an array item, can
+// only be known from the rest of the document, so r is read twice: first to
+// find the arrays, keeping a bit per element, then to emit every event as
+// its element is read. Input that cannot seek is copied to a temporary
+// file first.
+func (c *Codec) StreamEvents(r io.Reader, emit func(any)) error {
+	r, rewind, done, err := rewindable(r)
+	if err != nil {
+		return err
+	}
+	defer done()
+	l, err := c.arrays(r)
+	if err != nil {
+		return err
+	}
+	if err := rewind(); err != nil {
+		return err
+	}
+
+	t := streamTokens(r)
+	out := func(e event) {
+		if e.leaf {
+			emit([]any{e.path, e.value})
+		} else {
+			emit([]any{e.path})
+		}
+	}
+
+	var stack []*frame
+	n := 0
+	for {
+		tok, isCDATA, err := t.next()
+		if err == io.EOF {
+			if len(stack) > 0 {
+				return fmt.Errorf("element <%s> is not closed", rawName(stack[len(stack)-1].start))
+			}
+			return fmt.Errorf("no root element")
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var parent *frame
+			var scope map[string]string
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+				scope = parent.scope
+			}
+			f := &frame{start: tok.Name, scope: c.declare(tok, scope)}
+			name, err := c.name(tok.Name, f.scope, true)
+			if err != nil {
+				return err
+			}
+			if parent == nil {
+				f.path = []string{name}
+				f.out = prefixed(out, name)
+			} else {
+				f.path = append(parent.path[:len(parent.path):len(parent.path)], name)
+				f.out = parent.child(name, l.items.has(n) || c.forced(f.path))
+				// an array ends with its last item, or its only one when forced
+				f.endsArray = l.last.has(n) || (c.forced(f.path) && !l.items.has(n))
+			}
+			n++
+			for _, a := range tok.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					if c.Namespaces == "expand" {
+						continue
+					}
+				}
+				attrName, err := c.name(a.Name, f.scope, false)
+				if err != nil {
+					return err
+				}
+				f.isMap = true
+				f.lastKey = c.attrPrefix() + attrName
+				f.out(event{path: []any{f.lastKey}, value: util.ParseValue(a.Value), leaf: true})
+			}
+			stack = append(stack, f)
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+			f := stack[len(stack)-1]
+			if c.CDATA && isCDATA {
+				f.cdata.Write(tok)
+				f.hasCDATA = true
+				f.isMap = true
+			} else if f.text.Len() > 0 || len(bytes.TrimSpace(tok)) > 0 {
+				// leading whitespace is trimmed anyway, so the indentation
+				// between children is not kept
+				f.text.Write(tok)
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return fmt.Errorf("unexpected end element </%s>", rawName(tok.Name))
+			}
+			f := stack[len(stack)-1]
+			if tok.Name != f.start {
+				return fmt.Errorf("element <%s> closed by </%s>", rawName(f.start), rawName(tok.Name))
+			}
+			stack = stack[:len(stack)-1]
+			c.endFrame(f)
+			if len(stack) == 0 {
+				out(event{path: []any{f.path[0]}})
+				return nil
+			}
+			if f.endsArray {
+				parent := stack[len(stack)-1]
+				name := f.path[len(f.path)-1]
+				parent.out(event{path: []any{name, parent.counts[name] - 1}})
+			}
+		}
+	}
+}
+
+// child returns where the events of a child called name go, under its
+// index when it is an array item.
+func (f *frame) child(name string, item bool) sink {
+	f.isMap = true
+	f.lastKey = name
+	if f.counts == nil {
+		f.counts = make(map[string]int)
+	}
+	f.counts[name]++
+	if item {
+		return prefixed(f.out, name, f.counts[name]-1)
+	}
+	return prefixed(f.out, name)
+}
+
+// endFrame writes the text of f and its closing event; its children have
+// been written as they were read.
+func (c *Codec) endFrame(f *frame) {
+	text := strings.TrimSpace(f.text.String())
+	if !f.isMap {
+		f.out(event{path: []any{}, value: util.ParseValue(text), leaf: true})
+		return
+	}
+	if text != "" {
+		f.lastKey = c.textKey()
+		f.out(event{path: []any{f.lastKey}, value: util.ParseValue(text), leaf: true})
+	}
+	if f.hasCDATA {
+		f.lastKey = cdataKey
+		f.out(event{path: []any{cdataKey}, value: f.cdata.String(), leaf: true})
+	}
+	f.out(event{path: []any{f.lastKey}})
+}
@@ -2,35 +2,145 @@ package xml
 
 import (
 	"fmt"
-	"github.com/JFryy/qq/codec/util"
-	"github.com/clbanning/mxj/v2"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
-type Codec struct{}
+// Codec converts between XML and maps. Elements become keys, attributes are
+// keys with AttrPrefix and text content is stored under TextKey. The zero
+// value uses the historical conventions: "-name" attributes, "#text" text,
+// namespace prefixes stripped and no XML declaration.
+type Codec struct {
+	// AttrPrefix marks attribute keys, "-" by default.
+	AttrPrefix string
+	// TextKey holds the text of elements that also have attributes or
+	// children, "#text" by default.
+	TextKey string
+	// Root names the element wrapping output that has no single root:
+	// arrays, scalars and objects with several keys. Defaults to "doc", or
+	// "value" for scalars.
+	Root string
+	// Item names the elements holding the items of an array that is not the
+	// value of a key. Defaults to "root".
+	Item string
+	// Declaration writes an <?xml?> declaration before the output.
+	Declaration bool
+	// Encoding is the character encoding of the output. Anything other than
+	// UTF-8 implies Declaration.
+	Encoding string
+	// Namespaces is "strip" (default) to drop prefixes, so that a namespace
+	// declaration xmlns:soap becomes the attribute soap, "keep" to leave
+	// them as written, or "expand" to replace them by the namespace URI as
+	// {uri}name, dropping the declarations.
+	Namespaces string
+	// ForceArray lists dot separated element paths, such as
+	// project.dependencies.dependency, that are always decoded as arrays.
+	// A "*" segment matches any element and a segment without a prefix also
+	// matches prefixed or expanded names with that local name.
+	ForceArray []string
+	// CDATA keeps CDATA sections apart from text under the "#cdata" key
+	// when decoding.
+	CDATA bool
+}
+
+const cdataKey = "#cdata"
 
-func (c *Codec) Marshal(v any) ([]byte, error) {
-	switch v := v.(type) {
-	case map[string]any:
-		mv := mxj.Map(v)
-		return mv.XmlIndent("", "  ")
-	case []any:
-		mv := mxj.Map(map[string]any{"root": v})
-		return mv.XmlIndent("", "  ")
+// SetOption implements codec.Configurable for the xml options.
+func (c *Codec) SetOption(key, value string) error {
+	switch key {
+	case "attr_prefix":
+		if value == "" {
+			return fmt.Errorf("attr_prefix must not be empty")
+		}
+		c.AttrPrefix = value
+	case "text_key":
+		if value == "" {
+			return fmt.Errorf("text_key must not be empty")
+		}
+		c.TextKey = value
+	case "root":
+		if !validName(value) {
+			return fmt.Errorf("root %q is not a valid element name", value)
+		}
+		c.Root = value
+	case "item":
+		if !validName(value) {
+			return fmt.Errorf("item %q is not a valid element name", value)
+		}
+		c.Item = value
+	case "declaration", "cdata":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		if key == "cdata" {
+			c.CDATA = b
+		} else {
+			c.Declaration = b
+		}
+	case "encoding":
+		if _, err := lookupEncoding(value); err != nil {
+			return err
+		}
+		c.Encoding = value
+	case "namespaces":
+		switch value {
+		case "keep", "expand", "strip":
+			c.Namespaces = value
+		default:
+			return fmt.Errorf("unknown namespaces mode %q, expected keep, expand or strip", value)
+		}
+	case "force_array":
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				c.ForceArray = append(c.ForceArray, p)
+			}
+		}
 	default:
-		mv := mxj.Map(map[string]any{"value": v})
-		return mv.XmlIndent("", "  ")
+		return fmt.Errorf("unknown option %q, expected attr_prefix, text_key, root, item, declaration, encoding, namespaces, force_array or cdata", key)
+	}
+	return nil
+}
+
+func (c *Codec) attrPrefix() string {
+	if c.AttrPrefix == "" {
+		return "-"
 	}
+	return c.AttrPrefix
+}
+
+func (c *Codec) textKey() string {
+	if c.TextKey == "" {
+		return "#text"
+	}
+	return c.TextKey
+}
+
+func (c *Codec) root(v any) string {
+	if c.Root == "" {
+		switch v.(type) {
+		case map[string]any, []any:
+			return "doc"
+		}
+		return "value"
+	}
+	return c.Root
+}
+
+func (c *Codec) item() string {
+	if c.Item == "" {
+		return "root"
+	}
+	return c.Item
 }
 
 func (c *Codec) Unmarshal(input []byte, v any) error {
-	mv, err := mxj.NewMapXml(input)
+	parsedData, err := c.decode(input)
 	if err != nil {
 		return fmt.Errorf("error unmarshaling XML: %v", err)
 	}
 
-	parsedData := c.parseXMLValues(mv.Old())
-
 	// reflection of values required for type assertions on interface
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Pointer || rv.IsNil() {
@@ -40,23 +150,3 @@ func (c *Codec) Unmarshal(input []byte, v any) error {
 
 	return nil
 }
-
-// infer the type of the value and parse it accordingly
-func (c *Codec) parseXMLValues(v any) any {
-	switch v := v.(type) {
-	case map[string]any:
-		for key, val := range v {
-			v[key] = c.parseXMLValues(val)
-		}
-		return v
-	case []any:
-		for i, val := range v {
-			v[i] = c.parseXMLValues(val)
-		}
-		return v
-	case string:
-		return util.ParseValue(v)
-	default:
-		return v
-	}
-}
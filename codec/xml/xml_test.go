@@ -1,6 +1,8 @@
 package xml
 
 import (
+	"io"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -317,3 +319,264 @@ func TestXMLValidFormat(t *testing.T) {
 		t.Errorf("Mismatched item1 tags: %d open, %d close", openCount, closeCount)
 	}
 }
+
+const pom = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<project xmlns="urn:pom" xmlns:xsi="urn:xsi" xsi:schemaLocation="urn:pom x.xsd">
+  <name>caf` + "\xe9" + `</name>
+  <deps><dep>junit</dep></deps>
+  <script>if <![CDATA[a < b]]></script>
+</project>`
+
+func TestXMLNamespaces(t *testing.T) {
+	var keep map[string]any
+	if err := (&Codec{Namespaces: "keep"}).Unmarshal([]byte(pom), &keep); err != nil {
+		t.Fatal(err)
+	}
+	project := keep["project"].(map[string]any)
+	if project["-xsi:schemaLocation"] != "urn:pom x.xsd" || project["-xmlns:xsi"] != "urn:xsi" {
+		t.Errorf("prefixes not kept: %v", project)
+	}
+	if project["name"] != "café" {
+		t.Errorf("latin-1 input not decoded: %q", project["name"])
+	}
+
+	out, err := (&Codec{}).Marshal(keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `<project xmlns="urn:pom" xmlns:xsi="urn:xsi" xsi:schemaLocation="urn:pom x.xsd">`) {
+		t.Errorf("namespaces not written back:\n%s", out)
+	}
+
+	var expanded map[string]any
+	if err := (&Codec{Namespaces: "expand"}).Unmarshal([]byte(pom), &expanded); err != nil {
+		t.Fatal(err)
+	}
+	project = expanded["{urn:pom}project"].(map[string]any)
+	if project["-{urn:xsi}schemaLocation"] != "urn:pom x.xsd" {
+		t.Errorf("attribute not expanded: %v", project)
+	}
+	if _, ok := project["-xmlns"]; ok {
+		t.Error("namespace declarations should be dropped when expanding")
+	}
+	out, err = (&Codec{}).Marshal(expanded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), `<ns0:project xmlns:ns0="urn:pom" xmlns:ns1="urn:xsi" ns1:schemaLocation="urn:pom x.xsd">`) {
+		t.Errorf("expanded names not written with prefixes:\n%s", out)
+	}
+
+	// prefixes are stripped by default, leaving declarations named by them
+	var stripped map[string]any
+	if err := (&Codec{}).Unmarshal([]byte(`<s:Envelope xmlns:s="urn:s"><s:Body><x>1</x></s:Body></s:Envelope>`), &stripped); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stripped, map[string]any{"Envelope": map[string]any{"-s": "urn:s", "Body": map[string]any{"x": 1}}}) {
+		t.Errorf("unexpected stripped result: %v", stripped)
+	}
+
+	if err := (&Codec{Namespaces: "expand"}).Unmarshal([]byte(`<a:b/>`), &stripped); err == nil {
+		t.Error("expected error for an undeclared prefix")
+	}
+}
+
+func TestXMLConventions(t *testing.T) {
+	c := &Codec{AttrPrefix: "@", TextKey: "_", CDATA: true, Namespaces: "expand", ForceArray: []string{"project.deps.dep"}}
+	var v map[string]any
+	if err := c.Unmarshal([]byte(pom), &v); err != nil {
+		t.Fatal(err)
+	}
+	project := v["{urn:pom}project"].(map[string]any)
+	if project["@{urn:xsi}schemaLocation"] == nil {
+		t.Errorf("attribute prefix not applied: %v", project)
+	}
+	deps := project["{urn:pom}deps"].(map[string]any)
+	if !reflect.DeepEqual(deps["{urn:pom}dep"], []any{"junit"}) {
+		t.Errorf("forced array not applied: %v", deps)
+	}
+	script := project["{urn:pom}script"].(map[string]any)
+	if script["_"] != "if" || script["#cdata"] != "a < b" {
+		t.Errorf("text and cdata not kept apart: %v", script)
+	}
+
+	out, err := c.Marshal(map[string]any{"s": map[string]any{"@id": 1, "_": "x & y", "#cdata": "]]>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `<s id="1">x &amp; y<![CDATA[]]]]><![CDATA[>]]></s>` {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestXMLRootAndDeclaration(t *testing.T) {
+	c := &Codec{Root: "list", Item: "n", Encoding: "ISO-8859-1"}
+	out, err := c.Marshal([]any{"é", map[string]any{"a": nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<list>\n  <n>\xe9</n>\n  <n>\n    <a/>\n  </n>\n</list>"
+	if string(out) != want {
+		t.Errorf("got:\n%q\nwant:\n%q", out, want)
+	}
+
+	out, err = (&Codec{Declaration: true}).Marshal("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<value>x</value>" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestXMLSetOption(t *testing.T) {
+	c := &Codec{}
+	for _, kv := range [][2]string{
+		{"attr_prefix", "@"}, {"text_key", "$"}, {"root", "items"}, {"item", "entry"}, {"declaration", "true"},
+		{"encoding", "latin1"}, {"namespaces", "strip"}, {"force_array", "a.b, a.c"}, {"cdata", "1"},
+	} {
+		if err := c.SetOption(kv[0], kv[1]); err != nil {
+			t.Errorf("%s=%s: %v", kv[0], kv[1], err)
+		}
+	}
+	if !reflect.DeepEqual(c.ForceArray, []string{"a.b", "a.c"}) || !c.CDATA || c.Root != "items" {
+		t.Errorf("options not applied: %+v", c)
+	}
+	for _, kv := range [][2]string{
+		{"attr_prefix", ""}, {"root", "1x"}, {"declaration", "maybe"}, {"encoding", "klingon"}, {"namespaces", "drop"}, {"indent", "2"},
+	} {
+		if err := c.SetOption(kv[0], kv[1]); err == nil {
+			t.Errorf("expected error for %s=%s", kv[0], kv[1])
+		}
+	}
+}
+
+// setPath rebuilds a value from [path, leaf] stream events.
+func setPath(root any, path []any, v any) any {
+	if len(path) == 0 {
+		return v
+	}
+	switch k := path[0].(type) {
+	case string:
+		m, _ := root.(map[string]any)
+		if m == nil {
+			m = map[string]any{}
+		}
+		m[k] = setPath(m[k], path[1:], v)
+		return m
+	default:
+		i := k.(int)
+		arr, _ := root.([]any)
+		for len(arr) <= i {
+			arr = append(arr, nil)
+		}
+		arr[i] = setPath(arr[i], path[1:], v)
+		return arr
+	}
+}
+
+func TestXMLStreamEvents(t *testing.T) {
+	docs := []struct {
+		input string
+		codec *Codec
+	}{
+		{`<feed><title>T</title><entry id="1"><n>x</n></entry><entry id="2"><n>y</n><n>z</n></entry></feed>`, &Codec{}},
+		{`<a><b>1</b><c>2</c><b>3</b></a>`, &Codec{}},
+		{`<a x="1">hello<b/></a>`, &Codec{AttrPrefix: "@", TextKey: "_"}},
+		{`<a><list><i>1</i></list></a>`, &Codec{ForceArray: []string{"a.list.i"}}},
+		{`<a>t<![CDATA[<x>]]></a>`, &Codec{CDATA: true}},
+		{pom, &Codec{}},
+		{pom, &Codec{Namespaces: "expand"}},
+		{`<v>42</v>`, &Codec{}},
+	}
+	for _, tt := range docs {
+		var want map[string]any
+		if err := tt.codec.Unmarshal([]byte(tt.input), &want); err != nil {
+			t.Fatal(err)
+		}
+
+		var events []any
+		if err := tt.codec.StreamEvents(strings.NewReader(tt.input), func(v any) { events = append(events, v) }); err != nil {
+			t.Fatalf("%s: %v", tt.input, err)
+		}
+		var got any
+		for _, ev := range events {
+			if pair := ev.([]any); len(pair) == 2 {
+				got = setPath(got, pair[0].([]any), pair[1])
+			}
+		}
+		if !reflect.DeepEqual(got, any(want)) {
+			t.Errorf("%s:\n got %v\nwant %v", tt.input, got, want)
+		}
+		last := events[len(events)-1].([]any)
+		if len(last) != 1 || len(last[0].([]any)) != 1 {
+			t.Errorf("%s: expected a closing event for the root, got %v", tt.input, last)
+		}
+	}
+
+	if err := (&Codec{}).StreamEvents(strings.NewReader(`<a><b></a>`), func(any) {}); err == nil {
+		t.Error("expected error for mismatched tags")
+	}
+}
+
+func TestXMLStreamEventOrder(t *testing.T) {
+	// meta and records occur once, so nothing shows they are not arrays
+	// until the document ends; their events must still come as they are read
+	input := `<root><meta><v>m</v></meta><records><record>1</record><record>2</record></records>` +
+		`<item>a</item><item>b</item><tail>x</tail></root>`
+	want := []any{
+		[]any{[]any{"root", "meta", "v"}, "m"},
+		[]any{[]any{"root", "meta", "v"}},
+		[]any{[]any{"root", "records", "record", 0}, 1},
+		[]any{[]any{"root", "records", "record", 1}, 2},
+		[]any{[]any{"root", "records", "record", 1}},
+		[]any{[]any{"root", "records", "record"}},
+		[]any{[]any{"root", "item", 0}, "a"},
+		[]any{[]any{"root", "item", 1}, "b"},
+		[]any{[]any{"root", "item", 1}},
+		[]any{[]any{"root", "tail"}, "x"},
+		[]any{[]any{"root", "tail"}},
+		[]any{[]any{"root"}},
+	}
+	var got []any
+	if err := (&Codec{}).StreamEvents(strings.NewReader(input), func(v any) { got = append(got, v) }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events\n%v\nwant\n%v", got, want)
+	}
+
+	// input that cannot seek is read from a copy
+	got = nil
+	if err := (&Codec{}).StreamEvents(io.MultiReader(strings.NewReader(input)), func(v any) { got = append(got, v) }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events from a pipe\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestXMLStreamElements(t *testing.T) {
+	var got []any
+	err := (&Codec{}).StreamElements(strings.NewReader(pom), "dep", func(v any) { got = append(got, v) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []any{"junit"}) {
+		t.Errorf("got %v", got)
+	}
+
+	input := `<log><rec n="1"><m>a</m></rec><other><rec n="2"/></other><rec n="3"><rec>nested</rec></rec></log>`
+	got = nil
+	if err := (&Codec{}).StreamElements(strings.NewReader(input), "rec", func(v any) { got = append(got, v) }); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		map[string]any{"-n": 1, "m": "a"},
+		map[string]any{"-n": 2},
+		map[string]any{"-n": 3, "rec": "nested"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
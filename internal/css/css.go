@@ -0,0 +1,120 @@
+// Package css runs CSS selectors against parsed HTML and describes each match
+// as an element object with its tag, attributes, text and inner HTML.
+package css
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Compile parses a selector group such as `table.results tr > td:nth-child(2)`.
+func Compile(selector string) (cascadia.SelectorGroup, error) {
+	sel, err := cascadia.ParseGroup(selector)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing CSS selector: %v", err)
+	}
+	return sel, nil
+}
+
+// Select parses an HTML document and returns the elements matching selector
+// in document order.
+func Select(input []byte, selector string) ([]any, error) {
+	sel, err := Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(bytes.NewReader(input))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTML: %v", err)
+	}
+	return matches(sel, doc), nil
+}
+
+// SelectFragment matches selector against an HTML fragment. When context
+// names an element, the fragment is parsed as that element's content, so
+// `<td>` cells of a row survive parsing.
+func SelectFragment(fragment string, context string, sel cascadia.SelectorGroup) ([]any, error) {
+	if context == "" {
+		doc, err := html.Parse(strings.NewReader(fragment))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing HTML: %v", err)
+		}
+		return matches(sel, doc), nil
+	}
+	parent := &html.Node{Type: html.ElementNode, Data: context, DataAtom: atom.Lookup([]byte(context))}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), parent)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTML: %v", err)
+	}
+	// attach the nodes to the context element so selectors can match them
+	for _, n := range nodes {
+		parent.AppendChild(n)
+	}
+	return matches(sel, parent), nil
+}
+
+func matches(sel cascadia.SelectorGroup, root *html.Node) []any {
+	out := []any{}
+	for _, n := range cascadia.QueryAll(root, sel) {
+		out = append(out, element(n))
+	}
+	return out
+}
+
+// element describes a node as {tag, attrs, text, html}. text is the node's
+// rendered text: scripts and styles are skipped, block elements separate words
+// and whitespace is collapsed. html is its inner HTML.
+func element(n *html.Node) map[string]any {
+	attrs := make(map[string]any, len(n.Attr))
+	for _, a := range n.Attr {
+		key := a.Key
+		if a.Namespace != "" {
+			key = a.Namespace + ":" + a.Key
+		}
+		attrs[key] = a.Val
+	}
+
+	var text strings.Builder
+	var inner bytes.Buffer
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		textContent(c, &text)
+		_ = html.Render(&inner, c)
+	}
+
+	return map[string]any{
+		"tag":   n.Data,
+		"attrs": attrs,
+		"text":  strings.Join(strings.Fields(text.String()), " "),
+		"html":  inner.String(),
+	}
+}
+
+func textContent(n *html.Node, b *strings.Builder) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+	case html.ElementNode:
+		if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			textContent(c, b)
+		}
+		// keep words of adjacent cells, items and blocks apart
+		if !inline[n.DataAtom] {
+			b.WriteByte(' ')
+		}
+	}
+}
+
+var inline = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Bdi: true, atom.Bdo: true, atom.Cite: true,
+	atom.Code: true, atom.Data: true, atom.Dfn: true, atom.Em: true, atom.I: true, atom.Kbd: true,
+	atom.Mark: true, atom.Q: true, atom.S: true, atom.Samp: true, atom.Small: true, atom.Span: true,
+	atom.Strong: true, atom.Sub: true, atom.Sup: true, atom.Time: true, atom.U: true, atom.Var: true,
+}
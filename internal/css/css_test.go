@@ -0,0 +1,78 @@
+package css
+
+import (
+	"reflect"
+	"testing"
+)
+
+const page = `<html><body>
+<table class="results">
+  <tr><th>Name</th><th>Score</th></tr>
+  <tr><td>Ann</td><td data-x="1">9</td></tr>
+  <tr><td>Bob <b>B.</b></td><td>7</td></tr>
+</table>
+<script>var x = 1;</script>
+</body></html>`
+
+func TestSelect(t *testing.T) {
+	got, err := Select([]byte(page), "table.results tr > td:nth-child(2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		map[string]any{"tag": "td", "attrs": map[string]any{"data-x": "1"}, "text": "9", "html": "9"},
+		map[string]any{"tag": "td", "attrs": map[string]any{}, "text": "7", "html": "7"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v\nwant %#v", got, want)
+	}
+
+	got, err = Select([]byte(page), "td:first-child")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(got))
+	}
+	bob := got[1].(map[string]any)
+	if bob["text"] != "Bob B." || bob["html"] != "Bob <b>B.</b>" {
+		t.Errorf("unexpected element %v", bob)
+	}
+
+	got, err = Select([]byte(page), "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := got[0].(map[string]any)["text"]; text != "Name Score Ann 9 Bob B. 7" {
+		t.Errorf("script content should be excluded from text, got %q", text)
+	}
+}
+
+func TestSelectFragment(t *testing.T) {
+	sel, err := Compile("td")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// row content only parses as cells in the context of a <tr>
+	got, err := SelectFragment("<td>a</td><td>b</td>", "tr", sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].(map[string]any)["text"] != "b" {
+		t.Errorf("unexpected matches %v", got)
+	}
+
+	got, err = SelectFragment("<td>a</td><td>b</td>", "", sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("cells outside a table should not parse as cells, got %v", got)
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	if _, err := Compile("td:nth-child("); err == nil {
+		t.Error("expected error for invalid selector")
+	}
+}
@@ -0,0 +1,312 @@
+// Package flatten converts nested values to flat key/value pairs and back,
+// for formats that only hold flat keys: env, properties, ini and csv.
+package flatten
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JFryy/qq/codec/util"
+	"github.com/goccy/go-json"
+)
+
+// Options describe how paths are written as keys. The zero value writes
+// a.b[0].c.
+type Options struct {
+	// Separator joins object keys, "." by default.
+	Separator string
+	// Index is "bracket" (default) to write array indices as [0], or
+	// "separator" to write them as a key, as in a__b__0__c.
+	Index string
+	// OnRead rebuilds nested values from flat keys when reading.
+	OnRead bool
+}
+
+// IsOption reports whether key is one of the options handled by SetOption.
+func IsOption(key string) bool {
+	return key == "separator" || key == "index" || key == "unflatten"
+}
+
+// SetOption sets the separator, index or unflatten option.
+func (o *Options) SetOption(key, value string) error {
+	switch key {
+	case "separator":
+		if value == "" {
+			return fmt.Errorf("separator must not be empty")
+		}
+		o.Separator = value
+	case "index":
+		if value != "bracket" && value != "separator" {
+			return fmt.Errorf("unknown index style %q, expected bracket or separator", value)
+		}
+		o.Index = value
+	case "unflatten":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("unflatten must be true or false")
+		}
+		o.OnRead = b
+	default:
+		return fmt.Errorf("unknown option %q, expected separator, index or unflatten", key)
+	}
+	return nil
+}
+
+// Sep returns the separator, "." unless set.
+func (o Options) Sep() string {
+	if o.Separator == "" {
+		return "."
+	}
+	return o.Separator
+}
+
+func (o Options) bracket() bool {
+	return o.Index != "separator"
+}
+
+// Entry is a flattened key and its scalar value. Empty objects and arrays
+// are kept as values so that they survive a round trip.
+type Entry struct {
+	Key   string
+	Value any
+}
+
+// Flatten returns the leaves of v in document order: object keys sorted,
+// array items by index. A scalar v is a single entry with an empty key. Two
+// leaves that flatten to the same key, as in {"a.b": 1, "a": {"b": 2}}, are
+// an error naming the key.
+func (o Options) Flatten(v any) ([]Entry, error) {
+	var entries []Entry
+	seen := make(map[string]bool)
+	sep := o.Sep()
+	var walk func(key string, v any)
+	walk = func(key string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if len(v) == 0 {
+				entries = append(entries, Entry{key, v})
+				return
+			}
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if key != "" {
+					walk(key+sep+k, v[k])
+				} else {
+					walk(k, v[k])
+				}
+			}
+		case []any:
+			if len(v) == 0 {
+				entries = append(entries, Entry{key, v})
+				return
+			}
+			for i, item := range v {
+				switch {
+				case o.bracket():
+					walk(key+"["+strconv.Itoa(i)+"]", item)
+				case key != "":
+					walk(key+sep+strconv.Itoa(i), item)
+				default:
+					walk(strconv.Itoa(i), item)
+				}
+			}
+		default:
+			entries = append(entries, Entry{key, v})
+		}
+	}
+	walk("", v)
+	for _, e := range entries {
+		if seen[e.Key] {
+			return nil, fmt.Errorf("more than one value flattens to the key %q", e.Key)
+		}
+		seen[e.Key] = true
+	}
+	return entries, nil
+}
+
+// Map returns the entries of Flatten as a map.
+func (o Options) Map(v any) (map[string]any, error) {
+	entries, err := o.Flatten(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, len(entries))
+	for _, e := range entries {
+		m[e.Key] = e.Value
+	}
+	return m, nil
+}
+
+// Unflatten rebuilds the nested value described by flat keys, the reverse
+// of Flatten. With the separator index style every all-digit segment is an
+// array index. The strings "{}" and "[]" become empty objects and arrays.
+func (o Options) Unflatten(m map[string]any) (any, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var root any
+	for _, k := range keys {
+		v := m[k]
+		switch v {
+		case "{}":
+			v = map[string]any{}
+		case "[]":
+			v = []any{}
+		}
+		var err error
+		if root, err = set(root, o.path(k), v); err != nil {
+			return nil, fmt.Errorf("key %q: %v", k, err)
+		}
+	}
+	if root == nil {
+		return map[string]any{}, nil
+	}
+	return root, nil
+}
+
+// path splits a flat key into object keys and array indices.
+func (o Options) path(key string) []any {
+	var path []any
+	for _, part := range strings.Split(key, o.Sep()) {
+		if !o.bracket() {
+			if n, ok := index(part); ok {
+				path = append(path, n)
+			} else {
+				path = append(path, part)
+			}
+			continue
+		}
+		// peel trailing [n] groups; anything else is part of the key
+		var indices []any
+		for strings.HasSuffix(part, "]") {
+			open := strings.LastIndexByte(part, '[')
+			if open < 0 {
+				break
+			}
+			n, ok := index(part[open+1 : len(part)-1])
+			if !ok {
+				break
+			}
+			indices = append([]any{n}, indices...)
+			part = part[:open]
+		}
+		if part != "" || len(indices) == 0 {
+			path = append(path, part)
+		}
+		path = append(path, indices...)
+	}
+	return path
+}
+
+func index(s string) (int, bool) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// set stores v at path below node, creating objects and arrays on the way.
+func set(node any, path []any, v any) (any, error) {
+	if len(path) == 0 {
+		if node != nil {
+			return nil, fmt.Errorf("conflicts with a nested key")
+		}
+		return v, nil
+	}
+	switch seg := path[0].(type) {
+	case int:
+		if node == nil {
+			node = []any{}
+		}
+		a, ok := node.([]any)
+		if !ok {
+			return nil, fmt.Errorf("index %d of a value that is not an array", seg)
+		}
+		for len(a) <= seg {
+			a = append(a, nil)
+		}
+		child, err := set(a[seg], path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		a[seg] = child
+		return a, nil
+	default:
+		if node == nil {
+			node = map[string]any{}
+		}
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("key %q of a value that is not an object", seg)
+		}
+		child, err := set(obj[seg.(string)], path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		obj[seg.(string)] = child
+		return obj, nil
+	}
+}
+
+// Infer types a flat string value read back: numbers and booleans are
+// parsed when String writes them back as s, so 1.0, 007 and True stay
+// strings, and anything else, dates included, stays a string.
+func Infer(s string) any {
+	switch v := util.ParseValue(s).(type) {
+	case string, time.Time:
+		return s
+	default:
+		if String(v) != s {
+			return s
+		}
+		return v
+	}
+}
+
+// Retyped reports whether v is a string that Infer reads back as another
+// type, such as "true" or "1", so that flat formats quote or escape it.
+func Retyped(v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	_, ok = Infer(s).(string)
+	return !ok
+}
+
+// String formats a scalar value for a flat format: null is empty, numbers
+// are written without exponents and objects and arrays as JSON.
+func String(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]any, []any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,113 @@
+package flatten
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var nested = map[string]any{
+	"spring": map[string]any{
+		"datasource": map[string]any{"url": "jdbc:x", "hosts": []any{"a", map[string]any{"b": 1.5}}},
+	},
+	"empty": []any{},
+	"none":  map[string]any{},
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want []Entry
+	}{
+		{Options{}, []Entry{
+			{"empty", []any{}},
+			{"none", map[string]any{}},
+			{"spring.datasource.hosts[0]", "a"},
+			{"spring.datasource.hosts[1].b", 1.5},
+			{"spring.datasource.url", "jdbc:x"},
+		}},
+		{Options{Separator: "__", Index: "separator"}, []Entry{
+			{"empty", []any{}},
+			{"none", map[string]any{}},
+			{"spring__datasource__hosts__0", "a"},
+			{"spring__datasource__hosts__1__b", 1.5},
+			{"spring__datasource__url", "jdbc:x"},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := tt.opts.Flatten(nested)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, %v", tt.opts, got, err)
+		}
+		m, err := tt.opts.Map(nested)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.opts, err)
+		}
+		back, err := tt.opts.Unflatten(m)
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.opts, err)
+		}
+		if !reflect.DeepEqual(back, nested) {
+			t.Errorf("%+v: round trip got %v", tt.opts, back)
+		}
+	}
+
+	top, err := Options{}.Flatten([]any{map[string]any{"a": 1}})
+	if err != nil || !reflect.DeepEqual(top, []Entry{{"[0].a", 1}}) {
+		t.Errorf("top-level array: got %v, %v", top, err)
+	}
+
+	_, err = Options{}.Map(map[string]any{"a.b": 1, "a": map[string]any{"b": 2}})
+	if err == nil || !strings.Contains(err.Error(), `"a.b"`) {
+		t.Errorf("colliding keys: got error %v", err)
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	got, err := Options{}.Unflatten(map[string]any{
+		"m[1][0]":   "y",
+		"m[0]":      "x",
+		"a[b]":      "literal",
+		"s":         "{}",
+		"list[2].k": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"m":    []any{"x", []any{"y"}},
+		"a[b]": "literal",
+		"s":    map[string]any{},
+		"list": []any{nil, nil, map[string]any{"k": true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v", got)
+	}
+
+	for _, m := range []map[string]any{
+		{"a": 1, "a.b": 2},
+		{"a[0]": 1, "a.b": 2},
+		{"a.b": 1, "a[0]": 2},
+	} {
+		if _, err := (Options{}).Unflatten(m); err == nil {
+			t.Errorf("expected a conflict error for %v", m)
+		}
+	}
+}
+
+func TestInferAndString(t *testing.T) {
+	for s, want := range map[string]any{"8080": 8080, "1.5": 1.5, "true": true, "2024-01-02": "2024-01-02", " x ": " x ",
+		"1.0": "1.0", "007": "007", "True": "True", "1e3": "1e3", "12345678901234567890": "12345678901234567890"} {
+		if got := Infer(s); got != want {
+			t.Errorf("Infer(%q) = %#v, want %#v", s, got, want)
+		}
+	}
+	for v, want := range map[any]string{nil: "", 1e6: "1000000", true: "true", "s": "s"} {
+		if got := String(v); got != want {
+			t.Errorf("String(%#v) = %q, want %q", v, got, want)
+		}
+	}
+	if got := String([]any{1.0}); got != "[1]" {
+		t.Errorf("String([1]) = %q", got)
+	}
+}
@@ -0,0 +1,467 @@
+package jsonpath
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"github.com/goccy/go-json"
+)
+
+// nothing is the absence of a value, e.g. the result of a singular query that
+// selects no node (RFC 9535 section 2.4.1).
+type nothingType struct{}
+
+var nothing any = nothingType{}
+
+type evalContext struct {
+	root any
+}
+
+// Select evaluates the query against doc and returns the selected nodes in
+// order. Object members are visited in sorted key order.
+func (q *Query) Select(doc any) []any {
+	return selectSegments(q.segments, []any{doc}, &evalContext{root: doc})
+}
+
+func selectSegments(segments []*segment, nodes []any, ctx *evalContext) []any {
+	for _, seg := range segments {
+		var out []any
+		for _, node := range nodes {
+			if seg.descendant {
+				for _, d := range descendants(node, nil) {
+					out = seg.apply(d, ctx, out)
+				}
+			} else {
+				out = seg.apply(node, ctx, out)
+			}
+		}
+		nodes = out
+	}
+	return nodes
+}
+
+func (s *segment) apply(node any, ctx *evalContext, out []any) []any {
+	for _, sel := range s.selectors {
+		out = sel.selectFrom(node, ctx, out)
+	}
+	return out
+}
+
+// descendants returns node followed by all its descendants in document order.
+func descendants(node any, out []any) []any {
+	out = append(out, node)
+	switch v := node.(type) {
+	case []any:
+		for _, item := range v {
+			out = descendants(item, out)
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			out = descendants(v[k], out)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s nameSelector) selectFrom(node any, _ *evalContext, out []any) []any {
+	if m, ok := node.(map[string]any); ok {
+		if v, ok := m[s.name]; ok {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (wildcardSelector) selectFrom(node any, _ *evalContext, out []any) []any {
+	switch v := node.(type) {
+	case []any:
+		out = append(out, v...)
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			out = append(out, v[k])
+		}
+	}
+	return out
+}
+
+func (s indexSelector) selectFrom(node any, _ *evalContext, out []any) []any {
+	arr, ok := node.([]any)
+	if !ok {
+		return out
+	}
+	i := s.index
+	if i < 0 {
+		i += len(arr)
+	}
+	if i >= 0 && i < len(arr) {
+		out = append(out, arr[i])
+	}
+	return out
+}
+
+// selectFrom follows the slice semantics of RFC 9535 section 2.3.4.2.2.
+func (s sliceSelector) selectFrom(node any, _ *evalContext, out []any) []any {
+	arr, ok := node.([]any)
+	if !ok || s.step == 0 {
+		return out
+	}
+	n := len(arr)
+	normalize := func(i int) int {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int) int {
+		return min(max(i, lo), hi)
+	}
+
+	if s.step > 0 {
+		start, end := 0, n
+		if s.start != nil {
+			start = clamp(normalize(*s.start), 0, n)
+		}
+		if s.end != nil {
+			end = clamp(normalize(*s.end), 0, n)
+		}
+		for i := start; i < end; i += s.step {
+			out = append(out, arr[i])
+		}
+		return out
+	}
+
+	start, end := n-1, -1
+	if s.start != nil {
+		start = clamp(normalize(*s.start), -1, n-1)
+	}
+	if s.end != nil {
+		end = clamp(normalize(*s.end), -1, n-1)
+	}
+	for i := start; i > end; i += s.step {
+		out = append(out, arr[i])
+	}
+	return out
+}
+
+func (s filterSelector) selectFrom(node any, ctx *evalContext, out []any) []any {
+	switch v := node.(type) {
+	case []any:
+		for _, item := range v {
+			if test(s.expr, item, ctx) {
+				out = append(out, item)
+			}
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			if test(s.expr, v[k], ctx) {
+				out = append(out, v[k])
+			}
+		}
+	}
+	return out
+}
+
+// expr is a node of a filter expression.
+type expr interface{}
+
+type literal struct{ value any }
+
+// queryExpr is a filter query relative to the current node (@) or the root ($).
+type queryExpr struct {
+	relative bool
+	segments []*segment
+}
+
+type funcExpr struct {
+	name string
+	fn   *function
+	args []expr
+}
+
+type orExpr struct{ left, right expr }
+
+type andExpr struct{ left, right expr }
+
+type notExpr struct{ x expr }
+
+type parenExpr struct{ x expr }
+
+type comparison struct {
+	op          string
+	left, right expr
+}
+
+// singular reports whether a query selects at most one node: it only uses
+// name and index selectors in child segments.
+func (q *queryExpr) singular() bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		switch seg.selectors[0].(type) {
+		case nameSelector, indexSelector:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (q *queryExpr) nodes(cur any, ctx *evalContext) []any {
+	start := ctx.root
+	if q.relative {
+		start = cur
+	}
+	return selectSegments(q.segments, []any{start}, ctx)
+}
+
+// Type checking follows RFC 9535 section 2.4.3.
+
+func checkLogical(e expr) error {
+	switch x := e.(type) {
+	case *literal:
+		return fmt.Errorf("literal %v cannot be used as a test expression", formatLiteral(x.value))
+	case *funcExpr:
+		if x.fn.result == valueType {
+			return fmt.Errorf("%s() returns a value and must be compared", x.name)
+		}
+	}
+	return nil
+}
+
+func checkComparable(e expr) error {
+	switch x := e.(type) {
+	case *literal:
+		return nil
+	case *queryExpr:
+		if !x.singular() {
+			return fmt.Errorf("non-singular query cannot be compared")
+		}
+		return nil
+	case *funcExpr:
+		if x.fn.result != valueType {
+			return fmt.Errorf("%s() does not return a value and cannot be compared", x.name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("logical expression cannot be compared")
+	}
+}
+
+func checkArgument(e expr, want argType) error {
+	switch want {
+	case valueType:
+		return checkComparable(e)
+	case logicalType:
+		return checkLogical(e)
+	case nodesType:
+		switch x := e.(type) {
+		case *queryExpr:
+			return nil
+		case *funcExpr:
+			if x.fn.result == nodesType {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected a query")
+	}
+	return nil
+}
+
+func formatLiteral(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// test evaluates a logical expression against the current node.
+func test(e expr, cur any, ctx *evalContext) bool {
+	switch x := e.(type) {
+	case *orExpr:
+		return test(x.left, cur, ctx) || test(x.right, cur, ctx)
+	case *andExpr:
+		return test(x.left, cur, ctx) && test(x.right, cur, ctx)
+	case *notExpr:
+		return !test(x.x, cur, ctx)
+	case *parenExpr:
+		return test(x.x, cur, ctx)
+	case *comparison:
+		return compare(x.op, value(x.left, cur, ctx), value(x.right, cur, ctx))
+	case *queryExpr:
+		return len(x.nodes(cur, ctx)) > 0
+	case *funcExpr:
+		r := x.call(cur, ctx)
+		if nodes, ok := r.([]any); ok {
+			return len(nodes) > 0
+		}
+		return r == true
+	}
+	return false
+}
+
+// value evaluates a comparable: a literal, a singular query or a function
+// returning a value. The result may be nothing.
+func value(e expr, cur any, ctx *evalContext) any {
+	switch x := e.(type) {
+	case *literal:
+		return x.value
+	case *queryExpr:
+		nodes := x.nodes(cur, ctx)
+		if len(nodes) == 1 {
+			return nodes[0]
+		}
+		return nothing
+	case *funcExpr:
+		return x.call(cur, ctx)
+	}
+	return nothing
+}
+
+func (f *funcExpr) call(cur any, ctx *evalContext) any {
+	args := make([]any, len(f.args))
+	for i, arg := range f.args {
+		switch f.fn.params[i] {
+		case valueType:
+			args[i] = value(arg, cur, ctx)
+		case logicalType:
+			args[i] = test(arg, cur, ctx)
+		case nodesType:
+			if q, ok := arg.(*queryExpr); ok {
+				args[i] = q.nodes(cur, ctx)
+			} else {
+				args[i] = arg.(*funcExpr).call(cur, ctx)
+			}
+		}
+	}
+	return f.fn.call(args)
+}
+
+// compare implements the comparison rules of RFC 9535 section 2.3.5.2.2.
+func compare(op string, a, b any) bool {
+	switch op {
+	case "==":
+		return equal(a, b)
+	case "!=":
+		return !equal(a, b)
+	case "<":
+		return less(a, b)
+	case "<=":
+		return less(a, b) || equal(a, b)
+	case ">":
+		return less(b, a)
+	case ">=":
+		return less(b, a) || equal(a, b)
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	if a == nothing || b == nothing {
+		return a == b
+	}
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x.Cmp(y) == 0
+	}
+	switch x := a.(type) {
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func less(a, b any) bool {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x.Cmp(y) < 0
+	}
+	if x, ok := a.(string); ok {
+		y, ok := b.(string)
+		return ok && x < y
+	}
+	return false
+}
+
+// toNumber converts any numeric value to an exact big.Float so integers and
+// floats of all widths compare correctly.
+func toNumber(v any) (*big.Float, bool) {
+	switch n := v.(type) {
+	case int:
+		return new(big.Float).SetInt64(int64(n)), true
+	case int8:
+		return new(big.Float).SetInt64(int64(n)), true
+	case int16:
+		return new(big.Float).SetInt64(int64(n)), true
+	case int32:
+		return new(big.Float).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Float).SetInt64(n), true
+	case uint:
+		return new(big.Float).SetUint64(uint64(n)), true
+	case uint8:
+		return new(big.Float).SetUint64(uint64(n)), true
+	case uint16:
+		return new(big.Float).SetUint64(uint64(n)), true
+	case uint32:
+		return new(big.Float).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Float).SetUint64(n), true
+	case float32:
+		return new(big.Float).SetFloat64(float64(n)), true
+	case float64:
+		if n != n {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(n), true
+	case *big.Int:
+		return new(big.Float).SetInt(n), true
+	case json.Number:
+		// integers exactly, other numbers as the float64 a literal would be
+		if i, err := n.Int64(); err == nil {
+			return new(big.Float).SetInt64(i), true
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(f), true
+	}
+	return nil, false
+}
@@ -0,0 +1,128 @@
+package jsonpath
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// argType is a function parameter or result type (RFC 9535 section 2.4.1).
+type argType int
+
+const (
+	valueType argType = iota
+	logicalType
+	nodesType
+)
+
+type function struct {
+	params []argType
+	result argType
+	call   func(args []any) any
+}
+
+// functions are the function extensions defined by RFC 9535 section 2.4.
+var functions = map[string]*function{
+	"length": {params: []argType{valueType}, result: valueType, call: fnLength},
+	"count":  {params: []argType{nodesType}, result: valueType, call: fnCount},
+	"match":  {params: []argType{valueType, valueType}, result: logicalType, call: fnMatch},
+	"search": {params: []argType{valueType, valueType}, result: logicalType, call: fnSearch},
+	"value":  {params: []argType{nodesType}, result: valueType, call: fnValue},
+}
+
+func fnLength(args []any) any {
+	switch v := args[0].(type) {
+	case string:
+		return utf8.RuneCountInString(v)
+	case []any:
+		return len(v)
+	case map[string]any:
+		return len(v)
+	}
+	return nothing
+}
+
+func fnCount(args []any) any {
+	return len(args[0].([]any))
+}
+
+func fnValue(args []any) any {
+	nodes := args[0].([]any)
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return nothing
+}
+
+func fnMatch(args []any) any {
+	return regexpTest(args, true)
+}
+
+func fnSearch(args []any) any {
+	return regexpTest(args, false)
+}
+
+// regexpTest reports whether the string argument matches the I-Regexp
+// pattern, either entirely or anywhere. Invalid patterns never match.
+func regexpTest(args []any, full bool) bool {
+	s, ok := args[0].(string)
+	if !ok {
+		return false
+	}
+	pattern, ok := args[1].(string)
+	if !ok {
+		return false
+	}
+	re := compileIRegexp(pattern, full)
+	return re != nil && re.MatchString(s)
+}
+
+var regexpCache sync.Map
+
+func compileIRegexp(pattern string, full bool) *regexp.Regexp {
+	key := pattern
+	if full {
+		key = "^" + pattern
+	}
+	if re, ok := regexpCache.Load(key); ok {
+		return re.(*regexp.Regexp)
+	}
+	expr := translateIRegexp(pattern)
+	if full {
+		expr = `\A(?:` + expr + `)\z`
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		re = nil
+	}
+	regexpCache.Store(key, re)
+	return re
+}
+
+// translateIRegexp rewrites an I-Regexp (RFC 9485) into Go syntax. The only
+// difference that matters is '.', which in I-Regexp excludes both \n and \r.
+func translateIRegexp(pattern string) string {
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteByte(c)
+			i++
+			b.WriteByte(pattern[i])
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+		case c == ']':
+			inClass = false
+			b.WriteByte(c)
+		case c == '.' && !inClass:
+			b.WriteString(`[^\n\r]`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
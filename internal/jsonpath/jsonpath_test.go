@@ -0,0 +1,132 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// store is the example document of RFC 9535 section 1.5.
+const store = `{ "store": {
+    "book": [
+      { "category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95 },
+      { "category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99 },
+      { "category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99 },
+      { "category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99 }
+    ],
+    "bicycle": { "color": "red", "price": 399 }
+  }
+}`
+
+func TestSelect(t *testing.T) {
+	doc := decode(t, store)
+	arr := decode(t, `["a", "b", "c", "d", "e", "f", "g"]`)
+	// decimals as the parquet and avro codecs read them
+	prices := []any{
+		map[string]any{"id": "a", "price": json.Number("19.90")},
+		map[string]any{"id": "b", "price": json.Number("120.00")},
+	}
+	tests := []struct {
+		doc   any
+		query string
+		want  string
+	}{
+		{doc, `$.store.book[*].author`, `["Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"]`},
+		{doc, `$..author`, `["Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"]`},
+		{doc, `$.store..price`, `[399, 8.95, 12.99, 8.99, 22.99]`},
+		{doc, `$..book[2].title`, `["Moby Dick"]`},
+		{doc, `$..book[-1].title`, `["The Lord of the Rings"]`},
+		{doc, `$..book[0,1].title`, `["Sayings of the Century", "Sword of Honour"]`},
+		{doc, `$..book[:2].title`, `["Sayings of the Century", "Sword of Honour"]`},
+		{doc, `$..book[?@.isbn].title`, `["Moby Dick", "The Lord of the Rings"]`},
+		{doc, `$..book[?@.price<10].title`, `["Sayings of the Century", "Moby Dick"]`},
+		{doc, `$.store.book[?@.price < $.store.bicycle.price && @.category == 'fiction'].price`, `[12.99, 8.99, 22.99]`},
+		{doc, `$["store"]['bicycle']["color"]`, `["red"]`},
+		{doc, `$.store.book[?!@.isbn].author`, `["Nigel Rees", "Evelyn Waugh"]`},
+		{doc, `$.store.book[?length(@.title) >= 15].title`, `["Sayings of the Century", "Sword of Honour", "The Lord of the Rings"]`},
+		{doc, `$.store.book[?match(@.author, '.*Rees')].title`, `["Sayings of the Century"]`},
+		{doc, `$.store.book[?search(@.author, 'R\\.')].author`, `["J. R. R. Tolkien"]`},
+		{doc, `$.store[?count(@.*) == 2].color`, `["red"]`},
+		{doc, `$.store.book[?value(@..isbn) == '0-553-21311-3'].title`, `["Moby Dick"]`},
+		{doc, `$.store.book[?(@.price > 20 || @.price < 9) && !(@.category == 'reference')].title`, `["Moby Dick", "The Lord of the Rings"]`},
+		{doc, `$.missing`, `[]`},
+		{arr, `$[1:3]`, `["b", "c"]`},
+		{arr, `$[5:]`, `["f", "g"]`},
+		{arr, `$[1:5:2]`, `["b", "d"]`},
+		{arr, `$[5:1:-2]`, `["f", "d"]`},
+		{arr, `$[::-1]`, `["g", "f", "e", "d", "c", "b", "a"]`},
+		{arr, `$[1:3:0]`, `[]`},
+		{arr, `$[-20:2]`, `["a", "b"]`},
+		{decode(t, `{"o": {"j": 1, "k": 2}, "a": [5, 3]}`), `$.o[?@ > 1]`, `[2]`},
+		{decode(t, `{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`), `$.a[?@.b == 'kilo']`, `[{"b": "kilo"}]`},
+		{decode(t, `{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`), `$.a[?@ > 3.5]`, `[5, 4, 6]`},
+		{decode(t, `{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`), `$.a[?@.b]`, `[{"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]`},
+		{decode(t, `[{"a": null}, {"b": 1}, {"a": {"x": 1}}]`), `$[?@.a == null]`, `[{"a": null}]`},
+		{decode(t, `[{"a": {"x": 1}}, {"a": {"x": 2}}]`), `$[?@.a == {"x": 1}]`, ``},
+		{decode(t, `[{"a": [1, 2]}, {"a": [1]}]`), `$[?@.a == $[0].a]`, `[{"a": [1, 2]}]`},
+		{decode(t, `{"a": "été"}`), `$[?length(@) == 3]`, `["été"]`},
+		{decode(t, `{"a b": 1, "c'd": 2}`), `$['a b', "c'd", 'c\'d']`, `[1, 2, 2]`},
+		{decode(t, `{"items": [{"status": "Ready", "metadata": {"name": "n1"}}, {"status": "Pending", "metadata": {"name": "n2"}}]}`), `$.items[?@.status=="Ready"].metadata.name`, `["n1"]`},
+		{prices, `$[?@.price > 100].id`, `["b"]`},
+		{prices, `$[?@.price == 19.9].id`, `["a"]`},
+	}
+	for _, tt := range tests {
+		q, err := Compile(tt.query)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Compile(%s) should fail", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Compile(%s) error: %v", tt.query, err)
+			continue
+		}
+		got := q.Select(tt.doc)
+		if got == nil {
+			got = []any{}
+		}
+		want := decode(t, tt.want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s\n got %v\nwant %v", tt.query, got, want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, query := range []string{
+		``,
+		`store`,
+		`$.`,
+		`$[`,
+		`$[01]`,
+		`$[-0]`,
+		`$[9007199254740992]`,
+		`$['a`,
+		`$["\q"]`,
+		`$[?@.a == @.*]`,
+		`$[?@..a == 1]`,
+		`$[?length(@.*) == 1]`,
+		`$[?length(@.a)]`,
+		`$[?count(1) == 1]`,
+		`$[?match(@.a)]`,
+		`$[?match(@.a, 'x') == true]`,
+		`$[?1]`,
+		`$[?!@.a == 1]`,
+		`$[?foo(@)]`,
+		`$.a b`,
+	} {
+		if _, err := Compile(query); err == nil {
+			t.Errorf("Compile(%q) should fail", query)
+		}
+	}
+}
@@ -0,0 +1,634 @@
+// Package jsonpath implements JSONPath as specified by RFC 9535, evaluated over
+// the same value model gojq uses.
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxIndex is the largest integer accepted for indexes and slice bounds
+// (the I-JSON exact integer range).
+const maxIndex = 1<<53 - 1
+
+// Query is a compiled JSONPath query.
+type Query struct {
+	src      string
+	segments []*segment
+}
+
+// segment is a child (`[...]`, `.name`, `.*`) or descendant (`..`) segment.
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+type selector interface {
+	selectFrom(node any, ctx *evalContext, out []any) []any
+}
+
+type nameSelector struct{ name string }
+
+type wildcardSelector struct{}
+
+type indexSelector struct{ index int }
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+type filterSelector struct{ expr expr }
+
+// Compile parses a JSONPath query.
+func Compile(src string) (*Query, error) {
+	p := &parser{src: src}
+	if !p.consume("$") {
+		return nil, p.errorf("query must start with '$'")
+	}
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return &Query{src: src, segments: segments}, nil
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("jsonpath: %s at position %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *parser) consume(s string) bool {
+	if p.hasPrefix(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.consume(s) {
+		if p.pos >= len(p.src) {
+			return p.errorf("expected %q, got end of input", s)
+		}
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (p *parser) skipBlank() {
+	for p.pos < len(p.src) && isBlank(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// parseSegments reads segments until no further segment starts. Blank space
+// is allowed before each segment but is not consumed when none follows.
+func (p *parser) parseSegments() ([]*segment, error) {
+	var segments []*segment
+	for {
+		save := p.pos
+		p.skipBlank()
+		c := p.peek()
+		if c != '.' && c != '[' {
+			p.pos = save
+			return segments, nil
+		}
+		seg, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+}
+
+func (p *parser) parseSegment() (*segment, error) {
+	seg := &segment{}
+	if p.consume("..") {
+		seg.descendant = true
+		switch {
+		case p.peek() == '[':
+		case p.consume("*"):
+			seg.selectors = []selector{wildcardSelector{}}
+			return seg, nil
+		default:
+			name, err := p.parseMemberName()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = []selector{nameSelector{name}}
+			return seg, nil
+		}
+	} else if p.consume(".") {
+		if p.consume("*") {
+			seg.selectors = []selector{wildcardSelector{}}
+			return seg, nil
+		}
+		name, err := p.parseMemberName()
+		if err != nil {
+			return nil, err
+		}
+		seg.selectors = []selector{nameSelector{name}}
+		return seg, nil
+	}
+
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	for {
+		p.skipBlank()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		seg.selectors = append(seg.selectors, sel)
+		p.skipBlank()
+		if p.consume(",") {
+			continue
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return seg, nil
+	}
+}
+
+func isNameFirst(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseMemberName reads the name of a `.name` shorthand.
+func (p *parser) parseMemberName() (string, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !isNameFirst(r) && (p.pos == start || !(r >= '0' && r <= '9')) {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return "", p.errorf("expected member name")
+	}
+	return p.src[start:p.pos], nil
+}
+
+func (p *parser) parseSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector{s}, nil
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+	case c == '?':
+		p.pos++
+		p.skipBlank()
+		e, err := p.parseLogicalOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkLogical(e); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return filterSelector{e}, nil
+	case c == ':' || c == '-' || isDigit(c):
+		return p.parseIndexOrSlice()
+	case c == 0:
+		return nil, p.errorf("expected selector, got end of input")
+	default:
+		return nil, p.errorf("unexpected %q in selector", c)
+	}
+}
+
+func (p *parser) parseIndexOrSlice() (selector, error) {
+	var bounds [3]*int
+	i := 0
+	for {
+		p.skipBlank()
+		if c := p.peek(); c == '-' || isDigit(c) {
+			n, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			bounds[i] = &n
+			p.skipBlank()
+		}
+		if i < 2 && p.consume(":") {
+			i++
+			continue
+		}
+		break
+	}
+	if i == 0 {
+		if bounds[0] == nil {
+			return nil, p.errorf("expected index")
+		}
+		return indexSelector{*bounds[0]}, nil
+	}
+	sel := sliceSelector{start: bounds[0], end: bounds[1], step: 1}
+	if bounds[2] != nil {
+		sel.step = *bounds[2]
+	}
+	return sel, nil
+}
+
+// parseInt reads an integer without leading zeros; "-0" is not allowed.
+func (p *parser) parseInt() (int, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	switch {
+	case p.pos == digits:
+		return 0, p.errorf("expected digits")
+	case p.src[digits] == '0' && p.pos-digits > 1:
+		return 0, p.errorf("leading zeros are not allowed in %q", text)
+	case text == "-0":
+		return 0, p.errorf("-0 is not a valid index")
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || n > maxIndex || n < -maxIndex {
+		return 0, p.errorf("integer %s is out of range", text)
+	}
+	return int(n), nil
+}
+
+// parseString reads a single- or double-quoted string literal.
+func (p *parser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\':
+			p.pos++
+			r, err := p.parseEscape(quote)
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		default:
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+		}
+	}
+}
+
+func (p *parser) parseEscape(quote byte) (rune, error) {
+	if p.pos >= len(p.src) {
+		return 0, p.errorf("unterminated escape")
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case '/', '\\':
+		return rune(c), nil
+	case 'u':
+		r, err := p.parseHex4()
+		if err != nil {
+			return 0, err
+		}
+		if utf16.IsSurrogate(r) {
+			if r >= 0xDC00 || !p.consume(`\u`) {
+				return 0, p.errorf("invalid surrogate pair")
+			}
+			low, err := p.parseHex4()
+			if err != nil {
+				return 0, err
+			}
+			r = utf16.DecodeRune(r, low)
+			if r == utf8.RuneError {
+				return 0, p.errorf("invalid surrogate pair")
+			}
+		}
+		return r, nil
+	}
+	if c == quote {
+		return rune(c), nil
+	}
+	return 0, p.errorf("invalid escape \\%c", c)
+}
+
+func (p *parser) parseHex4() (rune, error) {
+	if p.pos+4 > len(p.src) {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	p.pos += 4
+	return rune(n), nil
+}
+
+// Filter expressions.
+
+func (p *parser) parseLogicalOr() (expr, error) {
+	left, err := p.parseLogicalAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipBlank()
+		if !p.consume("||") {
+			return left, nil
+		}
+		p.skipBlank()
+		right, err := p.parseLogicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkLogical(left); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if err := checkLogical(right); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		left = &orExpr{left, right}
+	}
+}
+
+func (p *parser) parseLogicalAnd() (expr, error) {
+	left, err := p.parseBasic()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipBlank()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		p.skipBlank()
+		right, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkLogical(left); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if err := checkLogical(right); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		left = &andExpr{left, right}
+	}
+}
+
+var comparisonOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseBasic reads a parenthesized expression, a negation, a comparison or a
+// bare operand used as a test expression.
+func (p *parser) parseBasic() (expr, error) {
+	if p.consume("!") {
+		p.skipBlank()
+		x, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := x.(*comparison); ok {
+			return nil, p.errorf("'!' cannot be applied to a comparison without parentheses")
+		}
+		if err := checkLogical(x); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		return &notExpr{x}, nil
+	}
+	if p.consume("(") {
+		p.skipBlank()
+		x, err := p.parseLogicalOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkLogical(x); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.skipBlank()
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &parenExpr{x}, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	save := p.pos
+	p.skipBlank()
+	for _, op := range comparisonOps {
+		if p.consume(op) {
+			p.skipBlank()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := checkComparable(left); err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			if err := checkComparable(right); err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			return &comparison{op: op, left: left, right: right}, nil
+		}
+	}
+	p.pos = save
+	return left, nil
+}
+
+// parseOperand reads a literal, a filter query or a function call.
+func (p *parser) parseOperand() (expr, error) {
+	c := p.peek()
+	switch {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.parseSegments()
+		if err != nil {
+			return nil, err
+		}
+		return &queryExpr{relative: c == '@', segments: segments}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return &literal{s}, nil
+	case c == '-' || isDigit(c):
+		return p.parseNumber()
+	case c >= 'a' && c <= 'z':
+		start := p.pos
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if !(c >= 'a' && c <= 'z') && !isDigit(c) && c != '_' {
+				break
+			}
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if p.peek() == '(' {
+			return p.parseFunction(name)
+		}
+		switch name {
+		case "true":
+			return &literal{true}, nil
+		case "false":
+			return &literal{false}, nil
+		case "null":
+			return &literal{nil}, nil
+		}
+		p.pos = start
+		return nil, p.errorf("unexpected %q", name)
+	case c == 0:
+		return nil, p.errorf("unexpected end of input")
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+func (p *parser) parseNumber() (expr, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == digits {
+		return nil, p.errorf("expected digits")
+	}
+	if p.src[digits] == '0' && p.pos-digits > 1 {
+		return nil, p.errorf("leading zeros are not allowed")
+	}
+	isFloat := false
+	if p.peek() == '.' {
+		p.pos++
+		fracStart := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == fracStart {
+			return nil, p.errorf("expected digits after '.'")
+		}
+		isFloat = true
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		expStart := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == expStart {
+			return nil, p.errorf("expected exponent digits")
+		}
+		isFloat = true
+	}
+	text := p.src[start:p.pos]
+	if !isFloat {
+		if n, err := strconv.Atoi(text); err == nil {
+			return &literal{n}, nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %s", text)
+	}
+	return &literal{f}, nil
+}
+
+func (p *parser) parseFunction(name string) (expr, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return nil, p.errorf("unknown function %s()", name)
+	}
+	p.pos++ // (
+	var args []expr
+	p.skipBlank()
+	if !p.consume(")") {
+		for {
+			p.skipBlank()
+			arg, err := p.parseLogicalOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			p.skipBlank()
+			if p.consume(",") {
+				continue
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if len(args) != len(fn.params) {
+		return nil, p.errorf("%s() takes %d argument(s), got %d", name, len(fn.params), len(args))
+	}
+	for i, arg := range args {
+		if err := checkArgument(arg, fn.params[i]); err != nil {
+			return nil, p.errorf("%s() argument %d: %v", name, i+1, err)
+		}
+	}
+	return &funcExpr{name: name, fn: fn, args: args}, nil
+}
@@ -0,0 +1,431 @@
+// Package profile summarises the fields of a dataset: observed types, null and
+// missing counts, distinct-count estimates, numeric ranges and string
+// statistics. All per-field state is bounded, so records can be fed one at a
+// time from a stream.
+package profile
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Profiler accumulates statistics over a sequence of records.
+type Profiler struct {
+	top     int
+	records int64
+	fields  map[string]*fieldStats
+}
+
+// New returns a Profiler that keeps the topN most frequent string values per field.
+func New(topN int) *Profiler {
+	if topN < 0 {
+		topN = 0
+	}
+	return &Profiler{top: topN, fields: make(map[string]*fieldStats)}
+}
+
+type fieldStats struct {
+	path     string
+	count    int64 // observations, including nulls
+	nulls    int64
+	objects  int64 // number of objects observed at this path (parents of child fields)
+	types    map[string]int64
+	distinct *distinctCounter
+
+	numCount int64
+	numMin   float64
+	numMax   float64
+	numMean  float64
+
+	lenCount int64
+	lenMin   int64
+	lenMax   int64
+	lenMean  float64
+
+	values *topK
+}
+
+// Records returns the number of records added so far.
+func (p *Profiler) Records() int64 {
+	return p.records
+}
+
+// Add profiles one record. Arrays nested inside the record are flattened into
+// "[]" paths so every element contributes to the same field.
+func (p *Profiler) Add(record any) {
+	p.records++
+	p.observe(".", record)
+}
+
+// AddAll profiles a decoded document. A top-level array is treated as a list of
+// records; anything else is a single record.
+func (p *Profiler) AddAll(doc any) {
+	if arr, ok := doc.([]any); ok {
+		for _, item := range arr {
+			p.Add(item)
+		}
+		return
+	}
+	p.Add(doc)
+}
+
+func (p *Profiler) field(path string) *fieldStats {
+	f, ok := p.fields[path]
+	if !ok {
+		f = &fieldStats{
+			path:     path,
+			types:    make(map[string]int64),
+			distinct: newDistinctCounter(),
+			values:   newTopK(max(p.top*10, 50)),
+		}
+		p.fields[path] = f
+	}
+	return f
+}
+
+func (p *Profiler) observe(path string, v any) {
+	f := p.field(path)
+	f.count++
+	typ := typeName(v)
+	f.types[typ]++
+
+	switch val := v.(type) {
+	case nil:
+		f.nulls++
+	case map[string]any:
+		f.objects++
+		for k, child := range val {
+			p.observe(childPath(path, k), child)
+		}
+	case []any:
+		f.addLength(int64(len(val)))
+		for _, child := range val {
+			p.observe(elemPath(path), child)
+		}
+	case string:
+		f.distinct.add("s:" + val)
+		f.addLength(int64(len([]rune(val))))
+		if p.top > 0 {
+			f.values.add(val)
+		}
+	case time.Time:
+		s := val.Format(time.RFC3339)
+		f.distinct.add("s:" + s)
+		f.addLength(int64(len(s)))
+		if p.top > 0 {
+			f.values.add(s)
+		}
+	default:
+		if n, ok := toFloat(v); ok {
+			f.distinct.add("n:" + strconv.FormatFloat(n, 'g', -1, 64))
+			f.addNumber(n)
+		} else {
+			f.distinct.add(typ + ":" + fmt.Sprint(v))
+		}
+	}
+}
+
+func (f *fieldStats) addNumber(n float64) {
+	if f.numCount == 0 || n < f.numMin {
+		f.numMin = n
+	}
+	if f.numCount == 0 || n > f.numMax {
+		f.numMax = n
+	}
+	f.numCount++
+	f.numMean += (n - f.numMean) / float64(f.numCount)
+}
+
+func (f *fieldStats) addLength(n int64) {
+	if f.lenCount == 0 || n < f.lenMin {
+		f.lenMin = n
+	}
+	if f.lenCount == 0 || n > f.lenMax {
+		f.lenMax = n
+	}
+	f.lenCount++
+	f.lenMean += (float64(n) - f.lenMean) / float64(f.lenCount)
+}
+
+// Stats is a min/max/mean summary.
+type Stats struct {
+	Min  float64
+	Max  float64
+	Mean float64
+}
+
+// Field is the profile of a single path.
+type Field struct {
+	Path          string
+	Types         map[string]int64
+	Count         int64
+	Nulls         int64
+	Missing       int64
+	Distinct      int64
+	DistinctExact bool
+	Number        *Stats
+	Length        *Stats
+	Top           []ValueCount
+}
+
+// Report returns the profile of every observed path, sorted by path.
+func (p *Profiler) Report() []Field {
+	paths := make([]string, 0, len(p.fields))
+	for path := range p.fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	report := make([]Field, 0, len(paths))
+	for _, path := range paths {
+		f := p.fields[path]
+		distinct, exact := f.distinct.estimate()
+		out := Field{
+			Path:          path,
+			Types:         f.types,
+			Count:         f.count,
+			Nulls:         f.nulls,
+			Missing:       p.missing(f),
+			Distinct:      distinct,
+			DistinctExact: exact,
+		}
+		if f.numCount > 0 {
+			out.Number = &Stats{Min: f.numMin, Max: f.numMax, Mean: f.numMean}
+		}
+		if f.lenCount > 0 {
+			out.Length = &Stats{Min: float64(f.lenMin), Max: float64(f.lenMax), Mean: f.lenMean}
+		}
+		if p.top > 0 {
+			out.Top = f.values.top(p.top)
+		}
+		report = append(report, out)
+	}
+	return report
+}
+
+// missing is the number of parent objects that lacked this field. Elements of
+// arrays ("[]" paths) and the root are never missing.
+func (p *Profiler) missing(f *fieldStats) int64 {
+	if f.path == "." || strings.HasSuffix(f.path, "[]") {
+		return 0
+	}
+	parent, ok := p.fields[parentPath(f.path)]
+	if !ok {
+		return 0
+	}
+	return max(parent.objects-(f.count), 0)
+}
+
+// ToValue converts a report into plain maps and slices so it can be encoded by
+// any output codec.
+func ToValue(report []Field) []any {
+	out := make([]any, 0, len(report))
+	for _, f := range report {
+		types := make(map[string]any, len(f.Types))
+		for k, v := range f.Types {
+			types[k] = v
+		}
+		m := map[string]any{
+			"path":     f.Path,
+			"types":    types,
+			"count":    f.Count,
+			"nulls":    f.Nulls,
+			"missing":  f.Missing,
+			"distinct": f.Distinct,
+		}
+		if !f.DistinctExact {
+			m["distinct_estimated"] = true
+		}
+		if f.Number != nil {
+			m["number"] = map[string]any{"min": f.Number.Min, "max": f.Number.Max, "mean": f.Number.Mean}
+		}
+		if f.Length != nil {
+			m["length"] = map[string]any{"min": f.Length.Min, "max": f.Length.Max, "mean": f.Length.Mean}
+		}
+		if len(f.Top) > 0 {
+			top := make([]any, 0, len(f.Top))
+			for _, vc := range f.Top {
+				top = append(top, map[string]any{"value": vc.Value, "count": vc.Count})
+			}
+			m["top"] = top
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// typeName returns the jq type name of a decoded value.
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string, time.Time:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		if _, ok := toFloat(v); ok {
+			return "number"
+		}
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return math.NaN(), false
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// childPath appends an object key using jq path syntax.
+func childPath(parent, key string) string {
+	if identifier.MatchString(key) {
+		if parent == "." {
+			return "." + key
+		}
+		return parent + "." + key
+	}
+	return parent + "[" + strconv.Quote(key) + "]"
+}
+
+func elemPath(parent string) string {
+	if parent == "." {
+		return ".[]"
+	}
+	return parent + "[]"
+}
+
+// parentPath strips the last segment from a path built by childPath.
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "\"]") {
+		// quoted key: find the opening `["` that is not escaped
+		for i := len(path) - 3; i >= 1; i-- {
+			if path[i-1] == '[' && path[i] == '"' {
+				if _, err := strconv.Unquote(path[i : len(path)-1]); err == nil {
+					return orRoot(path[:i-1])
+				}
+			}
+		}
+	}
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		return orRoot(path[:i])
+	}
+	return "."
+}
+
+func orRoot(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+// AddStream profiles [path, leaf] events as produced by codec.StreamParser.
+// Events are regrouped into records by their first path element (the row or
+// document index), so only one record is held in memory at a time. Streams
+// whose root is an object are assembled into a single record.
+func (p *Profiler) AddStream(events <-chan any) {
+	var current any
+	var currentIndex int
+	started := false
+
+	for ev := range events {
+		pair, ok := ev.([]any)
+		if !ok || len(pair) < 2 {
+			continue // closing markers carry no value
+		}
+		path, _ := pair[0].([]any)
+		leaf := pair[1]
+
+		if len(path) == 0 {
+			p.Add(leaf)
+			continue
+		}
+		index, isIndex := path[0].(int)
+		if !isIndex {
+			current = setPath(current, path, leaf)
+			started = true
+			currentIndex = -1
+			continue
+		}
+		if started && index != currentIndex {
+			p.Add(current)
+			current = nil
+		}
+		started = true
+		currentIndex = index
+		current = setPath(current, path[1:], leaf)
+	}
+	if started {
+		p.Add(current)
+	}
+}
+
+// setPath assigns v at path inside root, creating objects and arrays as needed.
+func setPath(root any, path []any, v any) any {
+	if len(path) == 0 {
+		return v
+	}
+	switch key := path[0].(type) {
+	case string:
+		m, ok := root.(map[string]any)
+		if !ok {
+			m = make(map[string]any)
+		}
+		m[key] = setPath(m[key], path[1:], v)
+		return m
+	case int:
+		arr, _ := root.([]any)
+		for len(arr) <= key {
+			arr = append(arr, nil)
+		}
+		arr[key] = setPath(arr[key], path[1:], v)
+		return arr
+	default:
+		return root
+	}
+}
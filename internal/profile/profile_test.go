@@ -0,0 +1,142 @@
+package profile
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func records() []any {
+	return []any{
+		map[string]any{"id": 1.0, "name": "alice", "tags": []any{"a", "b"}},
+		map[string]any{"id": 2.0, "name": "bob", "tags": []any{"a"}, "email": nil},
+		map[string]any{"id": 3.5, "name": "alice"},
+	}
+}
+
+func fieldByPath(report []Field, path string) *Field {
+	for i := range report {
+		if report[i].Path == path {
+			return &report[i]
+		}
+	}
+	return nil
+}
+
+func TestProfileRecords(t *testing.T) {
+	p := New(3)
+	p.AddAll(records())
+	report := p.Report()
+
+	if p.Records() != 3 {
+		t.Errorf("expected 3 records, got %d", p.Records())
+	}
+
+	id := fieldByPath(report, ".id")
+	if id == nil || id.Number == nil {
+		t.Fatalf("expected numeric stats for .id, got %+v", id)
+	}
+	if id.Number.Min != 1 || id.Number.Max != 3.5 || id.Number.Mean != 6.5/3 {
+		t.Errorf("unexpected numeric stats: %+v", id.Number)
+	}
+
+	name := fieldByPath(report, ".name")
+	if name.Distinct != 2 || !name.DistinctExact {
+		t.Errorf("expected 2 exact distinct names, got %d (exact=%v)", name.Distinct, name.DistinctExact)
+	}
+	if len(name.Top) == 0 || name.Top[0].Value != "alice" || name.Top[0].Count != 2 {
+		t.Errorf("expected alice to be the top value, got %+v", name.Top)
+	}
+	if name.Length.Min != 3 || name.Length.Max != 5 {
+		t.Errorf("unexpected length stats: %+v", name.Length)
+	}
+
+	email := fieldByPath(report, ".email")
+	if email.Nulls != 1 || email.Missing != 2 {
+		t.Errorf("expected 1 null and 2 missing emails, got nulls=%d missing=%d", email.Nulls, email.Missing)
+	}
+
+	tags := fieldByPath(report, ".tags[]")
+	if tags == nil || tags.Count != 3 || tags.Missing != 0 {
+		t.Errorf("expected 3 tag elements, got %+v", tags)
+	}
+	if fieldByPath(report, ".tags").Missing != 1 {
+		t.Error("expected .tags to be missing from one record")
+	}
+}
+
+func TestProfileStreamMatchesDocument(t *testing.T) {
+	events := make(chan any, 32)
+	go func() {
+		defer close(events)
+		events <- []any{[]any{0, "id"}, 1.0}
+		events <- []any{[]any{0, "name"}, "alice"}
+		events <- []any{[]any{0, "name"}}
+		events <- []any{[]any{1, "id"}, 2.0}
+		events <- []any{[]any{1, "name"}, "bob"}
+		events <- []any{[]any{1, "name"}}
+		events <- []any{[]any{1}}
+	}()
+
+	p := New(3)
+	p.AddStream(events)
+	if p.Records() != 2 {
+		t.Fatalf("expected 2 records from stream, got %d", p.Records())
+	}
+	name := fieldByPath(p.Report(), ".name")
+	if name == nil || name.Count != 2 || name.Distinct != 2 {
+		t.Errorf("unexpected stream profile for .name: %+v", name)
+	}
+}
+
+func TestDistinctCounterSwitchesToSketch(t *testing.T) {
+	d := newDistinctCounter()
+	for i := range 20000 {
+		d.add(fmt.Sprint(i))
+	}
+	n, exact := d.estimate()
+	if exact {
+		t.Error("expected estimate to be approximate above the exact limit")
+	}
+	if n < 19000 || n > 21000 {
+		t.Errorf("estimate %d is too far from 20000", n)
+	}
+}
+
+func TestTopKBounded(t *testing.T) {
+	k := newTopK(4)
+	for i := range 1000 {
+		k.add(fmt.Sprint(i % 50))
+		k.add("hot")
+	}
+	if len(k.counts) > 4 {
+		t.Errorf("expected at most 4 counters, got %d", len(k.counts))
+	}
+	if top := k.top(1); top[0].Value != "hot" {
+		t.Errorf("expected hot to be most frequent, got %+v", top)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	if got := childPath(".", "user-id"); got != `.["user-id"]` {
+		t.Errorf("unexpected child path %q", got)
+	}
+	if got := parentPath(`.a["x.y"]`); got != ".a" {
+		t.Errorf("unexpected parent path %q", got)
+	}
+	if got := parentPath(".items[].name"); got != ".items[]" {
+		t.Errorf("unexpected parent path %q", got)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	p := New(2)
+	p.AddAll(records())
+	var b strings.Builder
+	if err := WriteTable(&b, p.Report()); err != nil {
+		t.Fatalf("WriteTable failed: %v", err)
+	}
+	if !strings.Contains(b.String(), "PATH") || !strings.Contains(b.String(), `"alice"(2)`) {
+		t.Errorf("unexpected table output:\n%s", b.String())
+	}
+}
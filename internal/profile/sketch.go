@@ -0,0 +1,148 @@
+package profile
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// distinctCounter counts distinct values exactly while the set is small and
+// switches to a HyperLogLog sketch once it exceeds exactLimit, so memory stays
+// bounded regardless of input size.
+type distinctCounter struct {
+	exact map[uint64]struct{}
+	hll   *hyperLogLog
+}
+
+const exactLimit = 1024
+
+func newDistinctCounter() *distinctCounter {
+	return &distinctCounter{exact: make(map[uint64]struct{})}
+}
+
+func (d *distinctCounter) add(key string) {
+	h := hash64(key)
+	if d.hll != nil {
+		d.hll.add(h)
+		return
+	}
+	d.exact[h] = struct{}{}
+	if len(d.exact) > exactLimit {
+		d.hll = &hyperLogLog{}
+		for k := range d.exact {
+			d.hll.add(k)
+		}
+		d.exact = nil
+	}
+}
+
+// estimate returns the exact count while below exactLimit, otherwise the
+// HyperLogLog estimate.
+func (d *distinctCounter) estimate() (count int64, exact bool) {
+	if d.hll == nil {
+		return int64(len(d.exact)), true
+	}
+	return d.hll.estimate(), false
+}
+
+const hllPrecision = 12
+const hllRegisters = 1 << hllPrecision
+
+// hyperLogLog is a fixed-size (4 KiB) cardinality sketch with ~1.6% standard error.
+type hyperLogLog struct {
+	registers [hllRegisters]uint8
+}
+
+func (h *hyperLogLog) add(x uint64) {
+	idx := x >> (64 - hllPrecision)
+	w := x<<hllPrecision | 1<<(hllPrecision-1)
+	rank := uint8(bits.LeadingZeros64(w) + 1)
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+func (h *hyperLogLog) estimate() int64 {
+	m := float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := alpha * m * m / sum
+	// small range correction (linear counting)
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(e))
+}
+
+// hash64 is FNV-1a followed by a murmur3 finalizer, which spreads the bits that
+// HyperLogLog relies on more evenly than FNV alone.
+func hash64(s string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	x := f.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// topK tracks the most frequent values with the Space-Saving algorithm: at most
+// capacity counters are kept, and a new value evicts the least frequent one,
+// inheriting its count as an upper bound.
+type topK struct {
+	capacity int
+	counts   map[string]int64
+}
+
+func newTopK(capacity int) *topK {
+	return &topK{capacity: capacity, counts: make(map[string]int64)}
+}
+
+func (t *topK) add(value string) {
+	if _, ok := t.counts[value]; ok || len(t.counts) < t.capacity {
+		t.counts[value]++
+		return
+	}
+	var minKey string
+	minCount := int64(math.MaxInt64)
+	for k, c := range t.counts {
+		if c < minCount || (c == minCount && k < minKey) {
+			minKey, minCount = k, c
+		}
+	}
+	delete(t.counts, minKey)
+	t.counts[value] = minCount + 1
+}
+
+// ValueCount is a value and how many times it was observed.
+type ValueCount struct {
+	Value string
+	Count int64
+}
+
+func (t *topK) top(n int) []ValueCount {
+	out := make([]ValueCount, 0, len(t.counts))
+	for k, c := range t.counts {
+		out = append(out, ValueCount{Value: k, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteTable renders a report as an aligned, human-readable table.
+func WriteTable(w io.Writer, report []Field) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tTYPES\tCOUNT\tNULLS\tMISSING\tDISTINCT\tMIN\tMAX\tMEAN\tLENGTH\tTOP")
+	for _, f := range report {
+		distinct := strconv.FormatInt(f.Distinct, 10)
+		if !f.DistinctExact {
+			distinct = "~" + distinct
+		}
+		min, max, mean := "-", "-", "-"
+		if f.Number != nil {
+			min, max, mean = formatNumber(f.Number.Min), formatNumber(f.Number.Max), formatNumber(f.Number.Mean)
+		}
+		length := "-"
+		if f.Length != nil {
+			length = fmt.Sprintf("%s..%s (avg %s)", formatNumber(f.Length.Min), formatNumber(f.Length.Max), formatNumber(f.Length.Mean))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			f.Path, formatTypes(f.Types), f.Count, f.Nulls, f.Missing, distinct, min, max, mean, length, formatTop(f.Top))
+	}
+	return tw.Flush()
+}
+
+func formatTypes(types map[string]int64) string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 1 {
+		return names[0]
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s(%d)", name, types[name])
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatTop(top []ValueCount) string {
+	if len(top) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(top))
+	for i, vc := range top {
+		v := vc.Value
+		if r := []rune(v); len(r) > 24 {
+			v = string(r[:21]) + "..."
+		}
+		parts[i] = fmt.Sprintf("%q(%d)", v, vc.Count)
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
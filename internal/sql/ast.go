@@ -0,0 +1,236 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Select is a parsed SELECT statement.
+type Select struct {
+	Distinct bool
+	Items    []SelectItem
+	From     *TableRef
+	Joins    []Join
+	Where    Expr
+	GroupBy  []Expr
+	Having   Expr
+	OrderBy  []OrderItem
+	Limit    Expr
+	Offset   Expr
+}
+
+// SelectItem is one entry of the select list: an expression, `*` or `t.*`.
+type SelectItem struct {
+	Expr  Expr
+	Alias string
+	Star  bool
+	Table string // qualifier for t.*
+}
+
+// TableRef names an input file and the alias it is referenced by.
+type TableRef struct {
+	Path  string
+	Alias string
+}
+
+// JoinKind distinguishes inner, left and cross joins.
+type JoinKind int
+
+const (
+	InnerJoin JoinKind = iota
+	LeftJoin
+	CrossJoin
+)
+
+func (k JoinKind) String() string {
+	return [...]string{"INNER JOIN", "LEFT JOIN", "CROSS JOIN"}[k]
+}
+
+// Join attaches another table to the FROM clause.
+type Join struct {
+	Kind  JoinKind
+	Table *TableRef
+	On    Expr
+}
+
+// OrderItem is one ORDER BY key.
+type OrderItem struct {
+	Expr Expr
+	Desc bool
+}
+
+// Expr is any SQL expression node.
+type Expr interface {
+	String() string
+}
+
+// Literal is a constant: number, string, boolean or NULL.
+type Literal struct {
+	Value any
+}
+
+// ColumnRef is a possibly qualified, possibly nested column reference such as
+// `amount`, `s.amount` or `s.customer.name`.
+type ColumnRef struct {
+	Parts []string
+}
+
+// Unary is a prefix operator: NOT or unary minus.
+type Unary struct {
+	Op string
+	X  Expr
+}
+
+// Binary is an infix operator.
+type Binary struct {
+	Op   string
+	L, R Expr
+}
+
+// Call is a scalar or aggregate function call.
+type Call struct {
+	Name     string // lower-cased
+	Args     []Expr
+	Star     bool // count(*)
+	Distinct bool
+}
+
+// InList is `x [NOT] IN (a, b, ...)`.
+type InList struct {
+	X    Expr
+	List []Expr
+	Not  bool
+}
+
+// Between is `x [NOT] BETWEEN lo AND hi`.
+type Between struct {
+	X, Lo, Hi Expr
+	Not       bool
+}
+
+// Like is `x [NOT] LIKE pattern`.
+type Like struct {
+	X, Pattern Expr
+	Not        bool
+}
+
+// IsNull is `x IS [NOT] NULL`.
+type IsNull struct {
+	X   Expr
+	Not bool
+}
+
+// Case is a searched or simple CASE expression.
+type Case struct {
+	Operand Expr // nil for searched CASE
+	Whens   []When
+	Else    Expr
+}
+
+// When is one WHEN ... THEN ... arm.
+type When struct {
+	Cond, Result Expr
+}
+
+// Cast is `CAST(x AS type)`.
+type Cast struct {
+	X    Expr
+	Type string
+}
+
+func (l *Literal) String() string {
+	switch v := l.Value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (c *ColumnRef) String() string { return strings.Join(c.Parts, ".") }
+
+func (u *Unary) String() string {
+	if u.Op == "NOT" {
+		return "NOT " + u.X.String()
+	}
+	return u.Op + u.X.String()
+}
+
+func (b *Binary) String() string {
+	return fmt.Sprintf("%s %s %s", b.L, b.Op, b.R)
+}
+
+func (c *Call) String() string {
+	if c.Star {
+		return c.Name + "(*)"
+	}
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+	distinct := ""
+	if c.Distinct {
+		distinct = "DISTINCT "
+	}
+	return fmt.Sprintf("%s(%s%s)", c.Name, distinct, strings.Join(args, ", "))
+}
+
+func (in *InList) String() string {
+	items := make([]string, len(in.List))
+	for i, e := range in.List {
+		items[i] = e.String()
+	}
+	not := ""
+	if in.Not {
+		not = "NOT "
+	}
+	return fmt.Sprintf("%s %sIN (%s)", in.X, not, strings.Join(items, ", "))
+}
+
+func (b *Between) String() string {
+	not := ""
+	if b.Not {
+		not = "NOT "
+	}
+	return fmt.Sprintf("%s %sBETWEEN %s AND %s", b.X, not, b.Lo, b.Hi)
+}
+
+func (l *Like) String() string {
+	not := ""
+	if l.Not {
+		not = "NOT "
+	}
+	return fmt.Sprintf("%s %sLIKE %s", l.X, not, l.Pattern)
+}
+
+func (n *IsNull) String() string {
+	if n.Not {
+		return n.X.String() + " IS NOT NULL"
+	}
+	return n.X.String() + " IS NULL"
+}
+
+func (c *Case) String() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	if c.Operand != nil {
+		b.WriteString(" " + c.Operand.String())
+	}
+	for _, w := range c.Whens {
+		fmt.Fprintf(&b, " WHEN %s THEN %s", w.Cond, w.Result)
+	}
+	if c.Else != nil {
+		b.WriteString(" ELSE " + c.Else.String())
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+func (c *Cast) String() string {
+	return fmt.Sprintf("CAST(%s AS %s)", c.X, c.Type)
+}
@@ -0,0 +1,784 @@
+package sql
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// tuple is the unit flowing through a plan: one record per table in FROM/JOIN
+// order, the rows of its group once aggregated, and its projected values.
+type tuple struct {
+	recs  []map[string]any
+	group []*tuple
+	out   []any
+}
+
+// evaluator resolves column references against the tables of a query.
+type evaluator struct {
+	aliases []string
+}
+
+func (ev *evaluator) eval(e Expr, t *tuple) (any, error) {
+	switch x := e.(type) {
+	case *Literal:
+		return x.Value, nil
+	case *ColumnRef:
+		return ev.column(x, t)
+	case *Unary:
+		v, err := ev.eval(x.X, t)
+		if err != nil {
+			return nil, err
+		}
+		if x.Op == "NOT" {
+			if v == nil {
+				return nil, nil
+			}
+			return !truthy(v), nil
+		}
+		if v == nil {
+			return nil, nil
+		}
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %s", typeName(v))
+		}
+		return normalizeNumber(-n), nil
+	case *Binary:
+		return ev.binary(x, t)
+	case *Call:
+		if isAggregate(x.Name) {
+			return ev.aggregate(x, t)
+		}
+		return ev.call(x, t)
+	case *InList:
+		v, err := ev.eval(x.X, t)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		found := false
+		for _, item := range x.List {
+			iv, err := ev.eval(item, t)
+			if err != nil {
+				return nil, err
+			}
+			if iv != nil && compare(v, iv) == 0 {
+				found = true
+				break
+			}
+		}
+		return found != x.Not, nil
+	case *Between:
+		v, err := ev.eval(x.X, t)
+		if err != nil {
+			return nil, err
+		}
+		lo, err := ev.eval(x.Lo, t)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := ev.eval(x.Hi, t)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil || lo == nil || hi == nil {
+			return nil, nil
+		}
+		in := compare(v, lo) >= 0 && compare(v, hi) <= 0
+		return in != x.Not, nil
+	case *Like:
+		v, err := ev.eval(x.X, t)
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := ev.eval(x.Pattern, t)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil || pattern == nil {
+			return nil, nil
+		}
+		re, err := likeRegexp(toString(pattern))
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(toString(v)) != x.Not, nil
+	case *IsNull:
+		v, err := ev.eval(x.X, t)
+		if err != nil {
+			return nil, err
+		}
+		return (v == nil) != x.Not, nil
+	case *Case:
+		var operand any
+		if x.Operand != nil {
+			var err error
+			if operand, err = ev.eval(x.Operand, t); err != nil {
+				return nil, err
+			}
+		}
+		for _, w := range x.Whens {
+			cond, err := ev.eval(w.Cond, t)
+			if err != nil {
+				return nil, err
+			}
+			matched := false
+			if x.Operand != nil {
+				matched = operand != nil && cond != nil && compare(operand, cond) == 0
+			} else {
+				matched = truthy(cond)
+			}
+			if matched {
+				return ev.eval(w.Result, t)
+			}
+		}
+		if x.Else != nil {
+			return ev.eval(x.Else, t)
+		}
+		return nil, nil
+	case *Cast:
+		v, err := ev.eval(x.X, t)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		return cast(v, x.Type)
+	default:
+		return nil, fmt.Errorf("unsupported expression %s", e)
+	}
+}
+
+// column resolves a reference. A leading part that names a table alias selects
+// that table; otherwise the first table whose record has the key wins. Any
+// remaining parts descend into nested objects.
+func (ev *evaluator) column(ref *ColumnRef, t *tuple) (any, error) {
+	recs := t.recs
+	if recs == nil && len(t.group) > 0 {
+		recs = t.group[0].recs
+	}
+	parts := ref.Parts
+	var root any
+	found := false
+
+	if len(parts) > 1 {
+		for i, alias := range ev.aliases {
+			if alias == parts[0] {
+				if i < len(recs) && recs[i] != nil {
+					root = recs[i]
+				}
+				parts = parts[1:]
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		for _, rec := range recs {
+			if rec == nil {
+				continue
+			}
+			if _, ok := rec[parts[0]]; ok {
+				root = rec
+				break
+			}
+		}
+	}
+
+	v := root
+	for _, part := range parts {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		v = m[part]
+	}
+	return v, nil
+}
+
+func (ev *evaluator) binary(x *Binary, t *tuple) (any, error) {
+	l, err := ev.eval(x.L, t)
+	if err != nil {
+		return nil, err
+	}
+
+	// AND/OR short-circuit with three-valued logic
+	switch x.Op {
+	case "AND":
+		if l != nil && !truthy(l) {
+			return false, nil
+		}
+		r, err := ev.eval(x.R, t)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil && !truthy(r) {
+			return false, nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		return true, nil
+	case "OR":
+		if l != nil && truthy(l) {
+			return true, nil
+		}
+		r, err := ev.eval(x.R, t)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil && truthy(r) {
+			return true, nil
+		}
+		if l == nil || r == nil {
+			return nil, nil
+		}
+		return false, nil
+	}
+
+	r, err := ev.eval(x.R, t)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil || r == nil {
+		return nil, nil
+	}
+
+	switch x.Op {
+	case "=":
+		return compare(l, r) == 0, nil
+	case "<>":
+		return compare(l, r) != 0, nil
+	case "<":
+		return compare(l, r) < 0, nil
+	case "<=":
+		return compare(l, r) <= 0, nil
+	case ">":
+		return compare(l, r) > 0, nil
+	case ">=":
+		return compare(l, r) >= 0, nil
+	case "||":
+		return toString(l) + toString(r), nil
+	}
+
+	ln, lok := toNumber(l)
+	rn, rok := toNumber(r)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply %s to %s and %s", x.Op, typeName(l), typeName(r))
+	}
+	switch x.Op {
+	case "+":
+		return normalizeNumber(ln + rn), nil
+	case "-":
+		return normalizeNumber(ln - rn), nil
+	case "*":
+		return normalizeNumber(ln * rn), nil
+	case "/":
+		if rn == 0 {
+			return nil, nil
+		}
+		return normalizeNumber(ln / rn), nil
+	case "%":
+		if rn == 0 {
+			return nil, nil
+		}
+		return normalizeNumber(math.Mod(ln, rn)), nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s", x.Op)
+}
+
+var aggregates = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+	"group_concat": true, "string_agg": true, "array_agg": true, "total": true,
+}
+
+func isAggregate(name string) bool { return aggregates[name] }
+
+func (ev *evaluator) aggregate(c *Call, t *tuple) (any, error) {
+	if t.group == nil {
+		return nil, fmt.Errorf("aggregate %s() is not allowed here", c.Name)
+	}
+	if c.Star {
+		if c.Name != "count" {
+			return nil, fmt.Errorf("%s(*) is not supported", c.Name)
+		}
+		return len(t.group), nil
+	}
+	if len(c.Args) == 0 {
+		return nil, fmt.Errorf("%s() requires an argument", c.Name)
+	}
+
+	var values []any
+	seen := make(map[string]bool)
+	for _, row := range t.group {
+		v, err := ev.eval(c.Args[0], row)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		if c.Distinct {
+			k := hashKey(v)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		values = append(values, v)
+	}
+
+	switch c.Name {
+	case "count":
+		return len(values), nil
+	case "sum", "total", "avg":
+		if len(values) == 0 {
+			if c.Name == "total" {
+				return 0, nil
+			}
+			return nil, nil
+		}
+		sum := 0.0
+		for _, v := range values {
+			n, ok := toNumber(v)
+			if !ok {
+				return nil, fmt.Errorf("%s() of non-numeric value %s", c.Name, typeName(v))
+			}
+			sum += n
+		}
+		if c.Name == "avg" {
+			return sum / float64(len(values)), nil
+		}
+		return normalizeNumber(sum), nil
+	case "min", "max":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		best := values[0]
+		for _, v := range values[1:] {
+			cmp := compare(v, best)
+			if (c.Name == "min" && cmp < 0) || (c.Name == "max" && cmp > 0) {
+				best = v
+			}
+		}
+		return best, nil
+	case "group_concat", "string_agg":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		sep := ","
+		if len(c.Args) > 1 {
+			s, err := ev.eval(c.Args[1], t.group[0])
+			if err != nil {
+				return nil, err
+			}
+			sep = toString(s)
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = toString(v)
+		}
+		return strings.Join(parts, sep), nil
+	case "array_agg":
+		if values == nil {
+			return []any{}, nil
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown aggregate %s", c.Name)
+}
+
+func (ev *evaluator) call(c *Call, t *tuple) (any, error) {
+	args := make([]any, len(c.Args))
+	for i, a := range c.Args {
+		v, err := ev.eval(a, t)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	arity := func(min, max int) error {
+		if len(args) < min || (max >= 0 && len(args) > max) {
+			return fmt.Errorf("wrong number of arguments to %s()", c.Name)
+		}
+		return nil
+	}
+
+	switch c.Name {
+	case "coalesce", "ifnull":
+		for _, a := range args {
+			if a != nil {
+				return a, nil
+			}
+		}
+		return nil, nil
+	case "nullif":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		if args[0] != nil && args[1] != nil && compare(args[0], args[1]) == 0 {
+			return nil, nil
+		}
+		return args[0], nil
+	case "concat":
+		var b strings.Builder
+		for _, a := range args {
+			if a != nil {
+				b.WriteString(toString(a))
+			}
+		}
+		return b.String(), nil
+	}
+
+	// remaining functions return NULL for a NULL first argument
+	if err := arity(1, -1); err != nil {
+		return nil, err
+	}
+	if args[0] == nil {
+		return nil, nil
+	}
+
+	switch c.Name {
+	case "lower":
+		return strings.ToLower(toString(args[0])), nil
+	case "upper":
+		return strings.ToUpper(toString(args[0])), nil
+	case "trim":
+		return strings.TrimSpace(toString(args[0])), nil
+	case "ltrim":
+		return strings.TrimLeft(toString(args[0]), " \t\r\n"), nil
+	case "rtrim":
+		return strings.TrimRight(toString(args[0]), " \t\r\n"), nil
+	case "length", "len":
+		switch v := args[0].(type) {
+		case []any:
+			return len(v), nil
+		case map[string]any:
+			return len(v), nil
+		}
+		return len([]rune(toString(args[0]))), nil
+	case "substr", "substring":
+		if err := arity(2, 3); err != nil {
+			return nil, err
+		}
+		runes := []rune(toString(args[0]))
+		start, _ := toNumber(args[1])
+		from := int(start) - 1 // SQL is 1-based
+		if from < 0 {
+			from = 0
+		}
+		if from > len(runes) {
+			return "", nil
+		}
+		to := len(runes)
+		if len(args) == 3 {
+			n, _ := toNumber(args[2])
+			to = min(from+int(n), len(runes))
+		}
+		if to < from {
+			return "", nil
+		}
+		return string(runes[from:to]), nil
+	case "replace":
+		if err := arity(3, 3); err != nil {
+			return nil, err
+		}
+		return strings.ReplaceAll(toString(args[0]), toString(args[1]), toString(args[2])), nil
+	case "abs":
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("abs() of non-numeric value")
+		}
+		return normalizeNumber(math.Abs(n)), nil
+	case "round":
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("round() of non-numeric value")
+		}
+		digits := 0.0
+		if len(args) > 1 {
+			digits, _ = toNumber(args[1])
+		}
+		p := math.Pow(10, digits)
+		return normalizeNumber(math.Round(n*p) / p), nil
+	case "floor", "ceil", "ceiling":
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%s() of non-numeric value", c.Name)
+		}
+		if c.Name == "floor" {
+			return normalizeNumber(math.Floor(n)), nil
+		}
+		return normalizeNumber(math.Ceil(n)), nil
+	}
+	return nil, fmt.Errorf("unknown function %s()", c.Name)
+}
+
+func cast(v any, typ string) (any, error) {
+	switch typ {
+	case "int", "integer", "bigint":
+		if s, ok := v.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, nil
+			}
+			return int(f), nil
+		}
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, nil
+		}
+		return int(n), nil
+	case "real", "float", "double", "decimal", "numeric":
+		if s, ok := v.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, nil
+			}
+			return f, nil
+		}
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, nil
+		}
+		return n, nil
+	case "text", "varchar", "string", "char":
+		return toString(v), nil
+	case "bool", "boolean":
+		return truthy(v), nil
+	}
+	return nil, fmt.Errorf("unsupported CAST type %s", typ)
+}
+
+func likeRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	}
+	if n, ok := toNumber(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// normalizeNumber returns whole numbers as int, matching how gojq represents
+// integers, and everything else as float64.
+func normalizeNumber(f float64) any {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int(f)
+	}
+	return f
+}
+
+func toString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case map[string]any, []any:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+	if n, ok := toNumber(v); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string, time.Time:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := toNumber(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// typeRank orders values of different types the way jq does:
+// null < false/true < numbers < strings < arrays < objects.
+func typeRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case string, time.Time:
+		return 3
+	case []any:
+		return 4
+	case map[string]any:
+		return 5
+	}
+	if _, ok := toNumber(v); ok {
+		return 2
+	}
+	return 6
+}
+
+// compare orders two values, comparing numbers numerically regardless of
+// their Go type and falling back to jq's cross-type ordering.
+func compare(a, b any) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch ra {
+	case 0:
+		return 0
+	case 1:
+		ab, bb := a.(bool), b.(bool)
+		switch {
+		case ab == bb:
+			return 0
+		case !ab:
+			return -1
+		default:
+			return 1
+		}
+	case 2:
+		an, _ := toNumber(a)
+		bn, _ := toNumber(b)
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case 3:
+		return strings.Compare(toString(a), toString(b))
+	case 4:
+		aa, ba := a.([]any), b.([]any)
+		for i := 0; i < len(aa) && i < len(ba); i++ {
+			if c := compare(aa[i], ba[i]); c != 0 {
+				return c
+			}
+		}
+		return len(aa) - len(ba)
+	case 5:
+		am, bm := a.(map[string]any), b.(map[string]any)
+		ak, bk := sortedKeys(am), sortedKeys(bm)
+		if c := compare(toAnySlice(ak), toAnySlice(bk)); c != 0 {
+			return c
+		}
+		for _, k := range ak {
+			if c := compare(am[k], bm[k]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toAnySlice(s []string) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+// hashKey returns a string that is equal for values that compare equal, used
+// for hash joins, GROUP BY and DISTINCT.
+func hashKey(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "n"
+	case bool:
+		return "b" + strconv.FormatBool(x)
+	case string:
+		return "s" + x
+	case time.Time:
+		return "s" + x.Format(time.RFC3339)
+	}
+	if n, ok := toNumber(v); ok {
+		return "f" + strconv.FormatFloat(n, 'g', -1, 64)
+	}
+	b, _ := json.Marshal(v)
+	return "j" + string(b)
+}
@@ -0,0 +1,156 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return fmt.Sprintf("'%s'", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// lex splits a query into tokens. Keywords are returned as identifiers and
+// recognised case-insensitively by the parser.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			start := i
+			i += 2
+			for i+1 < len(runes) && (runes[i] != '*' || runes[i+1] != '/') {
+				i++
+			}
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("unterminated comment at position %d", start)
+			}
+			i += 2
+		case r == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string at position %d", start)
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						b.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: start})
+		case r == '"' || r == '`':
+			start := i
+			quote := r
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated identifier at position %d", start)
+				}
+				if runes[i] == quote {
+					if i+1 < len(runes) && runes[i+1] == quote {
+						b.WriteRune(quote)
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokQuotedIdent, text: b.String(), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[start:i]), pos: start})
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			if i < len(runes) && runes[i] == '.' {
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					i = j
+					for i < len(runes) && unicode.IsDigit(runes[i]) {
+						i++
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[start:i]), pos: start})
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "<=", ">=", "<>", "!=", "==", "||":
+				tokens = append(tokens, token{kind: tokOp, text: two, pos: start})
+				i += 2
+				continue
+			}
+			if !strings.ContainsRune("=<>+-*/%(),.;", r) {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: string(r), pos: start})
+			i++
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(runes)})
+	return tokens, nil
+}
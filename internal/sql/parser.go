@@ -0,0 +1,668 @@
+package sql
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// reserved words cannot be used as implicit aliases.
+var reserved = map[string]bool{
+	"select": true, "distinct": true, "all": true, "from": true, "where": true, "group": true,
+	"by": true, "having": true, "order": true, "limit": true, "offset": true, "join": true,
+	"inner": true, "left": true, "outer": true, "cross": true, "on": true, "as": true,
+	"and": true, "or": true, "not": true, "in": true, "is": true, "null": true, "like": true,
+	"between": true, "case": true, "when": true, "then": true, "else": true, "end": true,
+	"asc": true, "desc": true, "true": true, "false": true, "cast": true, "union": true,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses a single SELECT statement.
+func Parse(query string) (*Select, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	stmt, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	p.acceptOp(";")
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return stmt, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error at position %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return p.errorf("expected %s, got %s", strings.ToUpper(kw), p.peek())
+	}
+	return nil
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokOp && t.text == op
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.isOp(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.errorf("expected %q, got %s", op, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) parseSelect() (*Select, error) {
+	if err := p.expectKeyword("select"); err != nil {
+		return nil, err
+	}
+	stmt := &Select{}
+	if p.acceptKeyword("distinct") {
+		stmt.Distinct = true
+	} else {
+		p.acceptKeyword("all")
+	}
+
+	for {
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Items = append(stmt.Items, item)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+
+	if p.acceptKeyword("from") {
+		from, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		stmt.From = from
+		for {
+			join, ok, err := p.parseJoin()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			stmt.Joins = append(stmt.Joins, join)
+		}
+	}
+
+	var err error
+	if p.acceptKeyword("where") {
+		if stmt.Where, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("group") {
+		if err := p.expectKeyword("by"); err != nil {
+			return nil, err
+		}
+		if stmt.GroupBy, err = p.parseExprList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("having") {
+		if stmt.Having, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("order") {
+		if err := p.expectKeyword("by"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			item := OrderItem{Expr: e}
+			if p.acceptKeyword("desc") {
+				item.Desc = true
+			} else {
+				p.acceptKeyword("asc")
+			}
+			stmt.OrderBy = append(stmt.OrderBy, item)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("limit") {
+		if stmt.Limit, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if p.acceptOp(",") {
+			// LIMIT offset, count
+			stmt.Offset = stmt.Limit
+			if stmt.Limit, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.acceptKeyword("offset") {
+		if stmt.Offset, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseSelectItem() (SelectItem, error) {
+	if p.acceptOp("*") {
+		return SelectItem{Star: true}, nil
+	}
+	// t.*
+	if t := p.peek(); (t.kind == tokIdent || t.kind == tokQuotedIdent) &&
+		p.tokens[p.pos+1].kind == tokOp && p.tokens[p.pos+1].text == "." &&
+		p.tokens[p.pos+2].kind == tokOp && p.tokens[p.pos+2].text == "*" {
+		p.pos += 3
+		return SelectItem{Star: true, Table: t.text}, nil
+	}
+
+	e, err := p.parseExpr()
+	if err != nil {
+		return SelectItem{}, err
+	}
+	item := SelectItem{Expr: e}
+	alias, err := p.parseAlias()
+	if err != nil {
+		return SelectItem{}, err
+	}
+	item.Alias = alias
+	return item, nil
+}
+
+// parseAlias reads `[AS] name` if present.
+func (p *parser) parseAlias() (string, error) {
+	if p.acceptKeyword("as") {
+		t := p.next()
+		if t.kind != tokIdent && t.kind != tokQuotedIdent && t.kind != tokString {
+			return "", p.errorf("expected alias after AS, got %s", t)
+		}
+		return t.text, nil
+	}
+	t := p.peek()
+	if t.kind == tokQuotedIdent || (t.kind == tokIdent && !reserved[strings.ToLower(t.text)]) {
+		p.pos++
+		return t.text, nil
+	}
+	return "", nil
+}
+
+func (p *parser) parseTableRef() (*TableRef, error) {
+	t := p.next()
+	var path string
+	switch t.kind {
+	case tokString, tokQuotedIdent:
+		path = t.text
+	case tokIdent:
+		// bare names may contain dots and slashes: data/sales.csv
+		path = t.text
+		for p.isOp(".") || p.isOp("/") || p.isOp("-") {
+			path += p.next().text
+			n := p.next()
+			if n.kind != tokIdent && n.kind != tokNumber {
+				return nil, p.errorf("invalid table name near %s", n)
+			}
+			path += n.text
+		}
+	default:
+		return nil, p.errorf("expected table name, got %s", t)
+	}
+
+	alias, err := p.parseAlias()
+	if err != nil {
+		return nil, err
+	}
+	if alias == "" {
+		base := filepath.Base(path)
+		alias = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return &TableRef{Path: path, Alias: alias}, nil
+}
+
+func (p *parser) parseJoin() (Join, bool, error) {
+	var kind JoinKind
+	switch {
+	case p.acceptOp(","):
+		table, err := p.parseTableRef()
+		return Join{Kind: CrossJoin, Table: table}, true, err
+	case p.acceptKeyword("cross"):
+		kind = CrossJoin
+	case p.acceptKeyword("left"):
+		p.acceptKeyword("outer")
+		kind = LeftJoin
+	case p.acceptKeyword("inner"):
+		kind = InnerJoin
+	case p.isKeyword("join"):
+		kind = InnerJoin
+	default:
+		return Join{}, false, nil
+	}
+	if err := p.expectKeyword("join"); err != nil {
+		return Join{}, false, err
+	}
+	table, err := p.parseTableRef()
+	if err != nil {
+		return Join{}, false, err
+	}
+	join := Join{Kind: kind, Table: table}
+	if kind != CrossJoin {
+		if err := p.expectKeyword("on"); err != nil {
+			return Join{}, false, err
+		}
+		if join.On, err = p.parseExpr(); err != nil {
+			return Join{}, false, err
+		}
+	}
+	return join, true, nil
+}
+
+func (p *parser) parseExprList() ([]Expr, error) {
+	var list []Expr
+	for {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if !p.acceptOp(",") {
+			return list, nil
+		}
+	}
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("or") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &Binary{Op: "OR", L: l, R: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("and") {
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = &Binary{Op: "AND", L: l, R: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.acceptKeyword("not") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: "NOT", X: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	l, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind == tokOp {
+			switch t.text {
+			case "=", "==", "<>", "!=", "<", "<=", ">", ">=":
+				p.pos++
+				r, err := p.parseConcat()
+				if err != nil {
+					return nil, err
+				}
+				op := t.text
+				switch op {
+				case "==":
+					op = "="
+				case "!=":
+					op = "<>"
+				}
+				l = &Binary{Op: op, L: l, R: r}
+				continue
+			}
+			return l, nil
+		}
+
+		if p.acceptKeyword("is") {
+			not := p.acceptKeyword("not")
+			if err := p.expectKeyword("null"); err != nil {
+				return nil, err
+			}
+			l = &IsNull{X: l, Not: not}
+			continue
+		}
+
+		not := false
+		if p.isKeyword("not") {
+			n := p.tokens[p.pos+1]
+			if n.kind == tokIdent && (strings.EqualFold(n.text, "in") || strings.EqualFold(n.text, "like") || strings.EqualFold(n.text, "between")) {
+				p.pos++
+				not = true
+			}
+		}
+		switch {
+		case p.acceptKeyword("in"):
+			if err := p.expectOp("("); err != nil {
+				return nil, err
+			}
+			list, err := p.parseExprList()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			l = &InList{X: l, List: list, Not: not}
+		case p.acceptKeyword("like"):
+			pattern, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			l = &Like{X: l, Pattern: pattern, Not: not}
+		case p.acceptKeyword("between"):
+			lo, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("and"); err != nil {
+				return nil, err
+			}
+			hi, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			l = &Between{X: l, Lo: lo, Hi: hi, Not: not}
+		default:
+			return l, nil
+		}
+	}
+}
+
+func (p *parser) parseConcat() (Expr, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("||") {
+		r, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		l = &Binary{Op: "||", L: l, R: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAdditive() (Expr, error) {
+	l, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text
+		r, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &Binary{Op: op, L: l, R: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseMultiplicative() (Expr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") || p.isOp("%") {
+		op := p.next().text
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &Binary{Op: op, L: l, R: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.isOp("-") || p.isOp("+") {
+		op := p.next().text
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "+" {
+			return x, nil
+		}
+		if lit, ok := x.(*Literal); ok {
+			switch v := lit.Value.(type) {
+			case int:
+				return &Literal{Value: -v}, nil
+			case float64:
+				return &Literal{Value: -v}, nil
+			}
+		}
+		return &Unary{Op: "-", X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		if i, err := strconv.Atoi(t.text); err == nil {
+			return &Literal{Value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return &Literal{Value: f}, nil
+	case tokString:
+		return &Literal{Value: t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+		p.pos--
+		return nil, p.errorf("unexpected %s", t)
+	case tokQuotedIdent:
+		return p.parseColumnRef(t.text)
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "null":
+			return &Literal{Value: nil}, nil
+		case "true":
+			return &Literal{Value: true}, nil
+		case "false":
+			return &Literal{Value: false}, nil
+		case "case":
+			return p.parseCase()
+		case "cast":
+			return p.parseCast()
+		}
+		if p.isOp("(") {
+			return p.parseCall(strings.ToLower(t.text))
+		}
+		if reserved[strings.ToLower(t.text)] {
+			p.pos--
+			return nil, p.errorf("unexpected keyword %s", strings.ToUpper(t.text))
+		}
+		return p.parseColumnRef(t.text)
+	default:
+		p.pos--
+		return nil, p.errorf("unexpected %s", t)
+	}
+}
+
+func (p *parser) parseColumnRef(first string) (Expr, error) {
+	ref := &ColumnRef{Parts: []string{first}}
+	for p.isOp(".") {
+		n := p.tokens[p.pos+1]
+		if n.kind != tokIdent && n.kind != tokQuotedIdent {
+			break
+		}
+		p.pos += 2
+		ref.Parts = append(ref.Parts, n.text)
+	}
+	return ref, nil
+}
+
+func (p *parser) parseCall(name string) (Expr, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	call := &Call{Name: name}
+	if p.acceptOp("*") {
+		call.Star = true
+	} else if !p.isOp(")") {
+		call.Distinct = p.acceptKeyword("distinct")
+		args, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = args
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+func (p *parser) parseCase() (Expr, error) {
+	c := &Case{}
+	if !p.isKeyword("when") {
+		operand, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		c.Operand = operand
+	}
+	for p.acceptKeyword("when") {
+		cond, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("then"); err != nil {
+			return nil, err
+		}
+		result, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		c.Whens = append(c.Whens, When{Cond: cond, Result: result})
+	}
+	if len(c.Whens) == 0 {
+		return nil, p.errorf("CASE requires at least one WHEN")
+	}
+	if p.acceptKeyword("else") {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		c.Else = e
+	}
+	if err := p.expectKeyword("end"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (p *parser) parseCast() (Expr, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("as"); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	if t.kind != tokIdent {
+		return nil, p.errorf("expected type name, got %s", t)
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	return &Cast{X: x, Type: strings.ToLower(t.text)}, nil
+}
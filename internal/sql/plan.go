@@ -0,0 +1,877 @@
+// Package sql implements a small SELECT dialect over decoded documents. Table
+// names are file paths; rows are the objects of a top-level array (or the
+// document itself), using the same value model as gojq.
+package sql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Loader decodes the table stored at path.
+type Loader func(path string) (any, error)
+
+// Plan is a compiled query: a tree of operators evaluated bottom-up.
+type Plan struct {
+	root    node
+	columns []string
+	tables  []*TableRef
+	// refs are the column references of the query, checked against the
+	// tables before it runs
+	refs []*ColumnRef
+}
+
+// node is a plan operator.
+type node interface {
+	run(ctx *execContext) ([]*tuple, error)
+	explain() (string, []node)
+}
+
+type execContext struct {
+	load   Loader
+	tables map[string][]map[string]any
+}
+
+// Compile parses a query and builds its evaluation plan.
+func Compile(query string) (*Plan, error) {
+	stmt, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return compile(stmt)
+}
+
+// Columns returns the names of the output columns, or nil when the select list
+// contains a wildcard and columns depend on the data.
+func (p *Plan) Columns() []string {
+	return p.columns
+}
+
+// String renders the plan tree, one operator per line.
+func (p *Plan) String() string {
+	var b strings.Builder
+	var walk func(n node, depth int)
+	walk = func(n node, depth int) {
+		desc, children := n.explain()
+		fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", depth), desc)
+		for _, c := range children {
+			walk(c, depth+1)
+		}
+	}
+	walk(p.root, 0)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Run executes the plan and returns one object per result row.
+func (p *Plan) Run(load Loader) ([]any, error) {
+	ctx := &execContext{load: load, tables: make(map[string][]map[string]any)}
+	if err := p.checkColumns(ctx); err != nil {
+		return nil, err
+	}
+	tuples, err := p.root.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, 0, len(tuples))
+	for _, t := range tuples {
+		out = append(out, t.out[0])
+	}
+	return out, nil
+}
+
+// checkColumns loads the tables and reports a column reference that no row
+// of the tables it may refer to has. Rows may leave keys out, so a column
+// is known when any row has it, and is not checked against empty tables.
+func (p *Plan) checkColumns(ctx *execContext) error {
+	keys := make([]map[string]bool, len(p.tables))
+	for i, t := range p.tables {
+		recs, err := (&scanNode{table: t}).records(ctx)
+		if err != nil {
+			return err
+		}
+		if len(recs) == 0 {
+			continue
+		}
+		keys[i] = make(map[string]bool)
+		for _, rec := range recs {
+			for k := range rec {
+				keys[i][k] = true
+			}
+		}
+	}
+
+	known := func(i int, name string) bool { return keys[i] == nil || keys[i][name] }
+	for _, ref := range p.refs {
+		qualified := false
+		if len(ref.Parts) > 1 {
+			for i, t := range p.tables {
+				if t.Alias == ref.Parts[0] {
+					if !known(i, ref.Parts[1]) {
+						return fmt.Errorf("unknown column %s: %s has no column %s", ref, t.Alias, ref.Parts[1])
+					}
+					qualified = true
+					break
+				}
+			}
+		}
+		if qualified {
+			continue
+		}
+		found := false
+		for i := range p.tables {
+			if known(i, ref.Parts[0]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown column %s", ref)
+		}
+	}
+	return nil
+}
+
+// columnRefs returns the column references of the expressions.
+func columnRefs(exprs ...Expr) []*ColumnRef {
+	var refs []*ColumnRef
+	for _, e := range exprs {
+		if e == nil {
+			continue
+		}
+		rewrite(e, func(x Expr) Expr {
+			if ref, ok := x.(*ColumnRef); ok {
+				refs = append(refs, ref)
+			}
+			return x
+		})
+	}
+	return refs
+}
+
+func compile(stmt *Select) (*Plan, error) {
+	plan := &Plan{}
+	ev := &evaluator{}
+
+	var root node
+	if stmt.From == nil {
+		root = &singleRowNode{}
+	} else {
+		tables := append([]*TableRef{stmt.From}, joinTables(stmt.Joins)...)
+		seen := make(map[string]bool)
+		for _, t := range tables {
+			if seen[t.Alias] {
+				return nil, fmt.Errorf("duplicate table alias %q", t.Alias)
+			}
+			seen[t.Alias] = true
+			ev.aliases = append(ev.aliases, t.Alias)
+		}
+		plan.tables = tables
+		for _, j := range stmt.Joins {
+			plan.refs = append(plan.refs, columnRefs(j.On)...)
+		}
+
+		root = &scanNode{table: stmt.From, index: 0, width: len(tables)}
+		for i, j := range stmt.Joins {
+			join := &joinNode{
+				left:  root,
+				right: &scanNode{table: j.Table, index: i + 1, width: len(tables)},
+				kind:  j.Kind,
+				on:    j.On,
+				ev:    ev,
+				index: i + 1,
+			}
+			join.planHash()
+			root = join
+		}
+	}
+
+	if stmt.Where != nil {
+		if containsAggregate(stmt.Where) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in WHERE")
+		}
+		root = &filterNode{input: root, pred: stmt.Where, ev: ev, label: "Filter"}
+		plan.refs = append(plan.refs, columnRefs(stmt.Where)...)
+	}
+
+	// GROUP BY may refer to select aliases or 1-based positions
+	groupBy := make([]Expr, len(stmt.GroupBy))
+	for i, g := range stmt.GroupBy {
+		resolved, err := resolveOutputRef(g, stmt.Items, false)
+		if err != nil {
+			return nil, err
+		}
+		groupBy[i] = resolved
+	}
+	plan.refs = append(plan.refs, columnRefs(groupBy...)...)
+
+	aggregating := len(groupBy) > 0 || stmt.Having != nil
+	for _, item := range stmt.Items {
+		if item.Expr != nil && containsAggregate(item.Expr) {
+			aggregating = true
+		}
+	}
+	for _, o := range stmt.OrderBy {
+		if containsAggregate(o.Expr) {
+			aggregating = true
+		}
+	}
+	if aggregating {
+		root = &groupNode{input: root, keys: groupBy, ev: ev}
+		if stmt.Having != nil {
+			having, err := resolveOutputRef(stmt.Having, stmt.Items, true)
+			if err != nil {
+				return nil, err
+			}
+			root = &filterNode{input: root, pred: having, ev: ev, label: "Having"}
+			plan.refs = append(plan.refs, columnRefs(having)...)
+		}
+	}
+
+	// ORDER BY keys are evaluated before projection so they can use columns
+	// that are not selected; aliases and positions map to select items.
+	var order []sortKey
+	for _, o := range stmt.OrderBy {
+		key := sortKey{desc: o.Desc, expr: o.Expr}
+		if lit, ok := o.Expr.(*Literal); ok {
+			if n, ok := lit.Value.(int); ok {
+				if n < 1 || n > len(stmt.Items) || stmt.Items[n-1].Star {
+					return nil, fmt.Errorf("ORDER BY position %d is out of range", n)
+				}
+				key.expr = stmt.Items[n-1].Expr
+			}
+		} else if resolved, err := resolveOutputRef(o.Expr, stmt.Items, true); err == nil {
+			key.expr = resolved
+		}
+		order = append(order, key)
+		plan.refs = append(plan.refs, columnRefs(key.expr)...)
+	}
+	if len(order) > 0 {
+		root = &sortNode{input: root, keys: order, ev: ev}
+	}
+
+	for _, item := range stmt.Items {
+		plan.refs = append(plan.refs, columnRefs(item.Expr)...)
+	}
+	project := &projectNode{input: root, items: stmt.Items, ev: ev}
+	plan.columns = project.columnNames()
+	root = project
+
+	if stmt.Distinct {
+		root = &distinctNode{input: root}
+	}
+	if stmt.Limit != nil || stmt.Offset != nil {
+		limit := &limitNode{input: root, limit: -1}
+		if stmt.Limit != nil {
+			n, err := constInt(stmt.Limit, "LIMIT")
+			if err != nil {
+				return nil, err
+			}
+			limit.limit = n
+		}
+		if stmt.Offset != nil {
+			n, err := constInt(stmt.Offset, "OFFSET")
+			if err != nil {
+				return nil, err
+			}
+			limit.offset = n
+		}
+		root = limit
+	}
+
+	plan.root = root
+	return plan, nil
+}
+
+func joinTables(joins []Join) []*TableRef {
+	tables := make([]*TableRef, len(joins))
+	for i, j := range joins {
+		tables[i] = j.Table
+	}
+	return tables
+}
+
+func constInt(e Expr, clause string) (int, error) {
+	lit, ok := e.(*Literal)
+	if ok {
+		if n, ok := lit.Value.(int); ok && n >= 0 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%s requires a non-negative integer", clause)
+}
+
+// resolveOutputRef maps a bare identifier that names a select alias to the
+// aliased expression, and (for GROUP BY) an integer literal to a select item.
+func resolveOutputRef(e Expr, items []SelectItem, aliasOnly bool) (Expr, error) {
+	if lit, ok := e.(*Literal); ok && !aliasOnly {
+		if n, ok := lit.Value.(int); ok {
+			if n < 1 || n > len(items) || items[n-1].Star {
+				return nil, fmt.Errorf("GROUP BY position %d is out of range", n)
+			}
+			return items[n-1].Expr, nil
+		}
+	}
+	return rewrite(e, func(x Expr) Expr {
+		ref, ok := x.(*ColumnRef)
+		if !ok || len(ref.Parts) != 1 {
+			return x
+		}
+		for _, item := range items {
+			if item.Alias == ref.Parts[0] && item.Expr != nil {
+				return item.Expr
+			}
+		}
+		return x
+	}), nil
+}
+
+// rewrite applies fn to every node of an expression tree, bottom-up.
+func rewrite(e Expr, fn func(Expr) Expr) Expr {
+	switch x := e.(type) {
+	case *Unary:
+		return fn(&Unary{Op: x.Op, X: rewrite(x.X, fn)})
+	case *Binary:
+		return fn(&Binary{Op: x.Op, L: rewrite(x.L, fn), R: rewrite(x.R, fn)})
+	case *Call:
+		args := make([]Expr, len(x.Args))
+		for i, a := range x.Args {
+			args[i] = rewrite(a, fn)
+		}
+		return fn(&Call{Name: x.Name, Args: args, Star: x.Star, Distinct: x.Distinct})
+	case *InList:
+		list := make([]Expr, len(x.List))
+		for i, a := range x.List {
+			list[i] = rewrite(a, fn)
+		}
+		return fn(&InList{X: rewrite(x.X, fn), List: list, Not: x.Not})
+	case *Between:
+		return fn(&Between{X: rewrite(x.X, fn), Lo: rewrite(x.Lo, fn), Hi: rewrite(x.Hi, fn), Not: x.Not})
+	case *Like:
+		return fn(&Like{X: rewrite(x.X, fn), Pattern: rewrite(x.Pattern, fn), Not: x.Not})
+	case *IsNull:
+		return fn(&IsNull{X: rewrite(x.X, fn), Not: x.Not})
+	case *Cast:
+		return fn(&Cast{X: rewrite(x.X, fn), Type: x.Type})
+	case *Case:
+		c := &Case{}
+		if x.Operand != nil {
+			c.Operand = rewrite(x.Operand, fn)
+		}
+		for _, w := range x.Whens {
+			c.Whens = append(c.Whens, When{Cond: rewrite(w.Cond, fn), Result: rewrite(w.Result, fn)})
+		}
+		if x.Else != nil {
+			c.Else = rewrite(x.Else, fn)
+		}
+		return fn(c)
+	default:
+		return fn(e)
+	}
+}
+
+func containsAggregate(e Expr) bool {
+	found := false
+	rewrite(e, func(x Expr) Expr {
+		if c, ok := x.(*Call); ok && isAggregate(c.Name) {
+			found = true
+		}
+		return x
+	})
+	return found
+}
+
+// singleRowNode produces one empty row for SELECT without FROM.
+type singleRowNode struct{}
+
+func (n *singleRowNode) run(*execContext) ([]*tuple, error) {
+	return []*tuple{{recs: []map[string]any{}}}, nil
+}
+
+func (n *singleRowNode) explain() (string, []node) { return "SingleRow", nil }
+
+// scanNode decodes a table and yields one tuple per record.
+type scanNode struct {
+	table *TableRef
+	index int
+	width int
+}
+
+func (n *scanNode) explain() (string, []node) {
+	return fmt.Sprintf("Scan %q AS %s", n.table.Path, n.table.Alias), nil
+}
+
+func (n *scanNode) records(ctx *execContext) ([]map[string]any, error) {
+	if recs, ok := ctx.tables[n.table.Path]; ok {
+		return recs, nil
+	}
+	data, err := ctx.load(n.table.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading table %q: %v", n.table.Path, err)
+	}
+	var recs []map[string]any
+	switch v := data.(type) {
+	case []any:
+		for _, item := range v {
+			rec, ok := item.(map[string]any)
+			if !ok {
+				// scalars become single-column rows
+				rec = map[string]any{"value": item}
+			}
+			recs = append(recs, rec)
+		}
+	case map[string]any:
+		recs = []map[string]any{v}
+	case nil:
+	default:
+		recs = []map[string]any{{"value": v}}
+	}
+	ctx.tables[n.table.Path] = recs
+	return recs, nil
+}
+
+func (n *scanNode) run(ctx *execContext) ([]*tuple, error) {
+	recs, err := n.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*tuple, len(recs))
+	for i, rec := range recs {
+		t := &tuple{recs: make([]map[string]any, n.width)}
+		t.recs[n.index] = rec
+		out[i] = t
+	}
+	return out, nil
+}
+
+// joinNode combines its input with another table. Equality conditions between
+// the new table and earlier ones are executed as a hash join.
+type joinNode struct {
+	left  node
+	right *scanNode
+	kind  JoinKind
+	on    Expr
+	ev    *evaluator
+	index int
+
+	// hash join keys; nil when the condition is not a simple equality
+	leftKey, rightKey Expr
+}
+
+func (n *joinNode) explain() (string, []node) {
+	label := map[JoinKind]string{InnerJoin: "Join", LeftJoin: "LeftJoin", CrossJoin: "CrossJoin"}[n.kind]
+	switch {
+	case n.leftKey != nil:
+		return fmt.Sprintf("Hash%s ON %s = %s", label, n.leftKey, n.rightKey), []node{n.left, n.right}
+	case n.on != nil:
+		return fmt.Sprintf("NestedLoop%s ON %s", label, n.on), []node{n.left, n.right}
+	default:
+		return label, []node{n.left, n.right}
+	}
+}
+
+// planHash detects `a = b` where one side only references the joined table.
+func (n *joinNode) planHash() {
+	b, ok := n.on.(*Binary)
+	if !ok || b.Op != "=" {
+		return
+	}
+	lRight, lOther := n.references(b.L)
+	rRight, rOther := n.references(b.R)
+	switch {
+	case rRight && !rOther && lOther && !lRight:
+		n.leftKey, n.rightKey = b.L, b.R
+	case lRight && !lOther && rOther && !rRight:
+		n.leftKey, n.rightKey = b.R, b.L
+	}
+}
+
+// references reports whether e refers to the joined table and/or to earlier
+// tables. Unqualified columns are ambiguous and disable the hash join.
+func (n *joinNode) references(e Expr) (right, other bool) {
+	rewrite(e, func(x Expr) Expr {
+		ref, ok := x.(*ColumnRef)
+		if !ok {
+			return x
+		}
+		qualified := false
+		if len(ref.Parts) > 1 {
+			for i, alias := range n.ev.aliases {
+				if alias == ref.Parts[0] {
+					qualified = true
+					if i == n.index {
+						right = true
+					} else {
+						other = true
+					}
+				}
+			}
+		}
+		if !qualified {
+			right, other = true, true
+		}
+		return x
+	})
+	return right, other
+}
+
+func (n *joinNode) run(ctx *execContext) ([]*tuple, error) {
+	left, err := n.left.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var index map[string][]map[string]any
+	if n.leftKey != nil {
+		index = make(map[string][]map[string]any)
+		probe := &tuple{recs: make([]map[string]any, n.right.width)}
+		for _, rec := range right {
+			probe.recs[n.index] = rec
+			k, err := n.ev.eval(n.rightKey, probe)
+			if err != nil {
+				return nil, err
+			}
+			if k == nil {
+				continue // NULL never matches
+			}
+			index[hashKey(k)] = append(index[hashKey(k)], rec)
+		}
+	}
+
+	var out []*tuple
+	for _, lt := range left {
+		candidates := right
+		if index != nil {
+			k, err := n.ev.eval(n.leftKey, lt)
+			if err != nil {
+				return nil, err
+			}
+			candidates = nil
+			if k != nil {
+				candidates = index[hashKey(k)]
+			}
+		}
+
+		matched := false
+		for _, rec := range candidates {
+			t := &tuple{recs: append([]map[string]any{}, lt.recs...)}
+			t.recs[n.index] = rec
+			if n.on != nil && index == nil {
+				ok, err := n.ev.eval(n.on, t)
+				if err != nil {
+					return nil, err
+				}
+				if !truthy(ok) {
+					continue
+				}
+			}
+			matched = true
+			out = append(out, t)
+		}
+		if !matched && n.kind == LeftJoin {
+			out = append(out, &tuple{recs: append([]map[string]any{}, lt.recs...)})
+		}
+	}
+	return out, nil
+}
+
+// filterNode keeps tuples for which the predicate is true (WHERE and HAVING).
+type filterNode struct {
+	input node
+	pred  Expr
+	ev    *evaluator
+	label string
+}
+
+func (n *filterNode) explain() (string, []node) {
+	return fmt.Sprintf("%s %s", n.label, n.pred), []node{n.input}
+}
+
+func (n *filterNode) run(ctx *execContext) ([]*tuple, error) {
+	in, err := n.input.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := in[:0]
+	for _, t := range in {
+		v, err := n.ev.eval(n.pred, t)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(v) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// groupNode collects tuples into groups by key. Without keys every tuple forms
+// a single group, which is still produced when the input is empty.
+type groupNode struct {
+	input node
+	keys  []Expr
+	ev    *evaluator
+}
+
+func (n *groupNode) explain() (string, []node) {
+	if len(n.keys) == 0 {
+		return "Aggregate", []node{n.input}
+	}
+	keys := make([]string, len(n.keys))
+	for i, k := range n.keys {
+		keys[i] = k.String()
+	}
+	return "GroupBy " + strings.Join(keys, ", "), []node{n.input}
+}
+
+func (n *groupNode) run(ctx *execContext) ([]*tuple, error) {
+	in, err := n.input.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(n.keys) == 0 {
+		g := &tuple{group: in}
+		if g.group == nil {
+			g.group = []*tuple{}
+		}
+		if len(in) > 0 {
+			g.recs = in[0].recs
+		}
+		return []*tuple{g}, nil
+	}
+
+	var groups []*tuple
+	byKey := make(map[string]*tuple)
+	for _, t := range in {
+		var b strings.Builder
+		for _, k := range n.keys {
+			v, err := n.ev.eval(k, t)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(hashKey(v))
+			b.WriteByte(0)
+		}
+		key := b.String()
+		g, ok := byKey[key]
+		if !ok {
+			g = &tuple{recs: t.recs, group: []*tuple{}}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.group = append(g.group, t)
+	}
+	return groups, nil
+}
+
+type sortKey struct {
+	expr Expr
+	desc bool
+}
+
+// sortNode orders tuples; keys are evaluated once per tuple.
+type sortNode struct {
+	input node
+	keys  []sortKey
+	ev    *evaluator
+}
+
+func (n *sortNode) explain() (string, []node) {
+	keys := make([]string, len(n.keys))
+	for i, k := range n.keys {
+		keys[i] = k.expr.String()
+		if k.desc {
+			keys[i] += " DESC"
+		}
+	}
+	return "Sort " + strings.Join(keys, ", "), []node{n.input}
+}
+
+func (n *sortNode) run(ctx *execContext) ([]*tuple, error) {
+	in, err := n.input.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]any, len(in))
+	for i, t := range in {
+		values[i] = make([]any, len(n.keys))
+		for j, k := range n.keys {
+			v, err := n.ev.eval(k.expr, t)
+			if err != nil {
+				return nil, err
+			}
+			values[i][j] = v
+		}
+	}
+	idx := make([]int, len(in))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		for j, k := range n.keys {
+			c := compare(values[idx[a]][j], values[idx[b]][j])
+			if c == 0 {
+				continue
+			}
+			if k.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	out := make([]*tuple, len(in))
+	for i, j := range idx {
+		out[i] = in[j]
+	}
+	return out, nil
+}
+
+// projectNode evaluates the select list into an output object per tuple.
+type projectNode struct {
+	input node
+	items []SelectItem
+	ev    *evaluator
+}
+
+func (n *projectNode) explain() (string, []node) {
+	cols := make([]string, len(n.items))
+	for i, item := range n.items {
+		switch {
+		case item.Star && item.Table != "":
+			cols[i] = item.Table + ".*"
+		case item.Star:
+			cols[i] = "*"
+		case item.Alias != "":
+			cols[i] = fmt.Sprintf("%s AS %s", item.Expr, item.Alias)
+		default:
+			cols[i] = item.Expr.String()
+		}
+	}
+	return "Project " + strings.Join(cols, ", "), []node{n.input}
+}
+
+// columnName is the output key for a non-wildcard select item.
+func columnName(item SelectItem) string {
+	if item.Alias != "" {
+		return item.Alias
+	}
+	if ref, ok := item.Expr.(*ColumnRef); ok {
+		return ref.Parts[len(ref.Parts)-1]
+	}
+	return item.Expr.String()
+}
+
+func (n *projectNode) columnNames() []string {
+	var cols []string
+	for _, item := range n.items {
+		if item.Star {
+			return nil
+		}
+		cols = append(cols, columnName(item))
+	}
+	return cols
+}
+
+func (n *projectNode) run(ctx *execContext) ([]*tuple, error) {
+	in, err := n.input.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range in {
+		row := make(map[string]any)
+		for _, item := range n.items {
+			if item.Star {
+				n.expandStar(item, t, row)
+				continue
+			}
+			v, err := n.ev.eval(item.Expr, t)
+			if err != nil {
+				return nil, err
+			}
+			name := columnName(item)
+			if _, taken := row[name]; taken {
+				name = item.Expr.String()
+			}
+			row[name] = v
+		}
+		t.out = []any{row}
+	}
+	return in, nil
+}
+
+// expandStar copies every field of the selected tables into row. When joined
+// tables share a column name, later occurrences are prefixed with the alias.
+func (n *projectNode) expandStar(item SelectItem, t *tuple, row map[string]any) {
+	recs := t.recs
+	if recs == nil && len(t.group) > 0 {
+		recs = t.group[0].recs
+	}
+	for i, rec := range recs {
+		alias := n.ev.aliases[i]
+		if item.Table != "" && item.Table != alias {
+			continue
+		}
+		for k, v := range rec {
+			if _, taken := row[k]; taken {
+				row[alias+"."+k] = v
+			} else {
+				row[k] = v
+			}
+		}
+	}
+}
+
+// distinctNode removes duplicate output rows.
+type distinctNode struct {
+	input node
+}
+
+func (n *distinctNode) explain() (string, []node) { return "Distinct", []node{n.input} }
+
+func (n *distinctNode) run(ctx *execContext) ([]*tuple, error) {
+	in, err := n.input.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	out := in[:0]
+	for _, t := range in {
+		k := hashKey(t.out[0])
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// limitNode applies LIMIT and OFFSET; a negative limit means unbounded.
+type limitNode struct {
+	input  node
+	limit  int
+	offset int
+}
+
+func (n *limitNode) explain() (string, []node) {
+	return fmt.Sprintf("Limit %d Offset %d", n.limit, n.offset), []node{n.input}
+}
+
+func (n *limitNode) run(ctx *execContext) ([]*tuple, error) {
+	in, err := n.input.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if n.offset >= len(in) {
+		return nil, nil
+	}
+	in = in[n.offset:]
+	if n.limit >= 0 && n.limit < len(in) {
+		in = in[:n.limit]
+	}
+	return in, nil
+}
@@ -0,0 +1,183 @@
+package sql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testTables = map[string]any{
+	"sales.csv": []any{
+		map[string]any{"id": 1, "rid": 1, "amount": 10.5, "item": "apple"},
+		map[string]any{"id": 2, "rid": 2, "amount": 20, "item": "pear"},
+		map[string]any{"id": 3, "rid": 1, "amount": 5, "item": "plum"},
+		map[string]any{"id": 4, "rid": 3, "amount": 7, "item": "fig"},
+	},
+	"regions.json": []any{
+		map[string]any{"id": 1, "region": "north", "meta": map[string]any{"manager": "ann"}},
+		map[string]any{"id": 2, "region": "south", "meta": map[string]any{"manager": "bob"}},
+	},
+	"config.yaml": map[string]any{"name": "qq", "version": 2},
+}
+
+func runQuery(t *testing.T, query string) []any {
+	t.Helper()
+	plan, err := Compile(query)
+	if err != nil {
+		t.Fatalf("Compile(%q) error: %v", query, err)
+	}
+	rows, err := plan.Run(func(path string) (any, error) {
+		if v, ok := testTables[path]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("no such table")
+	})
+	if err != nil {
+		t.Fatalf("Run(%q) error: %v", query, err)
+	}
+	return rows
+}
+
+func TestQueries(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []any
+	}{
+		{
+			"SELECT region, sum(amount) AS total FROM 'sales.csv' s JOIN 'regions.json' r ON s.rid = r.id GROUP BY region ORDER BY 2 DESC",
+			[]any{
+				map[string]any{"region": "south", "total": 20},
+				map[string]any{"region": "north", "total": 15.5},
+			},
+		},
+		{
+			"SELECT item, r.meta.manager FROM 'sales.csv' s LEFT JOIN 'regions.json' r ON s.rid = r.id WHERE amount < 10 ORDER BY item",
+			[]any{
+				map[string]any{"item": "fig", "manager": nil},
+				map[string]any{"item": "plum", "manager": "ann"},
+			},
+		},
+		{
+			"SELECT rid, count(*) n FROM 'sales.csv' GROUP BY rid HAVING n > 1",
+			[]any{map[string]any{"rid": 1, "n": 2}},
+		},
+		{
+			"SELECT item FROM 'sales.csv' ORDER BY amount DESC LIMIT 2 OFFSET 1",
+			[]any{map[string]any{"item": "apple"}, map[string]any{"item": "fig"}},
+		},
+		{
+			"SELECT DISTINCT rid FROM 'sales.csv' WHERE rid IN (1, 3) ORDER BY rid",
+			[]any{map[string]any{"rid": 1}, map[string]any{"rid": 3}},
+		},
+		{
+			"SELECT count(*) AS n, max(amount) AS m FROM 'sales.csv' WHERE item LIKE 'zz%'",
+			[]any{map[string]any{"n": 0, "m": nil}},
+		},
+		{
+			"SELECT name, version + 1 AS next FROM 'config.yaml'",
+			[]any{map[string]any{"name": "qq", "next": 3}},
+		},
+		{
+			"SELECT upper(item) AS u, CASE WHEN amount >= 10 THEN 'big' ELSE 'small' END AS size FROM 'sales.csv' WHERE id BETWEEN 2 AND 3",
+			[]any{
+				map[string]any{"u": "PEAR", "size": "big"},
+				map[string]any{"u": "PLUM", "size": "small"},
+			},
+		},
+		{
+			"SELECT 1 + 2 * 3 AS x, 'a' || 'b' AS s",
+			[]any{map[string]any{"x": 7, "s": "ab"}},
+		},
+	}
+	for _, tt := range tests {
+		got := runQuery(t, tt.query)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s\n got %#v\nwant %#v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSelectStarJoin(t *testing.T) {
+	rows := runQuery(t, "SELECT * FROM 'sales.csv' s JOIN 'regions.json' r ON s.rid = r.id WHERE s.id = 2")
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	row := rows[0].(map[string]any)
+	if row["id"] != 2 || row["r.id"] != 2 || row["region"] != "south" {
+		t.Errorf("unexpected row %v", row)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	plan, err := Compile("SELECT * FROM 'a.json' a JOIN 'b.json' b ON a.x = b.y JOIN 'c.json' c ON a.x < c.z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := plan.String()
+	for _, want := range []string{"HashJoin ON a.x = b.y", "NestedLoopJoin ON a.x < c.z", `Scan "c.json" AS c`} {
+		if !strings.Contains(s, want) {
+			t.Errorf("plan missing %q:\n%s", want, s)
+		}
+	}
+}
+
+func TestUnknownColumns(t *testing.T) {
+	load := func(path string) (any, error) {
+		switch path {
+		case "empty.json":
+			return []any{}, nil
+		case "sparse.json":
+			return []any{map[string]any{"a": 1}, map[string]any{"b": 2}}, nil
+		}
+		return testTables[path], nil
+	}
+	for query, msg := range map[string]string{
+		"SELECT nmae FROM 'sales.csv'":                                             "unknown column nmae",
+		"SELECT item FROM 'sales.csv' WHERE amont > 1":                             "unknown column amont",
+		"SELECT s.region FROM 'sales.csv' s JOIN 'regions.json' r ON s.rid = r.id": "s has no column region",
+		"SELECT item FROM 'sales.csv' s JOIN 'regions.json' r ON s.rid = r.idd":    "unknown column r.idd",
+		"SELECT item FROM 'sales.csv' ORDER BY price":                              "unknown column price",
+		"SELECT x": "unknown column x",
+	} {
+		plan, err := Compile(query)
+		if err != nil {
+			t.Fatalf("Compile(%q) error: %v", query, err)
+		}
+		if _, err := plan.Run(load); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: got error %v, want %q", query, err, msg)
+		}
+	}
+
+	// columns some rows leave out, nested fields and columns of empty
+	// tables are known
+	for _, query := range []string{
+		"SELECT a, b FROM 'sparse.json'",
+		"SELECT r.meta.manager, meta.manager FROM 'regions.json' r",
+		"SELECT anything FROM 'empty.json'",
+	} {
+		plan, err := Compile(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := plan.Run(load); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, query := range []string{
+		"SELECT",
+		"SELECT a FROM",
+		"SELECT a FROM 'x.json' WHERE count(*) > 1",
+		"SELECT a FROM 'x.json' LIMIT -1",
+		"SELECT a FROM 'x.json' ORDER BY 3",
+		"SELECT a FROM 'x.json' t JOIN 'y.json' t ON t.a = t.b",
+		"SELECT 'unterminated",
+	} {
+		if _, err := Compile(query); err == nil {
+			t.Errorf("expected error for %q", query)
+		}
+	}
+}
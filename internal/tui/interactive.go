@@ -37,11 +37,13 @@ type model struct {
 	jsonObj        any
 	viewport       viewport.Model
 	gracefulExit   bool
+	compilerOpts   []gojq.CompilerOption
 }
 
-func newModel(data string) model {
+func newModel(data string, opts []gojq.CompilerOption) model {
 	m := model{
-		viewport: viewport.New(0, 0),
+		viewport:     viewport.New(0, 0),
+		compilerOpts: opts,
 	}
 
 	t := textarea.New()
@@ -225,6 +227,12 @@ func (m *model) runJqFilter() {
 		m.updateViewportContent()
 		return
 	}
+	code, err := gojq.Compile(query, m.compilerOpts...)
+	if err != nil {
+		m.jqOutput = fmt.Sprintf("Invalid jq query: %s\n\nLast valid output:\n%s", err, m.lastOutput)
+		m.updateViewportContent()
+		return
+	}
 
 	var jsonData any
 	err = json.Unmarshal([]byte(m.jsonInput), &jsonData)
@@ -234,7 +242,7 @@ func (m *model) runJqFilter() {
 		return
 	}
 
-	iter := query.Run(jsonData)
+	iter := code.Run(jsonData)
 	var result []string
 	isNull := true
 	for {
@@ -331,8 +339,10 @@ func printOutput(m model) {
 	}
 }
 
-func Interact(s string) {
-	m, err := tea.NewProgram(newModel(s), tea.WithAltScreen()).Run()
+// Interact runs the interactive query editor over s. opts are passed to the jq
+// compiler, e.g. to register additional builtins.
+func Interact(s string, opts ...gojq.CompilerOption) {
+	m, err := tea.NewProgram(newModel(s, opts), tea.WithAltScreen()).Run()
 	if err != nil {
 		fmt.Println("Error running program:", err)
 		os.Exit(1)
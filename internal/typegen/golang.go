@@ -0,0 +1,103 @@
+package typegen
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+func generateGo(root *Type, objects []*Type, opts Options) (string, error) {
+	var b strings.Builder
+	usesTime := false
+
+	typeOf := func(t *Type) string {
+		s := goType(t)
+		if strings.Contains(s, "time.Time") {
+			usesTime = true
+		}
+		return s
+	}
+
+	if root.Kind != Object {
+		fmt.Fprintf(&b, "type %s %s\n\n", opts.RootName, typeOf(root))
+	}
+
+	for _, obj := range objects {
+		fmt.Fprintf(&b, "type %s struct {\n", obj.Name)
+		used := make(map[string]bool)
+		for _, f := range obj.Fields {
+			name := pascalCase(f.Key, true)
+			if name == "" {
+				name = "Field"
+			}
+			name = uniqueName(name, used)
+			if !tagSafe(f.Key) {
+				// keep the field, but leave it out of (un)marshaling rather
+				// than emit a tag that does not compile or names another key
+				fmt.Fprintf(&b, "\t// key %q cannot be written in a struct tag\n", f.Key)
+				fmt.Fprintf(&b, "\t%s %s `%s:\"-\"`\n", name, typeOf(f.Type), opts.Tag)
+				continue
+			}
+			tag := f.Key
+			if f.Optional {
+				tag += ",omitempty"
+			}
+			typ := typeOf(f.Type)
+			if f.Optional && f.Type.Kind == Object && !f.Type.Nullable {
+				// omitempty has no effect on struct values
+				typ = "*" + typ
+			}
+			fmt.Fprintf(&b, "\t%s %s `%s:%q`\n", name, typ, opts.Tag, tag)
+		}
+		b.WriteString("}\n\n")
+	}
+
+	var src strings.Builder
+	if opts.Package != "" {
+		fmt.Fprintf(&src, "package %s\n\n", opts.Package)
+		if usesTime {
+			src.WriteString("import \"time\"\n\n")
+		}
+	}
+	src.WriteString(b.String())
+
+	formatted, err := format.Source([]byte(src.String()))
+	if err != nil {
+		return "", fmt.Errorf("error formatting generated Go code: %v", err)
+	}
+	return strings.TrimSpace(string(formatted)), nil
+}
+
+// tagSafe reports whether key can be a struct tag name as written: tags
+// sit in a raw string, so a backtick ends them, a comma starts the options
+// and escaped characters are not unquoted by every decoder.
+func tagSafe(key string) bool {
+	return key != "" && !strings.ContainsAny(key, "`,") && strconv.Quote(key) == `"`+key+`"`
+}
+
+func goType(t *Type) string {
+	var s string
+	switch t.Kind {
+	case Bool:
+		s = "bool"
+	case Int:
+		s = "int64"
+	case Float:
+		s = "float64"
+	case String:
+		s = "string"
+	case Time:
+		s = "time.Time"
+	case Array:
+		return "[]" + goType(t.Elem)
+	case Object:
+		s = t.Name
+	default:
+		return "any"
+	}
+	if t.Nullable {
+		return "*" + s
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package typegen
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Kind is the inferred shape of a value.
+type Kind int
+
+const (
+	Unknown Kind = iota // only null (or nothing) has been observed
+	Bool
+	Int
+	Float
+	String
+	Time
+	Array
+	Object
+	Mixed // incompatible kinds were observed at the same path
+)
+
+// Type describes the merged structure of every value observed at one path.
+type Type struct {
+	Kind     Kind
+	Nullable bool
+	Elem     *Type    // element type for arrays
+	Fields   []*Field // object fields, sorted by key
+	Name     string   // generated type name for objects
+}
+
+// Field is a single object member.
+type Field struct {
+	Key      string
+	Type     *Type
+	Optional bool // missing from at least one of the merged objects
+}
+
+// Infer walks a decoded document and returns its structural type. Objects found
+// in the same array are merged, so fields that only appear in some elements are
+// marked optional rather than dropped.
+func Infer(v any) *Type {
+	switch val := v.(type) {
+	case nil:
+		return &Type{Kind: Unknown, Nullable: true}
+	case bool:
+		return &Type{Kind: Bool}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, *big.Int:
+		return &Type{Kind: Int}
+	case float32:
+		return inferFloat(float64(val))
+	case float64:
+		return inferFloat(val)
+	case json.Number:
+		// as written: decimals keep their point even when whole
+		if strings.ContainsAny(val.String(), ".eE") {
+			return &Type{Kind: Float}
+		}
+		return &Type{Kind: Int}
+	case time.Time:
+		return &Type{Kind: Time}
+	case string:
+		if _, err := time.Parse(time.RFC3339, val); err == nil {
+			return &Type{Kind: Time}
+		}
+		return &Type{Kind: String}
+	case []any:
+		t := &Type{Kind: Array}
+		for _, item := range val {
+			t.Elem = merge(t.Elem, Infer(item))
+		}
+		if t.Elem == nil {
+			t.Elem = &Type{Kind: Unknown}
+		}
+		return t
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		t := &Type{Kind: Object}
+		for _, k := range keys {
+			t.Fields = append(t.Fields, &Field{Key: k, Type: Infer(val[k])})
+		}
+		return t
+	default:
+		return &Type{Kind: Mixed}
+	}
+}
+
+// inferFloat treats whole numbers as integers, since JSON decoding yields
+// float64 for every number regardless of how it was written.
+func inferFloat(f float64) *Type {
+	if f == math.Trunc(f) && !math.IsInf(f, 0) && math.Abs(f) < 1<<53 {
+		return &Type{Kind: Int}
+	}
+	return &Type{Kind: Float}
+}
+
+// merge combines two observations of the same path into one type.
+func merge(a, b *Type) *Type {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	nullable := a.Nullable || b.Nullable
+
+	switch {
+	case a.Kind == Unknown:
+		out := *b
+		out.Nullable = nullable
+		return &out
+	case b.Kind == Unknown:
+		out := *a
+		out.Nullable = nullable
+		return &out
+	case a.Kind == b.Kind:
+		switch a.Kind {
+		case Array:
+			return &Type{Kind: Array, Nullable: nullable, Elem: merge(a.Elem, b.Elem)}
+		case Object:
+			return &Type{Kind: Object, Nullable: nullable, Fields: mergeFields(a.Fields, b.Fields)}
+		default:
+			return &Type{Kind: a.Kind, Nullable: nullable}
+		}
+	case isNumber(a.Kind) && isNumber(b.Kind):
+		return &Type{Kind: Float, Nullable: nullable}
+	case isText(a.Kind) && isText(b.Kind):
+		return &Type{Kind: String, Nullable: nullable}
+	default:
+		return &Type{Kind: Mixed, Nullable: nullable}
+	}
+}
+
+func mergeFields(a, b []*Field) []*Field {
+	byKey := make(map[string]*Field, len(a)+len(b))
+	seen := make(map[string]int, len(a)+len(b))
+	for _, f := range a {
+		byKey[f.Key] = &Field{Key: f.Key, Type: f.Type, Optional: f.Optional}
+		seen[f.Key]++
+	}
+	for _, f := range b {
+		if existing, ok := byKey[f.Key]; ok {
+			existing.Type = merge(existing.Type, f.Type)
+			existing.Optional = existing.Optional || f.Optional
+		} else {
+			byKey[f.Key] = &Field{Key: f.Key, Type: f.Type, Optional: f.Optional}
+		}
+		seen[f.Key]++
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]*Field, 0, len(keys))
+	for _, k := range keys {
+		f := byKey[k]
+		if seen[k] < 2 {
+			f.Optional = true
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func isNumber(k Kind) bool { return k == Int || k == Float }
+func isText(k Kind) bool   { return k == String || k == Time }
@@ -0,0 +1,133 @@
+package typegen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true,
+	"async": true, "await": true, "break": true, "class": true, "continue": true,
+	"def": true, "del": true, "elif": true, "else": true, "except": true, "finally": true,
+	"for": true, "from": true, "global": true, "if": true, "import": true, "in": true,
+	"is": true, "lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+func generatePython(root *Type, objects []*Type, opts Options) (string, error) {
+	typing := make(map[string]bool)
+	usesDatetime := false
+	usesField := false
+
+	var typeOf func(t *Type) string
+	typeOf = func(t *Type) string {
+		var s string
+		switch t.Kind {
+		case Bool:
+			s = "bool"
+		case Int:
+			s = "int"
+		case Float:
+			s = "float"
+		case String:
+			s = "str"
+		case Time:
+			usesDatetime = true
+			s = "datetime"
+		case Array:
+			typing["List"] = true
+			s = "List[" + typeOf(t.Elem) + "]"
+		case Object:
+			s = t.Name
+		default:
+			typing["Any"] = true
+			return "Any"
+		}
+		if t.Nullable {
+			typing["Optional"] = true
+			return "Optional[" + s + "]"
+		}
+		return s
+	}
+
+	// Dataclasses must be declared before they are referenced, so children come
+	// first. Fields with defaults must follow fields without them.
+	var classes []string
+	for i := len(objects) - 1; i >= 0; i-- {
+		obj := objects[i]
+		var required, optional []string
+		used := make(map[string]bool)
+		for _, f := range obj.Fields {
+			name := snakeCase(f.Key)
+			if pythonKeywords[name] {
+				name += "_"
+			}
+			name = uniqueName(name, used)
+
+			hint := typeOf(f.Type)
+			var line string
+			switch {
+			case f.Optional && name != f.Key:
+				usesField = true
+				if !strings.HasPrefix(hint, "Optional[") {
+					typing["Optional"] = true
+					hint = "Optional[" + hint + "]"
+				}
+				line = fmt.Sprintf("%s: %s = field(default=None, metadata={%q: %s})", name, hint, opts.Tag, strconv.Quote(f.Key))
+			case f.Optional:
+				if !strings.HasPrefix(hint, "Optional[") {
+					typing["Optional"] = true
+					hint = "Optional[" + hint + "]"
+				}
+				line = fmt.Sprintf("%s: %s = None", name, hint)
+			case name != f.Key:
+				usesField = true
+				line = fmt.Sprintf("%s: %s = field(metadata={%q: %s})", name, hint, opts.Tag, strconv.Quote(f.Key))
+			default:
+				line = fmt.Sprintf("%s: %s", name, hint)
+			}
+			if f.Optional {
+				optional = append(optional, line)
+			} else {
+				required = append(required, line)
+			}
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "@dataclass\nclass %s:\n", obj.Name)
+		lines := append(required, optional...)
+		if len(lines) == 0 {
+			b.WriteString("    pass\n")
+		}
+		for _, line := range lines {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+		classes = append(classes, strings.TrimRight(b.String(), "\n"))
+	}
+
+	if root.Kind != Object {
+		classes = append(classes, fmt.Sprintf("%s = %s", opts.RootName, typeOf(root)))
+	}
+
+	var header []string
+	if usesField {
+		header = append(header, "from dataclasses import dataclass, field")
+	} else {
+		header = append(header, "from dataclasses import dataclass")
+	}
+	if usesDatetime {
+		header = append(header, "from datetime import datetime")
+	}
+	var names []string
+	for _, name := range []string{"Any", "List", "Optional"} {
+		if typing[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		header = append(header, "from typing import "+strings.Join(names, ", "))
+	}
+
+	return strings.Join(header, "\n") + "\n\n\n" + strings.Join(classes, "\n\n\n"), nil
+}
@@ -0,0 +1,103 @@
+package typegen
+
+import (
+	"fmt"
+	"strings"
+)
+
+var rustKeywords = map[string]bool{
+	"as": true, "async": true, "await": true, "break": true, "const": true, "continue": true,
+	"crate": true, "dyn": true, "else": true, "enum": true, "extern": true, "false": true,
+	"fn": true, "for": true, "if": true, "impl": true, "in": true, "let": true, "loop": true,
+	"match": true, "mod": true, "move": true, "mut": true, "pub": true, "ref": true,
+	"return": true, "self": true, "static": true, "struct": true, "super": true,
+	"trait": true, "true": true, "type": true, "unsafe": true, "use": true, "where": true,
+	"while": true, "abstract": true, "become": true, "box": true, "do": true, "final": true,
+	"macro": true, "override": true, "priv": true, "try": true, "typeof": true,
+	"unsized": true, "virtual": true, "yield": true,
+}
+
+// rustNoRaw holds the keywords that cannot be raw identifiers, which are
+// given a trailing underscore instead.
+var rustNoRaw = map[string]bool{"crate": true, "self": true, "Self": true, "super": true}
+
+// rustValueTypes maps the source format to the dynamic value type of its serde crate.
+var rustValueTypes = map[string]string{
+	"json": "serde_json::Value",
+	"yaml": "serde_yaml::Value",
+	"toml": "toml::Value",
+}
+
+func generateRust(root *Type, objects []*Type, opts Options) (string, error) {
+	valueType, ok := rustValueTypes[opts.Tag]
+	if !ok {
+		valueType = rustValueTypes["json"]
+	}
+
+	var typeOf func(t *Type) string
+	typeOf = func(t *Type) string {
+		var s string
+		switch t.Kind {
+		case Bool:
+			s = "bool"
+		case Int:
+			s = "i64"
+		case Float:
+			s = "f64"
+		case String, Time:
+			s = "String"
+		case Array:
+			s = "Vec<" + typeOf(t.Elem) + ">"
+		case Object:
+			s = t.Name
+		default:
+			return valueType
+		}
+		if t.Nullable {
+			return "Option<" + s + ">"
+		}
+		return s
+	}
+
+	decls := []string{"use serde::{Deserialize, Serialize};"}
+	if root.Kind != Object {
+		decls = append(decls, fmt.Sprintf("pub type %s = %s;", opts.RootName, typeOf(root)))
+	}
+
+	for _, obj := range objects {
+		var b strings.Builder
+		fmt.Fprintf(&b, "#[derive(Debug, Clone, Serialize, Deserialize)]\npub struct %s {\n", obj.Name)
+		used := make(map[string]bool)
+		for _, f := range obj.Fields {
+			name := snakeCase(f.Key)
+			if rustNoRaw[name] {
+				name += "_"
+			}
+			name = uniqueName(name, used)
+			ident := name
+			if rustKeywords[name] {
+				ident = "r#" + name
+			}
+
+			typ := typeOf(f.Type)
+			var attrs []string
+			if name != f.Key {
+				attrs = append(attrs, fmt.Sprintf("rename = %q", f.Key))
+			}
+			if f.Optional {
+				if !strings.HasPrefix(typ, "Option<") {
+					typ = "Option<" + typ + ">"
+				}
+				attrs = append(attrs, "default", `skip_serializing_if = "Option::is_none"`)
+			}
+			if len(attrs) > 0 {
+				fmt.Fprintf(&b, "    #[serde(%s)]\n", strings.Join(attrs, ", "))
+			}
+			fmt.Fprintf(&b, "    pub %s: %s,\n", ident, typ)
+		}
+		b.WriteString("}")
+		decls = append(decls, b.String())
+	}
+
+	return strings.Join(decls, "\n\n"), nil
+}
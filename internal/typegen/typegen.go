@@ -0,0 +1,186 @@
+// Package typegen generates type declarations (Go structs, TypeScript
+// interfaces, Python dataclasses, Rust serde structs) from sample documents.
+package typegen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Options controls code generation.
+type Options struct {
+	Lang     string // go, typescript, python-dataclass, rust-serde
+	RootName string // name of the top-level type
+	Tag      string // serialization format for tags: json, yaml or toml
+	Package  string // Go package clause; omitted when empty
+}
+
+// Languages lists the supported --lang values.
+var Languages = []string{"go", "typescript", "python-dataclass", "rust-serde"}
+
+// Generate infers the structure of v and renders it in the requested language.
+func Generate(v any, opts Options) (string, error) {
+	if opts.RootName == "" {
+		opts.RootName = "Root"
+	}
+	if opts.Tag == "" {
+		opts.Tag = "json"
+	}
+
+	root := Infer(v)
+	objects := assignNames(root, opts.RootName)
+
+	switch strings.ToLower(opts.Lang) {
+	case "", "go", "golang":
+		return generateGo(root, objects, opts)
+	case "typescript", "ts":
+		return generateTypeScript(root, objects, opts)
+	case "python-dataclass", "python", "py":
+		return generatePython(root, objects, opts)
+	case "rust-serde", "rust", "rs":
+		return generateRust(root, objects, opts)
+	default:
+		return "", fmt.Errorf("unsupported language: %s (supported: %s)", opts.Lang, strings.Join(Languages, ", "))
+	}
+}
+
+// assignNames gives every object type a unique name derived from its path and
+// returns the objects in declaration order (parents before children).
+func assignNames(root *Type, rootName string) []*Type {
+	var objects []*Type
+	used := map[string]bool{rootName: root.Kind != Object}
+
+	var walk func(t *Type, path []string)
+	walk = func(t *Type, path []string) {
+		switch t.Kind {
+		case Array:
+			walk(t.Elem, path)
+		case Object:
+			base := rootName
+			if len(path) > 0 {
+				base = pascalCase(strings.Join(path, " "), true)
+			}
+			if base == "" || (t != root && len(path) == 0) {
+				// elements of a top-level array leave the root name to the alias
+				base = rootName + "Item"
+			}
+			t.Name = uniqueName(base, used)
+			objects = append(objects, t)
+
+			for _, f := range t.Fields {
+				child := append(append([]string{}, path...), f.Key)
+				if f.Type.Kind == Array {
+					child[len(child)-1] = singular(f.Key)
+				}
+				walk(f.Type, child)
+			}
+		}
+	}
+	walk(root, nil)
+	return objects
+}
+
+// singular makes a best-effort English singular of an array field name so that
+// "items" produces an "Item" element type.
+func singular(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(s) > 4:
+		return s[:len(s)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "ches"):
+		return s[:len(s)-2]
+	case strings.HasSuffix(lower, "s") && len(s) > 3 &&
+		!strings.HasSuffix(lower, "ss") && !strings.HasSuffix(lower, "us") && !strings.HasSuffix(lower, "is"):
+		return s[:len(s)-1]
+	}
+	return s + "Item"
+}
+
+// commonInitialisms are kept upper-case in Go identifiers, as golint expects.
+var commonInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true,
+	"EOF": true, "GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "QPS": true, "RAM": true, "RPC": true, "SLA": true,
+	"SMTP": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true,
+	"UDP": true, "UI": true, "UID": true, "UUID": true, "URI": true, "URL": true,
+	"UTF8": true, "VM": true, "XML": true, "YAML": true, "TOML": true,
+}
+
+// splitWords breaks an arbitrary key into words on punctuation and camelCase
+// boundaries: "userID", "user_id" and "user-id" all become ["user", "ID"/"id"].
+func splitWords(s string) []string {
+	var words []string
+	var cur []rune
+	runes := []rune(s)
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = nil
+		}
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if len(cur) > 0 && unicode.IsUpper(r) {
+			prev := cur[len(cur)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return words
+}
+
+// pascalCase converts a key to an exported identifier. With initialisms set,
+// words such as "id" and "url" are rendered as "ID" and "URL".
+func pascalCase(s string, initialisms bool) string {
+	var b strings.Builder
+	for _, w := range splitWords(s) {
+		upper := strings.ToUpper(w)
+		if initialisms && commonInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(strings.ToLower(w))
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	out := b.String()
+	if out != "" && unicode.IsDigit([]rune(out)[0]) {
+		out = "X" + out
+	}
+	return out
+}
+
+// snakeCase converts a key to a lower_snake_case identifier.
+func snakeCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	out := strings.Join(words, "_")
+	if out == "" {
+		return "field"
+	}
+	if unicode.IsDigit([]rune(out)[0]) {
+		out = "f_" + out
+	}
+	return out
+}
+
+// uniqueName returns name, or name with a numeric suffix if it is already taken.
+func uniqueName(name string, used map[string]bool) string {
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	used[candidate] = true
+	return candidate
+}
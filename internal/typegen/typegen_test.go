@@ -0,0 +1,210 @@
+package typegen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func sample() any {
+	return map[string]any{
+		"apiVersion": "v1",
+		"items": []any{
+			map[string]any{
+				"metadata": map[string]any{"name": "a", "uid": "x"},
+				"count":    float64(1),
+				"status":   "Ready",
+			},
+			map[string]any{
+				"metadata": map[string]any{"name": "b"},
+				"count":    2.5,
+				"status":   nil,
+				"type":     "t",
+			},
+		},
+	}
+}
+
+func TestInferMergesArrayElements(t *testing.T) {
+	root := Infer(sample())
+	if root.Kind != Object {
+		t.Fatalf("expected object, got %v", root.Kind)
+	}
+
+	var items *Field
+	for _, f := range root.Fields {
+		if f.Key == "items" {
+			items = f
+		}
+	}
+	if items == nil || items.Type.Kind != Array || items.Type.Elem.Kind != Object {
+		t.Fatalf("expected items to be an array of objects, got %+v", items)
+	}
+
+	fields := map[string]*Field{}
+	for _, f := range items.Type.Elem.Fields {
+		fields[f.Key] = f
+	}
+	if fields["count"].Type.Kind != Float {
+		t.Errorf("expected int and float to merge into float, got %v", fields["count"].Type.Kind)
+	}
+	if !fields["status"].Type.Nullable || fields["status"].Type.Kind != String {
+		t.Errorf("expected status to be a nullable string, got %+v", fields["status"].Type)
+	}
+	if !fields["type"].Optional {
+		t.Error("expected type to be optional since it only appears in one element")
+	}
+	if fields["metadata"].Optional {
+		t.Error("expected metadata to be required")
+	}
+}
+
+func TestInferNumbers(t *testing.T) {
+	// decimals and large integers as the parquet, avro and textproto codecs
+	// read them
+	for v, want := range map[any]Kind{
+		json.Number("19.90"):                Float,
+		json.Number("1.00"):                 Float,
+		json.Number("9007199254740993"):     Int,
+		json.Number("18446744073709551615"): Int,
+	} {
+		if got := Infer(v).Kind; got != want {
+			t.Errorf("%v: got %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestGenerateGo(t *testing.T) {
+	out, err := Generate(sample(), Options{Lang: "go", Tag: "yaml"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, want := range []string{
+		"type Root struct",
+		"APIVersion string `yaml:\"apiVersion\"`",
+		"Items      []Item `yaml:\"items\"`",
+		"type ItemMetadata struct",
+		"UID  string `yaml:\"uid,omitempty\"`",
+		"Status   *string",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestGenerateGoUnsafeKeys(t *testing.T) {
+	v := map[string]any{"a`b": 1.0, `say "hi"`: "x", "a,b": true, "ok": "y"}
+	out, err := Generate(v, Options{Lang: "go", Package: "p"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, want := range []string{
+		"// key \"a`b\" cannot be written in a struct tag",
+		"// key \"say \\\"hi\\\"\" cannot be written in a struct tag",
+		"// key \"a,b\" cannot be written in a struct tag",
+		"`json:\"-\"`",
+		"Ok  string `json:\"ok\"`",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	out, err := Generate(map[string]any{"user-id": 1.0, "tags": []any{"a"}}, Options{Lang: "typescript"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, want := range []string{`"user-id": number;`, "tags: string[];"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestGeneratePythonDataclass(t *testing.T) {
+	out, err := Generate(sample(), Options{Lang: "python-dataclass"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	// Nested classes must be declared before the classes that use them.
+	if strings.Index(out, "class ItemMetadata:") > strings.Index(out, "class Root:") {
+		t.Errorf("expected nested classes before root, got:\n%s", out)
+	}
+	if !strings.Contains(out, `api_version: str = field(metadata={"json": "apiVersion"})`) {
+		t.Errorf("expected renamed field metadata, got:\n%s", out)
+	}
+	if !strings.Contains(out, "type: Optional[str] = None") {
+		t.Errorf("expected optional field with default, got:\n%s", out)
+	}
+}
+
+func TestGenerateRustSerde(t *testing.T) {
+	out, err := Generate(sample(), Options{Lang: "rust-serde"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, want := range []string{
+		"#[serde(rename = \"apiVersion\")]",
+		"pub r#type: Option<String>,",
+		"pub items: Vec<Item>,",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestGenerateRustReservedNames(t *testing.T) {
+	out, err := Generate(map[string]any{"self": 1.0, "Self": "x", "super": true, "crate": nil}, Options{Lang: "rust-serde"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, want := range []string{
+		"#[serde(rename = \"Self\")]\n    pub self_: String,",
+		"#[serde(rename = \"self\")]\n    pub self_2: i64,",
+		"#[serde(rename = \"super\")]\n    pub super_: bool,",
+		"#[serde(rename = \"crate\")]\n    pub crate_:",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "r#") {
+		t.Errorf("expected no raw identifiers, got:\n%s", out)
+	}
+}
+
+func TestGenerateRootArray(t *testing.T) {
+	out, err := Generate([]any{map[string]any{"id": 1.0}}, Options{Lang: "go", RootName: "Event"})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !strings.Contains(out, "type Event []EventItem") || !strings.Contains(out, "type EventItem struct") {
+		t.Errorf("unexpected output for root array:\n%s", out)
+	}
+}
+
+func TestGenerateUnsupportedLanguage(t *testing.T) {
+	if _, err := Generate(map[string]any{}, Options{Lang: "cobol"}); err == nil {
+		t.Error("expected error for unsupported language")
+	}
+}
+
+func TestPascalCase(t *testing.T) {
+	tests := map[string]string{
+		"user_id":    "UserID",
+		"apiVersion": "APIVersion",
+		"HTTPServer": "HTTPServer",
+		"first-name": "FirstName",
+		"2fa":        "X2fa",
+	}
+	for in, want := range tests {
+		if got := pascalCase(in, true); got != want {
+			t.Errorf("pascalCase(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
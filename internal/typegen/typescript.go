@@ -0,0 +1,64 @@
+package typegen
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func generateTypeScript(root *Type, objects []*Type, opts Options) (string, error) {
+	var decls []string
+
+	if root.Kind != Object {
+		decls = append(decls, fmt.Sprintf("export type %s = %s;", opts.RootName, tsType(root)))
+	}
+
+	for _, obj := range objects {
+		var b strings.Builder
+		fmt.Fprintf(&b, "export interface %s {\n", obj.Name)
+		for _, f := range obj.Fields {
+			key := f.Key
+			if !tsIdentifier.MatchString(key) {
+				key = strconv.Quote(key)
+			}
+			optional := ""
+			if f.Optional {
+				optional = "?"
+			}
+			fmt.Fprintf(&b, "  %s%s: %s;\n", key, optional, tsType(f.Type))
+		}
+		b.WriteString("}")
+		decls = append(decls, b.String())
+	}
+
+	return strings.Join(decls, "\n\n"), nil
+}
+
+func tsType(t *Type) string {
+	var s string
+	switch t.Kind {
+	case Bool:
+		s = "boolean"
+	case Int, Float:
+		s = "number"
+	case String, Time:
+		s = "string"
+	case Array:
+		elem := tsType(t.Elem)
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		s = elem + "[]"
+	case Object:
+		s = t.Name
+	default:
+		return "unknown"
+	}
+	if t.Nullable {
+		return s + " | null"
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+// Package xpath evaluates XPath 1.0 expressions against the DOM of an XML or
+// HTML document and converts the results into the objects the xml and html
+// codecs produce, so they can be queried further with jq.
+package xpath
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	htmlcodec "github.com/JFryy/qq/codec/html"
+	"github.com/JFryy/qq/codec/util"
+	xmlcodec "github.com/JFryy/qq/codec/xml"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// ParseNamespaces parses prefix=uri bindings as given to --ns.
+func ParseNamespaces(bindings []string) (map[string]string, error) {
+	namespaces := make(map[string]string, len(bindings))
+	for _, b := range bindings {
+		prefix, uri, ok := strings.Cut(b, "=")
+		if !ok || prefix == "" || uri == "" {
+			return nil, fmt.Errorf("invalid namespace binding %q, expected prefix=uri", b)
+		}
+		namespaces[prefix] = uri
+	}
+	return namespaces, nil
+}
+
+// Query evaluates expr against an XML (or, when isHTML is set, HTML) document.
+// Node-set results yield one value per node in document order; number, string
+// and boolean results yield a single value.
+func Query(input []byte, isHTML bool, expr string, namespaces map[string]string) ([]any, error) {
+	compiled, err := xpath.CompileWithNS(expr, namespaces)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing XPath expression: %v", err)
+	}
+
+	var nav xpath.NodeNavigator
+	if isHTML {
+		doc, err := htmlquery.Parse(bytes.NewReader(input))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing HTML: %v", err)
+		}
+		nav = htmlquery.CreateXPathNavigator(doc)
+	} else {
+		doc, err := xmlquery.Parse(bytes.NewReader(input))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing XML: %v", err)
+		}
+		nav = xmlquery.CreateXPathNavigator(doc)
+	}
+
+	switch result := compiled.Evaluate(nav).(type) {
+	case *xpath.NodeIterator:
+		var out []any
+		for result.MoveNext() {
+			v, err := convert(result.Current())
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	case float64:
+		if result == math.Trunc(result) && math.Abs(result) < 1<<53 {
+			return []any{int(result)}, nil
+		}
+		return []any{result}, nil
+	default:
+		return []any{result}, nil
+	}
+}
+
+var (
+	xmlCodec  = xmlcodec.Codec{}
+	htmlCodec = htmlcodec.Codec{}
+)
+
+// convert turns the node under the navigator into a value. Elements become
+// single-key objects named after the element; attributes and text become
+// scalars typed the same way the codecs type them.
+func convert(nav xpath.NodeNavigator) (any, error) {
+	switch nav.NodeType() {
+	case xpath.AttributeNode, xpath.TextNode:
+		return util.ParseValue(nav.Value()), nil
+	case xpath.CommentNode:
+		return map[string]any{"#comment": strings.TrimSpace(nav.Value())}, nil
+	}
+
+	switch n := nav.(type) {
+	case *xmlquery.NodeNavigator:
+		var v any
+		if err := xmlCodec.Unmarshal([]byte(n.Current().OutputXML(true)), &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case *htmlquery.NodeNavigator:
+		node := n.Current()
+		if nav.NodeType() == xpath.RootNode {
+			// the document itself: convert its <html> element
+			node = htmlquery.FindOne(node, "/html")
+			if node == nil {
+				return nil, nil
+			}
+		}
+		return map[string]any{node.Data: htmlCodec.NodeToMap(node)}, nil
+	}
+	return nav.Value(), nil
+}
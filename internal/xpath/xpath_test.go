@@ -0,0 +1,83 @@
+package xpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+const pom = `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0" xmlns:x="urn:extra">
+  <header>h</header>
+  <item>1</item>
+  <item>2</item>
+  <dependencies>
+    <dependency><artifactId>junit</artifactId><scope>test</scope></dependency>
+    <dependency id="slf"><artifactId>slf4j-api</artifactId></dependency>
+  </dependencies>
+  <item>3</item>
+  <x:note>extra</x:note>
+</project>`
+
+func TestQueryXML(t *testing.T) {
+	ns := map[string]string{"m": "http://maven.apache.org/POM/4.0.0", "e": "urn:extra"}
+	tests := []struct {
+		expr string
+		want []any
+	}{
+		{`//dependency[scope="test"]/artifactId`, []any{map[string]any{"artifactId": "junit"}}},
+		{`//m:dependency[not(m:scope)]/m:artifactId/text()`, []any{"slf4j-api"}},
+		{`//header/following-sibling::item[3]`, []any{map[string]any{"item": 3}}},
+		{`//item`, []any{map[string]any{"item": 1}, map[string]any{"item": 2}, map[string]any{"item": 3}}},
+		{`//dependency/@id`, []any{"slf"}},
+		{`count(//item)`, []any{3}},
+		{`string(//e:note)`, []any{"extra"}},
+		{`boolean(//missing)`, []any{false}},
+		{`//missing`, nil},
+	}
+	for _, tt := range tests {
+		got, err := Query([]byte(pom), false, tt.expr, ns)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s\n got %#v\nwant %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestQueryHTML(t *testing.T) {
+	page := `<html><body><ul><li class="a">x</li><li>y</li></ul></body></html>`
+	got, err := Query([]byte(page), true, `//li[@class="a"]`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{map[string]any{"li": map[string]any{"@class": "a", "#text": "x"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	got, err = Query([]byte(page), true, `//li[2]/text()`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []any{"y"}) {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := Query([]byte(pom), false, `//[`, nil); err == nil {
+		t.Error("expected error for invalid expression")
+	}
+	if _, err := Query([]byte("<a><b></a>"), false, `//a`, nil); err == nil {
+		t.Error("expected error for malformed XML")
+	}
+	if _, err := ParseNamespaces([]string{"m"}); err == nil {
+		t.Error("expected error for binding without uri")
+	}
+	ns, err := ParseNamespaces([]string{"m=urn:a=b"})
+	if err != nil || ns["m"] != "urn:a=b" {
+		t.Errorf("unexpected bindings %v, %v", ns, err)
+	}
+}